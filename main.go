@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"runtime"
 
+	"gopkg.mhn.org/tmpl.cgi/pkg/buildinfo"
 	"gopkg.mhn.org/tmpl.cgi/pkg/config"
 	"gopkg.mhn.org/tmpl.cgi/pkg/debug"
+	"gopkg.mhn.org/tmpl.cgi/pkg/fetch"
+	"gopkg.mhn.org/tmpl.cgi/pkg/har"
 
 	"gopkg.mhn.org/tmpl.cgi/pkg/server"
 )
@@ -29,28 +33,60 @@ func fatalErr(stage string, err error) {
 func main() {
 	// Parse command line flags
 	var validate = flag.Bool("validate", false, "Validate configuration and exit")
-	var configPath = flag.String("config", "", "Path to configuration file")
+	var configPath = flag.String("config", "", "Path to configuration file, - to read it from stdin, or an https:// URL of a signed config")
+	var harPath = flag.String("har", "", "With -validate, write a HAR file of the simulated requests")
+	var configFormat = flag.String("config-format", "", "Configuration file format: yaml, json or toml (default: from the file extension)")
+	var showVersion = flag.Bool("version", false, "Print version and build information and exit")
+	var mockData = flag.String("mock-data", os.Getenv(fetch.MockDataEnv), "Serve remote data, feeds and templates from the recorded responses in this directory instead of the network")
 	flag.Parse()
 
-	// Get config file path from flag, environment, or use default
-	if *configPath == "" {
-		*configPath = os.Getenv("TMPL_CGI_CONFIG")
-		if *configPath == "" {
-			*configPath = "config.yaml"
+	if *showVersion {
+		fmt.Printf("tmpl.cgi %s", buildinfo.Version())
+		if d := buildinfo.Date(); d != "" {
+			fmt.Printf(" (built %s)", d)
 		}
+		fmt.Printf(" %s\n", runtime.Version())
+		return
 	}
 
-	cfg, err := config.ParseConfigFile(*configPath)
+	fetch.SetMockDir(*mockData)
+
+	// Get config from the flag, the environment, or use the default file
+	var cfg *config.Config
+	var err error
+	if inline := os.Getenv(config.InlineConfigEnv); *configPath == "" && inline != "" {
+		cfg, err = config.ParseConfig([]byte(inline), *configFormat)
+	} else {
+		if *configPath == "" {
+			*configPath = os.Getenv("TMPL_CGI_CONFIG")
+			if *configPath == "" {
+				*configPath = "config.yaml"
+			}
+		}
+		cfg, err = config.ParseConfigFileAs(*configPath, *configFormat)
+	}
 	if err != nil {
 		fatalErr("Failed to parse configuration file: %v", err)
 	}
 
 	// If syntax check mode, run validation and exit
 	if *validate {
-		err = cfg.Validate()
+		var h *har.Log
+		if *harPath != "" {
+			h = har.New("tmpl.cgi", "validate")
+		}
+		err = cfg.ValidateWithHAR(h)
+		if h != nil {
+			if herr := h.WriteFile(*harPath); herr != nil {
+				log.Printf("Failed to write HAR file: %v", herr)
+			}
+		}
 		if err != nil {
 			fatalErr("Config validation failed: %v", err)
 		}
+		for _, w := range cfg.Warnings(cfg.Now()) {
+			log.Printf("Warning: %s", w)
+		}
 		log.Println("All templates are valid!")
 		return
 	}
@@ -0,0 +1,111 @@
+// Package action implements form actions: side effects a route performs when
+// it receives a POST, such as subscribing an address to a newsletter.
+package action
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// maxFormSize bounds the size of a submitted form
+const maxFormSize = 64 << 10
+
+// Action configures the form action of a route
+type Action struct {
+	Type string `yaml:"type"`
+
+	// Newsletter settings
+	Provider    string `yaml:"provider,omitempty"`
+	URL         string `yaml:"url,omitempty"`
+	APIKey      string `yaml:"api_key,omitempty"`
+	APIKeyEnv   string `yaml:"api_key_env,omitempty"`
+	List        string `yaml:"list,omitempty"`
+	DoubleOptIn bool   `yaml:"double_opt_in,omitempty"`
+
+	// Checkout settings
+	Prices      []string `yaml:"prices,omitempty"`
+	Mode        string   `yaml:"mode,omitempty"`
+	MaxQuantity int      `yaml:"max_quantity,omitempty"`
+	SuccessURL  string   `yaml:"success_url,omitempty"`
+	CancelURL   string   `yaml:"cancel_url,omitempty"`
+
+	SuccessTemplate string `yaml:"success_template,omitempty"`
+	ErrorTemplate   string `yaml:"error_template,omitempty"`
+}
+
+// Result is the outcome of an action, exposed to templates as .Action
+type Result struct {
+	OK       bool
+	Status   int
+	Error    string
+	Message  string
+	Redirect string
+	Form     map[string]string
+}
+
+// httpClient is used for all provider API calls
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// Validate checks the action configuration
+func (a *Action) Validate() error {
+	switch a.Type {
+	case "newsletter":
+		return a.validateNewsletter()
+	case "checkout":
+		return a.validateCheckout()
+	default:
+		return fmt.Errorf("unknown action type '%s'", a.Type)
+	}
+}
+
+// Run performs the action for a submitted form
+func (a *Action) Run(r *http.Request) *Result {
+	r.Body = http.MaxBytesReader(nil, r.Body, maxFormSize)
+	if err := r.ParseForm(); err != nil {
+		return failure(http.StatusBadRequest, "The form could not be read.", nil)
+	}
+	form := map[string]string{}
+	for k := range r.PostForm {
+		form[k] = r.PostForm.Get(k)
+	}
+
+	switch a.Type {
+	case "newsletter":
+		return a.runNewsletter(r, form)
+	case "checkout":
+		return a.runCheckout(r, form)
+	default:
+		return failure(http.StatusInternalServerError, fmt.Sprintf("unknown action type '%s'", a.Type), form)
+	}
+}
+
+// Template returns the template to render for the result, or "" to use the
+// route's own template
+func (a *Action) Template(res *Result) string {
+	if res.OK {
+		return a.SuccessTemplate
+	}
+	return a.ErrorTemplate
+}
+
+// apiKey returns the configured API key, reading it from the environment
+// when api_key_env is set
+func (a *Action) apiKey() string {
+	if a.APIKeyEnv != "" {
+		return os.Getenv(a.APIKeyEnv)
+	}
+	return a.APIKey
+}
+
+// failure builds an unsuccessful result
+func failure(status int, message string, form map[string]string) *Result {
+	return &Result{Status: status, Error: message, Form: form}
+}
+
+// basicAuth encodes HTTP basic authentication credentials
+func basicAuth(user, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+}
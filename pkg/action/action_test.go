@@ -0,0 +1,214 @@
+package action
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// postForm builds a form POST request
+func postForm(values url.Values) *http.Request {
+	req := httptest.NewRequest("POST", "/subscribe", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestNewsletter_Providers(t *testing.T) {
+	tests := []struct {
+		provider    string
+		doubleOptIn bool
+		wantPath    string
+		wantAuth    string
+		check       func(t *testing.T, body map[string]any)
+	}{
+		{
+			provider: "listmonk",
+			wantPath: "/api/subscribers",
+			wantAuth: "token api:secret",
+			check: func(t *testing.T, body map[string]any) {
+				if body["email"] != "reader@example.com" || body["preconfirm_subscriptions"] != true {
+					t.Errorf("listmonk body = %v", body)
+				}
+			},
+		},
+		{
+			provider:    "mailchimp",
+			doubleOptIn: true,
+			wantPath:    "/3.0/lists/7/members",
+			wantAuth:    "Basic " + basicAuth("tmpl.cgi", "api:secret"),
+			check: func(t *testing.T, body map[string]any) {
+				if body["email_address"] != "reader@example.com" || body["status"] != "pending" {
+					t.Errorf("mailchimp body = %v", body)
+				}
+			},
+		},
+		{
+			provider: "buttondown",
+			wantPath: "/v1/subscribers",
+			wantAuth: "Token api:secret",
+			check: func(t *testing.T, body map[string]any) {
+				if body["email_address"] != "reader@example.com" || body["type"] != "regular" {
+					t.Errorf("buttondown body = %v", body)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.wantPath {
+					t.Errorf("path = %s, want %s", r.URL.Path, tt.wantPath)
+				}
+				if r.Header.Get("Authorization") != tt.wantAuth {
+					t.Errorf("Authorization = %s, want %s", r.Header.Get("Authorization"), tt.wantAuth)
+				}
+				var body map[string]any
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				tt.check(t, body)
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer ts.Close()
+
+			a := &Action{Type: "newsletter", Provider: tt.provider, URL: ts.URL, APIKey: "api:secret", List: "7", DoubleOptIn: tt.doubleOptIn}
+			if err := a.Validate(); err != nil {
+				t.Fatalf("Validate() error: %v", err)
+			}
+			res := a.Run(postForm(url.Values{"email": {"reader@example.com"}}))
+			if !res.OK || res.Status != http.StatusOK {
+				t.Errorf("Run() = %+v, want success", res)
+			}
+			if tt.doubleOptIn && !strings.Contains(res.Message, "confirm") {
+				t.Errorf("Message = %q, want confirmation notice", res.Message)
+			}
+		})
+	}
+}
+
+func TestNewsletter_InvalidEmail(t *testing.T) {
+	a := &Action{Type: "newsletter", Provider: "buttondown", URL: "http://127.0.0.1:0", APIKey: "k"}
+	res := a.Run(postForm(url.Values{"email": {"not an email"}}))
+	if res.OK || res.Status != http.StatusBadRequest {
+		t.Errorf("Run() = %+v, want 400", res)
+	}
+	if res.Form["email"] != "not an email" {
+		t.Errorf("Form should echo submitted values, got %v", res.Form)
+	}
+}
+
+func TestNewsletter_ProviderFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "member exists", http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	a := &Action{Type: "newsletter", Provider: "buttondown", URL: ts.URL, APIKey: "k"}
+	res := a.Run(postForm(url.Values{"email": {"reader@example.com"}}))
+	if res.OK || res.Status != http.StatusBadGateway {
+		t.Errorf("Run() = %+v, want 502", res)
+	}
+}
+
+func TestSubscribeRequest_MailchimpKeyFromEnv(t *testing.T) {
+	t.Setenv("MAILCHIMP_KEY", "secretkeywithoutdatacenter")
+	a := Action{Type: "newsletter", Provider: "mailchimp", List: "1", APIKeyEnv: "MAILCHIMP_KEY"}
+	req, err := a.subscribeRequest("ann@example.com", "")
+	if err == nil {
+		t.Fatalf("subscribeRequest() = %s, want an error", req.URL)
+	}
+	if strings.Contains(err.Error(), "secretkey") {
+		t.Errorf("subscribeRequest() error = %v, must not contain the key", err)
+	}
+
+	t.Setenv("MAILCHIMP_KEY", "secretkey-us21")
+	if req, err = a.subscribeRequest("ann@example.com", ""); err != nil || req.URL.Host != "us21.api.mailchimp.com" {
+		t.Errorf("subscribeRequest() = %v, %v; want the us21 datacenter", req, err)
+	}
+}
+
+func TestAction_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		action Action
+	}{
+		{"Unknown type", Action{Type: "teleport"}},
+		{"Unknown provider", Action{Type: "newsletter", Provider: "aol", APIKey: "k"}},
+		{"Listmonk without list", Action{Type: "newsletter", Provider: "listmonk", URL: "http://x", APIKey: "k"}},
+		{"Mailchimp without datacenter", Action{Type: "newsletter", Provider: "mailchimp", List: "1", APIKey: "k"}},
+		{"Mailchimp with a bad datacenter", Action{Type: "newsletter", Provider: "mailchimp", List: "1", APIKey: "k-evil.example.com/"}},
+		{"Missing API key", Action{Type: "newsletter", Provider: "buttondown"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.action.Validate(); err == nil {
+				t.Error("Validate() expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestCheckout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/checkout/sessions" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer sk_test_123" {
+			t.Errorf("Authorization = %s", r.Header.Get("Authorization"))
+		}
+		_ = r.ParseForm()
+		if r.PostForm.Get("line_items[0][price]") != "price_B" || r.PostForm.Get("line_items[0][quantity]") != "2" {
+			t.Errorf("line items = %v", r.PostForm)
+		}
+		if r.PostForm.Get("success_url") != "http://shop.example.com/thanks?session_id={CHECKOUT_SESSION_ID}" {
+			t.Errorf("success_url = %s", r.PostForm.Get("success_url"))
+		}
+		if r.PostForm.Get("mode") != "payment" {
+			t.Errorf("mode = %s", r.PostForm.Get("mode"))
+		}
+		_, _ = w.Write([]byte(`{"id":"cs_test","url":"https://checkout.stripe.com/c/pay/cs_test"}`))
+	}))
+	defer ts.Close()
+
+	t.Setenv("TEST_STRIPE_KEY", "sk_test_123")
+	a := &Action{
+		Type: "checkout", URL: ts.URL, APIKeyEnv: "TEST_STRIPE_KEY",
+		Prices: []string{"price_A", "price_B"}, MaxQuantity: 3,
+		SuccessURL: "/thanks?session_id={CHECKOUT_SESSION_ID}", CancelURL: "https://shop.example.com/",
+	}
+	if err := a.Validate(); err != nil {
+		t.Fatalf("Validate() error: %v", err)
+	}
+
+	req := postForm(url.Values{"price": {"price_B"}, "quantity": {"2"}})
+	req.Host = "shop.example.com"
+	res := a.Run(req)
+	if !res.OK || res.Redirect != "https://checkout.stripe.com/c/pay/cs_test" {
+		t.Errorf("Run() = %+v, want redirect to checkout", res)
+	}
+}
+
+func TestCheckout_Rejected(t *testing.T) {
+	a := &Action{
+		Type: "checkout", URL: "http://127.0.0.1:0", APIKey: "sk",
+		Prices: []string{"price_A"}, SuccessURL: "/ok", CancelURL: "/cancel",
+	}
+	tests := []struct {
+		name string
+		form url.Values
+	}{
+		{"Unlisted price", url.Values{"price": {"price_FREE"}}},
+		{"Quantity too high", url.Values{"quantity": {"2"}}},
+		{"Quantity not a number", url.Values{"quantity": {"many"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := a.Run(postForm(tt.form))
+			if res.OK || res.Status != http.StatusBadRequest {
+				t.Errorf("Run() = %+v, want 400", res)
+			}
+		})
+	}
+}
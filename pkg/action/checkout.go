@@ -0,0 +1,110 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// stripeAPI is the Stripe API base URL
+const stripeAPI = "https://api.stripe.com"
+
+// validateCheckout checks the checkout settings
+func (a *Action) validateCheckout() error {
+	if a.APIKey == "" && a.APIKeyEnv == "" {
+		return fmt.Errorf("checkout requires api_key or api_key_env")
+	}
+	if len(a.Prices) == 0 {
+		return fmt.Errorf("checkout requires at least one price")
+	}
+	if a.SuccessURL == "" || a.CancelURL == "" {
+		return fmt.Errorf("checkout requires success_url and cancel_url")
+	}
+	switch a.Mode {
+	case "", "payment", "subscription":
+	default:
+		return fmt.Errorf("unknown checkout mode '%s'", a.Mode)
+	}
+	return nil
+}
+
+// runCheckout creates a Stripe Checkout session and redirects to it. Only
+// configured prices can be bought, so a tampered form cannot change what is
+// charged.
+func (a *Action) runCheckout(r *http.Request, form map[string]string) *Result {
+	price := form["price"]
+	if price == "" && len(a.Prices) == 1 {
+		price = a.Prices[0]
+	}
+	if !slices.Contains(a.Prices, price) {
+		return failure(http.StatusBadRequest, "The selected item is not available.", form)
+	}
+
+	quantity := 1
+	if q := form["quantity"]; q != "" {
+		n, err := strconv.Atoi(q)
+		maxQuantity := max(a.MaxQuantity, 1)
+		if err != nil || n < 1 || n > maxQuantity {
+			return failure(http.StatusBadRequest, fmt.Sprintf("Please choose a quantity between 1 and %d.", maxQuantity), form)
+		}
+		quantity = n
+	}
+
+	mode := a.Mode
+	if mode == "" {
+		mode = "payment"
+	}
+	params := url.Values{}
+	params.Set("mode", mode)
+	params.Set("line_items[0][price]", price)
+	params.Set("line_items[0][quantity]", strconv.Itoa(quantity))
+	params.Set("success_url", absoluteURL(r, a.SuccessURL))
+	params.Set("cancel_url", absoluteURL(r, a.CancelURL))
+
+	base := a.URL
+	if base == "" {
+		base = stripeAPI
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost,
+		strings.TrimRight(base, "/")+"/v1/checkout/sessions", strings.NewReader(params.Encode()))
+	if err != nil {
+		return failure(http.StatusInternalServerError, "The checkout could not be started.", form)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+a.apiKey())
+
+	body, err := doRequest(req)
+	var session struct {
+		URL string `json:"url"`
+	}
+	if err == nil {
+		err = json.Unmarshal(body, &session)
+	}
+	if err == nil && session.URL == "" {
+		err = fmt.Errorf("checkout session has no URL")
+	}
+	if err != nil {
+		log.Printf("creating checkout session: %v", err)
+		return failure(http.StatusBadGateway, "The checkout could not be started. Please try again later.", form)
+	}
+	return &Result{OK: true, Status: http.StatusSeeOther, Redirect: session.URL, Form: form}
+}
+
+// absoluteURL resolves a possibly relative URL against the request's origin
+func absoluteURL(r *http.Request, ref string) string {
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	base := &url.URL{Scheme: scheme, Host: r.Host, Path: "/"}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
@@ -0,0 +1,168 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// validateNewsletter checks the newsletter provider settings
+func (a *Action) validateNewsletter() error {
+	switch a.Provider {
+	case "listmonk":
+		if a.URL == "" || a.List == "" {
+			return fmt.Errorf("listmonk newsletter requires url and list")
+		}
+		if _, err := strconv.Atoi(a.List); err != nil {
+			return fmt.Errorf("listmonk list must be a numeric ID: %w", err)
+		}
+	case "mailchimp":
+		if a.List == "" {
+			return fmt.Errorf("mailchimp newsletter requires list")
+		}
+		if a.URL == "" && a.APIKeyEnv == "" {
+			if _, err := mailchimpDatacenter(a.APIKey); err != nil {
+				return fmt.Errorf("mailchimp newsletter requires url or a valid API key: %w", err)
+			}
+		}
+	case "buttondown":
+	default:
+		return fmt.Errorf("unknown newsletter provider '%s'", a.Provider)
+	}
+	if a.APIKey == "" && a.APIKeyEnv == "" {
+		return fmt.Errorf("%s newsletter requires api_key or api_key_env", a.Provider)
+	}
+	return nil
+}
+
+// runNewsletter subscribes the submitted email address
+func (a *Action) runNewsletter(r *http.Request, form map[string]string) *Result {
+	addr, err := mail.ParseAddress(strings.TrimSpace(form["email"]))
+	if err != nil || addr.Address != strings.TrimSpace(form["email"]) {
+		return failure(http.StatusBadRequest, "Please enter a valid email address.", form)
+	}
+
+	req, err := a.subscribeRequest(addr.Address, strings.TrimSpace(form["name"]))
+	if err == nil {
+		req = req.WithContext(r.Context())
+		_, err = doRequest(req)
+	}
+	if err != nil {
+		log.Printf("newsletter subscription via %s: %v", a.Provider, err)
+		return failure(http.StatusBadGateway, "The subscription could not be completed. Please try again later.", form)
+	}
+
+	message := "You are now subscribed."
+	if a.DoubleOptIn {
+		message = "Please check your inbox to confirm your subscription."
+	}
+	return &Result{OK: true, Status: http.StatusOK, Message: message, Form: form}
+}
+
+// subscribeRequest builds the provider API call that adds a subscriber
+func (a *Action) subscribeRequest(email, name string) (*http.Request, error) {
+	var endpoint string
+	var payload map[string]any
+	apiKey := a.apiKey()
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+
+	switch a.Provider {
+	case "listmonk":
+		list, _ := strconv.Atoi(a.List)
+		endpoint = strings.TrimRight(a.URL, "/") + "/api/subscribers"
+		payload = map[string]any{
+			"email":                    email,
+			"name":                     name,
+			"status":                   "enabled",
+			"lists":                    []int{list},
+			"preconfirm_subscriptions": !a.DoubleOptIn,
+		}
+		header.Set("Authorization", "token "+apiKey)
+	case "mailchimp":
+		base := a.URL
+		if base == "" {
+			dc, err := mailchimpDatacenter(apiKey)
+			if err != nil {
+				return nil, err
+			}
+			base = "https://" + dc + ".api.mailchimp.com"
+		}
+		endpoint = strings.TrimRight(base, "/") + "/3.0/lists/" + a.List + "/members"
+		status := "subscribed"
+		if a.DoubleOptIn {
+			status = "pending"
+		}
+		payload = map[string]any{"email_address": email, "status": status}
+		if name != "" {
+			payload["merge_fields"] = map[string]string{"FNAME": name}
+		}
+		header.Set("Authorization", "Basic "+basicAuth("tmpl.cgi", apiKey))
+	case "buttondown":
+		base := a.URL
+		if base == "" {
+			base = "https://api.buttondown.com"
+		}
+		endpoint = strings.TrimRight(base, "/") + "/v1/subscribers"
+		payload = map[string]any{"email_address": email}
+		if !a.DoubleOptIn {
+			payload["type"] = "regular"
+		}
+		header.Set("Authorization", "Token "+apiKey)
+	default:
+		return nil, fmt.Errorf("unknown newsletter provider '%s'", a.Provider)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	return req, nil
+}
+
+// mailchimpDatacenterPattern matches the datacenter of a Mailchimp API key,
+// such as us21
+var mailchimpDatacenterPattern = regexp.MustCompile(`^[a-z]{2,4}[0-9]{1,3}$`)
+
+// mailchimpDatacenter returns the datacenter suffix of a Mailchimp API key.
+// Errors do not quote the key, since it would otherwise end up in the logs.
+func mailchimpDatacenter(apiKey string) (string, error) {
+	i := strings.LastIndex(apiKey, "-")
+	if i < 0 || !mailchimpDatacenterPattern.MatchString(apiKey[i+1:]) {
+		return "", fmt.Errorf("API key has no datacenter suffix such as -us21")
+	}
+	return apiKey[i+1:], nil
+}
+
+// doRequest sends a provider API request, checks for a 2xx response and
+// returns the response body
+func doRequest(req *http.Request) ([]byte, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		if len(body) > 512 {
+			body = body[:512]
+		}
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return body, nil
+}
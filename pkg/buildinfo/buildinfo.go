@@ -0,0 +1,70 @@
+// Package buildinfo reports the version and build date of the running
+// binary, for display in templates and by the -version flag.
+package buildinfo
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// Set at link time, for example with
+// -ldflags "-X gopkg.mhn.org/tmpl.cgi/pkg/buildinfo.version=v1.2.3"
+var (
+	version string
+	date    string
+)
+
+// started is when the process started
+var started = time.Now()
+
+// Version returns the version set at link time, or else the module version
+// or VCS revision recorded by the Go toolchain, or "devel"
+func Version() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	if rev := setting(info, "vcs.revision"); rev != "" {
+		if len(rev) > 12 {
+			rev = rev[:12]
+		}
+		if setting(info, "vcs.modified") == "true" {
+			rev += "-dirty"
+		}
+		return rev
+	}
+	return "devel"
+}
+
+// Date returns the build date set at link time, or else the commit time
+// recorded by the Go toolchain, or ""
+func Date() string {
+	if date != "" {
+		return date
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return setting(info, "vcs.time")
+	}
+	return ""
+}
+
+// StartTime returns when the process started
+func StartTime() time.Time {
+	return started
+}
+
+// setting returns a build setting, or ""
+func setting(info *debug.BuildInfo, key string) string {
+	for _, s := range info.Settings {
+		if s.Key == key {
+			return s.Value
+		}
+	}
+	return ""
+}
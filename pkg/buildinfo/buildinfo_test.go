@@ -0,0 +1,27 @@
+package buildinfo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVersion(t *testing.T) {
+	if Version() == "" {
+		t.Error("Version() is empty")
+	}
+
+	defer func(v, d string) { version, date = v, d }(version, date)
+	version, date = "v1.2.3", "2025-01-02T03:04:05Z"
+	if got := Version(); got != "v1.2.3" {
+		t.Errorf("Version() = %q, want v1.2.3", got)
+	}
+	if got := Date(); got != "2025-01-02T03:04:05Z" {
+		t.Errorf("Date() = %q, want 2025-01-02T03:04:05Z", got)
+	}
+}
+
+func TestStartTime(t *testing.T) {
+	if s := StartTime(); s.IsZero() || s.After(time.Now()) {
+		t.Errorf("StartTime() = %v", s)
+	}
+}
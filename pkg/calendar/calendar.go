@@ -0,0 +1,257 @@
+// Package calendar reads iCalendar (ICS) feeds, such as a CalDAV calendar's
+// export URL, and lays their events out as day and week availability grids.
+package calendar
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/fetch"
+)
+
+// Defaults for calendar settings
+const (
+	DefaultCacheTTL = 15 * time.Minute
+	DefaultSlot     = 30 * time.Minute
+	DefaultHorizon  = 90 * 24 * time.Hour
+	DefaultDayStart = "09:00"
+	DefaultDayEnd   = "17:00"
+)
+
+// Source configures a calendar feed
+type Source struct {
+	Name     string        `yaml:"name"`
+	URL      string        `yaml:"url"`
+	Timezone string        `yaml:"timezone,omitempty"`
+	DayStart string        `yaml:"day_start,omitempty"`
+	DayEnd   string        `yaml:"day_end,omitempty"`
+	Slot     time.Duration `yaml:"slot,omitempty"`
+	Days     []string      `yaml:"days,omitempty"`
+	CacheTTL time.Duration `yaml:"cache_ttl,omitempty"`
+	CacheDir string        `yaml:"cache_dir,omitempty"`
+}
+
+// Event is a single (possibly expanded recurring) calendar event
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Free        bool
+}
+
+// Slot is a bookable time interval in a grid
+type Slot struct {
+	Start  time.Time
+	End    time.Time
+	Busy   bool
+	Events []Event
+}
+
+// Day is one row or column of a grid
+type Day struct {
+	Date  time.Time
+	Open  bool
+	Slots []Slot
+}
+
+// Calendar holds the events of a feed and the settings for laying them out
+type Calendar struct {
+	Name   string
+	Events []Event
+
+	loc      *time.Location
+	dayStart time.Duration
+	dayEnd   time.Duration
+	slot     time.Duration
+	days     map[time.Weekday]bool
+	now      time.Time
+}
+
+// Validate checks the source settings
+func (s *Source) Validate() error {
+	if s.Name == "" || s.URL == "" {
+		return fmt.Errorf("calendar requires name and url")
+	}
+	if _, err := s.location(); err != nil {
+		return err
+	}
+	if _, _, err := s.hours(); err != nil {
+		return err
+	}
+	for _, d := range s.Days {
+		if _, ok := weekdays[strings.ToUpper(d)]; !ok {
+			return fmt.Errorf("calendar %s: unknown day '%s'", s.Name, d)
+		}
+	}
+	return nil
+}
+
+// location returns the calendar's time zone
+func (s *Source) location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("calendar %s: %w", s.Name, err)
+	}
+	return loc, nil
+}
+
+// hours returns the opening hours as offsets from midnight
+func (s *Source) hours() (time.Duration, time.Duration, error) {
+	parse := func(v, def string) (time.Duration, error) {
+		if v == "" {
+			v = def
+		}
+		t, err := time.Parse("15:04", v)
+		if err != nil {
+			return 0, fmt.Errorf("calendar %s: invalid time '%s'", s.Name, v)
+		}
+		return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+	}
+	start, err := parse(s.DayStart, DefaultDayStart)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parse(s.DayEnd, DefaultDayEnd)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end <= start {
+		return 0, 0, fmt.Errorf("calendar %s: day_end must be after day_start", s.Name)
+	}
+	return start, end, nil
+}
+
+// Load fetches and parses the feed. Relative file paths are resolved by the
+// caller; http(s) URLs are fetched through the cache.
+func (s *Source) Load(now time.Time) (*Calendar, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	var data []byte
+	var err error
+	if strings.HasPrefix(s.URL, "http://") || strings.HasPrefix(s.URL, "https://") {
+		ttl := s.CacheTTL
+		if ttl == 0 {
+			ttl = DefaultCacheTTL
+		}
+		data, err = fetch.Cached(s.URL, fetch.Options{CacheDir: s.CacheDir, TTL: ttl, MaxSize: 8 << 20})
+	} else {
+		data, err = os.ReadFile(s.URL)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading calendar %s: %w", s.Name, err)
+	}
+
+	loc, _ := s.location()
+	events, err := Parse(data, loc, now.Add(DefaultHorizon))
+	if err != nil {
+		return nil, fmt.Errorf("parsing calendar %s: %w", s.Name, err)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+
+	dayStart, dayEnd, _ := s.hours()
+	slot := s.Slot
+	if slot <= 0 {
+		slot = DefaultSlot
+	}
+	days := map[time.Weekday]bool{}
+	for _, d := range s.Days {
+		days[weekdays[strings.ToUpper(d)]] = true
+	}
+	if len(days) == 0 {
+		for d := time.Monday; d <= time.Friday; d++ {
+			days[d] = true
+		}
+	}
+	return &Calendar{
+		Name:     s.Name,
+		Events:   events,
+		loc:      loc,
+		dayStart: dayStart,
+		dayEnd:   dayEnd,
+		slot:     slot,
+		days:     days,
+		now:      now.In(loc),
+	}, nil
+}
+
+// Between returns the busy events overlapping [start, end)
+func (c *Calendar) Between(start, end time.Time) []Event {
+	var out []Event
+	for _, e := range c.Events {
+		if !e.Free && e.Start.Before(end) && e.End.After(start) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Upcoming returns up to n events that have not yet ended
+func (c *Calendar) Upcoming(n int) []Event {
+	var out []Event
+	for _, e := range c.Events {
+		if len(out) >= n {
+			break
+		}
+		if e.End.After(c.now) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Day returns the slots of a single date (YYYY-MM-DD), or of today if the
+// date is empty
+func (c *Calendar) Day(date string) (Day, error) {
+	d := c.now
+	if date != "" {
+		var err error
+		if d, err = time.ParseInLocation("2006-01-02", date, c.loc); err != nil {
+			return Day{}, fmt.Errorf("invalid date '%s'", date)
+		}
+	}
+	return c.day(d), nil
+}
+
+// Week returns the seven days of the week offset weeks from the current one,
+// starting on Monday
+func (c *Calendar) Week(offset int) []Day {
+	today := time.Date(c.now.Year(), c.now.Month(), c.now.Day(), 0, 0, 0, 0, c.loc)
+	monday := today.AddDate(0, 0, -((int(today.Weekday())+6)%7)+7*offset)
+	week := make([]Day, 7)
+	for i := range week {
+		week[i] = c.day(monday.AddDate(0, 0, i))
+	}
+	return week
+}
+
+// day lays out the slots of the date containing t
+func (c *Calendar) day(t time.Time) Day {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, c.loc)
+	d := Day{Date: midnight, Open: c.days[midnight.Weekday()]}
+	if !d.Open {
+		return d
+	}
+	for off := c.dayStart; off+c.slot <= c.dayEnd; off += c.slot {
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, int(off/time.Minute), 0, 0, c.loc)
+		end := start.Add(c.slot)
+		events := c.Between(start, end)
+		d.Slots = append(d.Slots, Slot{
+			Start:  start,
+			End:    end,
+			Busy:   len(events) > 0 || !start.After(c.now),
+			Events: events,
+		})
+	}
+	return d
+}
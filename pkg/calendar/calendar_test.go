@@ -0,0 +1,141 @@
+package calendar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const sampleICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:1\r\n" +
+	"SUMMARY:Client meeting\\, room 2\r\n" +
+	"DTSTART;TZID=Europe/Berlin:20250602T100000\r\n" +
+	"DTEND;TZID=Europe/Berlin:20250602T110000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:2\r\n" +
+	"SUMMARY:Stand-up with a very long summary that the calendar server has fo\r\n" +
+	" lded onto two lines\r\n" +
+	"DTSTART:20250602T070000Z\r\n" +
+	"DTEND:20250602T073000Z\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=3\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:3\r\n" +
+	"SUMMARY:Holiday\r\n" +
+	"DTSTART;VALUE=DATE:20250606\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:4\r\n" +
+	"SUMMARY:Cancelled\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"DTSTART:20250603T090000Z\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParse(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	events, err := Parse([]byte(sampleICS), berlin, time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+
+	// 1 single + 3 recurrences + 1 all-day; the cancelled event is dropped
+	if len(events) != 5 {
+		t.Fatalf("Parse() returned %d events, want 5", len(events))
+	}
+	if events[0].Summary != "Client meeting, room 2" {
+		t.Errorf("Summary = %q", events[0].Summary)
+	}
+	if !events[0].Start.Equal(time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Start = %v, want 08:00 UTC", events[0].Start)
+	}
+	if events[1].Summary != "Stand-up with a very long summary that the calendar server has folded onto two lines" {
+		t.Errorf("folded Summary = %q", events[1].Summary)
+	}
+	wantStarts := []time.Time{
+		time.Date(2025, 6, 2, 7, 0, 0, 0, time.UTC),
+		time.Date(2025, 6, 4, 7, 0, 0, 0, time.UTC),
+		time.Date(2025, 6, 9, 7, 0, 0, 0, time.UTC),
+	}
+	for i, want := range wantStarts {
+		if !events[1+i].Start.Equal(want) {
+			t.Errorf("recurrence %d Start = %v, want %v", i, events[1+i].Start, want)
+		}
+	}
+	holiday := events[4]
+	if !holiday.AllDay || holiday.End.Sub(holiday.Start) != 24*time.Hour {
+		t.Errorf("all-day event = %+v", holiday)
+	}
+}
+
+func TestSource_LoadAndGrid(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "office.ics")
+	if err := os.WriteFile(filename, []byte(sampleICS), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := Source{Name: "office", URL: filename, Timezone: "Europe/Berlin", DayStart: "09:00", DayEnd: "12:00", Slot: time.Hour}
+
+	// Sunday before the sample week
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	cal, err := src.Load(now)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	week := cal.Week(1)
+	if len(week) != 7 || week[0].Date.Format("2006-01-02") != "2025-06-02" {
+		t.Fatalf("Week(1) starts %v", week[0].Date)
+	}
+	monday := week[0]
+	if !monday.Open || len(monday.Slots) != 3 {
+		t.Fatalf("Monday = %+v, want 3 open slots", monday)
+	}
+	// 09:00 stand-up (07:00Z), 10:00 meeting, 11:00 free
+	busy := []bool{true, true, false}
+	for i, want := range busy {
+		if monday.Slots[i].Busy != want {
+			t.Errorf("Monday slot %d Busy = %v, want %v", i, monday.Slots[i].Busy, want)
+		}
+	}
+	if week[5].Open || len(week[5].Slots) != 0 {
+		t.Errorf("Saturday should be closed, got %+v", week[5])
+	}
+	friday := week[4]
+	for _, s := range friday.Slots {
+		if !s.Busy {
+			t.Errorf("Friday slot %v should be busy (all-day holiday)", s.Start)
+		}
+	}
+
+	day, err := cal.Day("2025-06-03")
+	if err != nil {
+		t.Fatalf("Day() error: %v", err)
+	}
+	for _, s := range day.Slots {
+		if s.Busy {
+			t.Errorf("Tuesday slot %v should be free (event cancelled)", s.Start)
+		}
+	}
+
+	if up := cal.Upcoming(2); len(up) != 2 || up[0].UID != "2" {
+		t.Errorf("Upcoming(2) = %+v", up)
+	}
+}
+
+func TestSource_Validate(t *testing.T) {
+	tests := []Source{
+		{Name: "x"},
+		{Name: "x", URL: "a.ics", Timezone: "Mars/Olympus"},
+		{Name: "x", URL: "a.ics", DayStart: "17:00", DayEnd: "09:00"},
+		{Name: "x", URL: "a.ics", Days: []string{"XX"}},
+	}
+	for _, src := range tests {
+		if err := src.Validate(); err == nil {
+			t.Errorf("Validate(%+v) expected error", src)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package calendar
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// property is a parsed content line: NAME;PARAM=VALUE:value
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// unfold joins folded content lines (RFC 5545 section 3.1)
+func unfold(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseLine splits a content line into name, parameters and value
+func parseLine(line string) property {
+	p := property{params: map[string]string{}}
+	colon := -1
+	inQuote := false
+	for i, r := range line {
+		if r == '"' {
+			inQuote = !inQuote
+		} else if r == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		p.name = strings.ToUpper(line)
+		return p
+	}
+	p.value = line[colon+1:]
+	parts := strings.Split(line[:colon], ";")
+	p.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		if k, v, ok := strings.Cut(param, "="); ok {
+			p.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return p
+}
+
+// unescapeText reverses RFC 5545 TEXT escaping
+func unescapeText(s string) string {
+	r := strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return r.Replace(s)
+}
+
+// parseTime parses a DATE or DATE-TIME value
+func parseTime(p property, defaultLoc *time.Location) (time.Time, bool, error) {
+	loc := defaultLoc
+	if tzid := p.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	if p.params["VALUE"] == "DATE" || len(p.value) == 8 {
+		t, err := time.ParseInLocation("20060102", p.value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(p.value, "Z") {
+		t, err := time.Parse("20060102T150405Z", p.value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", p.value, loc)
+	return t, false, err
+}
+
+// rrule is the supported subset of a recurrence rule
+type rrule struct {
+	freq     string
+	interval int
+	count    int
+	until    time.Time
+	byDay    []time.Weekday
+}
+
+// weekdays maps RFC 5545 day codes to weekdays
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// parseRRule parses the DAILY and WEEKLY forms of RRULE
+func parseRRule(value string) (*rrule, error) {
+	r := &rrule{interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		k, v, _ := strings.Cut(part, "=")
+		switch strings.ToUpper(k) {
+		case "FREQ":
+			r.freq = strings.ToUpper(v)
+		case "INTERVAL":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL %q", v)
+			}
+			r.interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid COUNT %q", v)
+			}
+			r.count = n
+		case "UNTIL":
+			t, _, err := parseTime(property{value: v, params: map[string]string{}}, time.UTC)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UNTIL %q", v)
+			}
+			r.until = t
+		case "BYDAY":
+			for _, d := range strings.Split(v, ",") {
+				wd, ok := weekdays[strings.ToUpper(d)]
+				if !ok {
+					return nil, fmt.Errorf("unsupported BYDAY %q", d)
+				}
+				r.byDay = append(r.byDay, wd)
+			}
+		}
+	}
+	if r.freq != "DAILY" && r.freq != "WEEKLY" {
+		return nil, fmt.Errorf("unsupported FREQ %q", r.freq)
+	}
+	return r, nil
+}
+
+// expand returns the start times of the occurrences of an event beginning at
+// start that fall before limit
+func (r *rrule) expand(start, limit time.Time) []time.Time {
+	var starts []time.Time
+	emit := func(t time.Time) bool {
+		if t.Before(start) {
+			return true
+		}
+		if (!r.until.IsZero() && t.After(r.until)) || !t.Before(limit) {
+			return false
+		}
+		if r.count > 0 && len(starts) >= r.count {
+			return false
+		}
+		starts = append(starts, t)
+		return true
+	}
+
+	if r.freq == "DAILY" {
+		for t := start; emit(t); t = t.AddDate(0, 0, r.interval) {
+		}
+		return starts
+	}
+
+	byDay := r.byDay
+	if len(byDay) == 0 {
+		byDay = []time.Weekday{start.Weekday()}
+	}
+	// Weeks start on Monday (RFC 5545 default WKST)
+	weekStart := start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+	for week := weekStart; ; week = week.AddDate(0, 0, 7*r.interval) {
+		for offset := 0; offset < 7; offset++ {
+			t := week.AddDate(0, 0, offset)
+			for _, wd := range byDay {
+				if t.Weekday() == wd && !emit(t) {
+					return starts
+				}
+			}
+		}
+	}
+}
+
+// Parse reads the VEVENTs of an iCalendar document, expanding supported
+// recurrence rules up to limit. Times without a zone use loc.
+func Parse(data []byte, loc *time.Location, limit time.Time) ([]Event, error) {
+	var events []Event
+	var cur map[string]property
+	for _, line := range unfold(data) {
+		if line == "" {
+			continue
+		}
+		p := parseLine(line)
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT"):
+			cur = map[string]property{}
+		case p.name == "END" && strings.EqualFold(p.value, "VEVENT"):
+			evs, err := buildEvents(cur, loc, limit)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, evs...)
+			cur = nil
+		case cur != nil:
+			if _, seen := cur[p.name]; !seen {
+				cur[p.name] = p
+			}
+		}
+	}
+	return events, nil
+}
+
+// buildEvents converts the properties of one VEVENT into events
+func buildEvents(props map[string]property, loc *time.Location, limit time.Time) ([]Event, error) {
+	if strings.EqualFold(props["STATUS"].value, "CANCELLED") {
+		return nil, nil
+	}
+	dtstart, ok := props["DTSTART"]
+	if !ok {
+		return nil, fmt.Errorf("VEVENT without DTSTART")
+	}
+	start, allDay, err := parseTime(dtstart, loc)
+	if err != nil {
+		return nil, fmt.Errorf("parsing DTSTART: %w", err)
+	}
+	end := start
+	if dtend, ok := props["DTEND"]; ok {
+		if end, _, err = parseTime(dtend, loc); err != nil {
+			return nil, fmt.Errorf("parsing DTEND: %w", err)
+		}
+	} else if allDay {
+		end = start.AddDate(0, 0, 1)
+	}
+
+	base := Event{
+		UID:         props["UID"].value,
+		Summary:     unescapeText(props["SUMMARY"].value),
+		Description: unescapeText(props["DESCRIPTION"].value),
+		Location:    unescapeText(props["LOCATION"].value),
+		AllDay:      allDay,
+		Free:        strings.EqualFold(props["TRANSP"].value, "TRANSPARENT"),
+	}
+	duration := end.Sub(start)
+
+	rr, ok := props["RRULE"]
+	if !ok {
+		base.Start, base.End = start, end
+		return []Event{base}, nil
+	}
+	rule, err := parseRRule(rr.value)
+	if err != nil {
+		return nil, fmt.Errorf("event %q: %w", base.Summary, err)
+	}
+	var events []Event
+	for _, s := range rule.expand(start, limit) {
+		e := base
+		e.Start, e.End = s, s.Add(duration)
+		events = append(events, e)
+	}
+	return events, nil
+}
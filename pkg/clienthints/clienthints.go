@@ -0,0 +1,145 @@
+// Package clienthints reads the Save-Data header and the device, network
+// and user agent client hints browsers send, so templates can serve lighter
+// pages to constrained clients.
+package clienthints
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Hints are the client hints of a request. Hints the browser did not send
+// are zero.
+type Hints struct {
+	// SaveData is set when the user asked for reduced data usage
+	SaveData bool
+	// DPR is the device pixel ratio
+	DPR float64
+	// Width is the intended display width of an image, in physical pixels
+	Width int
+	// ViewportWidth is the layout viewport width, in CSS pixels
+	ViewportWidth int
+	// DeviceMemory is the approximate device memory, in GiB
+	DeviceMemory float64
+	// ECT is the effective connection type: slow-2g, 2g, 3g or 4g
+	ECT string
+	// Downlink is the approximate bandwidth, in Mbit/s
+	Downlink float64
+	// RTT is the approximate round trip time, in milliseconds
+	RTT int
+	// Mobile is set when the browser reports a mobile device
+	Mobile bool
+	// Platform is the operating system, such as "Android" or "Windows"
+	Platform string
+	// ReducedMotion is "reduce" when the user prefers reduced motion
+	ReducedMotion string
+}
+
+// Lite reports whether the client asked for reduced data usage or is on a
+// slow (2G-like) connection
+func (h Hints) Lite() bool {
+	return h.SaveData || h.ECT == "slow-2g" || h.ECT == "2g"
+}
+
+// Known maps the hints that can be requested with Accept-CH to the legacy
+// header that older browsers send instead, if any
+var Known = map[string]string{
+	"Sec-CH-DPR":                    "DPR",
+	"Sec-CH-Width":                  "Width",
+	"Sec-CH-Viewport-Width":         "Viewport-Width",
+	"Sec-CH-Device-Memory":          "Device-Memory",
+	"ECT":                           "",
+	"Downlink":                      "",
+	"RTT":                           "",
+	"Sec-CH-UA-Mobile":              "",
+	"Sec-CH-UA-Platform":            "",
+	"Sec-CH-Prefers-Reduced-Motion": "",
+}
+
+// Settings configures which hints are requested from browsers
+type Settings struct {
+	Enabled bool     `yaml:"enabled"`
+	Request []string `yaml:"request,omitempty"`
+}
+
+// Validate checks the client hint settings
+func (s *Settings) Validate() error {
+	for _, name := range s.Request {
+		if _, ok := legacyHeader(name); !ok {
+			return fmt.Errorf("client_hints: unknown hint '%s'", name)
+		}
+	}
+	return nil
+}
+
+// legacyHeader returns the legacy header of a known hint, ignoring case
+func legacyHeader(name string) (string, bool) {
+	for known, legacy := range Known {
+		if strings.EqualFold(known, name) {
+			return legacy, true
+		}
+	}
+	return "", false
+}
+
+// SetHeaders asks the browser for the configured hints and marks the
+// response as varying with them and with Save-Data
+func (s *Settings) SetHeaders(h http.Header) {
+	if !s.Enabled {
+		return
+	}
+	if len(s.Request) > 0 {
+		h.Set("Accept-CH", strings.Join(append(h.Values("Accept-CH"), s.Request...), ", "))
+	}
+	h.Add("Vary", strings.Join(append([]string{"Save-Data"}, s.Request...), ", "))
+}
+
+// Key identifies the hint values a page may vary with, for caching
+func (s *Settings) Key(r *http.Request) string {
+	if !s.Enabled {
+		return ""
+	}
+	values := []string{r.Header.Get("Save-Data")}
+	for _, name := range s.Request {
+		values = append(values, header(r.Header, name))
+	}
+	return strings.Join(values, "|")
+}
+
+// header returns a hint, falling back to its legacy header
+func header(h http.Header, name string) string {
+	if v := h.Get(name); v != "" {
+		return v
+	}
+	if legacy, _ := legacyHeader(name); legacy != "" {
+		return h.Get(legacy)
+	}
+	return ""
+}
+
+// Parse reads the client hints of a request
+func Parse(h http.Header) Hints {
+	atof := func(name string) float64 {
+		f, _ := strconv.ParseFloat(strings.TrimSpace(header(h, name)), 64)
+		return f
+	}
+	atoi := func(name string) int {
+		i, _ := strconv.Atoi(strings.TrimSpace(header(h, name)))
+		return i
+	}
+	return Hints{
+		SaveData:      strings.EqualFold(strings.TrimSpace(h.Get("Save-Data")), "on"),
+		DPR:           atof("Sec-CH-DPR"),
+		Width:         atoi("Sec-CH-Width"),
+		ViewportWidth: atoi("Sec-CH-Viewport-Width"),
+		DeviceMemory:  atof("Sec-CH-Device-Memory"),
+		ECT:           strings.TrimSpace(h.Get("ECT")),
+		Downlink:      atof("Downlink"),
+		RTT:           atoi("RTT"),
+		Mobile:        strings.TrimSpace(h.Get("Sec-CH-UA-Mobile")) == "?1",
+		Platform:      strings.Trim(h.Get("Sec-CH-UA-Platform"), `" `),
+		ReducedMotion: strings.Trim(h.Get("Sec-CH-Prefers-Reduced-Motion"), `" `),
+	}
+}
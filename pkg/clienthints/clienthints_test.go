@@ -0,0 +1,87 @@
+package clienthints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	h := http.Header{}
+	h.Set("Save-Data", "on")
+	h.Set("Sec-CH-DPR", "2.5")
+	h.Set("Viewport-Width", "412")
+	h.Set("Sec-CH-Device-Memory", "4")
+	h.Set("ECT", "3g")
+	h.Set("Downlink", "1.7")
+	h.Set("RTT", "250")
+	h.Set("Sec-CH-UA-Mobile", "?1")
+	h.Set("Sec-CH-UA-Platform", `"Android"`)
+
+	want := Hints{
+		SaveData:      true,
+		DPR:           2.5,
+		ViewportWidth: 412,
+		DeviceMemory:  4,
+		ECT:           "3g",
+		Downlink:      1.7,
+		RTT:           250,
+		Mobile:        true,
+		Platform:      "Android",
+	}
+	if got := Parse(h); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %+v, want %+v", got, want)
+	}
+	if got := Parse(http.Header{}); !reflect.DeepEqual(got, Hints{}) {
+		t.Errorf("Parse() without hints = %+v", got)
+	}
+}
+
+func TestHints_Lite(t *testing.T) {
+	tests := []struct {
+		hints    Hints
+		expected bool
+	}{
+		{Hints{}, false},
+		{Hints{SaveData: true}, true},
+		{Hints{ECT: "2g"}, true},
+		{Hints{ECT: "slow-2g"}, true},
+		{Hints{ECT: "4g"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.hints.Lite(); got != tt.expected {
+			t.Errorf("%+v.Lite() = %v, want %v", tt.hints, got, tt.expected)
+		}
+	}
+}
+
+func TestSettings(t *testing.T) {
+	s := &Settings{Enabled: true, Request: []string{"Sec-CH-DPR", "ect"}}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Validate() error: %v", err)
+	}
+	if err := (&Settings{Request: []string{"Sec-CH-Bogus"}}).Validate(); err == nil {
+		t.Error("expected error for an unknown hint")
+	}
+
+	h := http.Header{}
+	h.Set("Accept-CH", "Sec-CH-Prefers-Color-Scheme")
+	s.SetHeaders(h)
+	if got := h.Get("Accept-CH"); got != "Sec-CH-Prefers-Color-Scheme, Sec-CH-DPR, ect" {
+		t.Errorf("Accept-CH = %q", got)
+	}
+	if got := h.Get("Vary"); got != "Save-Data, Sec-CH-DPR, ect" {
+		t.Errorf("Vary = %q", got)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Save-Data", "on")
+	req.Header.Set("DPR", "2")
+	if got := s.Key(req); got != "on|2|" {
+		t.Errorf("Key() = %q", got)
+	}
+	if got := (&Settings{}).Key(req); got != "" {
+		t.Errorf("Key() with hints disabled = %q", got)
+	}
+}
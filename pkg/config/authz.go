@@ -0,0 +1,139 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/ldapgroup"
+)
+
+// AnyUser in require_user admits every authenticated user
+const AnyUser = "*"
+
+// Authz configures authorization of routes against the user authenticated
+// by the web server
+type Authz struct {
+	GroupFile         string `yaml:"group_file,omitempty"`
+	ForbiddenTemplate string `yaml:"forbidden_template,omitempty"`
+
+	LDAP *ldapgroup.Directory `yaml:"ldap,omitempty"`
+}
+
+// RemoteUser returns the user the web server authenticated, as passed to
+// CGI scripts in REMOTE_USER
+func RemoteUser() string {
+	return os.Getenv("REMOTE_USER")
+}
+
+// RequiresAuth reports whether the route is restricted to some users
+func (t *Template) RequiresAuth() bool {
+	return len(t.RequireUser) > 0 || len(t.RequireGroup) > 0
+}
+
+// Authorize reports whether user may request the route. A restricted route
+// admits a user named in require_user or belonging to a group named in
+// require_group, according to the group file or LDAP directory; an empty
+// user is never admitted.
+func (c *Config) Authorize(t *Template, user string) (bool, error) {
+	if !t.RequiresAuth() {
+		return true, nil
+	}
+	if user == "" {
+		return false, nil
+	}
+	if slices.Contains(t.RequireUser, user) || slices.Contains(t.RequireUser, AnyUser) {
+		return true, nil
+	}
+	if len(t.RequireGroup) == 0 {
+		return false, nil
+	}
+	groups, err := c.UserGroups(user)
+	if err != nil {
+		return false, err
+	}
+	for _, g := range t.RequireGroup {
+		if slices.Contains(groups, g) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// UserGroups returns the groups user belongs to according to the group file
+// and LDAP directory
+func (c *Config) UserGroups(user string) ([]string, error) {
+	var groups []string
+	if user == "" {
+		return groups, nil
+	}
+	if c.Authz.GroupFile != "" {
+		members, err := readGroupFile(c.resolvePath(c.Authz.GroupFile))
+		if err != nil {
+			return nil, err
+		}
+		for g, users := range members {
+			if slices.Contains(users, user) {
+				groups = append(groups, g)
+			}
+		}
+	}
+	if c.Authz.LDAP != nil {
+		ldapGroups, err := c.Authz.LDAP.Groups(user)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, ldapGroups...)
+	}
+	return groups, nil
+}
+
+// readGroupFile reads an Apache AuthGroupFile: lines of a group name, a
+// colon and the members separated by spaces. A group may span several lines.
+func readGroupFile(path string) (map[string][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading group file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	groups := map[string][]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, members, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("group file %s line %d: expected group: members", path, n)
+		}
+		groups[name] = append(groups[name], strings.Fields(members)...)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading group file: %w", err)
+	}
+	return groups, nil
+}
+
+// validateAuthz checks the authorization settings of the config and routes
+func (c *Config) validateAuthz() error {
+	for _, t := range c.Templates {
+		if len(t.RequireGroup) > 0 && c.Authz.GroupFile == "" && c.Authz.LDAP == nil {
+			return fmt.Errorf("pattern '%s': require_group needs authz.group_file or authz.ldap", t.Pattern)
+		}
+	}
+	if c.Authz.GroupFile != "" {
+		if _, err := readGroupFile(c.resolvePath(c.Authz.GroupFile)); err != nil {
+			return fmt.Errorf("authz: %w", err)
+		}
+	}
+	if c.Authz.LDAP != nil {
+		if err := c.Authz.LDAP.Validate(); err != nil {
+			return fmt.Errorf("authz: %w", err)
+		}
+	}
+	return nil
+}
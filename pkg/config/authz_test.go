@@ -0,0 +1,62 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/ldapgroup"
+)
+
+func TestReadGroupFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "groups")
+	err := os.WriteFile(path, []byte("# comment\nstaff: ann bob\n\nadmins:ann\nstaff: carol\nempty:\n"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write group file: %v", err)
+	}
+
+	groups, err := readGroupFile(path)
+	if err != nil {
+		t.Fatalf("readGroupFile() error: %v", err)
+	}
+	want := map[string][]string{
+		"staff":  {"ann", "bob", "carol"},
+		"admins": {"ann"},
+		"empty":  nil,
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("readGroupFile() = %v, want %v", groups, want)
+	}
+
+	if err = os.WriteFile(path, []byte("staff ann bob\n"), 0644); err != nil {
+		t.Fatalf("Failed to write group file: %v", err)
+	}
+	if _, err = readGroupFile(path); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("readGroupFile() error = %v, want line 1 error", err)
+	}
+}
+
+func TestValidate_Authz(t *testing.T) {
+	config := &Config{Templates: []Template{{Pattern: "^/", Template: "page.html", RequireGroup: []string{"staff"}}}}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "authz.group_file") {
+		t.Errorf("Validate() error = %v, want missing group_file", err)
+	}
+
+	config.ConfigFilePath = filepath.Join(t.TempDir(), "config.yaml")
+	config.Authz.GroupFile = "missing"
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "reading group file") {
+		t.Errorf("Validate() error = %v, want unreadable group file", err)
+	}
+}
+
+func TestValidate_AuthzLDAP(t *testing.T) {
+	config := &Config{
+		Templates: []Template{{Pattern: "^/", Template: "page.html", RequireGroup: []string{"staff"}}},
+		Authz:     Authz{LDAP: &ldapgroup.Directory{URL: "ldap.example.com", BaseDN: "dc=example,dc=com"}},
+	}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "authz: ldap: url") {
+		t.Errorf("Validate() error = %v, want ldap url error", err)
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NormalizedBasePath returns the base path without a trailing slash, or ""
+// if the site is served from the root
+func (c *Config) NormalizedBasePath() string {
+	return strings.TrimRight(c.BasePath, "/")
+}
+
+// StripBasePath returns the request URI relative to the base path, which is
+// what route patterns are matched against. URIs outside the base path are
+// returned unchanged.
+func (c *Config) StripBasePath(uri string) string {
+	base := c.NormalizedBasePath()
+	if base == "" {
+		return uri
+	}
+	rest, ok := strings.CutPrefix(uri, base)
+	if !ok {
+		return uri
+	}
+	switch {
+	case rest == "":
+		return "/"
+	case rest[0] == '?':
+		return "/" + rest
+	case rest[0] == '/':
+		return rest
+	}
+	// A longer path segment, such as /appx for a base path of /app
+	return uri
+}
+
+// validateBasePath checks that the base path is an absolute URL path
+func (c *Config) validateBasePath() error {
+	if c.BasePath == "" {
+		return nil
+	}
+	if !strings.HasPrefix(c.BasePath, "/") || strings.ContainsAny(c.BasePath, "?#") {
+		return fmt.Errorf("base_path must be a URL path starting with /")
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import "testing"
+
+func TestStripBasePath(t *testing.T) {
+	tests := []struct {
+		basePath string
+		uri      string
+		want     string
+	}{
+		{"", "/blog/post", "/blog/post"},
+		{"/app", "/app/blog/post", "/blog/post"},
+		{"/app/", "/app/blog/post?page=2", "/blog/post?page=2"},
+		{"/app", "/app", "/"},
+		{"/app", "/app/", "/"},
+		{"/app", "/app?page=2", "/?page=2"},
+		{"/app", "/apple/pie", "/apple/pie"},
+		{"/app", "/other/page", "/other/page"},
+		{"/cgi-bin/tmpl.cgi", "/cgi-bin/tmpl.cgi/blog/", "/blog/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.basePath+" "+tt.uri, func(t *testing.T) {
+			c := &Config{BasePath: tt.basePath}
+			if got := c.StripBasePath(tt.uri); got != tt.want {
+				t.Errorf("StripBasePath(%q) = %q, want %q", tt.uri, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateBasePath(t *testing.T) {
+	tests := []struct {
+		basePath string
+		wantErr  bool
+	}{
+		{"", false},
+		{"/app", false},
+		{"/cgi-bin/tmpl.cgi/", false},
+		{"app", true},
+		{"/app?x=1", true},
+		{"/app#top", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.basePath, func(t *testing.T) {
+			c := &Config{BasePath: tt.basePath}
+			if err := c.validateBasePath(); (err != nil) != tt.wantErr {
+				t.Errorf("validateBasePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
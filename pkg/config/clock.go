@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// NowEnv names the environment variable that fixes the current time seen by
+// date-sensitive features, as an RFC 3339 timestamp or a date
+const NowEnv = "TMPL_CGI_NOW"
+
+// Now returns the current time for scheduled publishing, calendars, cache
+// expiry and the now template function: the time fixed by TMPL_CGI_NOW or
+// the config's now, or else the clock
+func (c *Config) Now() time.Time {
+	if t, err := parseNow(os.Getenv(NowEnv)); err == nil && !t.IsZero() {
+		return t
+	}
+	if !c.NowOverride.IsZero() {
+		return c.NowOverride
+	}
+	return time.Now()
+}
+
+// parseNow parses a fixed time, returning the zero time if s is empty
+func parseNow(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp or a date: %w", NowEnv, err)
+	}
+	return t, nil
+}
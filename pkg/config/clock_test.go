@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig_Now(t *testing.T) {
+	fixed := time.Date(2030, 5, 6, 7, 8, 9, 0, time.UTC)
+	tests := []struct {
+		name     string
+		env      string
+		override time.Time
+		want     time.Time
+	}{
+		{name: "timestamp from env", env: "2030-05-06T07:08:09Z", want: fixed},
+		{name: "date from env", env: "2030-05-06", want: time.Date(2030, 5, 6, 0, 0, 0, 0, time.UTC)},
+		{name: "env wins over config", env: "2030-05-06T07:08:09Z", override: fixed.AddDate(1, 0, 0), want: fixed},
+		{name: "config", override: fixed, want: fixed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(NowEnv, tt.env)
+			c := &Config{NowOverride: tt.override}
+			if got := c.Now(); !got.Equal(tt.want) {
+				t.Errorf("Now() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Setenv(NowEnv, "")
+	if got := (&Config{}).Now(); time.Since(got) > time.Minute {
+		t.Errorf("Now() = %v, want the current time", got)
+	}
+}
+
+func TestConfig_NowInTemplates(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "page.html"), []byte(`{{now.Year}}`), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	c := &Config{
+		ConfigFilePath: filepath.Join(tempDir, "config.yaml"),
+		NowOverride:    time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	tmpl, err := c.LoadTemplate("page.html")
+	if err != nil {
+		t.Fatalf("LoadTemplate() error: %v", err)
+	}
+	var buf strings.Builder
+	if err = tmpl.Execute(&buf, TemplateData{}); err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+	if buf.String() != "2031" {
+		t.Errorf("output = %q, want 2031", buf.String())
+	}
+
+	t.Setenv(NowEnv, "next tuesday")
+	c = &Config{ConfigFilePath: filepath.Join(tempDir, "config.yaml"), DefaultTemplate: "page.html"}
+	if err = c.Validate(); err == nil || !strings.Contains(err.Error(), NowEnv) {
+		t.Errorf("Validate() error = %v, want %s error", err, NowEnv)
+	}
+}
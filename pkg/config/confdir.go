@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// readConfigDir reads every config file (*.yaml, *.yml, *.json, *.toml) in
+// dir in lexical order and merges them into one document. It also returns the concatenated file
+// contents, which identify the merged config's version.
+func readConfigDir(dir string) (map[string]any, []byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading config directory: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		if _, ok := configExtensions[strings.ToLower(filepath.Ext(e.Name()))]; ok && !e.IsDir() {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no config files in config directory %s", dir)
+	}
+
+	doc := map[string]any{}
+	var raw []byte
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading config file: %w", err)
+		}
+		part, err := decodeDoc(data, detectFormat(file))
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing config file %s: %w", filepath.Base(file), err)
+		}
+		mergeDocs(doc, part)
+		raw = append(raw, filepath.Base(file)...)
+		raw = append(raw, 0)
+		raw = append(raw, data...)
+	}
+	return doc, raw, nil
+}
+
+// mergeDocs merges src into dst: maps are merged recursively, lists (such
+// as templates) are appended, and other values in src replace those in dst
+func mergeDocs(dst, src map[string]any) {
+	for k, v := range src {
+		switch sv := v.(type) {
+		case map[string]any:
+			if dv, ok := dst[k].(map[string]any); ok {
+				mergeDocs(dv, sv)
+				continue
+			}
+		case []any:
+			if dv, ok := dst[k].([]any); ok {
+				dst[k] = append(dv, sv...)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
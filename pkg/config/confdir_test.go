@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseConfigFile_Directory(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"00-base.yaml": `default_template: "default.html"
+templates:
+  - pattern: "^/blog/"
+    template: "blog.html"
+data:
+  site: {name: "Example", lang: "en"}
+`,
+		"10-shop.yaml": `templates:
+  - pattern: "^/shop/"
+    template: "shop.html"
+data:
+  site: {name: "Example Shop"}
+  currency: "EUR"
+`,
+		"20-empty.yml": ``,
+		"README.txt":   `not a config file`,
+		"page.html":    `ok`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	config, err := ParseConfigFile(dir)
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	if config.DefaultTemplate != "default.html" {
+		t.Errorf("DefaultTemplate = %s, want default.html", config.DefaultTemplate)
+	}
+	if len(config.Templates) != 2 || config.Templates[0].Template != "blog.html" || config.Templates[1].Template != "shop.html" {
+		t.Errorf("Templates = %+v, want blog.html then shop.html", config.Templates)
+	}
+	wantData := map[string]any{
+		"site":     map[string]any{"name": "Example Shop", "lang": "en"},
+		"currency": "EUR",
+	}
+	if !reflect.DeepEqual(config.Data, wantData) {
+		t.Errorf("Data = %v, want %v", config.Data, wantData)
+	}
+
+	// Relative paths resolve against the directory itself
+	if got := config.resolvePath("page.html"); got != filepath.Join(dir, "page.html") {
+		t.Errorf("resolvePath() = %s", got)
+	}
+
+	again, _ := ParseConfigFile(dir)
+	if again.Version != config.Version {
+		t.Errorf("Version is not deterministic: %s != %s", again.Version, config.Version)
+	}
+}
+
+func TestParseConfigFile_EmptyDirectory(t *testing.T) {
+	if _, err := ParseConfigFile(t.TempDir()); err == nil {
+		t.Error("ParseConfigFile() of a directory without config files should fail")
+	}
+}
@@ -2,132 +2,1088 @@ package config
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
 	"html/template"
+	"io"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/Masterminds/sprig/v3"
 	"gopkg.in/yaml.v3"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/action"
+	"gopkg.mhn.org/tmpl.cgi/pkg/buildinfo"
+	"gopkg.mhn.org/tmpl.cgi/pkg/calendar"
+	"gopkg.mhn.org/tmpl.cgi/pkg/clienthints"
+	"gopkg.mhn.org/tmpl.cgi/pkg/consent"
+	"gopkg.mhn.org/tmpl.cgi/pkg/feed"
+	"gopkg.mhn.org/tmpl.cgi/pkg/fetch"
+	"gopkg.mhn.org/tmpl.cgi/pkg/gallery"
+	"gopkg.mhn.org/tmpl.cgi/pkg/har"
+	"gopkg.mhn.org/tmpl.cgi/pkg/oembed"
+	"gopkg.mhn.org/tmpl.cgi/pkg/pdf"
+	"gopkg.mhn.org/tmpl.cgi/pkg/share"
+	"gopkg.mhn.org/tmpl.cgi/pkg/slug"
+	"gopkg.mhn.org/tmpl.cgi/pkg/theme"
+	"gopkg.mhn.org/tmpl.cgi/pkg/vcard"
+	"gopkg.mhn.org/tmpl.cgi/pkg/wellknown"
+	"gopkg.mhn.org/tmpl.cgi/pkg/widget"
 )
 
 type Template struct {
 	Pattern  string `yaml:"pattern"`
+	Exclude  string `yaml:"exclude,omitempty"`
 	Template string `yaml:"template"`
+	Content  string `yaml:"content,omitempty"`
 	TestURI  string `yaml:"test_uri,omitempty"`
+	Draft    bool   `yaml:"draft,omitempty"`
+	NoCache  bool   `yaml:"no_cache,omitempty"`
+
+	PatternType string            `yaml:"pattern_type,omitempty"`
+	Priority    int               `yaml:"priority,omitempty"`
+	Host        string            `yaml:"host,omitempty"`
+	HostPattern string            `yaml:"host_pattern,omitempty"`
+	Methods     []string          `yaml:"methods,omitempty"`
+	Query       map[string]string `yaml:"query,omitempty"`
+
+	Patterns []string `yaml:"patterns,omitempty"`
+
+	Environments []string `yaml:"environments,omitempty"`
+
+	Data      any        `yaml:"data,omitempty"`
+	DataMerge *DataMerge `yaml:"data_merge,omitempty"`
+
+	PublishDate    time.Time `yaml:"publish_date,omitempty"`
+	ExpiryDate     time.Time `yaml:"expiry_date,omitempty"`
+	TeaserTemplate string    `yaml:"teaser_template,omitempty"`
+	PrintTemplate  string    `yaml:"print_template,omitempty"`
+	Fragment       string    `yaml:"fragment,omitempty"`
+
+	Output   string `yaml:"output,omitempty"`
+	Pipeline string `yaml:"pipeline,omitempty"`
+	Filename string `yaml:"filename,omitempty"`
+
+	RequireUser  []string `yaml:"require_user,omitempty"`
+	RequireGroup []string `yaml:"require_group,omitempty"`
+
+	Headers     map[string]string `yaml:"headers,omitempty"`
+	Status      int               `yaml:"status,omitempty"`
+	ContentType string            `yaml:"content_type,omitempty"`
+
+	Action *action.Action `yaml:"action,omitempty"`
+}
+
+// Published reports whether now falls within the route's publish window
+func (t *Template) Published(now time.Time) bool {
+	if !t.PublishDate.IsZero() && now.Before(t.PublishDate) {
+		return false
+	}
+	if !t.ExpiryDate.IsZero() && !now.Before(t.ExpiryDate) {
+		return false
+	}
+	return true
 }
 
 // Config represents the configuration structure
 type Config struct {
 	ConfigFilePath  string     `yaml:"-"`
+	Format          string     `yaml:"-"`
+	Version         string     `yaml:"-"`
 	DefaultTemplate string     `yaml:"default_template"`
+	TemplateDir     string     `yaml:"template_dir,omitempty"`
+	BasePath        string     `yaml:"base_path,omitempty"`
 	Templates       []Template `yaml:"templates"`
 	Data            any        `yaml:"data"`
+	DataFiles       []string   `yaml:"data_files,omitempty"`
+	DataDir         string     `yaml:"data_dir,omitempty"`
+	DataMerge       DataMerge  `yaml:"data_merge,omitempty"`
+	DataTemplates   bool       `yaml:"data_templates,omitempty"`
+	PreviewToken    string     `yaml:"preview_token,omitempty"`
+	PreviewAll      bool       `yaml:"preview_all,omitempty"`
+	Gallery         Gallery    `yaml:"gallery,omitempty"`
+	OEmbed          OEmbed     `yaml:"oembed,omitempty"`
+	Share           Share      `yaml:"share,omitempty"`
+
+	SSI         SSI                  `yaml:"ssi,omitempty"`
+	Env         []string             `yaml:"env,omitempty"`
+	Authz       Authz                `yaml:"authz,omitempty"`
+	Theme       theme.Settings       `yaml:"theme,omitempty"`
+	ClientHints clienthints.Settings `yaml:"client_hints,omitempty"`
+	Consent     consent.Settings     `yaml:"consent,omitempty"`
+
+	Pipelines map[string][]PipelineStep `yaml:"pipelines,omitempty"`
+
+	DataSources []DataSource `yaml:"data_sources,omitempty"`
+	EnvData     EnvData      `yaml:"env_data,omitempty"`
+	Remote      Remote       `yaml:"remote,omitempty"`
+	RequestHook RequestHook  `yaml:"request_hook,omitempty"`
+
+	NowOverride time.Time `yaml:"now,omitempty"`
+	LocaleName  string    `yaml:"locale,omitempty"`
+
+	Messages map[string]map[string]string `yaml:"messages,omitempty"`
+	Slug     Slug                         `yaml:"slug,omitempty"`
+	HMACKeys map[string]HMACKey           `yaml:"hmac_keys,omitempty"`
+
+	SecretsFile SecretsFile `yaml:"secrets_file,omitempty"`
+
+	Calendars []calendar.Source `yaml:"calendars,omitempty"`
+	Widgets   []widget.Widget   `yaml:"widgets,omitempty"`
+	Feeds     Feeds             `yaml:"feeds,omitempty"`
+
+	SecurityTxt *wellknown.SecurityTxt `yaml:"security_txt,omitempty"`
+	HumansTxt   *wellknown.HumansTxt   `yaml:"humans_txt,omitempty"`
+	WellKnown   map[string]any         `yaml:"well_known,omitempty"`
+
+	Announce Announce `yaml:"announce,omitempty"`
+	Admin    Admin    `yaml:"admin,omitempty"`
+	Watch    Watch    `yaml:"watch,omitempty"`
+	Cache    Cache    `yaml:"cache,omitempty"`
+	Render   Render   `yaml:"render,omitempty"`
+	Defaults Defaults `yaml:"defaults,omitempty"`
+	Canary   Canary   `yaml:"canary,omitempty"`
+	Mirror   Mirror   `yaml:"mirror,omitempty"`
+
+	PDF pdf.Renderer `yaml:"pdf,omitempty"`
+
+	// baseDir is the config directory when the config was merged from one
+	baseDir string
+	// templateRoot is the directory a template_dir symlink pointed at when
+	// the config was loaded
+	templateRoot string
+	// refresh holds the data sources refreshed in the background
+	refresh *dataRefresh
+}
+
+// Canary configures a second config that serves a share of the traffic
+type Canary struct {
+	Config  string  `yaml:"config"`
+	Percent float64 `yaml:"percent,omitempty"`
+	Header  string  `yaml:"header,omitempty"`
+}
+
+// Mirror configures copying a sample of requests to a shadow backend
+type Mirror struct {
+	URL                string        `yaml:"url"`
+	Percent            float64       `yaml:"percent"`
+	Methods            []string      `yaml:"methods,omitempty"`
+	Timeout            time.Duration `yaml:"timeout,omitempty"`
+	ForwardCredentials bool          `yaml:"forward_credentials,omitempty"`
+}
+
+// LoadCanary parses the canary config, or returns nil if none is configured
+func (c *Config) LoadCanary() (*Config, error) {
+	if c.Canary.Config == "" {
+		return nil, nil
+	}
+	canary, err := ParseConfigFile(c.resolvePath(c.Canary.Config))
+	if err != nil {
+		return nil, fmt.Errorf("canary: %w", err)
+	}
+	if canary.Canary.Config != "" {
+		return nil, fmt.Errorf("canary: a canary config cannot have its own canary")
+	}
+	return canary, nil
+}
+
+// Render configures how pages are rendered
+type Render struct {
+	StreamThreshold ByteSize      `yaml:"stream_threshold,omitempty"`
+	Timeout         time.Duration `yaml:"timeout,omitempty"`
+	MaxOutput       ByteSize      `yaml:"max_output,omitempty"`
+
+	PartialDepth int `yaml:"partial_depth,omitempty"`
+}
+
+// Cache configures the caches of long-lived server modes
+type Cache struct {
+	Templates      bool          `yaml:"templates"`
+	TemplateMemory ByteSize      `yaml:"template_memory,omitempty"`
+	Responses      time.Duration `yaml:"responses,omitempty"`
+	ResponseMemory ByteSize      `yaml:"response_memory,omitempty"`
+}
+
+// Default cache memory budgets
+const (
+	DefaultTemplateMemory ByteSize = 16 << 20
+	DefaultResponseMemory ByteSize = 64 << 20
+)
+
+// TemplateLimit returns the template cache's memory budget
+func (c *Cache) TemplateLimit() int64 {
+	if c.TemplateMemory > 0 {
+		return int64(c.TemplateMemory)
+	}
+	return int64(DefaultTemplateMemory)
+}
+
+// ResponseLimit returns the response cache's memory budget
+func (c *Cache) ResponseLimit() int64 {
+	if c.ResponseMemory > 0 {
+		return int64(c.ResponseMemory)
+	}
+	return int64(DefaultResponseMemory)
+}
+
+// Watch configures polling for config and template changes in the standalone
+// server
+type Watch struct {
+	Interval time.Duration `yaml:"interval"`
+	Disabled bool          `yaml:"disabled"`
+}
+
+// DefaultWatchInterval is how often the standalone server checks for
+// changes when no interval is configured
+const DefaultWatchInterval = 2 * time.Second
+
+// PollInterval returns the watch interval, or 0 if watching is disabled
+func (w *Watch) PollInterval() time.Duration {
+	if w.Disabled {
+		return 0
+	}
+	if w.Interval <= 0 {
+		return DefaultWatchInterval
+	}
+	return w.Interval
+}
+
+// Admin configures the JSON admin API of the standalone server
+type Admin struct {
+	Listen string `yaml:"listen"`
+	Token  string `yaml:"token,omitempty"`
+}
+
+// Announce configures the webhook notified when the standalone server starts
+type Announce struct {
+	Webhook string `yaml:"webhook"`
+	URL     string `yaml:"url,omitempty"`
+}
+
+// Feeds configures the fetchFeed template function
+type Feeds struct {
+	CacheTTL time.Duration `yaml:"cache_ttl,omitempty"`
+	CacheDir string        `yaml:"cache_dir,omitempty"`
+	MaxSize  int64         `yaml:"max_size,omitempty"`
+}
+
+// SSI configures translation of server-side include directives in templates
+type SSI struct {
+	Enabled bool   `yaml:"enabled"`
+	Root    string `yaml:"root,omitempty"`
+}
+
+// Share configures which platforms the share link functions support
+type Share struct {
+	Enabled []string `yaml:"enabled"`
+}
+
+// Slug configures the slugify template function
+type Slug struct {
+	Replacements map[string]string `yaml:"replacements,omitempty"`
+}
+
+// OEmbed configures the oembed template function. Only URLs handled by an
+// allowed built-in provider or a custom provider are looked up.
+type OEmbed struct {
+	Allow     []string          `yaml:"allow"`
+	Providers []oembed.Provider `yaml:"providers"`
+	CacheDir  string            `yaml:"cache_dir,omitempty"`
+	CacheTTL  time.Duration     `yaml:"cache_ttl,omitempty"`
+}
+
+// providers returns the allowed built-in providers followed by the custom ones
+func (o *OEmbed) providers() ([]oembed.Provider, error) {
+	var providers []oembed.Provider
+	for _, name := range o.Allow {
+		found := false
+		for _, p := range oembed.BuiltinProviders {
+			if p.Name == name {
+				providers = append(providers, p)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown oEmbed provider '%s'", name)
+		}
+	}
+	for i := range o.Providers {
+		if err := o.Providers[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return append(providers, o.Providers...), nil
+}
+
+// Gallery configures the gallery template function
+type Gallery struct {
+	Root      string `yaml:"root"`
+	URL       string `yaml:"url"`
+	ThumbSize int    `yaml:"thumb_size,omitempty"`
+
+	StripMetadata bool `yaml:"strip_metadata,omitempty"`
 }
 
 // TemplateData holds data passed to templates
 type TemplateData struct {
 	RequestURI string
+	BasePath   string
 	Request    interface{} // Using interface{} to avoid http import in tests
 	Data       any
+	Action     *action.Result
+	PrintURL   string
+	Theme      theme.Theme
+	Hints      clienthints.Hints
+	Consent    consent.Consent
+	Env        map[string]string
+	Vars       map[string]any
+	Content    template.HTML
+	Tmpl       TmplInfo
 }
 
-// ParseConfigFile parses YAML configuration data from a file
+// TmplInfo describes the running tmpl.cgi and its config, so that footers
+// and health pages can show what is deployed
+type TmplInfo struct {
+	Version    string
+	BuildDate  string
+	ConfigHash string
+	StartTime  time.Time
+}
+
+// TmplInfo returns the build information of the binary and the version of
+// the config
+func (c *Config) TmplInfo() TmplInfo {
+	return TmplInfo{
+		Version:    buildinfo.Version(),
+		BuildDate:  buildinfo.Date(),
+		ConfigHash: c.Version,
+		StartTime:  buildinfo.StartTime(),
+	}
+}
+
+// ParseConfigFile parses configuration data from a file, then applies
+// overrides from TMPL_CGI__* environment variables. If the file does not
+// exist but overrides are set, the config is built from the environment alone.
+// If filePath is a directory, all config files in it are merged in name order.
+// The format is detected from the file extension: .json, .toml, or YAML.
 func ParseConfigFile(filePath string) (*Config, error) {
-	data, err := os.ReadFile(filePath)
+	return ParseConfigFileAs(filePath, "")
+}
+
+// StdinPath is the config path that reads the config from standard input
+const StdinPath = "-"
+
+// InlineConfigEnv names the environment variable that can hold the whole
+// config instead of a file
+const InlineConfigEnv = "TMPL_CGI_CONFIG_INLINE"
+
+// ParseConfigFileAs parses a config file in the given format (yaml, json or
+// toml), or in the format implied by its extension if format is empty. A
+// path of "-" reads the config from standard input.
+func ParseConfigFileAs(filePath string, format string) (*Config, error) {
+	overrides := envOverrides(os.Environ())
+	var doc map[string]any
+	var data []byte
+	var err error
+	baseDir := ""
+	if filePath == StdinPath {
+		if data, err = io.ReadAll(os.Stdin); err != nil {
+			return nil, fmt.Errorf("reading config from stdin: %w", err)
+		}
+	} else if isRemoteConfig(filePath) {
+		if data, err = readRemoteConfig(filePath); err != nil {
+			return nil, err
+		}
+	} else if info, statErr := os.Stat(filePath); statErr == nil && info.IsDir() {
+		if doc, data, err = readConfigDir(filePath); err != nil {
+			return nil, err
+		}
+		baseDir = filePath
+	} else {
+		data, err = os.ReadFile(filePath)
+		if err != nil && !(os.IsNotExist(err) && len(overrides) > 0) {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+	}
+
+	fileFormat := format
+	if fileFormat == "" && isRemoteConfig(filePath) {
+		fileFormat = detectFormat(urlPath(filePath))
+	} else if fileFormat == "" {
+		fileFormat = detectFormat(filePath)
+	}
+	config, err := parseConfig(data, doc, fileFormat, overrides)
 	if err != nil {
-		return nil, fmt.Errorf("reading config file: %w", err)
+		return nil, err
+	}
+	config.baseDir = baseDir
+	config.ConfigFilePath = filePath
+	config.Format = format
+	config.pinTemplateDir()
+	if err = config.loadSecretsFile(); err != nil {
+		return nil, err
+	}
+	// Data files are loaded after the templated values are expanded, so
+	// that their contents are never evaluated
+	if err = config.expandDataTemplates(); err != nil {
+		return nil, err
+	}
+	if err = config.loadDataFiles(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ParseConfig parses config text in the given format, YAML if empty, then
+// applies overrides from TMPL_CGI__* environment variables. Relative paths
+// in the config resolve against the working directory.
+func ParseConfig(data []byte, format string) (*Config, error) {
+	if format == "" {
+		format = FormatYAML
+	}
+	config, err := parseConfig(data, nil, format, envOverrides(os.Environ()))
+	if err != nil {
+		return nil, err
+	}
+	config.Format = format
+	config.pinTemplateDir()
+	if err = config.loadSecretsFile(); err != nil {
+		return nil, err
 	}
+	// Data files are loaded after the templated values are expanded, so
+	// that their contents are never evaluated
+	if err = config.expandDataTemplates(); err != nil {
+		return nil, err
+	}
+	if err = config.loadDataFiles(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// parseConfig decodes config data, or the already decoded doc of a config
+// directory, and applies the environment overrides
+func parseConfig(data []byte, doc map[string]any, format string, overrides [][2]string) (*Config, error) {
 	var config Config
-	if err = yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("parsing config file: %w", err)
+	var err error
+	if doc == nil && len(overrides) == 0 && format == FormatYAML {
+		if err = yaml.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("parsing config file: %w", err)
+		}
+	} else {
+		if doc == nil {
+			if doc, err = decodeDoc(data, format); err != nil {
+				return nil, fmt.Errorf("parsing config file: %w", err)
+			}
+		}
+		if err = applyEnv(doc, overrides); err != nil {
+			return nil, err
+		}
+		merged, err := yaml.Marshal(doc)
+		if err == nil {
+			err = yaml.Unmarshal(merged, &config)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding config: %w", err)
+		}
 	}
-	config.ConfigFilePath = filePath
+	config.sortRoutes()
+	config.Version = version(data, overrides)
 	return &config, nil
 }
 
+// Reloadable reports whether the config was read from a file or directory
+// that can be read again
+func (c *Config) Reloadable() bool {
+	return c.ConfigFilePath != "" && c.ConfigFilePath != StdinPath
+}
+
+// version identifies a config by a hash of its file contents and overrides
+func version(data []byte, overrides [][2]string) string {
+	h := sha256.New()
+	h.Write(data)
+	for _, o := range overrides {
+		fmt.Fprintf(h, "\x00%s=%s", o[0], o[1])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
 // FindTemplate loads the appropriate template for a given URI
-func (c *Config) FindTemplate(uri string) (*template.Template, error) {
-	for _, t := range c.Templates {
-		re, err := regexp.Compile(t.Pattern)
-		if err != nil {
-			return nil, fmt.Errorf("compiling regexp: %w", err)
+func (c *Config) FindTemplate(uri string) (Executable, error) {
+	t, err := c.MatchTemplate(uri)
+	if err != nil {
+		return nil, err
+	}
+	if t != nil {
+		return c.LoadTemplate(t.TemplateName())
+	}
+	return c.LoadTemplate(c.DefaultTemplate)
+}
+
+// PreviewAllowed reports whether the request carries the configured preview
+// token, either as a preview_token query parameter or cookie
+func (c *Config) PreviewAllowed(r *http.Request) bool {
+	if c.PreviewToken == "" {
+		return false
+	}
+	token := r.URL.Query().Get("preview_token")
+	if token == "" {
+		if cookie, err := r.Cookie("preview_token"); err == nil {
+			token = cookie.Value
 		}
-		if re.MatchString(uri) {
-			return c.LoadTemplate(t.Template)
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.PreviewToken)) == 1
+}
+
+// WellKnownFile returns the generated document served at urlPath, if any
+func (c *Config) WellKnownFile(urlPath string) (*wellknown.File, bool) {
+	switch {
+	case urlPath == wellknown.SecurityTxtPath && c.SecurityTxt != nil:
+		return c.SecurityTxt.Render(), true
+	case urlPath == wellknown.HumansTxtPath && c.HumansTxt != nil:
+		return c.HumansTxt.Render(), true
+	}
+	if name, ok := strings.CutPrefix(urlPath, wellknown.Prefix); ok {
+		if doc, ok := c.WellKnown[name]; ok {
+			f, err := wellknown.JSON(name, doc)
+			return f, err == nil
 		}
 	}
-	return c.LoadTemplate(c.DefaultTemplate)
+	return nil, false
 }
 
 // LoadTemplate reads and parses a template file
-func (c *Config) LoadTemplate(filename string) (*template.Template, error) {
-	if !filepath.IsAbs(filename) {
-		filename = filepath.Join(path.Dir(c.ConfigFilePath), filename)
+func (c *Config) LoadTemplate(filename string) (Executable, error) {
+	if isInline(filename) {
+		return c.loadInlineTemplate(filename)
+	}
+	text := c.isTextTemplate(filename)
+	filename, err := c.localFile(c.templatePath(filename))
+	if err != nil {
+		return nil, err
+	}
+	if c.SSI.Enabled {
+		return c.loadSSITemplate(filename, text)
 	}
-	tmpl, err := template.New(path.Base(filename)).Funcs(sprig.FuncMap()).ParseFiles(filename)
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse: %w", err)
+	}
+	tmpl, err := parseTemplate(path.Base(filename), text, c.funcMap(), string(src))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse: %w", err)
 	}
 	return tmpl, nil
 }
 
+// TemplateDirs returns the directories holding the local templates the
+// config refers to, in sorted order
+func (c *Config) TemplateDirs() []string {
+	names := append([]string{c.DefaultTemplate, c.Authz.ForbiddenTemplate}, c.pipelineLayouts()...)
+	for _, t := range c.Templates {
+		names = append(names, t.Template, t.TeaserTemplate, t.PrintTemplate)
+		if t.Action != nil {
+			names = append(names, t.Action.SuccessTemplate, t.Action.ErrorTemplate)
+		}
+	}
+	seen := map[string]bool{}
+	var dirs []string
+	for _, name := range names {
+		if name == "" || isRemote(c.templatePath(name)) {
+			continue
+		}
+		dir := filepath.Dir(c.templatePath(name))
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// funcMap returns the functions available to templates: the sprig library
+// plus tmpl.cgi's own helpers
+func (c *Config) funcMap() template.FuncMap {
+	funcs := sprig.FuncMap()
+	funcs["gallery"] = c.gallery
+	funcs["oembed"] = c.oembed
+	funcs["calendar"] = c.calendar
+	funcs["widget"] = c.widget
+	funcs["fetchFeed"] = c.fetchFeed
+	funcs["vcardEscape"] = vcard.Escape
+	funcs["vcardPhoto"] = c.vcardPhoto
+	funcs[ssiEchoFunc] = c.ssiEcho
+	funcs["setVar"] = setVar
+	funcs["getVar"] = getVar
+	funcs["renderPartial"] = c.renderPartial(nil)
+	funcs["now"] = c.Now
+	funcs["dateIn"] = dateIn
+	funcs["inZone"] = inZone
+	funcs["parseDate"] = parseDate
+	funcs["parseDateIn"] = parseDateIn
+	funcs["localizeDate"] = c.localizeDate
+	funcs["formatNumber"] = c.formatNumber
+	funcs["formatCurrency"] = c.formatCurrency
+	funcs["t"], funcs["tN"] = c.messageFuncs()
+	funcs["slugify"] = c.slugify
+	funcs["hmacSHA256"] = c.hmacSHA256
+	funcs["hmacVerify"] = c.hmacVerify
+	for name, f := range randomFuncs() {
+		funcs[name] = f
+	}
+	shareBuilder := &share.Builder{Enabled: c.Share.Enabled}
+	funcs["shareURL"] = shareBuilder.URL
+	funcs["shareLinks"] = shareBuilder.Links
+	return funcs
+}
+
+// slugify returns the URL slug of a title
+func (c *Config) slugify(title string) string {
+	return slug.Make(title, c.Slug.Replacements)
+}
+
+// oembed looks up embed HTML and metadata for a media URL
+func (c *Config) oembed(mediaURL string) (*oembed.Response, error) {
+	providers, err := c.OEmbed.providers()
+	if err != nil {
+		return nil, err
+	}
+	client := oembed.Client{
+		Providers: providers,
+		CacheDir:  c.OEmbed.CacheDir,
+		CacheTTL:  c.OEmbed.CacheTTL,
+	}
+	return client.Lookup(mediaURL)
+}
+
+// gallery lists the images in a directory below the configured gallery root
+func (c *Config) gallery(dir string) ([]gallery.Image, error) {
+	return gallery.Scan(dir, gallery.Options{
+		Root:      c.resolvePath(c.Gallery.Root),
+		URLPrefix: c.Gallery.URL,
+		ThumbSize: c.Gallery.ThumbSize,
+
+		StripMetadata: c.Gallery.StripMetadata,
+	})
+}
+
+// calendar loads the named calendar feed
+func (c *Config) calendar(name string) (*calendar.Calendar, error) {
+	for _, src := range c.Calendars {
+		if src.Name == name {
+			if !strings.Contains(src.URL, "://") {
+				src.URL = c.resolvePath(src.URL)
+			}
+			return src.Load(c.Now())
+		}
+	}
+	return nil, fmt.Errorf("unknown calendar '%s'", name)
+}
+
+// widget loads the named widget's data
+func (c *Config) widget(name string) (*widget.Result, error) {
+	for _, w := range c.Widgets {
+		if w.Name == name {
+			return w.Load(), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown widget '%s'", name)
+}
+
+// fetchFeed fetches an RSS or Atom feed and returns up to limit entries
+func (c *Config) fetchFeed(feedURL string, limit int) ([]feed.Entry, error) {
+	ttl := c.Feeds.CacheTTL
+	if ttl == 0 {
+		ttl = 15 * time.Minute
+	}
+	body, err := fetch.Cached(feedURL, fetch.Options{
+		CacheDir: c.Feeds.CacheDir,
+		TTL:      ttl,
+		MaxSize:  c.Feeds.MaxSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching feed %s: %w", feedURL, err)
+	}
+	f, err := feed.Parse(body, limit)
+	if err != nil {
+		return nil, fmt.Errorf("feed %s: %w", feedURL, err)
+	}
+	return f.Entries, nil
+}
+
+// resolvePath resolves a path relative to the config file's directory, or
+// to the config directory itself when the config was merged from one. In a
+// remote config, relative paths become URLs next to the config.
+func (c *Config) resolvePath(filename string) string {
+	if isRemote(filename) {
+		return filename
+	}
+	if !filepath.IsAbs(filename) && c.baseDir == "" && isRemoteConfig(c.ConfigFilePath) {
+		return remotePath(remoteConfigDir(c.ConfigFilePath), filepath.ToSlash(filename))
+	}
+	if !filepath.IsAbs(filename) {
+		dir := c.baseDir
+		if dir == "" {
+			dir = path.Dir(c.ConfigFilePath)
+		}
+		filename = filepath.Join(dir, filename)
+	}
+	return filename
+}
+
+// templatePath resolves a template file name against template_dir, if set,
+// and otherwise like any other path. Names in a remote template_dir, and
+// remote names, are URLs.
+func (c *Config) templatePath(filename string) string {
+	if isRemote(filename) {
+		return filename
+	}
+	if isRemote(c.TemplateDir) && !filepath.IsAbs(filename) {
+		return remotePath(c.TemplateDir, filename)
+	}
+	if c.templateRoot != "" && !filepath.IsAbs(filename) {
+		return filepath.Join(c.templateRoot, filename)
+	}
+	if c.TemplateDir != "" && !filepath.IsAbs(filename) {
+		filename = filepath.Join(c.TemplateDir, filename)
+	}
+	return c.resolvePath(filename)
+}
+
 // Validate validates the configuration
 func (c *Config) Validate() error {
+	return c.ValidateWithHAR(nil)
+}
+
+// ValidateWithHAR validates the configuration, recording each simulated
+// request and its rendered response in the given HAR log (which may be nil)
+func (c *Config) ValidateWithHAR(h *har.Log) error {
 
 	// Validate that all regexes compile
 	for _, t := range c.Templates {
-		_, err := regexp.Compile(t.Pattern)
+		switch t.PatternType {
+		case "", PatternRegex, PatternGlob, PatternPrefix, PatternExact:
+		default:
+			return fmt.Errorf("pattern '%s': unknown pattern_type '%s'", t.Pattern, t.PatternType)
+		}
+		_, err := t.uriMatcher()
 		if err != nil {
 			return fmt.Errorf("compiling regex: %w", err)
 		}
+		if t.Content != "" && t.Template != "" {
+			return fmt.Errorf("pattern '%s': template and content are mutually exclusive", t.Pattern)
+		}
+		if t.Status != 0 && (t.Status < 200 || t.Status > 599 || t.Status == http.StatusNoContent || t.Status == http.StatusNotModified) {
+			return fmt.Errorf("pattern '%s': invalid status %d", t.Pattern, t.Status)
+		}
+		if t.ContentType != "" {
+			if _, _, err := mime.ParseMediaType(t.ContentType); err != nil {
+				return fmt.Errorf("pattern '%s': invalid content_type: %w", t.Pattern, err)
+			}
+		}
+		for name := range t.Headers {
+			if !validHeaderName(name) {
+				return fmt.Errorf("pattern '%s': invalid header name '%s'", t.Pattern, name)
+			}
+		}
+		if _, err = regexp.Compile(t.Exclude); err != nil {
+			return fmt.Errorf("compiling exclude: %w", err)
+		}
+		if _, err = regexp.Compile(t.HostPattern); err != nil {
+			return fmt.Errorf("compiling host_pattern: %w", err)
+		}
+		for _, m := range t.Methods {
+			if m == "" || strings.ContainsFunc(m, func(r rune) bool { return r < 'A' || r > 'Z' }) {
+				return fmt.Errorf("pattern '%s': invalid method '%s'", t.Pattern, m)
+			}
+		}
+	}
+
+	if _, err := c.OEmbed.providers(); err != nil {
+		return fmt.Errorf("oembed: %w", err)
+	}
+	if err := (&share.Builder{Enabled: c.Share.Enabled}).Validate(); err != nil {
+		return fmt.Errorf("share: %w", err)
+	}
+	for _, src := range c.Calendars {
+		if err := src.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, w := range c.Widgets {
+		if err := w.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.SecurityTxt != nil {
+		if err := c.SecurityTxt.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.HumansTxt != nil {
+		if err := c.HumansTxt.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Announce.Webhook != "" {
+		if u, err := url.Parse(c.Announce.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("announce: webhook must be an http(s) URL")
+		}
+	}
+	if c.Mirror.URL != "" {
+		if u, err := url.Parse(c.Mirror.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("mirror: url must be an http(s) URL")
+		}
+		if c.Mirror.Percent < 0 || c.Mirror.Percent > 100 {
+			return fmt.Errorf("mirror: percent must be between 0 and 100")
+		}
+	}
+	if dir := c.TemplateDirPath(); dir != "" {
+		if c.templateRoot != "" {
+			dir = c.templateRoot
+		}
+		if info, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("template_dir: %w", err)
+		} else if !info.IsDir() {
+			return fmt.Errorf("template_dir: %s is not a directory", c.TemplateDir)
+		}
+	}
+	if err := c.DataMerge.Validate(); err != nil {
+		return err
+	}
+	for _, t := range c.Templates {
+		if t.DataMerge == nil {
+			continue
+		}
+		if err := t.DataMerge.Validate(); err != nil {
+			return fmt.Errorf("pattern '%s': %w", t.Pattern, err)
+		}
+	}
+	if _, err := parseNow(os.Getenv(NowEnv)); err != nil {
+		return err
+	}
+	if err := c.validateDataSources(); err != nil {
+		return err
+	}
+	if err := c.validateRemote(); err != nil {
+		return err
+	}
+	if err := c.validateRequestHook(); err != nil {
+		return err
+	}
+	if err := c.validateLocale(); err != nil {
+		return err
+	}
+	if err := c.validateEnvData(); err != nil {
+		return err
+	}
+	if err := c.validateMessages(); err != nil {
+		return err
+	}
+	if err := c.validateHMACKeys(); err != nil {
+		return err
+	}
+	if err := c.validateEnvironments(); err != nil {
+		return err
+	}
+	if err := c.validatePipelines(); err != nil {
+		return err
+	}
+	if err := c.validateBasePath(); err != nil {
+		return err
+	}
+	if c.Render.PartialDepth < 0 {
+		return fmt.Errorf("render: partial_depth must not be negative")
+	}
+	for _, name := range c.Env {
+		if strings.TrimSuffix(name, "*") == "" || strings.ContainsAny(name, "=\x00") {
+			return fmt.Errorf("env: invalid variable name '%s'", name)
+		}
+	}
+	if err := c.Defaults.Validate(); err != nil {
+		return err
+	}
+	if err := c.validateAuthz(); err != nil {
+		return err
+	}
+	if err := c.validateRoles(); err != nil {
+		return err
+	}
+	if err := c.Theme.Validate(); err != nil {
+		return err
+	}
+	if err := c.ClientHints.Validate(); err != nil {
+		return err
+	}
+	if err := c.Consent.Validate(); err != nil {
+		return err
+	}
+	if err := c.PDF.Validate(); err != nil {
+		return err
+	}
+	if c.Canary.Percent < 0 || c.Canary.Percent > 100 {
+		return fmt.Errorf("canary: percent must be between 0 and 100")
+	}
+	if canary, err := c.LoadCanary(); err != nil {
+		return err
+	} else if canary != nil {
+		if err = canary.ValidateWithHAR(h); err != nil {
+			return fmt.Errorf("canary config: %w", err)
+		}
+	}
+	if c.Admin.Listen != "" && c.Admin.Token == "" {
+		host, _, err := net.SplitHostPort(c.Admin.Listen)
+		if err != nil {
+			return fmt.Errorf("admin: invalid listen address: %w", err)
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("admin: a token is required unless listening on a loopback address")
+		}
+	}
+	for name, doc := range c.WellKnown {
+		if _, err := wellknown.JSON(name, doc); err != nil {
+			return err
+		}
 	}
 
 	// Validate default template
-	if err := c.validateTemplate(&Template{
+	if err := c.validateTemplateHAR(&Template{
 		Template: c.DefaultTemplate,
 		TestURI:  "/test/path",
-	}); err != nil {
+	}, h); err != nil {
 		return fmt.Errorf("default template '%s': %w", c.DefaultTemplate, err)
 	}
+	if c.Authz.ForbiddenTemplate != "" {
+		if err := c.validateTemplateHAR(&Template{Template: c.Authz.ForbiddenTemplate}, h); err != nil {
+			return fmt.Errorf("forbidden template '%s': %w", c.Authz.ForbiddenTemplate, err)
+		}
+	}
+
+	for _, name := range c.pipelineLayouts() {
+		if err := c.validateTemplateHAR(&Template{Template: name}, h); err != nil {
+			return fmt.Errorf("layout template '%s': %w", name, err)
+		}
+	}
 
 	// Validate pattern-specific templates
 	for _, t := range c.Templates {
-		if err := c.validateTemplate(&t); err != nil {
-			return fmt.Errorf("template '%s': %w", t.Template, err)
+		if err := c.validateTemplateHAR(&t, h); err != nil {
+			return fmt.Errorf("template '%s': %w", t.TemplateName(), err)
+		}
+		if err := t.validateOutput(); err != nil {
+			return fmt.Errorf("pattern '%s': %w", t.Pattern, err)
+		}
+		if t.Action != nil {
+			if err := t.Action.Validate(); err != nil {
+				return fmt.Errorf("action for pattern '%s': %w", t.Pattern, err)
+			}
+			for _, name := range []string{t.Action.SuccessTemplate, t.Action.ErrorTemplate} {
+				if name == "" {
+					continue
+				}
+				at := Template{Template: name, TestURI: t.TestURI}
+				if err := c.validateTemplateHAR(&at, h); err != nil {
+					return fmt.Errorf("action template '%s': %w", name, err)
+				}
+			}
+		}
+		if t.TeaserTemplate != "" {
+			teaser := Template{Template: t.TeaserTemplate, TestURI: t.TestURI}
+			if err := c.validateTemplateHAR(&teaser, h); err != nil {
+				return fmt.Errorf("teaser template '%s': %w", t.TeaserTemplate, err)
+			}
+		}
+		if t.Fragment != "" {
+			tmpl, err := c.LoadTemplate(t.TemplateName())
+			if err == nil && tmpl.Trees()[t.Fragment] == nil {
+				return fmt.Errorf("template '%s': no block named '%s' for fragment", t.TemplateName(), t.Fragment)
+			}
+		}
+		if t.PrintTemplate != "" {
+			printable := Template{Template: t.PrintTemplate, TestURI: t.TestURI}
+			if err := c.validateTemplateHAR(&printable, h); err != nil {
+				return fmt.Errorf("print template '%s': %w", t.PrintTemplate, err)
+			}
 		}
 	}
 
 	return nil
 }
 
+// Warnings returns configuration problems that do not prevent serving
+func (c *Config) Warnings(now time.Time) []string {
+	var warnings []string
+	if c.SecurityTxt != nil {
+		warnings = append(warnings, c.SecurityTxt.Warnings(now)...)
+	}
+	warnings = append(warnings, c.outputWarnings()...)
+	return warnings
+}
+
 // validateTemplate validates a single template file
 func (c *Config) validateTemplate(t *Template) error {
-	tmpl, err := c.LoadTemplate(t.Template)
+	return c.validateTemplateHAR(t, nil)
+}
+
+// validateTemplateHAR validates a single template file, recording the
+// simulated request and its response in h if it is non-nil
+func (c *Config) validateTemplateHAR(t *Template, h *har.Log) error {
+	started := time.Now()
+	requestURI := "/test/path"
+	if t.TestURI != "" {
+		requestURI = t.TestURI
+	}
+	req := createSampleRequest(requestURI)
+
+	tmpl, err := c.LoadTemplate(t.TemplateName())
 	if err != nil {
+		recordFailure(h, req, started, t.TemplateName(), err)
 		return fmt.Errorf("loading template: %w", err)
 	}
 
 	sampleData := &TemplateData{
-		RequestURI: "/test/path",
-		Data:       c.Data,
-	}
-	if t.TestURI != "" {
-		sampleData.RequestURI = t.TestURI
+		RequestURI: requestURI,
+		BasePath:   c.NormalizedBasePath(),
+		Request:    req,
+		Data:       c.RouteData(t),
+		Env:        c.TemplateEnv(os.Environ()),
+		Vars:       map[string]any{},
+		Tmpl:       c.TmplInfo(),
 	}
-	sampleData.Request = createSampleRequest(sampleData.RequestURI)
 
 	var buf bytes.Buffer
 	if err = tmpl.Execute(&buf, sampleData); err != nil {
+		recordFailure(h, req, started, t.TemplateName(), err)
 		return fmt.Errorf("executing template: %w", err)
 	}
 
+	header := http.Header{}
+	header.Set("Content-Type", c.ContentTypeFor(t, t.TemplateName()))
+	h.Add(req, started, time.Since(started), http.StatusOK, header, buf.Bytes(), "template: "+t.TemplateName())
+
 	return nil
 }
 
+// recordFailure adds a failed validation run to the HAR log
+func recordFailure(h *har.Log, req *http.Request, started time.Time, name string, err error) {
+	header := http.Header{}
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	h.Add(req, started, time.Since(started), http.StatusInternalServerError,
+		header, []byte(err.Error()), "template: "+name)
+}
+
 // createSampleRequest creates a minimal HTTP request for template testing
 func createSampleRequest(uri string) *http.Request {
 	req, _ := http.NewRequest("GET", uri, nil)
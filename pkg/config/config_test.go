@@ -2,10 +2,15 @@ package config
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/har"
+	"gopkg.mhn.org/tmpl.cgi/pkg/oembed"
 )
 
 func TestParseConfigFile(t *testing.T) {
@@ -354,6 +359,18 @@ func TestValidate(t *testing.T) {
 			expectError: true,
 			errorText:   "default template",
 		},
+		{
+			name: "Invalid oEmbed provider pattern",
+			config: &Config{
+				ConfigFilePath:  filepath.Join(tempDir, "config.yaml"),
+				DefaultTemplate: "valid.html",
+				OEmbed: OEmbed{Providers: []oembed.Provider{
+					{Name: "peertube", Patterns: []string{"^https://video\\.example/(w"}, Endpoint: "https://video.example/oembed"},
+				}},
+			},
+			expectError: true,
+			errorText:   "oembed: compiling pattern for provider peertube",
+		},
 		{
 			name: "Template execution error",
 			config: &Config{
@@ -579,3 +596,436 @@ func TestConfig_Struct(t *testing.T) {
 		t.Error("Data field is not the expected type")
 	}
 }
+
+func TestValidateWithHAR(t *testing.T) {
+	tempDir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(tempDir, "valid.html"), []byte(`Valid: {{.RequestURI}}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	config := &Config{
+		ConfigFilePath:  filepath.Join(tempDir, "config.yaml"),
+		DefaultTemplate: "valid.html",
+		Templates: []Template{
+			{Pattern: "^/api/.*", Template: "valid.html", TestURI: "/api/users"},
+			{Pattern: "^/feed", Template: "valid.html", ContentType: "application/rss+xml"},
+			{Pattern: "^/missing/.*", Template: "missing.html"},
+		},
+	}
+
+	h := har.New("tmpl.cgi", "test")
+	err = config.ValidateWithHAR(h)
+	if err == nil {
+		t.Fatal("ValidateWithHAR() expected error for missing template")
+	}
+
+	if len(h.Entries) != 4 {
+		t.Fatalf("HAR entries = %d, want 4", len(h.Entries))
+	}
+	if h.Entries[1].Response.Content.Text != "Valid: /api/users" {
+		t.Errorf("Entry[1] content = %q", h.Entries[1].Response.Content.Text)
+	}
+	if got := h.Entries[1].Response.Content.MimeType; got != "text/html; charset=utf-8" {
+		t.Errorf("Entry[1] mime type = %q, want text/html", got)
+	}
+	if got := h.Entries[2].Response.Content.MimeType; got != "application/rss+xml; charset=utf-8" {
+		t.Errorf("Entry[2] mime type = %q, want the route's content type", got)
+	}
+	if h.Entries[3].Response.Status != http.StatusInternalServerError {
+		t.Errorf("Entry[3] status = %d, want 500", h.Entries[3].Response.Status)
+	}
+}
+
+func TestTemplate_Published(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		template Template
+		expected bool
+	}{
+		{"No dates", Template{}, true},
+		{"Publish date passed", Template{PublishDate: now.Add(-time.Hour)}, true},
+		{"Publish date in future", Template{PublishDate: now.Add(time.Hour)}, false},
+		{"Expiry date in future", Template{ExpiryDate: now.Add(time.Hour)}, true},
+		{"Expiry date passed", Template{ExpiryDate: now.Add(-time.Hour)}, false},
+		{"Expiry date exactly now", Template{ExpiryDate: now}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.template.Published(now); got != tt.expected {
+				t.Errorf("Published() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseConfigFile_PublishDates(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(configPath, []byte(`default_template: "default.html"
+templates:
+  - pattern: "^/sale$"
+    template: "sale.html"
+    publish_date: 2025-11-28
+    expiry_date: 2025-12-01T00:00:00Z
+    teaser_template: "coming-soon.html"`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := ParseConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+
+	route := config.Templates[0]
+	if !route.PublishDate.Equal(time.Date(2025, 11, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("PublishDate = %v", route.PublishDate)
+	}
+	if !route.ExpiryDate.Equal(time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("ExpiryDate = %v", route.ExpiryDate)
+	}
+	if route.TeaserTemplate != "coming-soon.html" {
+		t.Errorf("TeaserTemplate = %s", route.TeaserTemplate)
+	}
+}
+
+func TestFuncMap_Share(t *testing.T) {
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "share.html"),
+		[]byte(`{{range shareLinks "https://example.com/a b" "Hi"}}{{.Platform}} {{end}}|{{shareURL "reddit" "https://example.com/" "Hi"}}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	config := &Config{
+		ConfigFilePath: filepath.Join(tempDir, "config.yaml"),
+		Share:          Share{Enabled: []string{"reddit", "email"}},
+	}
+	tmpl, err := config.LoadTemplate("share.html")
+	if err != nil {
+		t.Fatalf("LoadTemplate() error: %v", err)
+	}
+	var buf strings.Builder
+	if err = tmpl.Execute(&buf, TemplateData{}); err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+	want := "reddit email |https://www.reddit.com/submit?url=https%3A%2F%2Fexample.com%2F&amp;title=Hi"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+
+	config.Share.Enabled = []string{"friendster"}
+	if err = config.Validate(); err == nil || !strings.Contains(err.Error(), "share") {
+		t.Errorf("Validate() error = %v, want share error", err)
+	}
+}
+
+func TestFuncMap_Slugify(t *testing.T) {
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "slug.html"),
+		[]byte(`<a href="/posts/{{.Data.title | slugify}}">`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	config := &Config{
+		ConfigFilePath: filepath.Join(tempDir, "config.yaml"),
+		Slug:           Slug{Replacements: map[string]string{"&": "and"}},
+	}
+	tmpl, err := config.LoadTemplate("slug.html")
+	if err != nil {
+		t.Fatalf("LoadTemplate() error: %v", err)
+	}
+	var buf strings.Builder
+	if err = tmpl.Execute(&buf, TemplateData{Data: map[string]any{"title": "Crème Brûlée & Tarte Tatin"}}); err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+	want := `<a href="/posts/creme-brulee-and-tarte-tatin">`
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestFuncMap_FetchFeed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<feed xmlns="http://www.w3.org/2005/Atom"><title>Blog</title>
+			<entry><title>A</title><link href="https://blog.example.com/a"/></entry>
+			<entry><title>B</title><link href="https://blog.example.com/b"/></entry>
+			<entry><title>C</title><link href="https://blog.example.com/c"/></entry></feed>`))
+	}))
+	defer ts.Close()
+
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "feed.html"),
+		[]byte(`{{range fetchFeed .Data.url 2}}[{{.Title}} {{.Link}}]{{end}}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	config := &Config{
+		ConfigFilePath: filepath.Join(tempDir, "config.yaml"),
+		Feeds:          Feeds{CacheDir: filepath.Join(tempDir, "cache")},
+	}
+	tmpl, err := config.LoadTemplate("feed.html")
+	if err != nil {
+		t.Fatalf("LoadTemplate() error: %v", err)
+	}
+	var buf strings.Builder
+	if err = tmpl.Execute(&buf, TemplateData{Data: map[string]any{"url": ts.URL}}); err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+	want := "[A https://blog.example.com/a][B https://blog.example.com/b]"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+
+	config.Feeds.MaxSize = 10
+	config.Feeds.CacheDir = filepath.Join(tempDir, "cache2")
+	tmpl, _ = config.LoadTemplate("feed.html")
+	if err = tmpl.Execute(&buf, TemplateData{Data: map[string]any{"url": ts.URL}}); err == nil {
+		t.Error("Execute() with oversized feed should fail")
+	}
+}
+
+func TestParseConfigFile_WellKnown(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+	err := os.WriteFile(configPath, []byte(`default_template: "default.html"
+well_known:
+  apple-app-site-association:
+    webcredentials:
+      apps: ["ABCDE12345.com.example.app"]
+`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+
+	config, err := ParseConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	f, ok := config.WellKnownFile("/.well-known/apple-app-site-association")
+	if !ok {
+		t.Fatal("WellKnownFile() found no document")
+	}
+	if !strings.Contains(string(f.Body), `"apps": [`) {
+		t.Errorf("Body = %s", f.Body)
+	}
+	if _, ok = config.WellKnownFile("/.well-known/assetlinks.json"); ok {
+		t.Error("WellKnownFile() returned an unconfigured document")
+	}
+}
+
+func TestValidate_Admin(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "default.html"), []byte(`ok`), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		admin   Admin
+		wantErr bool
+	}{
+		{"Disabled", Admin{}, false},
+		{"Loopback without token", Admin{Listen: "127.0.0.1:9090"}, false},
+		{"Localhost without token", Admin{Listen: "localhost:9090"}, false},
+		{"Public without token", Admin{Listen: ":9090"}, true},
+		{"Public with token", Admin{Listen: ":9090", Token: "s3cret"}, false},
+		{"Invalid address", Admin{Listen: "9090"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				ConfigFilePath:  filepath.Join(tempDir, "config.yaml"),
+				DefaultTemplate: "default.html",
+				Admin:           tt.admin,
+			}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseConfigFile_Version(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	parse := func(content string) string {
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create config: %v", err)
+		}
+		config, err := ParseConfigFile(configPath)
+		if err != nil {
+			t.Fatalf("ParseConfigFile() error: %v", err)
+		}
+		return config.Version
+	}
+
+	v1 := parse(`default_template: "a.html"`)
+	if len(v1) != 12 {
+		t.Errorf("Version = %q, want 12 hex digits", v1)
+	}
+	if v := parse(`default_template: "a.html"`); v != v1 {
+		t.Errorf("Version of unchanged config = %q, want %q", v, v1)
+	}
+	if v := parse(`default_template: "b.html"`); v == v1 {
+		t.Error("Version did not change with the config")
+	}
+}
+
+func TestLoadTemplate_TemplateDir(t *testing.T) {
+	tempDir := t.TempDir()
+	templateDir := filepath.Join(tempDir, "templates")
+	if err := os.MkdirAll(filepath.Join(templateDir, "blog"), 0755); err != nil {
+		t.Fatalf("Failed to create template dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(templateDir, "blog", "post.html"), []byte(`Post`), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	config := &Config{
+		ConfigFilePath:  filepath.Join(tempDir, "etc", "config.yaml"),
+		DefaultTemplate: "blog/post.html",
+		TemplateDir:     "../templates",
+	}
+	if _, err := config.LoadTemplate("blog/post.html"); err != nil {
+		t.Errorf("LoadTemplate() error: %v", err)
+	}
+	if dirs := config.TemplateDirs(); len(dirs) != 1 || dirs[0] != filepath.Join(templateDir, "blog") {
+		t.Errorf("TemplateDirs() = %v, want [%s]", dirs, filepath.Join(templateDir, "blog"))
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() error: %v", err)
+	}
+
+	config.TemplateDir = "../missing"
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "template_dir") {
+		t.Errorf("Validate() error = %v, want template_dir error", err)
+	}
+	config.TemplateDir = "../templates/blog/post.html"
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Errorf("Validate() error = %v, want not a directory", err)
+	}
+}
+
+func TestParseConfigFile_Stdin(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe() error: %v", err)
+	}
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+	go func() {
+		_, _ = w.Write([]byte("default_template: \"default.html\"\n"))
+		_ = w.Close()
+	}()
+
+	cfg, err := ParseConfigFile(StdinPath)
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	if cfg.DefaultTemplate != "default.html" {
+		t.Errorf("DefaultTemplate = %q, want %q", cfg.DefaultTemplate, "default.html")
+	}
+	if cfg.Reloadable() {
+		t.Error("config from stdin should not be reloadable")
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+	}{
+		{name: "default yaml", data: "default_template: \"default.html\"\n"},
+		{name: "json", format: FormatJSON, data: `{"default_template": "default.html"}`},
+		{name: "toml", format: FormatTOML, data: "default_template = \"default.html\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ParseConfig([]byte(tt.data), tt.format)
+			if err != nil {
+				t.Fatalf("ParseConfig() error: %v", err)
+			}
+			if cfg.DefaultTemplate != "default.html" {
+				t.Errorf("DefaultTemplate = %q, want %q", cfg.DefaultTemplate, "default.html")
+			}
+			if cfg.ConfigFilePath != "" || cfg.Reloadable() {
+				t.Error("inline config should have no file and not be reloadable")
+			}
+		})
+	}
+
+	t.Setenv("TMPL_CGI__DEFAULT_TEMPLATE", "other.html")
+	cfg, err := ParseConfig([]byte("default_template: \"default.html\"\n"), "")
+	if err != nil {
+		t.Fatalf("ParseConfig() error: %v", err)
+	}
+	if cfg.DefaultTemplate != "other.html" {
+		t.Errorf("DefaultTemplate = %q, want override %q", cfg.DefaultTemplate, "other.html")
+	}
+}
+
+func TestConfig_TmplInfo(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(`default_template: "default.html"`), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := ParseConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	info := cfg.TmplInfo()
+	if info.ConfigHash == "" || info.ConfigHash != cfg.Version {
+		t.Errorf("ConfigHash = %q, want config version %q", info.ConfigHash, cfg.Version)
+	}
+	if info.Version == "" {
+		t.Error("Version is empty")
+	}
+	if info.StartTime.IsZero() || info.StartTime.After(time.Now()) {
+		t.Errorf("StartTime = %v", info.StartTime)
+	}
+}
+
+func TestFuncMap_Random(t *testing.T) {
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "random.html"),
+		[]byte(`{{randomToken 16}}|{{ulid}}|{{uuidv7}}|{{uuidv4}}|{{randAlphaNum 12}}|{{randInt 1 2}}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	config := &Config{ConfigFilePath: filepath.Join(tempDir, "config.yaml")}
+	tmpl, err := config.LoadTemplate("random.html")
+	if err != nil {
+		t.Fatalf("LoadTemplate() error: %v", err)
+	}
+	var buf strings.Builder
+	if err = tmpl.Execute(&buf, TemplateData{}); err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+	parts := strings.Split(buf.String(), "|")
+	wantLens := []int{22, 26, 36, 36, 12, 1}
+	if len(parts) != len(wantLens) {
+		t.Fatalf("output = %q, want %d parts", buf.String(), len(wantLens))
+	}
+	for i, want := range wantLens {
+		if len(parts[i]) != want {
+			t.Errorf("part %d = %q, want length %d", i, parts[i], want)
+		}
+	}
+	if parts[2][14] != '7' {
+		t.Errorf("uuidv7 = %q, want version 7", parts[2])
+	}
+	if parts[5] != "1" {
+		t.Errorf("randInt 1 2 = %q, want 1", parts[5])
+	}
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// dataFileExtensions are the extensions of the data files found in the data
+// directory
+var dataFileExtensions = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+	".toml": true,
+	".csv":  true,
+}
+
+// DataFilePaths returns the resolved paths of the local data files,
+// followed by those in the data directory
+func (c *Config) DataFilePaths() []string {
+	paths := make([]string, 0, len(c.DataFiles))
+	for _, file := range c.DataFiles {
+		if path := c.resolvePath(file); !isRemote(path) {
+			paths = append(paths, path)
+		}
+	}
+	dirFiles, _ := c.dataDirFiles()
+	return append(paths, dirFiles...)
+}
+
+// dataDirFiles returns the data files in the data directory tree in sorted
+// order, skipping hidden files and directories
+func (c *Config) dataDirFiles() ([]string, error) {
+	if c.DataDir == "" {
+		return nil, nil
+	}
+	var files []string
+	root := c.resolvePath(c.DataDir)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && dataFileExtensions[strings.ToLower(filepath.Ext(path))] {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// dataFileName returns the data key a data file is exposed under: its file
+// name without the extension
+func dataFileName(file string) string {
+	if isRemote(file) {
+		file = urlPath(file)
+	}
+	base := filepath.Base(file)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// loadDataFiles reads the data files into the data block, each under its
+// name, and the files of the data directory into nested maps mirroring the
+// directory tree. Their contents are folded into the config version.
+func (c *Config) loadDataFiles() error {
+	if len(c.DataFiles) == 0 && c.DataDir == "" {
+		return nil
+	}
+	if c.Data == nil {
+		c.Data = map[string]any{}
+	}
+	data, ok := c.Data.(map[string]any)
+	if !ok {
+		return fmt.Errorf("data_files: data must be a map")
+	}
+	h := sha256.New()
+	h.Write([]byte(c.Version))
+	load := func(path, name string) (any, error) {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		h.Write([]byte(path))
+		h.Write([]byte{0})
+		h.Write(raw)
+		v, err := decodeDataFile(raw, path)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", name, err)
+		}
+		return v, nil
+	}
+
+	for _, path := range c.DataFiles {
+		name := dataFileName(path)
+		if _, ok := data[name]; ok {
+			return fmt.Errorf("data_files: %s conflicts with data.%s", path, name)
+		}
+		file := c.resolvePath(path)
+		if isRemote(file) {
+			var err error
+			if file, err = c.remoteFile(file); err != nil {
+				return fmt.Errorf("data_files: %w", err)
+			}
+		}
+		v, err := load(file, path)
+		if err != nil {
+			return fmt.Errorf("data_files: %w", err)
+		}
+		data[name] = v
+	}
+
+	files, err := c.dataDirFiles()
+	if err != nil {
+		return fmt.Errorf("data_dir: %w", err)
+	}
+	root := c.resolvePath(c.DataDir)
+	for _, path := range files {
+		rel, _ := filepath.Rel(root, path)
+		keys := strings.Split(filepath.ToSlash(rel), "/")
+		keys[len(keys)-1] = dataFileName(rel)
+		parent := data
+		for n, key := range keys[:len(keys)-1] {
+			child, ok := parent[key].(map[string]any)
+			if !ok {
+				if _, exists := parent[key]; exists {
+					return fmt.Errorf("data_dir: %s conflicts with data.%s", rel, strings.Join(keys[:n+1], "."))
+				}
+				child = map[string]any{}
+				parent[key] = child
+			}
+			parent = child
+		}
+		key := keys[len(keys)-1]
+		if _, exists := parent[key]; exists {
+			return fmt.Errorf("data_dir: %s conflicts with data.%s", rel, strings.Join(keys, "."))
+		}
+		if parent[key], err = load(path, rel); err != nil {
+			return fmt.Errorf("data_dir: %w", err)
+		}
+	}
+	c.Version = hex.EncodeToString(h.Sum(nil))[:12]
+	return nil
+}
+
+// decodeDataFile decodes a data file in the format implied by its extension.
+// Values are normalized through YAML so that they have the same types as in
+// the config's data block.
+func decodeDataFile(raw []byte, path string) (any, error) {
+	var v any
+	var err error
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		return decodeCSV(raw)
+	case ".json":
+		if len(bytes.TrimSpace(raw)) > 0 {
+			err = json.Unmarshal(raw, &v)
+		}
+	case ".toml":
+		err = toml.Unmarshal(raw, &v)
+	case ".yaml", ".yml":
+		// YAML already decodes to the normalized types
+		if err := yaml.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unknown data file extension '%s'", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return normalizeData(v)
+}
+
+// normalizeData passes decoded data through YAML, so that maps, lists and
+// numbers have the types the config's data block has
+func normalizeData(v any) (any, error) {
+	normalized, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	err = yaml.Unmarshal(normalized, &out)
+	return out, err
+}
+
+// decodeCSV decodes CSV with a header row into a list of maps from the
+// column names to the values of each row
+func decodeCSV(raw []byte) ([]any, error) {
+	records, err := csv.NewReader(bytes.NewReader(raw)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	rows := []any{}
+	if len(records) == 0 {
+		return rows, nil
+	}
+	header := records[0]
+	for _, record := range records[1:] {
+		row := make(map[string]any, len(header))
+		for i, name := range header {
+			row[name] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigFile_DataFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"data/products.csv": "sku,name\nA1,Widget\nB2,\"Gadget, large\"\n",
+		"data/team.json":    `[{"name": "Ada", "age": 36}]`,
+		"data/menu.yaml":    "main: [home, blog]\n",
+		"data/hours.toml":   "[weekday]\nopen = 9\n",
+		"config.yaml": `default_template: "default.html"
+data:
+  site: "Example"
+data_files:
+  - data/products.csv
+  - data/team.json
+  - data/menu.yaml
+  - data/hours.toml
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	configPath := filepath.Join(tempDir, "config.yaml")
+	config, err := ParseConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	expected := map[string]any{
+		"site": "Example",
+		"products": []any{
+			map[string]any{"sku": "A1", "name": "Widget"},
+			map[string]any{"sku": "B2", "name": "Gadget, large"},
+		},
+		"team":  []any{map[string]any{"name": "Ada", "age": 36}},
+		"menu":  map[string]any{"main": []any{"home", "blog"}},
+		"hours": map[string]any{"weekday": map[string]any{"open": 9}},
+	}
+	if !reflect.DeepEqual(config.Data, expected) {
+		t.Errorf("Data = %#v, want %#v", config.Data, expected)
+	}
+
+	// Editing a data file changes the config version
+	if err = os.WriteFile(filepath.Join(tempDir, "data/menu.yaml"), []byte("main: [home]\n"), 0644); err != nil {
+		t.Fatalf("Failed to update data file: %v", err)
+	}
+	updated, err := ParseConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	if updated.Version == config.Version {
+		t.Error("Version did not change with the data file")
+	}
+}
+
+func TestParseConfigFile_DataFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:    "missing file",
+			config:  "data_files: [missing.json]\n",
+			wantErr: "data_files",
+		},
+		{
+			name:    "name conflict",
+			config:  "data: {menu: []}\ndata_files: [menu.yaml]\n",
+			files:   map[string]string{"menu.yaml": "a: 1\n"},
+			wantErr: "conflicts with data.menu",
+		},
+		{
+			name:    "data not a map",
+			config:  "data: [1, 2]\ndata_files: [menu.yaml]\n",
+			files:   map[string]string{"menu.yaml": "a: 1\n"},
+			wantErr: "data must be a map",
+		},
+		{
+			name:    "unknown extension",
+			config:  "data_files: [menu.ini]\n",
+			files:   map[string]string{"menu.ini": "a=1\n"},
+			wantErr: "unknown data file extension",
+		},
+		{
+			name:    "malformed JSON",
+			config:  "data_files: [team.json]\n",
+			files:   map[string]string{"team.json": "[{"},
+			wantErr: "parsing team.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("Failed to create file: %v", err)
+				}
+			}
+			configPath := filepath.Join(tempDir, "config.yaml")
+			if err := os.WriteFile(configPath, []byte(tt.config), 0644); err != nil {
+				t.Fatalf("Failed to create config: %v", err)
+			}
+			_, err := ParseConfigFile(configPath)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseConfigFile() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseConfigFile_DataDir(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"data/authors/jane.yaml":     "name: Jane\n",
+		"data/authors/joe.json":      `{"name": "Joe"}`,
+		"data/site.toml":             "title = \"Example\"\n",
+		"data/authors/.draft.yaml":   "name: Hidden\n",
+		"data/.git/config.yaml":      "x: 1\n",
+		"data/authors/notes.txt":     "ignored",
+		"data/nav/footer/links.yaml": "[about, contact]\n",
+		"config.yaml":                "data: {owner: Ada}\ndata_dir: data\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	config, err := ParseConfigFile(filepath.Join(tempDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	expected := map[string]any{
+		"owner": "Ada",
+		"authors": map[string]any{
+			"jane": map[string]any{"name": "Jane"},
+			"joe":  map[string]any{"name": "Joe"},
+		},
+		"site": map[string]any{"title": "Example"},
+		"nav":  map[string]any{"footer": map[string]any{"links": []any{"about", "contact"}}},
+	}
+	if !reflect.DeepEqual(config.Data, expected) {
+		t.Errorf("Data = %#v, want %#v", config.Data, expected)
+	}
+	if n := len(config.DataFilePaths()); n != 4 {
+		t.Errorf("DataFilePaths() has %d files, want 4", n)
+	}
+
+	if err = os.WriteFile(filepath.Join(tempDir, "config.yaml"), []byte("data: {site: x}\ndata_dir: data\n"), 0644); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+	if _, err = ParseConfigFile(filepath.Join(tempDir, "config.yaml")); err == nil || !strings.Contains(err.Error(), "conflicts with data.site") {
+		t.Errorf("ParseConfigFile() error = %v, want conflict with data.site", err)
+	}
+}
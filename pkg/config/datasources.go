@@ -0,0 +1,150 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/fetch"
+)
+
+// DefaultDataSourceTTL is how long a data source's response is reused
+const DefaultDataSourceTTL = 5 * time.Minute
+
+// Data source types
+const (
+	DataSourceHTTP     = "http"
+	DataSourceSQLite   = "sqlite"
+	DataSourcePostgres = "postgres"
+	DataSourceMySQL    = "mysql"
+	DataSourceSecrets  = "secrets"
+)
+
+// DataSource is data exposed in the data block under its name: a remote
+// JSON document, the rows of a query against a SQLite file or a database
+// server, or the contents of secret files. HTTP responses are cached on
+// disk, so that CGI processes share them. In the standalone server a source
+// with a refresh interval is reloaded in the background instead.
+type DataSource struct {
+	Name     string        `yaml:"name"`
+	Type     string        `yaml:"type,omitempty"`
+	URL      string        `yaml:"url,omitempty"`
+	TTL      time.Duration `yaml:"ttl,omitempty"`
+	CacheDir string        `yaml:"cache_dir,omitempty"`
+	MaxSize  int64         `yaml:"max_size,omitempty"`
+	Refresh  time.Duration `yaml:"refresh,omitempty"`
+
+	Path    string        `yaml:"path,omitempty"`
+	DSNEnv  string        `yaml:"dsn_env,omitempty"`
+	Query   string        `yaml:"query,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	Files   map[string]string `yaml:"files,omitempty"`
+	FileEnv []string          `yaml:"file_env,omitempty"`
+}
+
+// Load fetches and decodes an HTTP data source, reusing a cached response
+// younger than the TTL
+func (s *DataSource) Load() (any, error) {
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = DefaultDataSourceTTL
+	}
+	body, err := fetch.Cached(s.URL, fetch.Options{CacheDir: s.CacheDir, TTL: ttl, MaxSize: s.MaxSize})
+	if err != nil {
+		return nil, fmt.Errorf("data source %s: %w", s.Name, err)
+	}
+	var v any
+	if err = json.Unmarshal(body, &v); err != nil {
+		return nil, fmt.Errorf("data source %s: decoding JSON: %w", s.Name, err)
+	}
+	return normalizeData(v)
+}
+
+// loadDataSource loads a data source of any type
+func (c *Config) loadDataSource(s *DataSource) (any, error) {
+	switch s.Type {
+	case "", DataSourceHTTP:
+		return s.Load()
+	case DataSourceSecrets:
+		return c.loadSecrets(s)
+	}
+	return c.loadSQL(s)
+}
+
+// withDataSources returns data with each data source added under its name.
+// A source that cannot be loaded is logged and set to nil, so that pages
+// can render a fallback instead of failing.
+func (c *Config) withDataSources(data any) any {
+	if len(c.DataSources) == 0 {
+		return data
+	}
+	base, _ := data.(map[string]any)
+	out := make(map[string]any, len(base)+len(c.DataSources))
+	for k, v := range base {
+		out[k] = v
+	}
+	for i := range c.DataSources {
+		s := &c.DataSources[i]
+		if v, ok := c.refresh.value(s.Name); ok {
+			out[s.Name] = v
+			continue
+		}
+		v, err := c.loadDataSource(s)
+		if err != nil {
+			log.Print(err)
+		}
+		out[s.Name] = v
+	}
+	return out
+}
+
+// validateDataSources checks the data sources
+func (c *Config) validateDataSources() error {
+	if len(c.DataSources) == 0 {
+		return nil
+	}
+	data, ok := c.Data.(map[string]any)
+	if !ok && c.Data != nil {
+		return fmt.Errorf("data_sources: data must be a map")
+	}
+	seen := map[string]bool{}
+	for _, s := range c.DataSources {
+		if s.Name == "" {
+			return fmt.Errorf("data_sources: name is required")
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("data_sources: duplicate name '%s'", s.Name)
+		}
+		seen[s.Name] = true
+		if s.Refresh != 0 && s.Refresh < MinDataRefresh {
+			return fmt.Errorf("data_sources: %s: refresh must be at least %s", s.Name, MinDataRefresh)
+		}
+		if _, ok := data[s.Name]; ok {
+			return fmt.Errorf("data_sources: %s conflicts with data.%s", s.Name, s.Name)
+		}
+		switch s.Type {
+		case "", DataSourceHTTP:
+			if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("data_sources: %s: url must be an http(s) URL", s.Name)
+			}
+		case DataSourceSQLite:
+			if s.Path == "" || s.Query == "" {
+				return fmt.Errorf("data_sources: %s: path and query are required", s.Name)
+			}
+		case DataSourceSecrets:
+			if s.Path == "" && len(s.Files) == 0 && len(s.FileEnv) == 0 {
+				return fmt.Errorf("data_sources: %s: path, files or file_env is required", s.Name)
+			}
+		case DataSourcePostgres, DataSourceMySQL:
+			if s.DSNEnv == "" || s.Query == "" {
+				return fmt.Errorf("data_sources: %s: dsn_env and query are required", s.Name)
+			}
+		default:
+			return fmt.Errorf("data_sources: %s: unknown type '%s'", s.Name, s.Type)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDataFor_DataSources(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/weather":
+			_, _ = w.Write([]byte(`{"temp": 21, "sky": "clear"}`))
+		case "/bad":
+			_, _ = w.Write([]byte(`not json`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	config := &Config{
+		Data: map[string]any{"site": "Example"},
+		DataSources: []DataSource{
+			{Name: "weather", URL: ts.URL + "/weather", TTL: time.Hour, CacheDir: cacheDir},
+			{Name: "broken", URL: ts.URL + "/bad", CacheDir: cacheDir},
+			{Name: "missing", URL: ts.URL + "/missing", CacheDir: cacheDir},
+		},
+	}
+	if err := config.validateDataSources(); err != nil {
+		t.Fatalf("validateDataSources() error: %v", err)
+	}
+
+	expected := map[string]any{
+		"site":    "Example",
+		"weather": map[string]any{"temp": 21, "sky": "clear"},
+		"broken":  nil,
+		"missing": nil,
+	}
+	for i := 0; i < 2; i++ {
+		data, err := config.DataFor(nil, "")
+		if err != nil {
+			t.Fatalf("DataFor() error: %v", err)
+		}
+		if !reflect.DeepEqual(data, expected) {
+			t.Errorf("DataFor() = %v, want %v", data, expected)
+		}
+	}
+	// The weather and broken responses are cached; the 404 is fetched again
+	if n := hits.Load(); n != 4 {
+		t.Errorf("server hit %d times, want 4", n)
+	}
+	if _, ok := config.Data.(map[string]any)["weather"]; ok {
+		t.Error("DataFor() modified the config data")
+	}
+}
+
+func TestValidateDataSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    any
+		sources []DataSource
+		wantErr string
+	}{
+		{name: "missing name", sources: []DataSource{{URL: "https://example.com"}}, wantErr: "name is required"},
+		{name: "duplicate", sources: []DataSource{{Name: "a", URL: "https://example.com"}, {Name: "a", URL: "https://example.com"}}, wantErr: "duplicate"},
+		{name: "conflict", data: map[string]any{"a": 1}, sources: []DataSource{{Name: "a", URL: "https://example.com"}}, wantErr: "conflicts with data.a"},
+		{name: "bad url", sources: []DataSource{{Name: "a", URL: "file:///etc/passwd"}}, wantErr: "http(s) URL"},
+		{name: "refresh too short", sources: []DataSource{{Name: "a", URL: "https://example.com", Refresh: time.Millisecond}}, wantErr: "refresh must be at least"},
+		{name: "data not a map", data: []any{1}, sources: []DataSource{{Name: "a", URL: "https://example.com"}}, wantErr: "data must be a map"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Data: tt.data, DataSources: tt.sources}
+			if err := config.validateDataSources(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateDataSources() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStartDataRefresh(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		_, _ = fmt.Fprintf(w, `{"version": %d}`, n)
+	}))
+	defer ts.Close()
+
+	config := &Config{
+		DataSources: []DataSource{
+			{Name: "live", URL: ts.URL, TTL: time.Hour, CacheDir: filepath.Join(t.TempDir(), "cache"), Refresh: 20 * time.Millisecond},
+		},
+	}
+	stop := config.StartDataRefresh()
+	version := func() int {
+		data, err := config.DataFor(nil, "")
+		if err != nil {
+			t.Fatalf("DataFor() error: %v", err)
+		}
+		v, _ := data.(map[string]any)["live"].(map[string]any)
+		n, _ := v["version"].(int)
+		return n
+	}
+	// The refresh interval overrides the longer TTL
+	deadline := time.Now().Add(5 * time.Second)
+	for version() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("data source not refreshed, version %d", version())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Requests read the refreshed value without fetching
+	stop()
+	stopped := hits.Load()
+	for i := 0; i < 3; i++ {
+		version()
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := hits.Load(); n != stopped {
+		t.Errorf("server hit %d times after stopping, want %d", n, stopped)
+	}
+}
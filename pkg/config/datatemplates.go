@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+// maxDataTemplatePasses bounds the passes over templated data values. A
+// value referring to another templated value takes a pass more; values
+// still templated after the last pass refer to each other.
+const maxDataTemplatePasses = 10
+
+// expandDataTemplates evaluates the templated strings of the data blocks,
+// once, with sprig's functions (including env) and the data itself in
+// scope. Route data sees the global data merged with its own. Only the
+// config's own data is expanded: data files, which may come from remote
+// hosts, must not be able to read the environment.
+func (c *Config) expandDataTemplates() error {
+	if !c.DataTemplates {
+		return nil
+	}
+	data, err := expandData("data", c.Data, func(v any) any { return v })
+	if err != nil {
+		return err
+	}
+	c.Data = data
+	for i := range c.Templates {
+		t := &c.Templates[i]
+		if t.Data == nil {
+			continue
+		}
+		lists := c.DataMerge.Lists
+		if t.DataMerge != nil && t.DataMerge.Lists != "" {
+			lists = t.DataMerge.Lists
+		}
+		path := fmt.Sprintf("templates[%d].data", i)
+		if t.Data, err = expandData(path, t.Data, func(v any) any { return mergeData(c.Data, v, lists) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandData evaluates the templated strings of v in passes until none is
+// left, with scope giving the data in scope for the current v
+func expandData(path string, v any, scope func(any) any) (any, error) {
+	for pass := 0; pass < maxDataTemplatePasses; pass++ {
+		if !hasDataTemplate(v) {
+			return v, nil
+		}
+		var err error
+		if v, err = expandValue(path, v, scope(v)); err != nil {
+			return nil, err
+		}
+	}
+	if hasDataTemplate(v) {
+		return nil, fmt.Errorf("%s: templated values still unresolved after %d passes; do they refer to each other?", path, maxDataTemplatePasses)
+	}
+	return v, nil
+}
+
+// hasDataTemplate reports whether v holds a templated string
+func hasDataTemplate(v any) bool {
+	switch v := v.(type) {
+	case string:
+		return strings.Contains(v, "{{")
+	case map[string]any:
+		for _, e := range v {
+			if hasDataTemplate(e) {
+				return true
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if hasDataTemplate(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expandValue returns a copy of v with its templated strings evaluated
+// against scope
+func expandValue(path string, v any, scope any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+		tmpl, err := template.New(path).Funcs(sprig.TxtFuncMap()).Option("missingkey=error").Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		var b strings.Builder
+		if err = tmpl.Execute(&b, scope); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return b.String(), nil
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			x, err := expandValue(path+"."+k, e, scope)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = x
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			x, err := expandValue(fmt.Sprintf("%s[%d]", path, i), e, scope)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = x
+		}
+		return out, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig_DataTemplates(t *testing.T) {
+	t.Setenv("TEST_SITE_HOST", "example.org")
+	config, err := ParseConfig([]byte(`
+default_template: default.html
+data_templates: true
+data:
+  feed_url: "{{ .base_url }}/feed.xml"
+  base_url: "https://{{ env \"TEST_SITE_HOST\" }}"
+  links:
+    - "{{ .base_url }}/about"
+    - plain
+  year: 2025
+templates:
+  - pattern: ^/blog
+    template: blog.html
+    data:
+      blog_url: "{{ .base_url }}/{{ .section }}"
+      section: blog
+`), "")
+	if err != nil {
+		t.Fatalf("ParseConfig() error: %v", err)
+	}
+	expected := map[string]any{
+		"feed_url": "https://example.org/feed.xml",
+		"base_url": "https://example.org",
+		"links":    []any{"https://example.org/about", "plain"},
+		"year":     2025,
+	}
+	if !reflect.DeepEqual(config.Data, expected) {
+		t.Errorf("Data = %v, want %v", config.Data, expected)
+	}
+	route := map[string]any{"blog_url": "https://example.org/blog", "section": "blog"}
+	if !reflect.DeepEqual(config.Templates[0].Data, route) {
+		t.Errorf("route data = %v, want %v", config.Templates[0].Data, route)
+	}
+}
+
+func TestParseConfig_DataTemplatesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "cycle", data: `{a: "{{ .b }}", b: "{{ .a }}"}`, wantErr: "still unresolved"},
+		{name: "missing key", data: `{a: "{{ .nope }}"}`, wantErr: "data.a"},
+		{name: "syntax", data: `{a: {b: "{{ .x"}}`, wantErr: "data.a.b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte("default_template: d.html\ndata_templates: true\ndata: "+tt.data+"\n"), "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	config, err := ParseConfig([]byte("default_template: d.html\ndata: {a: \"{{ .b }}\"}\n"), "")
+	if err != nil {
+		t.Fatalf("ParseConfig() error: %v", err)
+	}
+	if a := config.Data.(map[string]any)["a"]; a != "{{ .b }}" {
+		t.Errorf("data.a = %v, want it left alone without data_templates", a)
+	}
+}
+
+func TestParseConfigFile_DataTemplatesSkipDataFiles(t *testing.T) {
+	t.Setenv("TEST_DATA_SECRET", "hunter2")
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.yaml": "default_template: d.html\ndata_templates: true\ndata_files: [remote.yaml]\ndata: {site: \"{{ env \\\"TEST_DATA_SECRET\\\" | len }}\"}\n",
+		"remote.yaml": "leak: '{{ env \"TEST_DATA_SECRET\" }}'\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+	config, err := ParseConfigFile(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	expected := map[string]any{
+		"site":   "7",
+		"remote": map[string]any{"leak": `{{ env "TEST_DATA_SECRET" }}`},
+	}
+	if !reflect.DeepEqual(config.Data, expected) {
+		t.Errorf("Data = %v, want data files left alone", config.Data)
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"time"
+	_ "time/tzdata" // time zones for hosts without a zoneinfo database
+
+	"golang.org/x/text/language"
+	"gopkg.mhn.org/tmpl.cgi/pkg/i18n"
+)
+
+// dateLayouts are the layouts parseDate tries, in order. Times without a
+// zone are taken as UTC.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	time.DateTime,
+	"2006-01-02 15:04",
+	time.DateOnly,
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.ANSIC,
+}
+
+// Locale returns the active locale, or English if none is configured
+func (c *Config) Locale() language.Tag {
+	if c.LocaleName != "" {
+		if tag, err := i18n.ParseLocale(c.LocaleName); err == nil {
+			return tag
+		}
+	}
+	return language.MustParse(i18n.DefaultLocale)
+}
+
+// toTime converts a template value to a time: a time, a Unix timestamp in
+// seconds, or a string parseDate understands
+func toTime(v any) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, fmt.Errorf("nil time")
+		}
+		return *v, nil
+	case int:
+		return time.Unix(int64(v), 0), nil
+	case int64:
+		return time.Unix(v, 0), nil
+	case float64:
+		return time.Unix(int64(v), 0), nil
+	case string:
+		return parseDate(v)
+	}
+	return time.Time{}, fmt.Errorf("cannot use %T as a time", v)
+}
+
+// parseDate parses a date or timestamp in any of the common layouts
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse '%s' as a date", s)
+}
+
+// parseDateIn parses a date in a layout, taking times without a zone as
+// local to the named time zone
+func parseDateIn(zone, layout, s string) (time.Time, error) {
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.ParseInLocation(layout, s, loc)
+}
+
+// inZone returns a time converted to the named time zone
+func inZone(zone string, v any) (time.Time, error) {
+	t, err := toTime(v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(loc), nil
+}
+
+// dateIn formats a time in a layout as seen in the named time zone
+func dateIn(zone string, v any, layout string) (string, error) {
+	t, err := inZone(zone, v)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(layout), nil
+}
+
+// localizeDate formats a time in a date style of the active locale
+func (c *Config) localizeDate(style string, v any) (string, error) {
+	t, err := toTime(v)
+	if err != nil {
+		return "", err
+	}
+	return i18n.FormatDate(c.Locale(), t, style)
+}
+
+// validateLocale checks the configured locale
+func (c *Config) validateLocale() error {
+	if c.LocaleName == "" {
+		return nil
+	}
+	_, err := i18n.ParseLocale(c.LocaleName)
+	return err
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDateFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		locale   string
+		template string
+		expected string
+	}{
+		{
+			name:     "dateIn converts the zone",
+			template: `{{dateIn "Europe/Berlin" .Data.t "2006-01-02 15:04 MST"}}`,
+			expected: "2025-07-01 14:30 CEST",
+		},
+		{
+			name:     "dateIn parses strings",
+			template: `{{dateIn "America/New_York" "2025-01-15T12:00:00Z" "15:04"}}`,
+			expected: "07:00",
+		},
+		{
+			name:     "dateIn takes Unix timestamps",
+			template: `{{dateIn "UTC" 86400 "2006-01-02"}}`,
+			expected: "1970-01-02",
+		},
+		{
+			name:     "parseDateIn",
+			template: `{{(parseDateIn "Asia/Tokyo" "02.01.2006 15:04" "24.12.2025 09:00").UTC.Format "2006-01-02T15:04Z07:00"}}`,
+			expected: "2025-12-24T00:00Z",
+		},
+		{
+			name:     "parseDate",
+			template: `{{(parseDate "2025-03-04 05:06").Format "Jan 2 15:04"}}`,
+			expected: "Mar 4 05:06",
+		},
+		{
+			name:     "localizeDate defaults to English",
+			template: `{{.Data.t | localizeDate "long"}}`,
+			expected: "July 1, 2025",
+		},
+		{
+			name:     "localizeDate in the active locale",
+			locale:   "de-AT",
+			template: `{{.Data.t | inZone "Europe/Vienna" | localizeDate "full"}}`,
+			expected: "Dienstag, 1. Juli 2025",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tempDir, "page.html"), []byte(tt.template), 0644); err != nil {
+				t.Fatalf("Failed to create template: %v", err)
+			}
+			c := &Config{ConfigFilePath: filepath.Join(tempDir, "config.yaml"), LocaleName: tt.locale}
+			tmpl, err := c.LoadTemplate("page.html")
+			if err != nil {
+				t.Fatalf("LoadTemplate() error: %v", err)
+			}
+			data := TemplateData{Data: map[string]any{"t": time.Date(2025, 7, 1, 12, 30, 0, 0, time.UTC)}}
+			var buf strings.Builder
+			if err = tmpl.Execute(&buf, data); err != nil {
+				t.Fatalf("Execute() error: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("output = %q, want %q", buf.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestDateFunctions_Errors(t *testing.T) {
+	if _, err := dateIn("Mars/Olympus", time.Now(), "15:04"); err == nil {
+		t.Error("dateIn() succeeded for an unknown zone")
+	}
+	if _, err := parseDate("last Tuesday"); err == nil {
+		t.Error("parseDate() succeeded for an unparseable date")
+	}
+	if _, err := toTime([]string{"x"}); err == nil {
+		t.Error("toTime() succeeded for a list")
+	}
+	if err := (&Config{LocaleName: "???"}).validateLocale(); err == nil {
+		t.Error("validateLocale() succeeded for an invalid locale")
+	}
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Default response settings
+const (
+	DefaultContentType = "text/html"
+	DefaultCharset     = "utf-8"
+)
+
+// Defaults configures the headers of every response
+type Defaults struct {
+	ContentType  string            `yaml:"content_type,omitempty"`
+	Charset      string            `yaml:"charset,omitempty"`
+	CacheControl string            `yaml:"cache_control,omitempty"`
+	Headers      map[string]string `yaml:"headers,omitempty"`
+}
+
+// templateContentTypes are the content types implied by template file
+// extensions
+var templateContentTypes = map[string]string{
+	".html": "text/html",
+	".htm":  "text/html",
+	".txt":  "text/plain",
+	".json": "application/json",
+	".xml":  "application/xml",
+	".csv":  "text/csv",
+	".css":  "text/css",
+	".js":   "text/javascript",
+	".svg":  "image/svg+xml",
+}
+
+// ContentTypeHeader returns the Content-Type of pages rendered from
+// templates with no more specific type. The charset is added unless the
+// content type already has one.
+func (d *Defaults) ContentTypeHeader() string {
+	return d.withCharset(d.ContentType)
+}
+
+// ContentTypeFor returns the Content-Type of a page rendered from the named
+// template for a route: the route's content_type, else the type implied by
+// the template's extension, else the default
+func (c *Config) ContentTypeFor(t *Template, templateName string) string {
+	if t != nil && t.ContentType != "" {
+		return c.Defaults.withCharset(t.ContentType)
+	}
+	if contentType, ok := templateContentTypes[strings.ToLower(path.Ext(templateName))]; ok && !isInline(templateName) {
+		return c.Defaults.withCharset(contentType)
+	}
+	return c.Defaults.ContentTypeHeader()
+}
+
+// withCharset adds the configured charset to a content type, or returns
+// the default content type if it is empty
+func (d *Defaults) withCharset(contentType string) string {
+	if contentType == "" {
+		contentType = DefaultContentType
+		if d.ContentType != "" {
+			contentType = d.ContentType
+		}
+	}
+	if strings.Contains(strings.ToLower(contentType), "charset=") {
+		return contentType
+	}
+	charset := d.Charset
+	if charset == "" {
+		charset = DefaultCharset
+	}
+	return contentType + "; charset=" + charset
+}
+
+// SetHeaders sets the configured Cache-Control and extra headers
+func (d *Defaults) SetHeaders(h http.Header) {
+	for name, value := range d.Headers {
+		h.Set(name, value)
+	}
+	if d.CacheControl != "" {
+		h.Set("Cache-Control", d.CacheControl)
+	}
+}
+
+// ClearCaching removes the Cache-Control and Expires headers set by the
+// defaults, so that caches do not keep error responses as long as pages.
+// Values set by a route are kept.
+func (d *Defaults) ClearCaching(h http.Header) {
+	for name, value := range d.Headers {
+		if name = http.CanonicalHeaderKey(name); (name == "Cache-Control" || name == "Expires") && h.Get(name) == value {
+			h.Del(name)
+		}
+	}
+	if d.CacheControl != "" && h.Get("Cache-Control") == d.CacheControl {
+		h.Del("Cache-Control")
+	}
+}
+
+// SetHeaders sets the route's headers, overriding the defaults
+func (t *Template) SetHeaders(h http.Header) {
+	for name, value := range t.Headers {
+		h.Set(name, value)
+	}
+}
+
+// Validate checks the response defaults
+func (d *Defaults) Validate() error {
+	if _, _, err := mime.ParseMediaType(d.ContentTypeHeader()); err != nil {
+		return fmt.Errorf("defaults: invalid content type: %w", err)
+	}
+	for name := range d.Headers {
+		if !validHeaderName(name) {
+			return fmt.Errorf("defaults: invalid header name '%s'", name)
+		}
+		if strings.EqualFold(name, "Content-Type") {
+			return fmt.Errorf("defaults: set content_type instead of a Content-Type header")
+		}
+	}
+	return nil
+}
+
+// validHeaderName reports whether name is a valid HTTP header field name
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r > 0x7e || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDefaults_ContentTypeHeader(t *testing.T) {
+	tests := []struct {
+		defaults Defaults
+		expected string
+	}{
+		{Defaults{}, "text/html; charset=utf-8"},
+		{Defaults{ContentType: "text/plain"}, "text/plain; charset=utf-8"},
+		{Defaults{Charset: "iso-8859-1"}, "text/html; charset=iso-8859-1"},
+		{Defaults{ContentType: "text/xml; charset=us-ascii", Charset: "utf-8"}, "text/xml; charset=us-ascii"},
+	}
+	for _, tt := range tests {
+		if got := tt.defaults.ContentTypeHeader(); got != tt.expected {
+			t.Errorf("ContentTypeHeader() = %q, want %q", got, tt.expected)
+		}
+	}
+}
+
+func TestDefaults_SetHeaders(t *testing.T) {
+	d := Defaults{
+		CacheControl: "no-cache",
+		Headers:      map[string]string{"cache-control": "max-age=60", "Referrer-Policy": "no-referrer"},
+	}
+	h := http.Header{}
+	d.SetHeaders(h)
+	if h.Get("Cache-Control") != "no-cache" || h.Get("Referrer-Policy") != "no-referrer" {
+		t.Errorf("SetHeaders() = %v", h)
+	}
+}
+
+func TestDefaults_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults Defaults
+		ok       bool
+	}{
+		{"Empty", Defaults{}, true},
+		{"Headers", Defaults{Headers: map[string]string{"X-Frame-Options": "DENY"}}, true},
+		{"Bad content type", Defaults{ContentType: "text/"}, false},
+		{"Bad header name", Defaults{Headers: map[string]string{"X Frame": "DENY"}}, false},
+		{"Content-Type header", Defaults{Headers: map[string]string{"content-type": "text/plain"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.defaults.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestContentTypeFor(t *testing.T) {
+	tests := []struct {
+		name         string
+		defaults     Defaults
+		route        *Template
+		templateName string
+		expected     string
+	}{
+		{"HTML", Defaults{}, nil, "default.html", "text/html; charset=utf-8"},
+		{"JSON", Defaults{}, nil, "api/users.json", "application/json; charset=utf-8"},
+		{"XML upper case", Defaults{}, nil, "sitemap.XML", "application/xml; charset=utf-8"},
+		{"Text with charset", Defaults{Charset: "iso-8859-1"}, nil, "robots.txt", "text/plain; charset=iso-8859-1"},
+		{"Unknown extension", Defaults{ContentType: "application/xhtml+xml"}, nil, "page.tmpl", "application/xhtml+xml; charset=utf-8"},
+		{"Extension beats default", Defaults{ContentType: "application/xhtml+xml"}, nil, "data.json", "application/json; charset=utf-8"},
+		{"Route override", Defaults{}, &Template{ContentType: "application/ld+json"}, "data.json", "application/ld+json; charset=utf-8"},
+		{"Inline", Defaults{}, &Template{Content: "ok"}, (&Template{Content: "ok"}).TemplateName(), "text/html; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Defaults: tt.defaults}
+			if got := config.ContentTypeFor(tt.route, tt.templateName); got != tt.expected {
+				t.Errorf("ContentTypeFor() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
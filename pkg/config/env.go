@@ -0,0 +1,261 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvDataKey is the data key the env_data variables are exposed under
+const EnvDataKey = "env"
+
+// EnvPrefix starts the names of environment variables that override config
+// fields, with path segments separated by double underscores:
+// TMPL_CGI__DEFAULT_TEMPLATE, TMPL_CGI__TEMPLATES__0__PATTERN
+const EnvPrefix = "TMPL_CGI__"
+
+// envOverrides returns the config overrides found in environ, sorted by name
+func envOverrides(environ []string) [][2]string {
+	var overrides [][2]string
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if ok && strings.HasPrefix(name, EnvPrefix) && len(name) > len(EnvPrefix) {
+			overrides = append(overrides, [2]string{name, value})
+		}
+	}
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i][0] < overrides[j][0] })
+	return overrides
+}
+
+// applyEnv sets the fields named by the overrides in the decoded config
+// document, creating intermediate maps and list entries as needed
+func applyEnv(doc map[string]any, overrides [][2]string) error {
+	for _, o := range overrides {
+		path := strings.Split(strings.ToLower(strings.TrimPrefix(o[0], EnvPrefix)), "__")
+		for _, seg := range path {
+			if seg == "" {
+				return fmt.Errorf("environment variable %s: empty path segment", o[0])
+			}
+		}
+		// doc is non-nil, so setPath updates it in place
+		if _, err := setPath(doc, path, envValue(fieldType(path), o[1])); err != nil {
+			return fmt.Errorf("environment variable %s: %w", o[0], err)
+		}
+	}
+	return nil
+}
+
+// setPath returns node with the value at path replaced
+func setPath(node any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	seg := path[0]
+	if idx, err := strconv.Atoi(seg); err == nil {
+		list, ok := node.([]any)
+		if node != nil && !ok {
+			return nil, fmt.Errorf("'%s' indexes a value that is not a list", seg)
+		}
+		if idx < 0 || idx > 1000 {
+			return nil, fmt.Errorf("list index %d out of range", idx)
+		}
+		for len(list) <= idx {
+			list = append(list, nil)
+		}
+		child, err := setPath(list[idx], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		list[idx] = child
+		return list, nil
+	}
+
+	m, ok := node.(map[string]any)
+	if node != nil && !ok {
+		return nil, fmt.Errorf("'%s' names a field of a value that is not a map", seg)
+	}
+	if m == nil {
+		m = map[string]any{}
+	}
+	// Environment variable names are upper case, so match keys that
+	// already exist in the document regardless of case
+	key := seg
+	for k := range m {
+		if strings.EqualFold(k, seg) {
+			key = k
+			break
+		}
+	}
+	child, err := setPath(m[key], path[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	m[key] = child
+	return m, nil
+}
+
+// fieldType returns the type of the config field at path, or nil if the
+// path leads into an untyped value such as data, or to no field at all
+func fieldType(path []string) reflect.Type {
+	t := reflect.TypeOf(Config{})
+	for _, seg := range path {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			var next reflect.Type
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+				if name == "" {
+					name = strings.ToLower(f.Name)
+				}
+				if f.IsExported() && name != "-" && strings.EqualFold(name, seg) {
+					next = f.Type
+					break
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			t = next
+		case reflect.Slice, reflect.Array:
+			if _, err := strconv.Atoi(seg); err != nil {
+				return nil
+			}
+			t = t.Elem()
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return nil
+		}
+	}
+	if t.Kind() == reflect.Interface {
+		return nil
+	}
+	return t
+}
+
+// envValue interprets an override for a field of type t. String fields
+// take the text as is, so tokens such as 0123 are not read as numbers.
+// Other fields read it as a YAML scalar, so that numbers, booleans and
+// durations work; flow-style lists and maps ([a, b], {k: v}) are accepted
+// too. In untyped fields such as data, numbers and booleans keep their
+// type, but text that YAML would rewrite, such as 007 or 1e3, stays a
+// string.
+func envValue(t reflect.Type, s string) any {
+	if t != nil && t.Kind() == reflect.String {
+		return s
+	}
+	var v any
+	if err := yaml.Unmarshal([]byte(s), &v); err != nil || v == nil {
+		return s
+	}
+	switch v.(type) {
+	case map[string]any, []any:
+		if trimmed := strings.TrimSpace(s); !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+			return s
+		}
+	case string:
+		return s
+	default:
+		if t == nil && !strings.EqualFold(fmt.Sprint(v), s) {
+			return s
+		}
+	}
+	return v
+}
+
+// TemplateEnv returns the variables of environ named by the env allowlist,
+// such as the CGI meta-variables REMOTE_USER and SSL_CLIENT_S_DN. A name
+// ending in * allows every variable with that prefix.
+func (c *Config) TemplateEnv(environ []string) map[string]string {
+	env := map[string]string{}
+	if len(c.Env) == 0 {
+		return env
+	}
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		for _, allowed := range c.Env {
+			prefix, wildcard := strings.CutSuffix(allowed, "*")
+			if name == allowed || (wildcard && prefix != "" && strings.HasPrefix(name, prefix)) {
+				env[name] = value
+				break
+			}
+		}
+	}
+	return env
+}
+
+// EnvData maps environment variables into the data block: every variable
+// starting with Prefix, named without it in lower case, and the variables
+// named by Vars under their keys
+type EnvData struct {
+	Prefix string            `yaml:"prefix,omitempty"`
+	Vars   map[string]string `yaml:"vars,omitempty"`
+}
+
+// values returns the mapped variables of environ. Variables that are not
+// set are left out.
+func (e *EnvData) values(environ []string) map[string]any {
+	out := map[string]any{}
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		if e.Prefix != "" && strings.HasPrefix(name, e.Prefix) && len(name) > len(e.Prefix) {
+			out[strings.ToLower(strings.TrimPrefix(name, e.Prefix))] = value
+		}
+		for key, v := range e.Vars {
+			if v == name {
+				out[key] = value
+			}
+		}
+	}
+	return out
+}
+
+// withEnvData returns data with the env_data variables of the process
+// environment added under EnvDataKey
+func (c *Config) withEnvData(data any) any {
+	if c.EnvData.Prefix == "" && len(c.EnvData.Vars) == 0 {
+		return data
+	}
+	base, _ := data.(map[string]any)
+	out := make(map[string]any, len(base)+1)
+	for k, v := range base {
+		out[k] = v
+	}
+	out[EnvDataKey] = c.EnvData.values(os.Environ())
+	return out
+}
+
+// validateEnvData checks that env_data has a place in the data block
+func (c *Config) validateEnvData() error {
+	if c.EnvData.Prefix == "" && len(c.EnvData.Vars) == 0 {
+		return nil
+	}
+	data, ok := c.Data.(map[string]any)
+	if !ok && c.Data != nil {
+		return fmt.Errorf("env_data: data must be a map")
+	}
+	if _, ok := data[EnvDataKey]; ok {
+		return fmt.Errorf("env_data: conflicts with data.%s", EnvDataKey)
+	}
+	for key, name := range c.EnvData.Vars {
+		if key == "" || name == "" {
+			return fmt.Errorf("env_data: vars need a key and a variable name")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyEnv(t *testing.T) {
+	doc := map[string]any{
+		"default_template": "default.html",
+		"templates":        []any{map[string]any{"pattern": "^/a", "template": "a.html"}},
+		"data":             map[string]any{"siteName": "Old"},
+	}
+	err := applyEnv(doc, envOverrides([]string{
+		"TMPL_CGI__DEFAULT_TEMPLATE=home.html",
+		"TMPL_CGI__TEMPLATES__0__TEMPLATE=alpha.html",
+		"TMPL_CGI__TEMPLATES__1__PATTERN=^/b",
+		"TMPL_CGI__TEMPLATES__1__DRAFT=true",
+		"TMPL_CGI__DATA__SITENAME=New",
+		"TMPL_CGI__DATA__TAGS=[go, cgi]",
+		"TMPL_CGI__DATA__MOTTO=note: keep it simple",
+		"TMPL_CGI__DATA__ZIP=01234",
+		"TMPL_CGI__DATA__PRICE=1e3",
+		"TMPL_CGI__DATA__COUNT=42",
+		"TMPL_CGI__PREVIEW_TOKEN=0x1F",
+		"TMPL_CGI_PORT=8080",
+	}))
+	if err != nil {
+		t.Fatalf("applyEnv() error: %v", err)
+	}
+
+	want := map[string]any{
+		"default_template": "home.html",
+		"preview_token":    "0x1F",
+		"templates": []any{
+			map[string]any{"pattern": "^/a", "template": "alpha.html"},
+			map[string]any{"pattern": "^/b", "draft": true},
+		},
+		"data": map[string]any{
+			"siteName": "New",
+			"tags":     []any{"go", "cgi"},
+			"motto":    "note: keep it simple",
+			"zip":      "01234",
+			"price":    "1e3",
+			"count":    42,
+		},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("applyEnv() = %#v, want %#v", doc, want)
+	}
+}
+
+func TestApplyEnv_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+	}{
+		{"Index into map", "TMPL_CGI__DATA__0=x"},
+		{"Field of list", "TMPL_CGI__TEMPLATES__PATTERN=x"},
+		{"Empty segment", "TMPL_CGI__DATA____X=x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := map[string]any{"data": map[string]any{}, "templates": []any{}}
+			if err := applyEnv(doc, envOverrides([]string{tt.env})); err == nil {
+				t.Errorf("applyEnv(%s) should fail", tt.env)
+			}
+		})
+	}
+}
+
+func TestParseConfigFile_EnvOnly(t *testing.T) {
+	t.Setenv("TMPL_CGI__DEFAULT_TEMPLATE", "default.html")
+	t.Setenv("TMPL_CGI__TEMPLATES__0__PATTERN", "^/sale$")
+	t.Setenv("TMPL_CGI__TEMPLATES__0__TEMPLATE", "sale.html")
+	t.Setenv("TMPL_CGI__TEMPLATES__0__PUBLISH_DATE", "2025-11-28")
+	t.Setenv("TMPL_CGI__FEEDS__CACHE_TTL", "1h")
+	t.Setenv("TMPL_CGI__ADMIN__TOKEN", "0123")
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	config, err := ParseConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	if config.DefaultTemplate != "default.html" || len(config.Templates) != 1 {
+		t.Fatalf("ParseConfigFile() = %+v", config)
+	}
+	if config.Templates[0].Pattern != "^/sale$" || config.Templates[0].Template != "sale.html" {
+		t.Errorf("Templates[0] = %+v", config.Templates[0])
+	}
+	if !config.Templates[0].PublishDate.Equal(time.Date(2025, 11, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("PublishDate = %v", config.Templates[0].PublishDate)
+	}
+	if config.Feeds.CacheTTL != time.Hour {
+		t.Errorf("Feeds.CacheTTL = %v, want 1h", config.Feeds.CacheTTL)
+	}
+	// String fields keep leading zeros instead of being read as octal
+	if config.Admin.Token != "0123" {
+		t.Errorf("Admin.Token = %q, want 0123", config.Admin.Token)
+	}
+	if config.ConfigFilePath != configPath {
+		t.Errorf("ConfigFilePath = %s, want %s", config.ConfigFilePath, configPath)
+	}
+
+	// Overrides apply on top of the file when it exists
+	if err = os.WriteFile(configPath, []byte("default_template: \"file.html\"\ndata: {a: 1}\n"), 0644); err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	if config, err = ParseConfigFile(configPath); err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	if config.DefaultTemplate != "default.html" || !reflect.DeepEqual(config.Data, map[string]any{"a": 1}) {
+		t.Errorf("ParseConfigFile() = %+v", config)
+	}
+}
+
+func TestTemplateEnv(t *testing.T) {
+	environ := []string{
+		"REMOTE_USER=ann",
+		"SSL_CLIENT_S_DN=CN=Ann,O=Example",
+		"SSL_CLIENT_VERIFY=SUCCESS",
+		"SITE_REGION=eu",
+		"AWS_SECRET_ACCESS_KEY=hidden",
+	}
+	config := &Config{Env: []string{"REMOTE_USER", "SSL_CLIENT_*", "SITE_REGION", "AUTH_TYPE"}}
+	env := config.TemplateEnv(environ)
+	want := map[string]string{
+		"REMOTE_USER":       "ann",
+		"SSL_CLIENT_S_DN":   "CN=Ann,O=Example",
+		"SSL_CLIENT_VERIFY": "SUCCESS",
+		"SITE_REGION":       "eu",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("TemplateEnv() = %v, want %v", env, want)
+	}
+
+	if env = (&Config{}).TemplateEnv(environ); len(env) != 0 {
+		t.Errorf("TemplateEnv() without an allowlist = %v, want empty", env)
+	}
+
+	for _, name := range []string{"*", "", "A=B"} {
+		config = &Config{Env: []string{name}}
+		if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "env:") {
+			t.Errorf("Validate() with env %q error = %v, want env error", name, err)
+		}
+	}
+}
+
+func TestDataFor_EnvData(t *testing.T) {
+	t.Setenv("SITE_REGION", "eu-west")
+	t.Setenv("SITE_CDN_HOST", "cdn.example.com")
+	t.Setenv("SITE_", "ignored")
+	t.Setenv("DEPLOY_COMMIT", "abc123")
+	config := &Config{
+		Data: map[string]any{"title": "Example"},
+		EnvData: EnvData{
+			Prefix: "SITE_",
+			Vars:   map[string]string{"commit": "DEPLOY_COMMIT", "missing": "TMPL_CGI_TEST_UNSET"},
+		},
+	}
+	if err := config.validateEnvData(); err != nil {
+		t.Fatalf("validateEnvData() error: %v", err)
+	}
+	data, err := config.DataFor(nil, "")
+	if err != nil {
+		t.Fatalf("DataFor() error: %v", err)
+	}
+	expected := map[string]any{
+		"title": "Example",
+		"env":   map[string]any{"region": "eu-west", "cdn_host": "cdn.example.com", "commit": "abc123"},
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("DataFor() = %v, want %v", data, expected)
+	}
+	if _, ok := config.Data.(map[string]any)["env"]; ok {
+		t.Error("DataFor() modified the config data")
+	}
+
+	config.Data = map[string]any{"env": "taken"}
+	if err = config.validateEnvData(); err == nil || !strings.Contains(err.Error(), "conflicts with data.env") {
+		t.Errorf("validateEnvData() error = %v, want conflict", err)
+	}
+}
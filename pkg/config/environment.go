@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+)
+
+// EnvironmentVar names the environment variable holding the deployment
+// environment, such as dev or prod, that routes and data can be limited to
+const EnvironmentVar = "TMPL_CGI_ENV"
+
+// EnvironmentsKey marks a map in the data block as present only in the
+// listed environments
+const EnvironmentsKey = "_environments"
+
+// Environment returns the current deployment environment, or "" if none
+// is set
+func Environment() string {
+	return os.Getenv(EnvironmentVar)
+}
+
+// inEnvironment reports whether the route applies in the environment
+func (t *Template) inEnvironment(env string) bool {
+	return len(t.Environments) == 0 || slices.Contains(t.Environments, env)
+}
+
+// filterEnvironments returns a copy of v without the maps limited to other
+// environments, and whether v itself is present in env
+func filterEnvironments(v any, env string) (any, bool) {
+	switch v := v.(type) {
+	case map[string]any:
+		if envs, ok := v[EnvironmentsKey]; ok && !slices.Contains(nameList(envs), env) {
+			return nil, false
+		}
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			if k == EnvironmentsKey {
+				continue
+			}
+			if e, ok := filterEnvironments(e, env); ok {
+				out[k] = e
+			}
+		}
+		return out, true
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, e := range v {
+			if e, ok := filterEnvironments(e, env); ok {
+				out = append(out, e)
+			}
+		}
+		return out, true
+	}
+	return v, true
+}
+
+// validateEnvironments checks the environment annotations of the routes
+// and data blocks
+func (c *Config) validateEnvironments() error {
+	for _, t := range c.Templates {
+		if slices.Contains(t.Environments, "") {
+			return fmt.Errorf("pattern '%s': environments must not be empty", t.Pattern)
+		}
+	}
+	var check func(v any) error
+	check = func(v any) error {
+		switch v := v.(type) {
+		case map[string]any:
+			if envs, ok := v[EnvironmentsKey]; ok && len(nameList(envs)) == 0 {
+				return fmt.Errorf("data: %s must be an environment or list of environments", EnvironmentsKey)
+			}
+			for _, e := range v {
+				if err := check(e); err != nil {
+					return err
+				}
+			}
+		case []any:
+			for _, e := range v {
+				if err := check(e); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, data := range c.dataBlocks() {
+		if err := check(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchTemplate_Environments(t *testing.T) {
+	config := &Config{
+		Templates: []Template{
+			{Pattern: "^/debug/", Template: "debug.html", Environments: []string{"dev", "staging"}},
+			{Pattern: "^/", Template: "page.html"},
+		},
+	}
+
+	tests := []struct {
+		env      string
+		expected string
+	}{
+		{"dev", "debug.html"},
+		{"staging", "debug.html"},
+		{"prod", "page.html"},
+		{"", "page.html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv(EnvironmentVar, tt.env)
+			route, err := config.MatchTemplate("/debug/vars")
+			if err != nil {
+				t.Fatalf("MatchTemplate() error: %v", err)
+			}
+			if route == nil || route.Template != tt.expected {
+				t.Errorf("MatchTemplate() = %v, want %s", route, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDataFor_Environments(t *testing.T) {
+	config := &Config{
+		Data: map[string]any{
+			"api": map[string]any{"url": "https://api.example.com"},
+			"debug": map[string]any{
+				EnvironmentsKey: "dev",
+				"verbose":       true,
+			},
+			"links": []any{
+				map[string]any{"title": "Home"},
+				map[string]any{"title": "Staging", EnvironmentsKey: []any{"dev", "staging"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		env      string
+		expected map[string]any
+	}{
+		{
+			env: "dev",
+			expected: map[string]any{
+				"api":   map[string]any{"url": "https://api.example.com"},
+				"debug": map[string]any{"verbose": true},
+				"links": []any{map[string]any{"title": "Home"}, map[string]any{"title": "Staging"}},
+			},
+		},
+		{
+			env: "prod",
+			expected: map[string]any{
+				"api":   map[string]any{"url": "https://api.example.com"},
+				"links": []any{map[string]any{"title": "Home"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv(EnvironmentVar, tt.env)
+			data, err := config.DataFor(nil, "")
+			if err != nil {
+				t.Fatalf("DataFor() error: %v", err)
+			}
+			if !reflect.DeepEqual(data, tt.expected) {
+				t.Errorf("DataFor() = %v, want %v", data, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateEnvironments(t *testing.T) {
+	config := &Config{Templates: []Template{{Pattern: "^/", Environments: []string{""}}}}
+	if err := config.validateEnvironments(); err == nil || !strings.Contains(err.Error(), "must not be empty") {
+		t.Errorf("validateEnvironments() error = %v, want empty environment error", err)
+	}
+	config = &Config{Data: map[string]any{"x": map[string]any{EnvironmentsKey: 42}}}
+	if err := config.validateEnvironments(); err == nil || !strings.Contains(err.Error(), EnvironmentsKey) {
+		t.Errorf("validateEnvironments() error = %v, want %s error", err, EnvironmentsKey)
+	}
+}
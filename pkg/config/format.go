@@ -0,0 +1,58 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config file formats
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// configExtensions are the file extensions recognized in config directories
+var configExtensions = map[string]string{
+	".yaml": FormatYAML,
+	".yml":  FormatYAML,
+	".json": FormatJSON,
+	".toml": FormatTOML,
+}
+
+// detectFormat returns the format implied by a file's extension, defaulting
+// to YAML
+func detectFormat(filePath string) string {
+	if format, ok := configExtensions[strings.ToLower(filepath.Ext(filePath))]; ok {
+		return format
+	}
+	return FormatYAML
+}
+
+// decodeDoc decodes a config file into a generic document
+func decodeDoc(data []byte, format string) (map[string]any, error) {
+	doc := map[string]any{}
+	var err error
+	switch format {
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &doc)
+	case FormatJSON:
+		if len(bytes.TrimSpace(data)) > 0 {
+			err = json.Unmarshal(data, &doc)
+		}
+	case FormatTOML:
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("unknown config format '%s'", format)
+	}
+	if doc == nil {
+		doc = map[string]any{}
+	}
+	return doc, err
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseConfigFile_Formats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		format  string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `default_template: "default.html"
+templates:
+  - pattern: "^/blog/"
+    template: "blog.html"
+    publish_date: 2024-01-02T00:00:00Z
+data:
+  site: {name: "Example"}
+watch: {interval: "5s"}
+`,
+		},
+		{
+			name: "json",
+			file: "config.json",
+			content: `{
+  "default_template": "default.html",
+  "templates": [
+    {"pattern": "^/blog/", "template": "blog.html", "publish_date": "2024-01-02T00:00:00Z"}
+  ],
+  "data": {"site": {"name": "Example"}},
+  "watch": {"interval": "5s"}
+}`,
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: `default_template = "default.html"
+
+[[templates]]
+pattern = "^/blog/"
+template = "blog.html"
+publish_date = 2024-01-02T00:00:00Z
+
+[data.site]
+name = "Example"
+
+[watch]
+interval = "5s"
+`,
+		},
+		{
+			name:   "toml with explicit format",
+			file:   "config.conf",
+			format: FormatTOML,
+			content: `default_template = "default.html"
+templates = [{pattern = "^/blog/", template = "blog.html", publish_date = 2024-01-02T00:00:00Z}]
+data = {site = {name = "Example"}}
+watch = {interval = "5s"}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to create config file: %v", err)
+			}
+			config, err := ParseConfigFileAs(path, tt.format)
+			if err != nil {
+				t.Fatalf("ParseConfigFileAs() error: %v", err)
+			}
+			if config.DefaultTemplate != "default.html" {
+				t.Errorf("DefaultTemplate = %q", config.DefaultTemplate)
+			}
+			if len(config.Templates) != 1 || config.Templates[0].Template != "blog.html" {
+				t.Fatalf("Templates = %+v", config.Templates)
+			}
+			want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+			if got := config.Templates[0].PublishDate; !got.Equal(want) {
+				t.Errorf("PublishDate = %v, want %v", got, want)
+			}
+			data, _ := config.Data.(map[string]any)
+			site, _ := data["site"].(map[string]any)
+			if site["name"] != "Example" {
+				t.Errorf("Data = %v", config.Data)
+			}
+			if config.Watch.Interval != 5*time.Second {
+				t.Errorf("Watch.Interval = %v", config.Watch.Interval)
+			}
+		})
+	}
+}
+
+func TestParseConfigFile_FormatErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{"default_template": `), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	if _, err := ParseConfigFile(path); err == nil {
+		t.Error("expected error for malformed JSON")
+	}
+	if _, err := ParseConfigFileAs(path, "ini"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/debug"
+)
+
+// HMACKey names where the value of a key for hmacSHA256 is read from: an
+// environment variable or a file, so that it never appears in templates or
+// the config
+type HMACKey struct {
+	Env  string `yaml:"env,omitempty"`
+	File string `yaml:"file,omitempty"`
+}
+
+// hmacKey reads the named key. Its value is kept out of debug output.
+func (c *Config) hmacKey(name string) ([]byte, error) {
+	k, ok := c.HMACKeys[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown HMAC key '%s'", name)
+	}
+	var value string
+	if k.Env != "" {
+		value = os.Getenv(k.Env)
+	} else {
+		raw, err := os.ReadFile(c.resolvePath(k.File))
+		if err != nil {
+			return nil, fmt.Errorf("HMAC key %s: %w", name, err)
+		}
+		value = strings.TrimSpace(string(raw))
+	}
+	if value == "" {
+		return nil, fmt.Errorf("HMAC key %s is empty", name)
+	}
+	debug.AddSecret(value)
+	return []byte(value), nil
+}
+
+// hmacSHA256 returns the hex HMAC-SHA256 of value under the named key
+func (c *Config) hmacSHA256(name string, value any) (string, error) {
+	key, err := c.hmacKey(name)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(fmt.Sprint(value)))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// hmacVerify reports whether signature is the hex HMAC-SHA256 of value
+// under the named key, comparing in constant time. A "sha256=" prefix, as
+// webhook senders use, is ignored.
+func (c *Config) hmacVerify(name string, value any, signature string) (bool, error) {
+	expected, err := c.hmacSHA256(name, value)
+	if err != nil {
+		return false, err
+	}
+	signature = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(signature), "sha256="))
+	return hmac.Equal([]byte(expected), []byte(signature)), nil
+}
+
+// validateHMACKeys checks that each key names one source
+func (c *Config) validateHMACKeys() error {
+	for name, k := range c.HMACKeys {
+		if (k.Env == "") == (k.File == "") {
+			return fmt.Errorf("hmac_keys: %s needs exactly one of env or file", name)
+		}
+	}
+	return nil
+}
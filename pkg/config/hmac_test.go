@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHMACFunctions(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "webhook.key"), []byte("webhook-secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_DOWNLOAD_KEY", "key")
+	config := &Config{
+		ConfigFilePath: filepath.Join(tempDir, "config.yaml"),
+		HMACKeys: map[string]HMACKey{
+			"downloads": {Env: "TEST_DOWNLOAD_KEY"},
+			"webhooks":  {File: "webhook.key"},
+			"unset":     {Env: "TEST_UNSET_HMAC_KEY"},
+		},
+	}
+	if err := config.validateHMACKeys(); err != nil {
+		t.Fatalf("validateHMACKeys() error: %v", err)
+	}
+
+	// The widely published HMAC-SHA256 of this pangram under "key"
+	got, err := config.hmacSHA256("downloads", "The quick brown fox jumps over the lazy dog")
+	if err != nil {
+		t.Fatalf("hmacSHA256() error: %v", err)
+	}
+	if got != "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8" {
+		t.Errorf("hmacSHA256() = %s", got)
+	}
+
+	sig, err := config.hmacSHA256("webhooks", `{"event":"push"}`)
+	if err != nil {
+		t.Fatalf("hmacSHA256() error: %v", err)
+	}
+	for _, signature := range []string{sig, "sha256=" + sig, strings.ToUpper(sig)} {
+		if ok, err := config.hmacVerify("webhooks", `{"event":"push"}`, signature); err != nil || !ok {
+			t.Errorf("hmacVerify(%q) = %v, %v, want true", signature, ok, err)
+		}
+	}
+	if ok, _ := config.hmacVerify("webhooks", `{"event":"push!"}`, sig); ok {
+		t.Error("hmacVerify() accepted a signature of another value")
+	}
+
+	if _, err = config.hmacSHA256("nope", "x"); err == nil || !strings.Contains(err.Error(), "unknown HMAC key") {
+		t.Errorf("hmacSHA256() error = %v, want unknown HMAC key", err)
+	}
+	if _, err = config.hmacSHA256("unset", "x"); err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("hmacSHA256() error = %v, want empty key error", err)
+	}
+
+	config.HMACKeys["both"] = HMACKey{Env: "A", File: "b"}
+	if err = config.validateHMACKeys(); err == nil || !strings.Contains(err.Error(), "exactly one of env or file") {
+		t.Errorf("validateHMACKeys() error = %v, want exactly one of env or file", err)
+	}
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// DefaultHookTimeout bounds a request hook call
+const DefaultHookTimeout = 2 * time.Second
+
+// maxHookResponse bounds the JSON a request hook returns
+const maxHookResponse = 1 << 20
+
+// hookClient calls HTTP request hooks; the timeout comes from the context
+var hookClient = &http.Client{}
+
+// RequestHook is an external program or HTTP endpoint called with the
+// metadata of each request, whose JSON object is merged into the data
+// block before the page is rendered
+type RequestHook struct {
+	Command []string      `yaml:"command,omitempty"`
+	URL     string        `yaml:"url,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	Headers []string      `yaml:"headers,omitempty"`
+}
+
+// hookRequest is the request metadata sent to a hook
+type hookRequest struct {
+	Method     string              `json:"method"`
+	URI        string              `json:"uri"`
+	Path       string              `json:"path"`
+	Query      map[string][]string `json:"query"`
+	Host       string              `json:"host"`
+	RemoteAddr string              `json:"remote_addr"`
+	RemoteUser string              `json:"remote_user,omitempty"`
+	Headers    map[string]string   `json:"headers"`
+}
+
+// Enabled reports whether a request hook is configured
+func (h *RequestHook) Enabled() bool {
+	return len(h.Command) > 0 || h.URL != ""
+}
+
+// WithRequestHook returns data with the request hook's response merged in,
+// like route data. A hook that fails is logged and its data left out, so
+// that pages can render without it.
+func (c *Config) WithRequestHook(r *http.Request, requestURI string, data any) any {
+	h := &c.RequestHook
+	if !h.Enabled() {
+		return data
+	}
+	v, err := c.callRequestHook(r, requestURI)
+	if err != nil {
+		log.Printf("request hook: %v", err)
+		return data
+	}
+	return mergeData(data, v, c.DataMerge.Lists)
+}
+
+// callRequestHook sends the request metadata to the hook and decodes its
+// response
+func (c *Config) callRequestHook(r *http.Request, requestURI string) (any, error) {
+	h := &c.RequestHook
+	req := hookRequest{
+		Method:     r.Method,
+		URI:        requestURI,
+		Path:       r.URL.Path,
+		Query:      r.URL.Query(),
+		Host:       r.Host,
+		RemoteAddr: r.RemoteAddr,
+		RemoteUser: RemoteUser(),
+		Headers:    map[string]string{},
+	}
+	if len(h.Headers) > 0 {
+		for _, name := range h.Headers {
+			if v := r.Header.Get(name); v != "" {
+				req.Headers[http.CanonicalHeaderKey(name)] = v
+			}
+		}
+	} else {
+		for name := range r.Header {
+			if name != "Authorization" && name != "Proxy-Authorization" {
+				req.Headers[name] = r.Header.Get(name)
+			}
+		}
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	var out []byte
+	if h.URL != "" {
+		out, err = postHook(ctx, h.URL, body)
+	} else {
+		out, err = c.runHook(ctx, body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if err = json.Unmarshal(out, &v); err != nil {
+		return nil, fmt.Errorf("decoding JSON: %w", err)
+	}
+	if _, ok := v.(map[string]any); !ok {
+		return nil, fmt.Errorf("response is not a JSON object")
+	}
+	return normalizeData(v)
+}
+
+// runHook runs the hook command with the request metadata on its standard
+// input and returns its standard output
+func (c *Config) runHook(ctx context.Context, body []byte) ([]byte, error) {
+	name := c.RequestHook.Command[0]
+	if filepath.Base(name) != name {
+		name = c.resolvePath(name)
+	}
+	cmd := exec.CommandContext(ctx, name, c.RequestHook.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &limitedBuffer{buf: &stdout, limit: maxHookResponse}
+	cmd.Stderr = &limitedBuffer{buf: &stderr, limit: 4096}
+	if err := cmd.Run(); err != nil {
+		if stderr.Len() > 0 {
+			return nil, fmt.Errorf("running %s: %w: %s", name, err, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return nil, fmt.Errorf("running %s: %w", name, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// postHook posts the request metadata to the hook endpoint and returns the
+// response body
+func postHook(ctx context.Context, hookURL string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hookURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := hookClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	out, err := io.ReadAll(io.LimitReader(resp.Body, maxHookResponse+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxHookResponse {
+		return nil, fmt.Errorf("response exceeds %d bytes", maxHookResponse)
+	}
+	return out, nil
+}
+
+// limitedBuffer collects output up to a limit and fails writes beyond it,
+// which stops a runaway command
+type limitedBuffer struct {
+	buf   *bytes.Buffer
+	limit int
+}
+
+// Write appends p, or fails if the limit would be exceeded
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.limit {
+		return 0, fmt.Errorf("output exceeds %d bytes", b.limit)
+	}
+	return b.buf.Write(p)
+}
+
+// validateRequestHook checks the request hook settings
+func (c *Config) validateRequestHook() error {
+	h := &c.RequestHook
+	if !h.Enabled() {
+		return nil
+	}
+	if len(h.Command) > 0 && h.URL != "" {
+		return fmt.Errorf("request_hook: command and url are exclusive")
+	}
+	if h.URL != "" {
+		if u, err := url.Parse(h.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("request_hook: url must be an http(s) URL")
+		}
+	}
+	if len(h.Command) > 0 && h.Command[0] == "" {
+		return fmt.Errorf("request_hook: command must name a program")
+	}
+	if h.Timeout < 0 {
+		return fmt.Errorf("request_hook: timeout must not be negative")
+	}
+	return nil
+}
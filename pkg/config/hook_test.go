@@ -0,0 +1,120 @@
+package config
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithRequestHook(t *testing.T) {
+	var sent atomic.Value
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req hookRequest
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &req)
+		sent.Store(req)
+		switch r.URL.Path {
+		case "/hook":
+			_, _ = w.Write([]byte(`{"user": {"name": "Ada"}, "tags": ["new"]}`))
+		case "/list":
+			_, _ = w.Write([]byte(`["not", "an", "object"]`))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	base := map[string]any{"site": "Example", "user": map[string]any{"plan": "free"}, "tags": []any{"a"}}
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest("GET", "/account?tab=2", nil)
+		r.Header.Set("Accept-Language", "de")
+		r.Header.Set("Authorization", "Bearer secret")
+		return r
+	}
+
+	config := &Config{RequestHook: RequestHook{URL: ts.URL + "/hook"}, DataMerge: DataMerge{Lists: ListsAppend}}
+	data := config.WithRequestHook(newRequest(), "/account?tab=2", base)
+	expected := map[string]any{"site": "Example", "user": map[string]any{"plan": "free", "name": "Ada"}, "tags": []any{"a", "new"}}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("WithRequestHook() = %v, want %v", data, expected)
+	}
+	got := sent.Load().(hookRequest)
+	if got.Method != "GET" || got.URI != "/account?tab=2" || got.Path != "/account" || got.Query["tab"][0] != "2" {
+		t.Errorf("hook request = %+v", got)
+	}
+	if got.Headers["Accept-Language"] != "de" || got.Headers["Authorization"] != "" {
+		t.Errorf("hook request headers = %v, want all but Authorization", got.Headers)
+	}
+	if _, ok := base["user"].(map[string]any)["name"]; ok {
+		t.Error("WithRequestHook() modified the data")
+	}
+
+	// Only the listed headers are sent
+	config.RequestHook.Headers = []string{"authorization"}
+	config.WithRequestHook(newRequest(), "/account", base)
+	if got = sent.Load().(hookRequest); !reflect.DeepEqual(got.Headers, map[string]string{"Authorization": "Bearer secret"}) {
+		t.Errorf("hook request headers = %v, want only Authorization", got.Headers)
+	}
+
+	// Failing hooks leave the data alone
+	for _, path := range []string{"/down", "/list", "/slow"} {
+		config := &Config{RequestHook: RequestHook{URL: ts.URL + path, Timeout: 50 * time.Millisecond}}
+		if data := config.WithRequestHook(newRequest(), "/", base); !reflect.DeepEqual(data, base) {
+			t.Errorf("WithRequestHook() with %s = %v, want the data unchanged", path, data)
+		}
+	}
+}
+
+func TestWithRequestHook_Command(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "hook.sh")
+	content := "#!/bin/sh\ncat > \"$1\"\necho '{\"greeting\": \"hello\", \"count\": 2}'\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	input := filepath.Join(dir, "input.json")
+	config := &Config{
+		ConfigFilePath: filepath.Join(dir, "config.yaml"),
+		RequestHook:    RequestHook{Command: []string{"./hook.sh", input}},
+	}
+	if err := config.validateRequestHook(); err != nil {
+		t.Fatalf("validateRequestHook() error: %v", err)
+	}
+	data := config.WithRequestHook(httptest.NewRequest("POST", "/form", nil), "/form", nil)
+	if expected := map[string]any{"greeting": "hello", "count": 2}; !reflect.DeepEqual(data, expected) {
+		t.Errorf("WithRequestHook() = %v, want %v", data, expected)
+	}
+	raw, _ := os.ReadFile(input)
+	if !strings.Contains(string(raw), `"method":"POST"`) {
+		t.Errorf("hook input = %s, want the request metadata", raw)
+	}
+}
+
+func TestValidateRequestHook(t *testing.T) {
+	tests := []struct {
+		hook    RequestHook
+		wantErr string
+	}{
+		{RequestHook{Command: []string{"hook"}, URL: "https://example.com"}, "exclusive"},
+		{RequestHook{URL: "ftp://example.com"}, "http(s) URL"},
+		{RequestHook{Command: []string{""}}, "must name a program"},
+		{RequestHook{URL: "https://example.com", Timeout: -time.Second}, "must not be negative"},
+	}
+	for _, tt := range tests {
+		config := &Config{RequestHook: tt.hook}
+		if err := config.validateRequestHook(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("validateRequestHook(%+v) error = %v, want %q", tt.hook, err, tt.wantErr)
+		}
+	}
+}
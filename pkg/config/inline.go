@@ -0,0 +1,43 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// inlinePrefix starts the names given to templates defined in the config
+const inlinePrefix = "inline:"
+
+// TemplateName returns the name the route's template is loaded by: its
+// file name, or a name derived from its content if it is defined inline
+func (t *Template) TemplateName() string {
+	if t.Content == "" {
+		return t.Template
+	}
+	sum := sha256.Sum256([]byte(t.Content))
+	return inlinePrefix + hex.EncodeToString(sum[:6])
+}
+
+// loadInlineTemplate parses the content of the route whose inline template
+// has the given name
+func (c *Config) loadInlineTemplate(name string) (Executable, error) {
+	for i := range c.Templates {
+		t := &c.Templates[i]
+		if t.Content == "" || t.TemplateName() != name {
+			continue
+		}
+		tmpl, err := parseTemplate(name, c.isTextTemplate(name), c.funcMap(), t.Content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse: %w", err)
+		}
+		return tmpl, nil
+	}
+	return nil, fmt.Errorf("no route defines template %s", name)
+}
+
+// isInline reports whether a template name refers to inline content
+func isInline(name string) bool {
+	return strings.HasPrefix(name, inlinePrefix)
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigFile_InlineContent(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(configPath, []byte(`default_template: "default.html"
+templates:
+  - pattern: "^/healthz$"
+    content: "ok"
+  - pattern: "^/old/(.*)$"
+    content: |
+      <meta http-equiv="refresh" content="0; url={{ .RequestURI | replace "/old/" "/new/" }}">`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := ParseConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+
+	tests := map[string]string{
+		"/healthz":   "ok",
+		"/old/page1": `<meta http-equiv="refresh" content="0; url=/new/page1">`,
+	}
+	for uri, want := range tests {
+		tmpl, err := config.FindTemplate(uri)
+		if err != nil {
+			t.Fatalf("FindTemplate(%q) error: %v", uri, err)
+		}
+		var buf strings.Builder
+		if err = tmpl.Execute(&buf, TemplateData{RequestURI: uri}); err != nil {
+			t.Fatalf("Execute() error: %v", err)
+		}
+		if got := strings.TrimSpace(buf.String()); got != want {
+			t.Errorf("FindTemplate(%q) rendered %q, want %q", uri, got, want)
+		}
+	}
+
+	if name := config.Templates[0].TemplateName(); !strings.HasPrefix(name, inlinePrefix) {
+		t.Errorf("TemplateName() = %q, want an inline name", name)
+	}
+	if _, err = config.LoadTemplate(inlinePrefix + "000000000000"); err == nil {
+		t.Errorf("LoadTemplate() of an unknown inline template succeeded")
+	}
+
+	config.Templates[0].Template = "health.html"
+	if err = config.Validate(); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("Validate() error = %v, want mutually exclusive", err)
+	}
+}
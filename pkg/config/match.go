@@ -0,0 +1,249 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MatchTemplate returns the first template entry whose pattern matches the
+// given URI for a GET request, or nil if the default template applies.
+// Routes restricted to a host never match.
+func (c *Config) MatchTemplate(uri string) (*Template, error) {
+	return c.MatchRequest(nil, uri)
+}
+
+// MatchRequest returns the first template entry that matches the request
+// and its URI, or nil if the default template applies
+func (c *Config) MatchRequest(r *http.Request, uri string) (*Template, error) {
+	for i := range c.Templates {
+		t := &c.Templates[i]
+		ok, err := t.matches(r, uri)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+
+// sortRoutes orders routes by descending priority. Routes of equal
+// priority keep their order in the config.
+func (c *Config) sortRoutes() {
+	sort.SliceStable(c.Templates, func(i, j int) bool {
+		return c.Templates[i].Priority > c.Templates[j].Priority
+	})
+}
+
+// matches reports whether the route applies to the request and URI
+func (t *Template) matches(r *http.Request, uri string) (bool, error) {
+	if !t.inEnvironment(Environment()) {
+		return false, nil
+	}
+	match, err := t.uriMatcher()
+	if err != nil {
+		return false, fmt.Errorf("compiling regexp: %w", err)
+	}
+	if !match(uri) {
+		return false, nil
+	}
+	if t.Exclude != "" {
+		re, err := regexp.Compile(t.Exclude)
+		if err != nil {
+			return false, fmt.Errorf("compiling exclude: %w", err)
+		}
+		if re.MatchString(uri) {
+			return false, nil
+		}
+	}
+	if len(t.Query) > 0 && !t.matchesQuery(uri) {
+		return false, nil
+	}
+	if len(t.Methods) > 0 {
+		method := http.MethodGet
+		if r != nil {
+			method = r.Method
+		}
+		if !t.allowsMethod(method) {
+			return false, nil
+		}
+	}
+	if t.Host != "" || t.HostPattern != "" {
+		if r == nil {
+			return false, nil
+		}
+		host := RequestHost(r)
+		if t.Host != "" && !strings.EqualFold(t.Host, host) {
+			return false, nil
+		}
+		if t.HostPattern != "" {
+			re, err := regexp.Compile(t.HostPattern)
+			if err != nil {
+				return false, fmt.Errorf("compiling host_pattern: %w", err)
+			}
+			if !re.MatchString(host) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// Pattern types
+const (
+	PatternRegex  = "regex"
+	PatternGlob   = "glob"
+	PatternPrefix = "prefix"
+	PatternExact  = "exact"
+)
+
+// AllPatterns returns the route's patterns: pattern followed by the aliases
+// in patterns
+func (t *Template) AllPatterns() []string {
+	if t.Pattern == "" && len(t.Patterns) > 0 {
+		return t.Patterns
+	}
+	return append([]string{t.Pattern}, t.Patterns...)
+}
+
+// uriMatcher returns a function reporting whether a request URI matches any
+// of the route's patterns
+func (t *Template) uriMatcher() (func(string) bool, error) {
+	var matchers []func(string) bool
+	for _, pattern := range t.AllPatterns() {
+		match, err := t.patternMatcher(pattern)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, match)
+	}
+	return func(uri string) bool {
+		for _, match := range matchers {
+			if match(uri) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// patternMatcher returns a function reporting whether a request URI matches
+// a pattern of the route's pattern type. Regular expressions see the whole
+// URI; the other pattern types only its path.
+func (t *Template) patternMatcher(pattern string) (func(string) bool, error) {
+	pathOnly := func(match func(string) bool) func(string) bool {
+		return func(uri string) bool {
+			p, _, _ := strings.Cut(uri, "?")
+			return match(p)
+		}
+	}
+	switch t.PatternType {
+	case "", PatternRegex:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	case PatternGlob:
+		re, err := globRegexp(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return pathOnly(re.MatchString), nil
+	case PatternPrefix:
+		return pathOnly(func(p string) bool { return strings.HasPrefix(p, pattern) }), nil
+	case PatternExact:
+		return pathOnly(func(p string) bool { return p == pattern }), nil
+	}
+	return nil, fmt.Errorf("unknown pattern_type '%s'", t.PatternType)
+}
+
+// globRegexp translates a glob to a regular expression matching whole
+// paths. * and ? match within one path segment, ** across segments.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for rest := glob; rest != ""; {
+		switch {
+		case strings.HasPrefix(rest, "**"):
+			b.WriteString(".*")
+			rest = rest[2:]
+		case rest[0] == '*':
+			b.WriteString("[^/]*")
+			rest = rest[1:]
+		case rest[0] == '?':
+			b.WriteString("[^/]")
+			rest = rest[1:]
+		default:
+			n := strings.IndexAny(rest, "*?")
+			if n < 0 {
+				n = len(rest)
+			}
+			b.WriteString(regexp.QuoteMeta(rest[:n]))
+			rest = rest[n:]
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// matchesQuery reports whether the URI's query string has every parameter
+// of the route's query matcher. A value of "*" accepts any value, including
+// an empty one.
+func (t *Template) matchesQuery(uri string) bool {
+	_, rawQuery, _ := strings.Cut(uri, "?")
+	query, _ := url.ParseQuery(rawQuery)
+	for name, want := range t.Query {
+		values, ok := query[name]
+		if !ok || (want != "*" && values[0] != want) {
+			return false
+		}
+	}
+	return true
+}
+
+// allowsMethod reports whether the route's methods include the given one.
+// HEAD is allowed wherever GET is.
+func (t *Template) allowsMethod(method string) bool {
+	for _, m := range t.Methods {
+		if m == method || (m == http.MethodGet && method == http.MethodHead) {
+			return true
+		}
+	}
+	return false
+}
+
+// FragmentFor returns the block to render instead of the whole template
+// for partial page requests from htmx or Turbo Frames, or "" for a full page.
+// Boosted htmx requests replace the whole body and get the full page.
+func (t *Template) FragmentFor(r *http.Request) string {
+	if t.Fragment == "" {
+		return ""
+	}
+	htmx := r.Header.Get("HX-Request") == "true" && r.Header.Get("HX-Boosted") != "true"
+	if htmx || r.Header.Get("Turbo-Frame") != "" {
+		return t.Fragment
+	}
+	return ""
+}
+
+// RequestHost returns the lowercased name of the host a request was sent
+// to, without the port. Under CGI, SERVER_NAME is used when the client sent
+// no Host header.
+func RequestHost(r *http.Request) string {
+	host := r.Host
+	if host == "" {
+		host = os.Getenv("SERVER_NAME")
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
@@ -0,0 +1,329 @@
+package config
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatchRequest_Host(t *testing.T) {
+	config := &Config{
+		Templates: []Template{
+			{Pattern: "^/", Template: "shop.html", Host: "shop.example.com"},
+			{Pattern: "^/", Template: "regional.html", HostPattern: `^(de|fr)\.example\.com$`},
+			{Pattern: "^/blog/", Template: "blog.html"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		host       string
+		serverName string
+		uri        string
+		expected   string
+	}{
+		{"Exact host", "shop.example.com", "", "/cart", "shop.html"},
+		{"Exact host is case-insensitive and ignores the port", "Shop.Example.COM:8443", "", "/cart", "shop.html"},
+		{"Host pattern", "fr.example.com", "", "/", "regional.html"},
+		{"Host pattern does not match", "es.example.com", "", "/", ""},
+		{"Unrestricted route on any host", "es.example.com", "", "/blog/post", "blog.html"},
+		{"SERVER_NAME without Host header", "", "shop.example.com", "/", "shop.html"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SERVER_NAME", tt.serverName)
+			req := httptest.NewRequest("GET", tt.uri, nil)
+			req.Host = tt.host
+			route, err := config.MatchRequest(req, tt.uri)
+			if err != nil {
+				t.Fatalf("MatchRequest() error: %v", err)
+			}
+			got := ""
+			if route != nil {
+				got = route.Template
+			}
+			if got != tt.expected {
+				t.Errorf("MatchRequest() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	// Without a request, host-restricted routes are skipped
+	route, err := config.MatchTemplate("/blog/post")
+	if err != nil || route == nil || route.Template != "blog.html" {
+		t.Errorf("MatchTemplate() = %v, %v", route, err)
+	}
+	if route, _ = config.MatchTemplate("/cart"); route != nil {
+		t.Errorf("MatchTemplate() matched host route %q", route.Template)
+	}
+}
+
+func TestMatchRequest_Methods(t *testing.T) {
+	config := &Config{
+		Templates: []Template{
+			{Pattern: "^/contact$", Template: "thanks.html", Methods: []string{"POST"}},
+			{Pattern: "^/contact$", Template: "contact.html", Methods: []string{"GET"}},
+			{Pattern: "^/api/", Template: "api.html"},
+		},
+	}
+
+	tests := []struct {
+		method   string
+		uri      string
+		expected string
+	}{
+		{"GET", "/contact", "contact.html"},
+		{"HEAD", "/contact", "contact.html"},
+		{"POST", "/contact", "thanks.html"},
+		{"PUT", "/contact", ""},
+		{"DELETE", "/api/item", "api.html"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.uri, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.uri, nil)
+			route, err := config.MatchRequest(req, tt.uri)
+			if err != nil {
+				t.Fatalf("MatchRequest() error: %v", err)
+			}
+			got := ""
+			if route != nil {
+				got = route.Template
+			}
+			if got != tt.expected {
+				t.Errorf("MatchRequest() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	if route, _ := config.MatchTemplate("/contact"); route == nil || route.Template != "contact.html" {
+		t.Errorf("MatchTemplate() = %v, want contact.html", route)
+	}
+
+	config.Templates[0].Methods = []string{"post"}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "invalid method") {
+		t.Errorf("Validate() error = %v, want invalid method", err)
+	}
+}
+
+func TestMatchRequest_Query(t *testing.T) {
+	config := &Config{
+		Templates: []Template{
+			{Pattern: "^/blog/", Template: "rss.xml", Query: map[string]string{"format": "rss"}},
+			{Pattern: "^/blog/", Template: "preview.html", Query: map[string]string{"preview": "*", "lang": "en"}},
+			{Pattern: `^/search\?(.*&)?q=`, Template: "results.html"},
+			{Pattern: "^/blog/", Template: "blog.html"},
+		},
+	}
+
+	tests := []struct {
+		uri      string
+		expected string
+	}{
+		{"/blog/post?format=rss", "rss.xml"},
+		{"/blog/post?page=2&format=rss", "rss.xml"},
+		{"/blog/post?format=atom", "blog.html"},
+		{"/blog/post?lang=en&preview", "preview.html"},
+		{"/blog/post?lang=en&preview=1", "preview.html"},
+		{"/blog/post?preview=1", "blog.html"},
+		{"/blog/post", "blog.html"},
+		{"/search?sort=new&q=go", "results.html"},
+		{"/search", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			route, err := config.MatchTemplate(tt.uri)
+			if err != nil {
+				t.Fatalf("MatchTemplate() error: %v", err)
+			}
+			got := ""
+			if route != nil {
+				got = route.Template
+			}
+			if got != tt.expected {
+				t.Errorf("MatchTemplate() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidate_Fragment(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "page.html"), []byte(`{{define "list"}}items{{end}}page`), 0644); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	config := &Config{
+		ConfigFilePath:  filepath.Join(tempDir, "config.yaml"),
+		DefaultTemplate: "page.html",
+		Templates:       []Template{{Pattern: "^/", Template: "page.html", Fragment: "list"}},
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() error: %v", err)
+	}
+	config.Templates[0].Fragment = "missing"
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "no block named 'missing'") {
+		t.Errorf("Validate() error = %v, want missing block", err)
+	}
+}
+
+func TestMatchTemplate_PatternType(t *testing.T) {
+	tests := []struct {
+		name        string
+		patternType string
+		pattern     string
+		uri         string
+		expected    bool
+	}{
+		{"Regex by default", "", `^/docs/\d+$`, "/docs/12", true},
+		{"Regex sees the query string", "regex", `\?page=`, "/docs/?page=2", true},
+		{"Prefix", "prefix", "/docs/", "/docs/guide/intro", true},
+		{"Prefix ignores the query string", "prefix", "/docs/", "/api?next=/docs/", false},
+		{"Prefix with regex characters", "prefix", "/c++/", "/c++/intro", true},
+		{"Exact", "exact", "/about", "/about?ref=nav", true},
+		{"Exact mismatch", "exact", "/about", "/about/team", false},
+		{"Glob star stays in a segment", "glob", "/blog/*/comments", "/blog/post/comments", true},
+		{"Glob star does not cross segments", "glob", "/blog/*", "/blog/2024/post", false},
+		{"Glob double star", "glob", "/assets/**.css", "/assets/css/site.css", true},
+		{"Glob question mark", "glob", "/v?/api", "/v2/api", true},
+		{"Glob is anchored", "glob", "/blog/*", "/en/blog/post", false},
+		{"Glob with UTF-8", "glob", "/café/*", "/café/menu", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Templates: []Template{{Pattern: tt.pattern, PatternType: tt.patternType, Template: "page.html"}}}
+			route, err := config.MatchTemplate(tt.uri)
+			if err != nil {
+				t.Fatalf("MatchTemplate() error: %v", err)
+			}
+			if got := route != nil; got != tt.expected {
+				t.Errorf("MatchTemplate(%q) matched = %v, want %v", tt.uri, got, tt.expected)
+			}
+		})
+	}
+
+	config := &Config{Templates: []Template{{Pattern: "/", PatternType: "wildcard", Template: "page.html"}}}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "unknown pattern_type") {
+		t.Errorf("Validate() error = %v, want unknown pattern_type", err)
+	}
+}
+
+func TestParseConfigFile_Priority(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(configPath, []byte(`default_template: "default.html"
+templates:
+  - pattern: "^/"
+    template: "catchall.html"
+    priority: -10
+  - pattern: "^/docs/"
+    template: "docs.html"
+  - pattern: "^/docs/api"
+    template: "api.html"
+    priority: 5
+  - pattern: "^/docs/"
+    template: "docs-dup.html"`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := ParseConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+
+	tests := map[string]string{
+		"/docs/api/v1": "api.html",
+		"/docs/intro":  "docs.html",
+		"/about":       "catchall.html",
+	}
+	for uri, want := range tests {
+		route, err := config.MatchTemplate(uri)
+		if err != nil {
+			t.Fatalf("MatchTemplate() error: %v", err)
+		}
+		if route == nil || route.Template != want {
+			t.Errorf("MatchTemplate(%q) = %v, want %s", uri, route, want)
+		}
+	}
+}
+
+func TestMatchTemplate_Exclude(t *testing.T) {
+	config := &Config{
+		Templates: []Template{
+			{Pattern: "^/docs/", Exclude: "^/docs/(internal|drafts)/", Template: "docs.html"},
+			{Pattern: "/docs/", PatternType: PatternPrefix, Exclude: `\?raw=1`, Template: "prefix.html"},
+		},
+	}
+
+	tests := []struct {
+		uri      string
+		expected string
+	}{
+		{"/docs/intro", "docs.html"},
+		{"/docs/internal/plan", "prefix.html"},
+		{"/docs/drafts/x?raw=1", ""},
+		{"/docs/internals", "docs.html"},
+	}
+	for _, tt := range tests {
+		route, err := config.MatchTemplate(tt.uri)
+		if err != nil {
+			t.Fatalf("MatchTemplate() error: %v", err)
+		}
+		got := ""
+		if route != nil {
+			got = route.Template
+		}
+		if got != tt.expected {
+			t.Errorf("MatchTemplate(%q) = %q, want %q", tt.uri, got, tt.expected)
+		}
+	}
+
+	config = &Config{Templates: []Template{{Pattern: "^/", Exclude: "(", Template: "page.html"}}}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "compiling exclude") {
+		t.Errorf("Validate() error = %v, want compiling exclude", err)
+	}
+}
+
+func TestMatchTemplate_Patterns(t *testing.T) {
+	config := &Config{
+		Templates: []Template{
+			{Pattern: "/about", Patterns: []string{"/about-us", "/company"}, PatternType: PatternExact, Template: "about.html"},
+			{Patterns: []string{`^/old/blog/`, `^/news/`}, Template: "blog.html"},
+		},
+	}
+
+	tests := []struct {
+		uri      string
+		expected string
+	}{
+		{"/about", "about.html"},
+		{"/about-us?ref=nav", "about.html"},
+		{"/company", "about.html"},
+		{"/company/team", ""},
+		{"/news/2024", "blog.html"},
+		{"/old/blog/post", "blog.html"},
+		{"/contact", ""},
+	}
+	for _, tt := range tests {
+		route, err := config.MatchTemplate(tt.uri)
+		if err != nil {
+			t.Fatalf("MatchTemplate() error: %v", err)
+		}
+		got := ""
+		if route != nil {
+			got = route.Template
+		}
+		if got != tt.expected {
+			t.Errorf("MatchTemplate(%q) = %q, want %q", tt.uri, got, tt.expected)
+		}
+	}
+
+	config = &Config{Templates: []Template{{Patterns: []string{"^/ok", "("}, Template: "page.html"}}}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "compiling regex") {
+		t.Errorf("Validate() error = %v, want compiling regex", err)
+	}
+}
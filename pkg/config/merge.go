@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// List merge policies for route data
+const (
+	ListsReplace = "replace"
+	ListsAppend  = "append"
+)
+
+// DataMerge configures how a route's data is merged into the global data
+type DataMerge struct {
+	Lists string `yaml:"lists,omitempty"`
+}
+
+// RouteData returns the data block for a route: the global data with the
+// route's data merged in. Maps are merged recursively; lists are replaced
+// unless the merge policy appends them, and other values are replaced.
+func (c *Config) RouteData(t *Template) any {
+	if t == nil || t.Data == nil {
+		return c.Data
+	}
+	lists := c.DataMerge.Lists
+	if t.DataMerge != nil && t.DataMerge.Lists != "" {
+		lists = t.DataMerge.Lists
+	}
+	return mergeData(c.Data, t.Data, lists)
+}
+
+// mergeData returns over merged into base, without modifying either
+func mergeData(base, over any, lists string) any {
+	switch o := over.(type) {
+	case map[string]any:
+		b, ok := base.(map[string]any)
+		if !ok {
+			return o
+		}
+		out := make(map[string]any, len(b)+len(o))
+		for k, v := range b {
+			out[k] = v
+		}
+		for k, v := range o {
+			if bv, ok := b[k]; ok {
+				v = mergeData(bv, v, lists)
+			}
+			out[k] = v
+		}
+		return out
+	case []any:
+		if b, ok := base.([]any); ok && lists == ListsAppend {
+			return append(slices.Clip(b), o...)
+		}
+	}
+	return over
+}
+
+// Validate checks the merge policy
+func (m *DataMerge) Validate() error {
+	switch m.Lists {
+	case "", ListsReplace, ListsAppend:
+		return nil
+	}
+	return fmt.Errorf("data_merge: lists must be %s or %s", ListsReplace, ListsAppend)
+}
+
+// dataBlocks returns the global data and the data of each route
+func (c *Config) dataBlocks() []any {
+	blocks := []any{c.Data}
+	for _, t := range c.Templates {
+		if t.Data != nil {
+			blocks = append(blocks, t.Data)
+		}
+	}
+	return blocks
+}
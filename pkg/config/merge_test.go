@@ -0,0 +1,107 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRouteData(t *testing.T) {
+	global := map[string]any{
+		"site": map[string]any{
+			"name": "Example",
+			"nav":  []any{"home", "blog"},
+			"meta": map[string]any{"lang": "en", "robots": "index"},
+		},
+		"footer": "© Example",
+	}
+	route := map[string]any{
+		"site": map[string]any{
+			"nav":  []any{"docs"},
+			"meta": map[string]any{"robots": "noindex"},
+		},
+		"footer": nil,
+		"title":  "Docs",
+	}
+
+	tests := []struct {
+		name      string
+		policy    string
+		override  *DataMerge
+		routeData any
+		expected  any
+	}{
+		{
+			name:      "replace lists by default",
+			routeData: route,
+			expected: map[string]any{
+				"site": map[string]any{
+					"name": "Example",
+					"nav":  []any{"docs"},
+					"meta": map[string]any{"lang": "en", "robots": "noindex"},
+				},
+				"footer": nil,
+				"title":  "Docs",
+			},
+		},
+		{
+			name:      "append lists",
+			policy:    ListsAppend,
+			routeData: map[string]any{"site": map[string]any{"nav": []any{"docs"}}},
+			expected: map[string]any{
+				"site": map[string]any{
+					"name": "Example",
+					"nav":  []any{"home", "blog", "docs"},
+					"meta": map[string]any{"lang": "en", "robots": "index"},
+				},
+				"footer": "© Example",
+			},
+		},
+		{
+			name:      "route overrides policy",
+			policy:    ListsAppend,
+			override:  &DataMerge{Lists: ListsReplace},
+			routeData: map[string]any{"site": map[string]any{"nav": []any{"docs"}}},
+			expected: map[string]any{
+				"site": map[string]any{
+					"name": "Example",
+					"nav":  []any{"docs"},
+					"meta": map[string]any{"lang": "en", "robots": "index"},
+				},
+				"footer": "© Example",
+			},
+		},
+		{
+			name:     "no route data",
+			expected: global,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Data: global, DataMerge: DataMerge{Lists: tt.policy}}
+			got := config.RouteData(&Template{Data: tt.routeData, DataMerge: tt.override})
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("RouteData() = %v, want %v", got, tt.expected)
+			}
+			if nav := global["site"].(map[string]any)["nav"].([]any); len(nav) != 2 {
+				t.Errorf("RouteData() modified the global data: nav = %v", nav)
+			}
+		})
+	}
+
+	if got := (&Config{Data: global}).RouteData(nil); !reflect.DeepEqual(got, global) {
+		t.Errorf("RouteData(nil) = %v, want the global data", got)
+	}
+}
+
+func TestDataMerge_Validate(t *testing.T) {
+	for _, lists := range []string{"", ListsReplace, ListsAppend} {
+		if err := (&DataMerge{Lists: lists}).Validate(); err != nil {
+			t.Errorf("Validate(%q) error: %v", lists, err)
+		}
+	}
+	config := &Config{Templates: []Template{{Pattern: "^/", DataMerge: &DataMerge{Lists: "merge"}}}}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "data_merge") {
+		t.Errorf("Validate() error = %v, want data_merge error", err)
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/i18n"
+)
+
+// messageFuncs returns the t and tN template functions, which look up the
+// configured messages in the active locale
+func (c *Config) messageFuncs() (t func(string, ...map[string]any) (string, error), tN func(string, any, ...map[string]any) (string, error)) {
+	catalog, err := i18n.NewCatalog(c.Messages)
+	t = func(key string, args ...map[string]any) (string, error) {
+		if err != nil {
+			return "", err
+		}
+		return catalog.Format(c.Locale(), key, mergeArgs(args))
+	}
+	tN = func(key string, count any, args ...map[string]any) (string, error) {
+		if err != nil {
+			return "", err
+		}
+		n, nErr := toNumber(count)
+		if nErr != nil {
+			return "", fmt.Errorf("tN %s: %w", key, nErr)
+		}
+		merged := mergeArgs(args)
+		merged["count"] = n
+		return catalog.Format(c.Locale(), key, merged)
+	}
+	return t, tN
+}
+
+// mergeArgs combines the argument maps given to t or tN
+func mergeArgs(args []map[string]any) map[string]any {
+	merged := map[string]any{}
+	for _, a := range args {
+		for k, v := range a {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// validateMessages checks that the messages parse
+func (c *Config) validateMessages() error {
+	if _, err := i18n.NewCatalog(c.Messages); err != nil {
+		return fmt.Errorf("messages: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMessageFunctions(t *testing.T) {
+	messages := map[string]map[string]string{
+		"en": {
+			"items":   "{count, plural, =0 {Your cart is empty} one {# item in {owner}'s cart} other {# items in {owner}'s cart}}",
+			"welcome": "Welcome, {name}",
+		},
+		"de": {
+			"items": "{count, plural, =0 {Ihr Warenkorb ist leer} one {# Artikel} other {# Artikel}}",
+		},
+	}
+	tests := []struct {
+		name     string
+		locale   string
+		template string
+		expected string
+	}{
+		{name: "plural one", template: `{{tN "items" 1 (dict "owner" "Kim")}}`, expected: "1 item in Kim&#39;s cart"},
+		{name: "plural other", template: `{{tN "items" .Data.count (dict "owner" "Kim")}}`, expected: "1,200 items in Kim&#39;s cart"},
+		{name: "exact match", template: `{{tN "items" 0}}`, expected: "Your cart is empty"},
+		{name: "german", locale: "de-DE", template: `{{tN "items" .Data.count}}`, expected: "1.200 Artikel"},
+		{name: "fallback to default locale", locale: "de", template: `{{t "welcome" (dict "name" "Jo")}}`, expected: "Welcome, Jo"},
+		{name: "missing key", template: `{{t "nope"}}`, expected: "nope"},
+		{name: "escaped", template: `{{t "welcome" (dict "name" "<b>")}}`, expected: "Welcome, &lt;b&gt;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tempDir, "page.html"), []byte(tt.template), 0644); err != nil {
+				t.Fatalf("Failed to create template: %v", err)
+			}
+			c := &Config{ConfigFilePath: filepath.Join(tempDir, "config.yaml"), LocaleName: tt.locale, Messages: messages}
+			tmpl, err := c.LoadTemplate("page.html")
+			if err != nil {
+				t.Fatalf("LoadTemplate() error: %v", err)
+			}
+			var buf strings.Builder
+			if err = tmpl.Execute(&buf, TemplateData{Data: map[string]any{"count": 1200}}); err != nil {
+				t.Fatalf("Execute() error: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("output = %q, want %q", buf.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateMessages(t *testing.T) {
+	c := &Config{Messages: map[string]map[string]string{"en": {"items": "{count, plural, one {# item}}"}}}
+	if err := c.validateMessages(); err == nil || !strings.Contains(err.Error(), "messages: message en.items") {
+		t.Errorf("validateMessages() error = %v, want message en.items", err)
+	}
+}
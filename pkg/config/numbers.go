@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/i18n"
+)
+
+// toNumber converts a template value to a number: any integer or float, or
+// a numeric string as found in CSV data
+func toNumber(v any) (float64, error) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot use '%s' as a number", v)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("cannot use %T as a number", v)
+}
+
+// formatNumber formats a number for the active locale
+func (c *Config) formatNumber(v any) (string, error) {
+	f, err := toNumber(v)
+	if err != nil {
+		return "", err
+	}
+	return i18n.FormatNumber(c.Locale(), f), nil
+}
+
+// formatCurrency formats an amount of a currency for the active locale
+func (c *Config) formatCurrency(code string, amount any) (string, error) {
+	f, err := toNumber(amount)
+	if err != nil {
+		return "", err
+	}
+	return i18n.FormatCurrency(c.Locale(), code, f)
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNumberFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		locale   string
+		template string
+		expected string
+	}{
+		{name: "number", template: `{{formatNumber .Data.n}}`, expected: "1,234,567.25"},
+		{name: "number in German", locale: "de", template: `{{.Data.n | formatNumber}}`, expected: "1.234.567,25"},
+		{name: "currency", template: `{{formatCurrency "EUR" .Data.price}}`, expected: "€1,299.00"},
+		{name: "currency in German", locale: "de-DE", template: `{{formatCurrency "EUR" .Data.price}}`, expected: "1.299,00\u00a0€"},
+		{name: "currency from CSV text", locale: "en-GB", template: `{{formatCurrency "GBP" .Data.text}}`, expected: "£19.50"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tempDir, "page.html"), []byte(tt.template), 0644); err != nil {
+				t.Fatalf("Failed to create template: %v", err)
+			}
+			c := &Config{ConfigFilePath: filepath.Join(tempDir, "config.yaml"), LocaleName: tt.locale}
+			tmpl, err := c.LoadTemplate("page.html")
+			if err != nil {
+				t.Fatalf("LoadTemplate() error: %v", err)
+			}
+			data := TemplateData{Data: map[string]any{"n": 1234567.25, "price": 1299, "text": " 19.5 "}}
+			var buf strings.Builder
+			if err = tmpl.Execute(&buf, data); err != nil {
+				t.Fatalf("Execute() error: %v", err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("output = %q, want %q", buf.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestToNumber_Errors(t *testing.T) {
+	for _, v := range []any{"twelve", nil, []any{1}} {
+		if _, err := toNumber(v); err == nil {
+			t.Errorf("toNumber(%v) succeeded, want error", v)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"mime"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/pdf"
+	"gopkg.mhn.org/tmpl.cgi/pkg/sheet"
+	"gopkg.mhn.org/tmpl.cgi/pkg/vcard"
+)
+
+// Output formats a route's rendered page can be converted to
+const (
+	OutputPDF  = "pdf"
+	OutputXLSX = "xlsx"
+	OutputODS  = "ods"
+	OutputVCF  = "vcf"
+)
+
+// outputFormat describes the response produced by an output format
+type outputFormat struct {
+	contentType string
+	extension   string
+}
+
+var outputFormats = map[string]outputFormat{
+	OutputPDF:  {contentType: "application/pdf", extension: ".pdf"},
+	OutputXLSX: {contentType: sheet.XLSXContentType, extension: ".xlsx"},
+	OutputODS:  {contentType: sheet.ODSContentType, extension: ".ods"},
+	OutputVCF:  {contentType: vcard.ContentType, extension: ".vcf"},
+}
+
+// validateOutput checks a route's output settings
+func (t *Template) validateOutput() error {
+	if t.Output == "" {
+		return nil
+	}
+	if _, ok := outputFormats[t.Output]; !ok {
+		return fmt.Errorf("unknown output '%s'", t.Output)
+	}
+	if strings.ContainsAny(t.Filename, `/\`) {
+		return fmt.Errorf("filename must not contain a path")
+	}
+	return nil
+}
+
+// ConvertOutput converts a rendered page to an output format and returns it
+// with its content type
+func (c *Config) ConvertOutput(format string, page []byte) ([]byte, string, error) {
+	var out []byte
+	var err error
+	switch format {
+	case OutputPDF:
+		out, err = c.PDF.Render(page)
+	case OutputXLSX, OutputODS:
+		out, err = spreadsheet(format, page)
+	case OutputVCF:
+		// The template is rendered as HTML, so undo the escaping of values
+		out = vcard.Format([]byte(html.UnescapeString(string(page))))
+	default:
+		return nil, "", fmt.Errorf("unknown output '%s'", format)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return out, outputFormats[format].contentType, nil
+}
+
+// spreadsheet converts the tables of a rendered page to a spreadsheet file
+func spreadsheet(format string, page []byte) ([]byte, error) {
+	sheets, err := sheet.ParseHTML(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if format == OutputODS {
+		err = sheet.WriteODS(&buf, sheets)
+	} else {
+		err = sheet.WriteXLSX(&buf, sheets)
+	}
+	return buf.Bytes(), err
+}
+
+// maxPhotoSize limits images embedded with vcardPhoto
+const maxPhotoSize = 1 << 20
+
+// vcardPhoto returns an image file as a data: URI for a vCard PHOTO property
+func (c *Config) vcardPhoto(file string) (string, error) {
+	file = c.resolvePath(file)
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", fmt.Errorf("vcardPhoto: %w", err)
+	}
+	if info.Size() > maxPhotoSize {
+		return "", fmt.Errorf("vcardPhoto: %s is larger than %d bytes", file, maxPhotoSize)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("vcardPhoto: %w", err)
+	}
+	return vcard.PhotoURI(data), nil
+}
+
+// Disposition returns the Content-Disposition header offering a page
+// converted to the output format as a download. Without a configured
+// filename, the name is taken from the last segment of the URL path.
+func (t *Template) Disposition(urlPath, output string) string {
+	format := outputFormats[output]
+	name := t.Filename
+	if name == "" {
+		name = path.Base(strings.TrimSuffix(urlPath, "/"))
+		if name == "/" || name == "." || name == "" {
+			name = "document"
+		}
+		name = strings.TrimSuffix(name, path.Ext(name)) + format.extension
+	}
+	return mime.FormatMediaType("attachment", map[string]string{"filename": name})
+}
+
+// outputWarnings reports converters that are used but not installed
+func (c *Config) outputWarnings() []string {
+	for i := range c.Templates {
+		if c.OutputFormat(&c.Templates[i]) != OutputPDF {
+			continue
+		}
+		command := c.PDF.Command
+		if len(command) == 0 {
+			command = pdf.DefaultCommand
+		}
+		if _, err := exec.LookPath(command[0]); err != nil {
+			return []string{fmt.Sprintf("pdf: renderer '%s' not found: routes with output: pdf will fail", command[0])}
+		}
+		break
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+// Executable is a parsed page, layout or partial template. Templates
+// producing HTML or XML are parsed with html/template, which escapes values
+// for the markup around them; other content types, such as JSON and plain
+// text, are parsed with text/template, which inserts values as they are.
+type Executable interface {
+	Name() string
+	Execute(w io.Writer, data any) error
+	ExecuteTemplate(w io.Writer, name string, data any) error
+	// Trees returns the parse trees of the template and the templates it
+	// defines, by name
+	Trees() map[string]*parse.Tree
+}
+
+// htmlExecutable is a template parsed with html/template
+type htmlExecutable struct{ *htmltemplate.Template }
+
+func (t htmlExecutable) Trees() map[string]*parse.Tree {
+	trees := map[string]*parse.Tree{}
+	for _, d := range t.Templates() {
+		trees[d.Name()] = d.Tree
+	}
+	return trees
+}
+
+// textExecutable is a template parsed with text/template
+type textExecutable struct{ *texttemplate.Template }
+
+func (t textExecutable) Trees() map[string]*parse.Tree {
+	trees := map[string]*parse.Tree{}
+	for _, d := range t.Templates() {
+		trees[d.Name()] = d.Tree
+	}
+	return trees
+}
+
+// isMarkup reports whether a content type is HTML or XML, whose templates
+// are escaped by html/template
+func isMarkup(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml" ||
+		mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+// isTextTemplate reports whether the named template is parsed with
+// text/template: an inline template whose route sets a content type that
+// is not markup, or a file whose extension implies one
+func (c *Config) isTextTemplate(name string) bool {
+	if isInline(name) {
+		for i := range c.Templates {
+			if t := &c.Templates[i]; t.Content != "" && t.TemplateName() == name {
+				return t.ContentType != "" && !isMarkup(t.ContentType)
+			}
+		}
+		return false
+	}
+	contentType, ok := templateContentTypes[strings.ToLower(path.Ext(name))]
+	return ok && !isMarkup(contentType)
+}
+
+// parseTemplate parses src as the template named name, with text/template
+// if text is set and html/template otherwise
+func parseTemplate(name string, text bool, funcs map[string]any, src string) (Executable, error) {
+	if text {
+		tmpl, err := texttemplate.New(name).Funcs(funcs).Parse(src)
+		if err != nil {
+			return nil, err
+		}
+		return textExecutable{tmpl}, nil
+	}
+	tmpl, err := htmltemplate.New(name).Funcs(funcs).Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return htmlExecutable{tmpl}, nil
+}
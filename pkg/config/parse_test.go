@@ -0,0 +1,62 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadTemplate_ContentTypes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"page.html": `<p>{{.Data.name}}</p>`,
+		"page.json": `{"name": {{toJson .Data.name}}}`,
+		"page.txt":  `name: {{.Data.name}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+	name := `say "hi" \ <b>`
+	cfg := &Config{
+		ConfigFilePath: filepath.Join(dir, "config.yaml"),
+		Templates: []Template{
+			{Pattern: "^/api$", Content: `[{{toJson .Data.name}}]`, ContentType: "application/json"},
+			{Pattern: "^/$", Content: `<i>{{.Data.name}}</i>`},
+		},
+	}
+	render := func(templateName string) string {
+		t.Helper()
+		tmpl, err := cfg.LoadTemplate(templateName)
+		if err != nil {
+			t.Fatalf("LoadTemplate(%s) error: %v", templateName, err)
+		}
+		var buf bytes.Buffer
+		if err = tmpl.Execute(&buf, TemplateData{Data: map[string]any{"name": name}}); err != nil {
+			t.Fatalf("Execute(%s) error: %v", templateName, err)
+		}
+		return buf.String()
+	}
+
+	var doc struct{ Name string }
+	if out := render("page.json"); json.Unmarshal([]byte(out), &doc) != nil || doc.Name != name {
+		t.Errorf("page.json = %s, want the name as a JSON string", out)
+	}
+	var list []string
+	if out := render(cfg.Templates[0].TemplateName()); json.Unmarshal([]byte(out), &list) != nil || len(list) != 1 || list[0] != name {
+		t.Errorf("inline JSON = %s, want the name as a JSON string", out)
+	}
+	if out := render("page.txt"); out != "name: "+name {
+		t.Errorf("page.txt = %q, want the name as is", out)
+	}
+	want := `say &#34;hi&#34; \ &lt;b&gt;`
+	if out := render("page.html"); out != "<p>"+want+"</p>" {
+		t.Errorf("page.html = %q, want the name escaped", out)
+	}
+	if out := render(cfg.Templates[1].TemplateName()); out != "<i>"+want+"</i>" {
+		t.Errorf("inline HTML = %q, want the name escaped", out)
+	}
+}
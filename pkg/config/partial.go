@@ -0,0 +1,78 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+)
+
+// DefaultPartialDepth limits the nesting of renderPartial calls when
+// render.partial_depth is not set
+const DefaultPartialDepth = 10
+
+// partialFrame is a partial being rendered, with the data it was given
+type partialFrame struct {
+	name string
+	data any
+}
+
+// renderPartial returns the renderPartial template function for partials
+// nested inside the given stack of partials. A partial may render itself
+// again for a nested menu or tree, but not with the same data, which would
+// never end. An HTML partial is returned as safe HTML; a partial parsed
+// with text/template is returned as a string, which HTML pages escape.
+func (c *Config) renderPartial(stack []partialFrame) func(name string, data any) (any, error) {
+	return func(name string, data any) (any, error) {
+		for _, f := range stack {
+			if f.name == name && reflect.DeepEqual(f.data, data) {
+				return "", fmt.Errorf("renderPartial: cycle %s", partialTrace(stack, name))
+			}
+		}
+		depth := c.Render.PartialDepth
+		if depth <= 0 {
+			depth = DefaultPartialDepth
+		}
+		if len(stack) >= depth {
+			return "", fmt.Errorf("renderPartial: depth limit %d exceeded: %s", depth, partialTrace(stack, name))
+		}
+		nested := append(stack[:len(stack):len(stack)], partialFrame{name: name, data: data})
+
+		filename, err := c.localFile(c.templatePath(name))
+		if err != nil {
+			return "", fmt.Errorf("renderPartial %s: %w", name, err)
+		}
+		funcs := c.funcMap()
+		funcs["renderPartial"] = c.renderPartial(nested)
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			return "", fmt.Errorf("renderPartial %s: %w", name, err)
+		}
+		text := c.isTextTemplate(name)
+		tmpl, err := parseTemplate(path.Base(filename), text, funcs, string(src))
+		if err != nil {
+			return "", fmt.Errorf("renderPartial %s: %w", name, err)
+		}
+		var buf bytes.Buffer
+		if err = tmpl.Execute(&buf, data); err != nil {
+			return "", fmt.Errorf("renderPartial %s: %w", name, err)
+		}
+		if text {
+			return buf.String(), nil
+		}
+		// The partial was escaped when it was rendered
+		return template.HTML(buf.String()), nil
+	}
+}
+
+// partialTrace describes the chain of partials leading to name
+func partialTrace(stack []partialFrame, name string) string {
+	names := make([]string, 0, len(stack)+1)
+	for _, f := range stack {
+		names = append(names, f.name)
+	}
+	return strings.Join(append(names, name), " -> ")
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFuncMap_RenderPartial(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"page.html": `<ul>{{renderPartial "tree.html" .Data.tree}}</ul>`,
+		"tree.html": `<li>{{.name}}{{with .children}}<ul>{{range .}}{{renderPartial "tree.html" .}}{{end}}</ul>{{end}}</li>`,
+		"loop.html": `{{renderPartial "loop.html" .}}`,
+		"deep.html": `{{renderPartial "deep.html" (add1 .)}}`,
+		"self.html": `{{renderPartial "loop.html" .Data}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create template: %v", err)
+		}
+	}
+	tree := map[string]any{
+		"name": "<root>",
+		"children": []any{
+			map[string]any{"name": "a", "children": []any{map[string]any{"name": "a1"}}},
+			map[string]any{"name": "b"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		data     any
+		depth    int
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "nested tree",
+			template: "page.html",
+			data:     TemplateData{Data: map[string]any{"tree": tree}},
+			want:     "<ul><li>&lt;root&gt;<ul><li>a<ul><li>a1</li></ul></li><li>b</li></ul></li></ul>",
+		},
+		{
+			name:     "cycle",
+			template: "self.html",
+			data:     TemplateData{Data: 1},
+			wantErr:  "cycle loop.html -> loop.html",
+		},
+		{
+			name:     "depth limit",
+			template: "deep.html",
+			data:     0,
+			depth:    3,
+			wantErr:  "depth limit 3 exceeded",
+		},
+		{
+			name:     "default depth limit",
+			template: "deep.html",
+			data:     0,
+			wantErr:  "depth limit 10 exceeded",
+		},
+		{
+			name:     "tree within depth limit",
+			template: "page.html",
+			data:     TemplateData{Data: map[string]any{"tree": tree}},
+			depth:    3,
+			want:     "<ul><li>&lt;root&gt;<ul><li>a<ul><li>a1</li></ul></li><li>b</li></ul></li></ul>",
+		},
+		{
+			name:     "tree beyond depth limit",
+			template: "page.html",
+			data:     TemplateData{Data: map[string]any{"tree": tree}},
+			depth:    2,
+			wantErr:  "depth limit 2 exceeded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{ConfigFilePath: filepath.Join(tempDir, "config.yaml")}
+			config.Render.PartialDepth = tt.depth
+			tmpl, err := config.LoadTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("LoadTemplate() error: %v", err)
+			}
+			var buf strings.Builder
+			err = tmpl.Execute(&buf, tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Execute() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("output = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
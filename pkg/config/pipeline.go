@@ -0,0 +1,138 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+)
+
+// PipelineStep is one stage of a render pipeline. Exactly one field is set:
+// Layout renders a layout template around the page, which it receives as
+// .Content; Output converts the page to an output format and must come last.
+type PipelineStep struct {
+	Layout string `yaml:"layout,omitempty"`
+	Output string `yaml:"output,omitempty"`
+}
+
+// PipelineSteps returns the steps a route's rendered page goes through: its
+// named pipeline, or the conversion to its output format
+func (c *Config) PipelineSteps(t *Template) []PipelineStep {
+	switch {
+	case t == nil:
+		return nil
+	case t.Pipeline != "":
+		return c.Pipelines[t.Pipeline]
+	case t.Output != "":
+		return []PipelineStep{{Output: t.Output}}
+	}
+	return nil
+}
+
+// OutputFormat returns the format a route's page is converted to, or "" if
+// it is sent as rendered
+func (c *Config) OutputFormat(t *Template) string {
+	steps := c.PipelineSteps(t)
+	if len(steps) == 0 {
+		return ""
+	}
+	return steps[len(steps)-1].Output
+}
+
+// RunPipeline passes a page rendered from the named template through the
+// steps and returns the result, with its content type if an output step
+// converted it. Layouts are loaded with load and executed with data.
+func (c *Config) RunPipeline(steps []PipelineStep, page []byte, pageTemplate string, data TemplateData,
+	load func(name string) (Executable, error)) ([]byte, string, error) {
+	contentType := ""
+	// Pages rendered with text/template are not escaped
+	text := c.isTextTemplate(pageTemplate)
+	for _, step := range steps {
+		if step.Output != "" {
+			out, ct, err := c.ConvertOutput(step.Output, page)
+			if err != nil {
+				return nil, "", err
+			}
+			page, contentType = out, ct
+			continue
+		}
+		tmpl, err := load(step.Layout)
+		if err != nil {
+			return nil, "", fmt.Errorf("layout %s: %w", step.Layout, err)
+		}
+		layoutText := c.isTextTemplate(step.Layout)
+		if text && !layoutText {
+			data.Content = template.HTML(template.HTMLEscapeString(string(page)))
+		} else {
+			// The page was escaped when it was rendered, or goes into a
+			// layout that is not escaped either
+			data.Content = template.HTML(page)
+		}
+		var buf bytes.Buffer
+		if err = tmpl.Execute(&buf, data); err != nil {
+			return nil, "", fmt.Errorf("layout %s: %w", step.Layout, err)
+		}
+		page, text = buf.Bytes(), layoutText
+	}
+	return page, contentType, nil
+}
+
+// WithoutLayouts returns the steps other than layouts, for fragments of a
+// page that are inserted into a page that already has its layout
+func WithoutLayouts(steps []PipelineStep) []PipelineStep {
+	var out []PipelineStep
+	for _, step := range steps {
+		if step.Layout == "" {
+			out = append(out, step)
+		}
+	}
+	return out
+}
+
+// validatePipelines checks the pipeline definitions and the routes using them
+func (c *Config) validatePipelines() error {
+	for name, steps := range c.Pipelines {
+		if len(steps) == 0 {
+			return fmt.Errorf("pipeline '%s': no steps", name)
+		}
+		for i, step := range steps {
+			switch {
+			case (step.Layout == "") == (step.Output == ""):
+				return fmt.Errorf("pipeline '%s' step %d: set exactly one of layout and output", name, i+1)
+			case step.Output != "" && i != len(steps)-1:
+				return fmt.Errorf("pipeline '%s' step %d: output must be the last step", name, i+1)
+			case step.Output != "":
+				if _, ok := outputFormats[step.Output]; !ok {
+					return fmt.Errorf("pipeline '%s' step %d: unknown output '%s'", name, i+1, step.Output)
+				}
+			}
+		}
+	}
+	for _, t := range c.Templates {
+		if t.Pipeline == "" {
+			continue
+		}
+		if _, ok := c.Pipelines[t.Pipeline]; !ok {
+			return fmt.Errorf("pattern '%s': unknown pipeline '%s'", t.Pattern, t.Pipeline)
+		}
+		if t.Output != "" {
+			return fmt.Errorf("pattern '%s': output and pipeline are mutually exclusive", t.Pattern)
+		}
+	}
+	return nil
+}
+
+// pipelineLayouts returns the layout templates used by the pipelines, in
+// sorted order
+func (c *Config) pipelineLayouts() []string {
+	var names []string
+	for _, steps := range c.Pipelines {
+		for _, step := range steps {
+			if step.Layout != "" {
+				names = append(names, step.Layout)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
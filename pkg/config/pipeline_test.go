@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/vcard"
+)
+
+func TestRunPipeline(t *testing.T) {
+	tempDir := t.TempDir()
+	layouts := map[string]string{
+		"base.html":  `<main>{{.Content}}</main>`,
+		"outer.html": `<html>{{.Content}}<p>{{.RequestURI}}</p></html>`,
+		"wrap.txt":   `[{{.Content}}]`,
+	}
+	for name, content := range layouts {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create template: %v", err)
+		}
+	}
+	config := &Config{
+		ConfigFilePath: filepath.Join(tempDir, "config.yaml"),
+		Pipelines: map[string][]PipelineStep{
+			"page":  {{Layout: "base.html"}, {Layout: "outer.html"}},
+			"cards": {{Layout: "base.html"}, {Output: OutputVCF}},
+			"plain": {{Layout: "wrap.txt"}},
+			"mixed": {{Layout: "wrap.txt"}, {Layout: "base.html"}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		route       *Template
+		page        string
+		want        string
+		contentType string
+	}{
+		{name: "nested layouts", route: &Template{Pipeline: "page"}, want: "<html><main><b>x</b></main><p>/doc</p></html>"},
+		{name: "layout then output", route: &Template{Pipeline: "cards"}, want: "<main><b>x</b></main>", contentType: vcard.ContentType},
+		{name: "output only", route: &Template{Output: OutputVCF}, want: "<b>x</b>", contentType: vcard.ContentType},
+		{name: "no pipeline", route: &Template{}, want: "<b>x</b>"},
+		// A text page is not escaped, so an HTML layout escapes it
+		{name: "text page in HTML layout", route: &Template{Pipeline: "page"}, page: "page.txt",
+			want: "<html><main>&lt;b&gt;x&lt;/b&gt;</main><p>/doc</p></html>"},
+		{name: "text page in text layout", route: &Template{Pipeline: "plain"}, page: "page.txt", want: "[<b>x</b>]"},
+		{name: "text layout in HTML layout", route: &Template{Pipeline: "mixed"}, page: "page.txt",
+			want: "<main>[&lt;b&gt;x&lt;/b&gt;]</main>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := config.PipelineSteps(tt.route)
+			page := tt.page
+			if page == "" {
+				page = "page.html"
+			}
+			out, contentType, err := config.RunPipeline(steps, []byte("<b>x</b>"), page, TemplateData{RequestURI: "/doc"},
+				config.LoadTemplate)
+			if err != nil {
+				t.Fatalf("RunPipeline() error: %v", err)
+			}
+			if contentType != tt.contentType {
+				t.Errorf("content type = %q, want %q", contentType, tt.contentType)
+			}
+			if tt.contentType == "" && string(out) != tt.want {
+				t.Errorf("output = %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePipelines(t *testing.T) {
+	tests := []struct {
+		name      string
+		pipelines map[string][]PipelineStep
+		route     Template
+		wantErr   string
+	}{
+		{name: "valid", pipelines: map[string][]PipelineStep{"p": {{Layout: "a.html"}, {Output: OutputPDF}}}, route: Template{Pipeline: "p"}},
+		{name: "empty", pipelines: map[string][]PipelineStep{"p": {}}, wantErr: "no steps"},
+		{name: "empty step", pipelines: map[string][]PipelineStep{"p": {{}}}, wantErr: "exactly one"},
+		{name: "both in step", pipelines: map[string][]PipelineStep{"p": {{Layout: "a.html", Output: OutputPDF}}}, wantErr: "exactly one"},
+		{name: "output not last", pipelines: map[string][]PipelineStep{"p": {{Output: OutputPDF}, {Layout: "a.html"}}}, wantErr: "must be the last"},
+		{name: "unknown output", pipelines: map[string][]PipelineStep{"p": {{Output: "docx"}}}, wantErr: "unknown output"},
+		{name: "unknown pipeline", route: Template{Pattern: "^/", Pipeline: "missing"}, wantErr: "unknown pipeline"},
+		{name: "output and pipeline", pipelines: map[string][]PipelineStep{"p": {{Layout: "a.html"}}}, route: Template{Pattern: "^/", Pipeline: "p", Output: OutputPDF}, wantErr: "mutually exclusive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Pipelines: tt.pipelines, Templates: []Template{tt.route}}
+			err := c.validatePipelines()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validatePipelines() error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validatePipelines() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+)
+
+// printSuffix is the path suffix that asks for a route's print variant, as
+// an alternative to a print=1 query parameter
+const printSuffix = "/print"
+
+// PrintRequest reports whether uri asks for a print variant, either with a
+// print=1 query parameter or a /print path suffix, and returns the URI of
+// the page itself
+func PrintRequest(uri string) (string, bool) {
+	p, rawQuery, _ := strings.Cut(uri, "?")
+	if rawQuery != "" {
+		query, err := url.ParseQuery(rawQuery)
+		if err == nil && query.Get("print") == "1" {
+			query.Del("print")
+			return withQuery(p, query.Encode()), true
+		}
+	}
+	if trimmed := strings.TrimSuffix(p, "/"); strings.HasSuffix(trimmed, printSuffix) {
+		page := strings.TrimSuffix(trimmed, printSuffix)
+		if page == "" {
+			page = "/"
+		}
+		return withQuery(page, rawQuery), true
+	}
+	return uri, false
+}
+
+// PrintURL returns the URI of the print variant of the page at uri
+func PrintURL(uri string) string {
+	p, rawQuery, _ := strings.Cut(uri, "?")
+	if rawQuery == "" {
+		return p + "?print=1"
+	}
+	return p + "?" + rawQuery + "&print=1"
+}
+
+// withQuery joins a path and a raw query
+func withQuery(p, rawQuery string) string {
+	if rawQuery == "" {
+		return p
+	}
+	return p + "?" + rawQuery
+}
@@ -0,0 +1,35 @@
+package config
+
+import "testing"
+
+func TestPrintRequest(t *testing.T) {
+	tests := []struct {
+		uri          string
+		expectedPage string
+		expectedOK   bool
+	}{
+		{"/article/1", "/article/1", false},
+		{"/article/1?print=1", "/article/1", true},
+		{"/article/1?lang=de&print=1", "/article/1?lang=de", true},
+		{"/article/1?print=0", "/article/1?print=0", false},
+		{"/article/1/print", "/article/1", true},
+		{"/article/1/print/?lang=de", "/article/1?lang=de", true},
+		{"/print", "/", true},
+		{"/blueprint", "/blueprint", false},
+	}
+	for _, tt := range tests {
+		page, ok := PrintRequest(tt.uri)
+		if page != tt.expectedPage || ok != tt.expectedOK {
+			t.Errorf("PrintRequest(%q) = %q, %v, want %q, %v", tt.uri, page, ok, tt.expectedPage, tt.expectedOK)
+		}
+	}
+}
+
+func TestPrintURL(t *testing.T) {
+	if got := PrintURL("/article/1"); got != "/article/1?print=1" {
+		t.Errorf("PrintURL() = %q", got)
+	}
+	if got := PrintURL("/article/1?lang=de"); got != "/article/1?lang=de&print=1" {
+		t.Errorf("PrintURL() = %q", got)
+	}
+}
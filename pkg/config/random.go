@@ -0,0 +1,39 @@
+package config
+
+import (
+	"html/template"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.mhn.org/tmpl.cgi/pkg/token"
+)
+
+// randomFuncs returns the functions generating identifiers and random
+// strings. They replace sprig's random string helpers, which return an
+// empty string if crypto/rand fails, and its randInt, which uses
+// math/rand, so that any of them can be used for tokens.
+func randomFuncs() template.FuncMap {
+	return template.FuncMap{
+		"randomToken": token.New,
+		"ulid": func() (string, error) {
+			return token.ULID(time.Now())
+		},
+		"uuidv7": func() (string, error) {
+			id, err := uuid.NewV7()
+			return id.String(), err
+		},
+		"randAlphaNum": func(n int) (string, error) {
+			return token.String(n, token.AlphaNumeric)
+		},
+		"randAlpha": func(n int) (string, error) {
+			return token.String(n, token.Alpha)
+		},
+		"randNumeric": func(n int) (string, error) {
+			return token.String(n, token.Numeric)
+		},
+		"randAscii": func(n int) (string, error) {
+			return token.String(n, token.ASCII)
+		},
+		"randInt": token.Int,
+	}
+}
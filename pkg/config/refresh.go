@@ -0,0 +1,96 @@
+package config
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MinDataRefresh is the shortest refresh interval of a data source
+const MinDataRefresh = time.Second
+
+// dataRefresh holds the values of data sources refreshed in the background.
+// The map is fixed when refreshing starts; each value is swapped whole, so
+// that a request sees either the previous or the new value of a source.
+type dataRefresh struct {
+	values map[string]*atomic.Pointer[any]
+	stop   chan struct{}
+	done   sync.WaitGroup
+}
+
+// value returns the last refreshed value of a data source, if it has been
+// loaded yet
+func (r *dataRefresh) value(name string) (any, bool) {
+	if r == nil {
+		return nil, false
+	}
+	p, ok := r.values[name]
+	if !ok {
+		return nil, false
+	}
+	v := p.Load()
+	if v == nil {
+		return nil, false
+	}
+	return *v, true
+}
+
+// StartDataRefresh reloads the data sources that have a refresh interval in
+// the background, for long-running servers, so that requests use the last
+// loaded value instead of loading the source themselves. A source is used
+// per request until its first load completes, and keeps its previous value
+// when a reload fails. The returned function stops refreshing. c is read
+// until then, so it must not be modified; copies of it share the values.
+func (c *Config) StartDataRefresh() (stop func()) {
+	r := &dataRefresh{values: map[string]*atomic.Pointer[any]{}, stop: make(chan struct{})}
+	for i := range c.DataSources {
+		if c.DataSources[i].Refresh > 0 {
+			r.values[c.DataSources[i].Name] = &atomic.Pointer[any]{}
+		}
+	}
+	if len(r.values) == 0 {
+		return func() {}
+	}
+	c.refresh = r
+	for i := range c.DataSources {
+		s := c.DataSources[i]
+		if s.Refresh <= 0 {
+			continue
+		}
+		// Cached HTTP responses must not outlive the refresh interval
+		if s.TTL <= 0 || s.TTL > s.Refresh {
+			s.TTL = s.Refresh
+		}
+		r.done.Add(1)
+		go c.refreshDataSource(r, &s)
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(r.stop)
+			r.done.Wait()
+		})
+	}
+}
+
+// refreshDataSource loads a data source now and on every tick of its
+// refresh interval until stopped
+func (c *Config) refreshDataSource(r *dataRefresh, s *DataSource) {
+	defer r.done.Done()
+	ticker := time.NewTicker(s.Refresh)
+	defer ticker.Stop()
+	for {
+		v, err := c.loadDataSource(s)
+		if err != nil {
+			log.Printf("refreshing %v", err)
+		} else {
+			r.values[s.Name].Store(&v)
+		}
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/fetch"
+	"gopkg.mhn.org/tmpl.cgi/pkg/s3"
+)
+
+// DefaultRemoteTTL is how long a remote template or data file is used
+// before it is revalidated
+const DefaultRemoteTTL = time.Minute
+
+// Remote configures how templates and data files given as s3:// or
+// http(s):// URLs are fetched and cached. S3 credentials come from the
+// standard AWS environment variables.
+type Remote struct {
+	CacheDir string        `yaml:"cache_dir,omitempty"`
+	TTL      time.Duration `yaml:"ttl,omitempty"`
+	MaxSize  ByteSize      `yaml:"max_size,omitempty"`
+	Region   string        `yaml:"region,omitempty"`
+	Endpoint string        `yaml:"endpoint,omitempty"`
+}
+
+// isRemote reports whether a template or data file name is a URL
+func isRemote(name string) bool {
+	return strings.HasPrefix(name, "s3://") || strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// remotePath joins a file name to a remote directory URL, keeping the
+// name from climbing out of the directory
+func remotePath(dir, name string) string {
+	return strings.TrimSuffix(dir, "/") + path.Clean("/"+name)
+}
+
+// remoteFile returns the path of a local copy of a remote template or data
+// file, fetching or revalidating it once the cached copy is older than the
+// TTL. Files next to a remote config are fetched like the config and must
+// be signed with its key.
+func (c *Config) remoteFile(name string) (string, error) {
+	ttl := c.Remote.TTL
+	if ttl <= 0 {
+		ttl = DefaultRemoteTTL
+	}
+	opts := fetch.Options{CacheDir: c.Remote.CacheDir, TTL: ttl, MaxSize: int64(c.Remote.MaxSize)}
+	target := name
+	if strings.HasPrefix(name, "s3://") {
+		client := s3.FromEnv(c.Remote.Region, c.Remote.Endpoint)
+		u, err := client.ObjectURL(name)
+		if err != nil {
+			return "", err
+		}
+		target = u
+		opts.Sign = func(req *http.Request) { client.Sign(req, time.Now()) }
+	}
+	signed := c.nextToRemoteConfig(name)
+	if signed {
+		opts.Client = configClient
+	}
+	local, err := fetch.File(target, opts)
+	if err != nil {
+		return "", fmt.Errorf("fetching %s: %w", name, err)
+	}
+	if signed {
+		if err = verifySignedFile(target, local, opts); err != nil {
+			return "", fmt.Errorf("fetching %s: %w", name, err)
+		}
+	}
+	return local, nil
+}
+
+// localFile returns the path of a template or data file on disk, fetching
+// remote ones
+func (c *Config) localFile(name string) (string, error) {
+	if isRemote(name) {
+		return c.remoteFile(name)
+	}
+	return name, nil
+}
+
+// urlPath returns the path of a URL, without the query, for naming the
+// file it refers to
+func urlPath(name string) string {
+	if u, err := url.Parse(name); err == nil && u.Path != "" {
+		return u.Path
+	}
+	return name
+}
+
+// validateRemote checks the remote settings and the settings that must
+// refer to local files
+func (c *Config) validateRemote() error {
+	if c.Remote.MaxSize < 0 {
+		return fmt.Errorf("remote: max_size must not be negative")
+	}
+	if c.Remote.Endpoint != "" {
+		if u, err := url.Parse(c.Remote.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("remote: endpoint must be an http(s) URL")
+		}
+	}
+	if c.SSI.Enabled && c.SSI.Root == "" && isRemote(c.templatePath(".")) {
+		return fmt.Errorf("ssi: root is required with a remote template_dir")
+	}
+	if c.DataDir != "" && isRemote(c.resolvePath(c.DataDir)) {
+		return fmt.Errorf("data_dir: must be a local directory")
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRemoteTemplatesAndData(t *testing.T) {
+	var requests atomic.Int32
+	var auth atomic.Value
+	auth.Store("")
+	files := map[string]string{
+		"/site/templates/page.html":      `{{.Data.site.name}}: {{renderPartial "parts/nav.html" .}}`,
+		"/site/templates/parts/nav.html": `nav`,
+		"/site/data/site.yaml":           `name: Example`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		auth.Store(r.Header.Get("Authorization"))
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		etag := `"` + r.URL.Path + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	tests := []struct {
+		name       string
+		config     string
+		wantSigned bool
+	}{
+		{"HTTP", `template_dir: "` + ts.URL + `/site/templates/"
+data_files: ["` + ts.URL + `/site/data/site.yaml?v=2"]
+remote:
+  ttl: 1m`, false},
+		{"S3", `template_dir: "s3://site/templates"
+data_files: ["s3://site/data/site.yaml"]
+remote:
+  endpoint: "` + ts.URL + `"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			content := "default_template: page.html\n" + tt.config + "\n  cache_dir: " + filepath.Join(t.TempDir(), "cache") + "\n"
+			if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(content), 0644); err != nil {
+				t.Fatalf("WriteFile() error: %v", err)
+			}
+			config, err := ParseConfigFile(filepath.Join(dir, "config.yaml"))
+			if err != nil {
+				t.Fatalf("ParseConfigFile() error: %v", err)
+			}
+			if err = config.Validate(); err != nil {
+				t.Fatalf("Validate() error: %v", err)
+			}
+			tmpl, err := config.LoadTemplate("page.html")
+			if err != nil {
+				t.Fatalf("LoadTemplate() error: %v", err)
+			}
+			var buf bytes.Buffer
+			if err = tmpl.Execute(&buf, TemplateData{Data: config.Data}); err != nil {
+				t.Fatalf("Execute() error: %v", err)
+			}
+			if got := buf.String(); got != "Example: nav" {
+				t.Errorf("rendered %q, want %q", got, "Example: nav")
+			}
+			signed := strings.HasPrefix(auth.Load().(string), "AWS4-HMAC-SHA256 Credential=AKID/")
+			if signed != tt.wantSigned {
+				t.Errorf("request authorization = %q, want signed %v", auth.Load(), tt.wantSigned)
+			}
+			if dirs := config.TemplateDirs(); len(dirs) != 0 {
+				t.Errorf("TemplateDirs() = %v, want no local directories", dirs)
+			}
+
+			// Cached copies are used within the TTL
+			before := requests.Load()
+			if _, err = config.LoadTemplate("page.html"); err != nil {
+				t.Fatalf("LoadTemplate() error: %v", err)
+			}
+			if n := requests.Load(); n != before {
+				t.Errorf("LoadTemplate() made %d requests within the TTL", n-before)
+			}
+		})
+	}
+}
+
+func TestValidateRemote(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{"bad endpoint", Config{Remote: Remote{Endpoint: "minio:9000"}}, "endpoint must be an http(s) URL"},
+		{"remote ssi", Config{TemplateDir: "s3://site/templates", SSI: SSI{Enabled: true}}, "ssi: root is required"},
+		{"remote data_dir", Config{DataDir: "https://example.com/data"}, "must be a local directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validateRemote(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateRemote() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+	if got := remotePath("s3://site/templates/", "../../secret.html"); got != "s3://site/templates/secret.html" {
+		t.Errorf("remotePath() = %q", got)
+	}
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/fetch"
+)
+
+// Environment variables of remote configs
+const (
+	// ConfigKeyEnv holds the base64 Ed25519 public key that remote configs
+	// must be signed with
+	ConfigKeyEnv = "TMPL_CGI_CONFIG_KEY"
+	// ConfigTTLEnv is how long a fetched config is used before it is
+	// fetched again
+	ConfigTTLEnv = "TMPL_CGI_CONFIG_TTL"
+	// ConfigCacheEnv is the directory fetched configs are cached in
+	ConfigCacheEnv = "TMPL_CGI_CONFIG_CACHE"
+)
+
+// DefaultConfigTTL is how long a fetched config is used by default
+const DefaultConfigTTL = 5 * time.Minute
+
+// SignatureSuffix is appended to the path of a remote config to get the
+// URL of its signature
+const SignatureSuffix = ".sig"
+
+// configClient fetches remote configs and their signatures
+var configClient = &http.Client{Timeout: 10 * time.Second}
+
+// isRemoteConfig reports whether a config path is a URL
+func isRemoteConfig(filePath string) bool {
+	return strings.HasPrefix(filePath, "https://") || strings.HasPrefix(filePath, "http://")
+}
+
+// configKey returns the public key remote configs are verified with
+func configKey() (ed25519.PublicKey, error) {
+	encoded := strings.TrimSpace(os.Getenv(ConfigKeyEnv))
+	if encoded == "" {
+		return nil, fmt.Errorf("%s must hold the public key of remote configs", ConfigKeyEnv)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%s is not a base64 Ed25519 public key", ConfigKeyEnv)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// signatureURL returns the URL of a remote config's signature
+func signatureURL(configURL string) string {
+	u, err := url.Parse(configURL)
+	if err != nil {
+		return configURL + SignatureSuffix
+	}
+	u.Path += SignatureSuffix
+	u.RawPath = ""
+	return u.String()
+}
+
+// readRemoteConfig fetches a config over HTTPS and checks its Ed25519
+// signature. Verified configs are cached with their signature and checked
+// again when read from the cache, which is used for the TTL and whenever
+// the server cannot be reached or serves a config that fails verification.
+func readRemoteConfig(configURL string) ([]byte, error) {
+	if !strings.HasPrefix(configURL, "https://") {
+		return nil, fmt.Errorf("remote config %s must be fetched over https", configURL)
+	}
+	key, err := configKey()
+	if err != nil {
+		return nil, err
+	}
+	ttl := DefaultConfigTTL
+	if v := os.Getenv(ConfigTTLEnv); v != "" {
+		if ttl, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("%s: %w", ConfigTTLEnv, err)
+		}
+	}
+	cacheFile, cacheErr := remoteConfigCacheFile(configURL)
+	var cached []byte
+	if cacheErr == nil {
+		cached, cacheErr = readCachedConfig(cacheFile, key)
+	}
+	if cacheErr == nil {
+		if st, err := os.Stat(cacheFile); err == nil && time.Since(st.ModTime()) < ttl {
+			return cached, nil
+		}
+	}
+
+	data, err := fetchSignedConfig(configURL, key)
+	if err != nil {
+		if cacheErr == nil {
+			log.Printf("using cached config: %v", err)
+			return cached, nil
+		}
+		return nil, err
+	}
+	// Caching is best-effort, as for other fetched files
+	if cacheFile != "" {
+		_ = writeCachedConfig(cacheFile, data)
+	}
+	return data[ed25519.SignatureSize:], nil
+}
+
+// fetchSignedConfig fetches a config and its signature, returning the
+// signature followed by the config once it is verified
+func fetchSignedConfig(configURL string, key ed25519.PublicKey) ([]byte, error) {
+	opts := fetch.Options{Client: configClient}
+	body, err := fetch.Get(configURL, opts)
+	if err != nil {
+		return nil, fmt.Errorf("fetching config %s: %w", configURL, err)
+	}
+	sigURL := signatureURL(configURL)
+	raw, err := fetch.Get(sigURL, opts)
+	if err != nil {
+		return nil, fmt.Errorf("fetching signature %s: %w", sigURL, err)
+	}
+	sig, ok := decodeSignature(raw)
+	if !ok {
+		return nil, fmt.Errorf("signature %s is not an Ed25519 signature", sigURL)
+	}
+	if !ed25519.Verify(key, body, sig) {
+		return nil, fmt.Errorf("config %s does not match its signature", configURL)
+	}
+	return append(sig, body...), nil
+}
+
+// decodeSignature returns the Ed25519 signature held by a signature file,
+// raw or base64
+func decodeSignature(raw []byte) ([]byte, bool) {
+	if len(raw) == ed25519.SignatureSize {
+		return raw, true
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	return sig, err == nil && len(sig) == ed25519.SignatureSize
+}
+
+// remoteConfigCacheFile returns the cache path of a remote config, in the
+// directory named by ConfigCacheEnv or the default cache directory
+func remoteConfigCacheFile(configURL string) (string, error) {
+	dir, err := fetch.CacheDir(os.Getenv(ConfigCacheEnv))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(configURL))
+	return filepath.Join(dir, "config-"+hex.EncodeToString(sum[:])), nil
+}
+
+// readCachedConfig reads a cached config and verifies it again, since the
+// cache directory may be writable by others
+func readCachedConfig(cacheFile string, key ed25519.PublicKey) ([]byte, error) {
+	data, err := os.ReadFile(cacheFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < ed25519.SignatureSize || !ed25519.Verify(key, data[ed25519.SignatureSize:], data[:ed25519.SignatureSize]) {
+		return nil, fmt.Errorf("cached config %s does not match its signature", cacheFile)
+	}
+	return data[ed25519.SignatureSize:], nil
+}
+
+// writeCachedConfig writes a signed config via a temporary file and rename
+func writeCachedConfig(cacheFile string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(cacheFile), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(cacheFile), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), cacheFile)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// remoteConfigDir returns the URL of the directory of a remote config, that
+// relative template and data file names are resolved against
+func remoteConfigDir(configURL string) string {
+	u, err := url.Parse(configURL)
+	if err != nil {
+		return configURL
+	}
+	u.Path = path.Dir(u.Path)
+	u.RawQuery = ""
+	return u.String()
+}
+
+// nextToRemoteConfig reports whether a remote file lies in the directory of
+// a remote config, as files named relative to it do. Such files are part
+// of the config and must be signed like it.
+func (c *Config) nextToRemoteConfig(fileURL string) bool {
+	if !isRemoteConfig(c.ConfigFilePath) {
+		return false
+	}
+	return strings.HasPrefix(fileURL, strings.TrimSuffix(remoteConfigDir(c.ConfigFilePath), "/")+"/")
+}
+
+// verifySignedFile checks the fetched copy of a file next to a remote config
+// against its detached signature, fetched from the file's URL with
+// SignatureSuffix appended. The check runs on every use of the copy, cached
+// or not. If it fails, both are fetched again once, in case one of them was
+// updated before the other.
+func verifySignedFile(fileURL, local string, opts fetch.Options) error {
+	key, err := configKey()
+	if err != nil {
+		return err
+	}
+	if err = checkFileSignature(fileURL, local, key, opts); err == nil {
+		return nil
+	}
+	opts.TTL = 0
+	if _, err = fetch.File(fileURL, opts); err != nil {
+		return err
+	}
+	return checkFileSignature(fileURL, local, key, opts)
+}
+
+// checkFileSignature verifies a local copy of fileURL with its signature
+func checkFileSignature(fileURL, local string, key ed25519.PublicKey, opts fetch.Options) error {
+	sigURL := signatureURL(fileURL)
+	sigFile, err := fetch.File(sigURL, opts)
+	if err != nil {
+		return fmt.Errorf("fetching signature %s: %w", sigURL, err)
+	}
+	raw, err := os.ReadFile(sigFile)
+	if err != nil {
+		return err
+	}
+	sig, ok := decodeSignature(raw)
+	if !ok {
+		return fmt.Errorf("signature %s is not an Ed25519 signature", sigURL)
+	}
+	body, err := os.ReadFile(local)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, body, sig) {
+		return fmt.Errorf("%s does not match its signature", fileURL)
+	}
+	return nil
+}
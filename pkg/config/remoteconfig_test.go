@@ -0,0 +1,154 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseConfigFile_Remote(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	var body, sig atomic.Value
+	publish := func(config string, signer ed25519.PrivateKey) {
+		body.Store(config)
+		sig.Store(base64.StdEncoding.EncodeToString(ed25519.Sign(signer, []byte(config))) + "\n")
+	}
+	var requests atomic.Int32
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/fleet/site.yaml":
+			_, _ = w.Write([]byte(body.Load().(string)))
+		case "/fleet/site.yaml.sig":
+			_, _ = w.Write([]byte(sig.Load().(string)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	defer func(c *http.Client) { configClient = c }(configClient)
+	configClient = ts.Client()
+
+	t.Setenv(ConfigKeyEnv, base64.StdEncoding.EncodeToString(pub))
+	t.Setenv(ConfigCacheEnv, filepath.Join(t.TempDir(), "cache"))
+	t.Setenv(ConfigTTLEnv, "1h")
+	configURL := ts.URL + "/fleet/site.yaml"
+
+	publish(`default_template: "v1.html"`, priv)
+	config, err := ParseConfigFile(configURL)
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	if config.DefaultTemplate != "v1.html" {
+		t.Errorf("DefaultTemplate = %q, want v1.html", config.DefaultTemplate)
+	}
+	if got, want := config.templatePath("v1.html"), ts.URL+"/fleet/v1.html"; got != want {
+		t.Errorf("templatePath() = %q, want %q next to the config", got, want)
+	}
+
+	// Within the TTL the cached config is used
+	publish(`default_template: "v2.html"`, priv)
+	before := requests.Load()
+	if config, err = ParseConfigFile(configURL); err != nil || config.DefaultTemplate != "v1.html" {
+		t.Errorf("ParseConfigFile() within the TTL = %v, %v", config, err)
+	}
+	if requests.Load() != before {
+		t.Error("ParseConfigFile() fetched the config within the TTL")
+	}
+
+	// After the TTL a new config is fetched, unless it fails verification
+	t.Setenv(ConfigTTLEnv, "0s")
+	if config, err = ParseConfigFile(configURL); err != nil || config.DefaultTemplate != "v2.html" {
+		t.Fatalf("ParseConfigFile() after the TTL = %v, %v", config, err)
+	}
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	publish(`default_template: "evil.html"`, otherKey)
+	if config, err = ParseConfigFile(configURL); err != nil || config.DefaultTemplate != "v2.html" {
+		t.Errorf("ParseConfigFile() with a bad signature = %v, %v; want the cached config", config, err)
+	}
+
+	// Without a verified copy, a bad signature is an error, and so is a
+	// cache entry that was tampered with
+	cacheFile, _ := remoteConfigCacheFile(configURL)
+	data, _ := os.ReadFile(cacheFile)
+	_ = os.WriteFile(cacheFile, []byte(strings.Replace(string(data), "v2", "v3", 1)), 0600)
+	if _, err = ParseConfigFile(configURL); err == nil || !strings.Contains(err.Error(), "does not match its signature") {
+		t.Errorf("ParseConfigFile() error = %v, want a signature mismatch", err)
+	}
+}
+
+func TestParseConfigFile_RemoteSignedFiles(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("GenerateKey() error: %v", err)
+	}
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	config := "default_template: page.html\ndata_files: [\"menu.yaml\"]\nremote:\n  cache_dir: " + filepath.Join(t.TempDir(), "remote") + "\n"
+	files := map[string]string{
+		"/fleet/site.yaml":          config,
+		"/fleet/site.yaml.sig":      string(ed25519.Sign(priv, []byte(config))),
+		"/fleet/menu.yaml":          "home: /\n",
+		"/fleet/menu.yaml.sig":      base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte("home: /\n"))),
+		"/fleet/page.html":          "<p>{{.Data.menu.home}}</p>",
+		"/fleet/page.html.sig":      string(ed25519.Sign(priv, []byte("<p>{{.Data.menu.home}}</p>"))),
+		"/fleet/unsigned.html":      "<p>unsigned</p>",
+		"/fleet/forged.html":        "<p>forged</p>",
+		"/fleet/forged.html.sig":    string(ed25519.Sign(otherKey, []byte("<p>forged</p>"))),
+		"/fleet/templates/sub.html": "<p>sub</p>",
+	}
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer ts.Close()
+	defer func(c *http.Client) { configClient = c }(configClient)
+	configClient = ts.Client()
+
+	t.Setenv(ConfigKeyEnv, base64.StdEncoding.EncodeToString(pub))
+	t.Setenv(ConfigCacheEnv, filepath.Join(t.TempDir(), "cache"))
+	cfg, err := ParseConfigFile(ts.URL + "/fleet/site.yaml")
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	if menu, _ := cfg.Data.(map[string]any)["menu"].(map[string]any); menu["home"] != "/" {
+		t.Errorf("Data = %v, want the signed menu", cfg.Data)
+	}
+	if _, err = cfg.LoadTemplate("page.html"); err != nil {
+		t.Errorf("LoadTemplate() of a signed file error: %v", err)
+	}
+	for _, name := range []string{"unsigned.html", "forged.html", "templates/sub.html"} {
+		if _, err = cfg.LoadTemplate(name); err == nil {
+			t.Errorf("LoadTemplate(%s) should fail without a valid signature", name)
+		}
+	}
+}
+
+func TestReadRemoteConfig_Errors(t *testing.T) {
+	t.Setenv(ConfigKeyEnv, "")
+	if _, err := readRemoteConfig("https://config.example.com/site.yaml"); err == nil || !strings.Contains(err.Error(), ConfigKeyEnv) {
+		t.Errorf("readRemoteConfig() without a key error = %v", err)
+	}
+	t.Setenv(ConfigKeyEnv, "bm90IGEga2V5")
+	if _, err := readRemoteConfig("https://config.example.com/site.yaml"); err == nil || !strings.Contains(err.Error(), "not a base64 Ed25519 public key") {
+		t.Errorf("readRemoteConfig() with a bad key error = %v", err)
+	}
+	if _, err := readRemoteConfig("http://config.example.com/site.yaml"); err == nil || !strings.Contains(err.Error(), "https") {
+		t.Errorf("readRemoteConfig() over http error = %v", err)
+	}
+	if got, want := signatureURL("https://config.example.com/site.yaml?env=prod"), "https://config.example.com/site.yaml.sig?env=prod"; got != want {
+		t.Errorf("signatureURL() = %q, want %q", got, want)
+	}
+}
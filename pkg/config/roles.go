@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// RolesKey marks a map in the data block as visible only to users with one
+// of the listed roles (groups), or to any authenticated user with "*"
+const RolesKey = "_roles"
+
+// DataFor returns the data block of a route, or of the default template if
+// t is nil, with the data sources loaded and the env_data variables added,
+// as seen by user in the current environment: maps annotated with
+// _environments are left out in other environments, maps annotated with
+// _roles unless the user has one of their roles, and the annotations
+// themselves are removed
+func (c *Config) DataFor(t *Template, user string) (any, error) {
+	data := c.withEnvData(c.withDataSources(c.RouteData(t)))
+	if hasKey(data, EnvironmentsKey) {
+		data, _ = filterEnvironments(data, Environment())
+	}
+	if !hasKey(data, RolesKey) {
+		return data, nil
+	}
+	groups, err := c.UserGroups(user)
+	if err != nil {
+		return nil, err
+	}
+	data, _ = filterRoles(data, user, groups)
+	return data, nil
+}
+
+// DataHasRoles reports whether parts of a route's data block are restricted
+// to some roles, so that its pages depend on the user
+func (c *Config) DataHasRoles(t *Template) bool {
+	return hasKey(c.RouteData(t), RolesKey)
+}
+
+// hasKey reports whether any map in v has the given annotation key
+func hasKey(v any, key string) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		if _, ok := v[key]; ok {
+			return true
+		}
+		for _, e := range v {
+			if hasKey(e, key) {
+				return true
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if hasKey(e, key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filterRoles returns a copy of v without the maps the user may not see,
+// and whether v itself is visible
+func filterRoles(v any, user string, groups []string) (any, bool) {
+	switch v := v.(type) {
+	case map[string]any:
+		if roles, ok := v[RolesKey]; ok && !rolesAllow(roles, user, groups) {
+			return nil, false
+		}
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			if k == RolesKey {
+				continue
+			}
+			if e, ok := filterRoles(e, user, groups); ok {
+				out[k] = e
+			}
+		}
+		return out, true
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, e := range v {
+			if e, ok := filterRoles(e, user, groups); ok {
+				out = append(out, e)
+			}
+		}
+		return out, true
+	}
+	return v, true
+}
+
+// rolesAllow reports whether a _roles annotation admits the user
+func rolesAllow(roles any, user string, groups []string) bool {
+	if user == "" {
+		return false
+	}
+	for _, r := range nameList(roles) {
+		if r == AnyUser || slices.Contains(groups, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameList returns the names of an annotation such as _roles: a name or a
+// list of names
+func nameList(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var names []string
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				names = append(names, s)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// validateRoles checks the _roles annotations of the data blocks
+func (c *Config) validateRoles() error {
+	var check func(v any) error
+	check = func(v any) error {
+		switch v := v.(type) {
+		case map[string]any:
+			if roles, ok := v[RolesKey]; ok {
+				names := nameList(roles)
+				if len(names) == 0 {
+					return fmt.Errorf("data: %s must be a role or list of roles", RolesKey)
+				}
+				for _, r := range names {
+					if r != AnyUser && c.Authz.GroupFile == "" && c.Authz.LDAP == nil {
+						return fmt.Errorf("data: role '%s' needs authz.group_file or authz.ldap", r)
+					}
+				}
+			}
+			for _, e := range v {
+				if err := check(e); err != nil {
+					return err
+				}
+			}
+		case []any:
+			for _, e := range v {
+				if err := check(e); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, data := range c.dataBlocks() {
+		if err := check(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
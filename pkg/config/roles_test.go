@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDataFor(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "groups"), []byte("members: ann bob\nstaff: ann\n"), 0644); err != nil {
+		t.Fatalf("Failed to write group file: %v", err)
+	}
+	var data any
+	err := yaml.Unmarshal([]byte(`
+site: Example
+news:
+  - title: Public
+  - title: Members only
+    _roles: [members]
+  - title: Signed in
+    _roles: "*"
+staff:
+  _roles: staff
+  phone: "555-0100"
+`), &data)
+	if err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	config := &Config{
+		ConfigFilePath: filepath.Join(dir, "config.yaml"),
+		Authz:          Authz{GroupFile: "groups"},
+		Data:           data,
+	}
+	if err = config.Validate(); err != nil && !strings.Contains(err.Error(), "default template") {
+		t.Fatalf("Validate() error: %v", err)
+	}
+
+	titles := func(d any) []string {
+		var out []string
+		for _, item := range d.(map[string]any)["news"].([]any) {
+			out = append(out, item.(map[string]any)["title"].(string))
+		}
+		return out
+	}
+	tests := []struct {
+		user      string
+		titles    []string
+		seesStaff bool
+	}{
+		{"", []string{"Public"}, false},
+		{"carol", []string{"Public", "Signed in"}, false},
+		{"bob", []string{"Public", "Members only", "Signed in"}, false},
+		{"ann", []string{"Public", "Members only", "Signed in"}, true},
+	}
+	for _, tt := range tests {
+		visible, err := config.DataFor(nil, tt.user)
+		if err != nil {
+			t.Fatalf("DataFor(%q) error: %v", tt.user, err)
+		}
+		if got := titles(visible); !reflect.DeepEqual(got, tt.titles) {
+			t.Errorf("DataFor(%q) news = %v, want %v", tt.user, got, tt.titles)
+		}
+		staff, ok := visible.(map[string]any)["staff"]
+		if ok != tt.seesStaff {
+			t.Errorf("DataFor(%q) shows staff = %v, want %v", tt.user, ok, tt.seesStaff)
+		}
+		if ok {
+			if _, annotated := staff.(map[string]any)[RolesKey]; annotated {
+				t.Errorf("DataFor(%q) kept the %s annotation", tt.user, RolesKey)
+			}
+		}
+	}
+
+	// The config's own data is not modified
+	if !config.DataHasRoles(nil) || len(data.(map[string]any)["news"].([]any)) != 3 {
+		t.Errorf("DataFor() modified the config data")
+	}
+
+	config.Authz.GroupFile = ""
+	if err = config.Validate(); err == nil || !strings.Contains(err.Error(), "needs authz.group_file") {
+		t.Errorf("Validate() error = %v, want role without group source", err)
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/debug"
+)
+
+// FileEnvSuffix ends the names of environment variables that point at a
+// secret file, in the Docker convention: MAPS_KEY_FILE=/run/secrets/maps
+const FileEnvSuffix = "_FILE"
+
+// loadSecrets reads a secrets data source into a map of trimmed file
+// contents: every regular file of the directory at Path by file name, the
+// Files by key, and for each name in FileEnv the file named by NAME_FILE,
+// keyed by the name in lower case. The values are kept out of debug output.
+func (c *Config) loadSecrets(s *DataSource) (any, error) {
+	files := map[string]string{}
+	if s.Path != "" {
+		dir := c.resolvePath(s.Path)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("data source %s: %w", s.Name, err)
+		}
+		for _, e := range entries {
+			// Kubernetes mounts secrets through ..data symlinks
+			if !strings.HasPrefix(e.Name(), ".") {
+				files[e.Name()] = filepath.Join(dir, e.Name())
+			}
+		}
+	}
+	for key, path := range s.Files {
+		files[key] = c.resolvePath(path)
+	}
+	for _, name := range s.FileEnv {
+		path := os.Getenv(name + FileEnvSuffix)
+		if path == "" {
+			return nil, fmt.Errorf("data source %s: %s%s is not set", s.Name, name, FileEnvSuffix)
+		}
+		files[strings.ToLower(name)] = path
+	}
+	out := make(map[string]any, len(files))
+	for key, path := range files {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			continue
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("data source %s: %w", s.Name, err)
+		}
+		value := strings.TrimSpace(string(raw))
+		debug.AddSecret(value)
+		out[key] = value
+	}
+	return out, nil
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/debug"
+)
+
+func TestDataFor_Secrets(t *testing.T) {
+	dir := t.TempDir()
+	secretsDir := filepath.Join(dir, "secrets")
+	for name, content := range map[string]string{
+		"secrets/maps_key":      "maps-0123456789\n",
+		"secrets/.hidden":       "skip me",
+		"secrets/..data/nested": "skip me too",
+		"keys/analytics.txt":    "  UA-424242  ",
+		"env/db_password":       "hunter2hunter2",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("DB_PASSWORD_FILE", filepath.Join(dir, "env/db_password"))
+
+	config := &Config{
+		ConfigFilePath: filepath.Join(dir, "config.yaml"),
+		DataSources: []DataSource{{
+			Name:    "secrets",
+			Type:    DataSourceSecrets,
+			Path:    secretsDir,
+			Files:   map[string]string{"analytics": "keys/analytics.txt"},
+			FileEnv: []string{"DB_PASSWORD"},
+		}},
+	}
+	if err := config.validateDataSources(); err != nil {
+		t.Fatalf("validateDataSources() error: %v", err)
+	}
+	data, err := config.DataFor(nil, "")
+	if err != nil {
+		t.Fatalf("DataFor() error: %v", err)
+	}
+	expected := map[string]any{"secrets": map[string]any{
+		"maps_key":    "maps-0123456789",
+		"analytics":   "UA-424242",
+		"db_password": "hunter2hunter2",
+	}}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("DataFor() = %v, want %v", data, expected)
+	}
+
+	got := debug.Redact("key=maps-0123456789 pw=hunter2hunter2 id=UA-424242")
+	if got != "key=[REDACTED] pw=[REDACTED] id=[REDACTED]" {
+		t.Errorf("Redact() = %q, want secrets redacted", got)
+	}
+
+	t.Setenv("DB_PASSWORD_FILE", "")
+	if _, err = config.loadSecrets(&config.DataSources[0]); err == nil || !strings.Contains(err.Error(), "DB_PASSWORD_FILE is not set") {
+		t.Errorf("loadSecrets() error = %v, want DB_PASSWORD_FILE is not set", err)
+	}
+	config.DataSources[0].FileEnv = nil
+	config.DataSources[0].Path = ""
+	config.DataSources[0].Files = nil
+	if err = config.validateDataSources(); err == nil || !strings.Contains(err.Error(), "path, files or file_env is required") {
+		t.Errorf("validateDataSources() error = %v, want path, files or file_env is required", err)
+	}
+}
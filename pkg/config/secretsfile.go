@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"gopkg.in/yaml.v3"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/debug"
+)
+
+// DefaultSecretsKeyEnv holds the age identity that decrypts the secrets
+// file, unless key_env or key_file is set
+const DefaultSecretsKeyEnv = "TMPL_CGI_SECRETS_KEY"
+
+// maxSecretsFileSize bounds the decrypted secrets file
+const maxSecretsFileSize = 1 << 20
+
+// SecretsFile is an age-encrypted YAML map of environment variable names
+// to secret values, decrypted when the config is loaded
+type SecretsFile struct {
+	Path    string `yaml:"path"`
+	KeyEnv  string `yaml:"key_env,omitempty"`
+	KeyFile string `yaml:"key_file,omitempty"`
+}
+
+// envName matches the names of variables in the secrets file
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// secretEnv records the environment variables set from secrets files, so
+// that a reload can update them while variables set by the operator win
+var secretEnv = struct {
+	sync.Mutex
+	names map[string]bool
+}{names: map[string]bool{}}
+
+// secretsIdentities returns the age identities of the secrets file's key
+func (c *Config) secretsIdentities() ([]age.Identity, error) {
+	s := &c.SecretsFile
+	var key string
+	if s.KeyFile != "" {
+		raw, err := os.ReadFile(c.resolvePath(s.KeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("secrets_file: reading key: %w", err)
+		}
+		key = string(raw)
+	} else {
+		name := s.KeyEnv
+		if name == "" {
+			name = DefaultSecretsKeyEnv
+		}
+		if key = os.Getenv(name); key == "" {
+			return nil, fmt.Errorf("secrets_file: %s is not set", name)
+		}
+	}
+	identities, err := age.ParseIdentities(strings.NewReader(key))
+	if err != nil {
+		return nil, fmt.Errorf("secrets_file: parsing key: %w", err)
+	}
+	for _, line := range strings.Split(key, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "AGE-SECRET-KEY-") {
+			debug.AddSecret(line)
+		}
+	}
+	return identities, nil
+}
+
+// loadSecretsFile decrypts the secrets file into the environment, where
+// api_key_env, dsn_env, hmac_keys and the other *_env settings read them.
+// A variable already set in the environment is kept, so operators can
+// override a secret, and secret values are redacted on debug pages.
+func (c *Config) loadSecretsFile() error {
+	if c.SecretsFile.Path == "" {
+		return nil
+	}
+	identities, err := c.secretsIdentities()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(c.resolvePath(c.SecretsFile.Path))
+	if err != nil {
+		return fmt.Errorf("secrets_file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	r, err := age.Decrypt(f, identities...)
+	if err != nil {
+		return fmt.Errorf("secrets_file: decrypting: %w", err)
+	}
+	raw, err := io.ReadAll(io.LimitReader(r, maxSecretsFileSize+1))
+	if err != nil {
+		return fmt.Errorf("secrets_file: decrypting: %w", err)
+	}
+	if len(raw) > maxSecretsFileSize {
+		return fmt.Errorf("secrets_file: exceeds %d bytes", maxSecretsFileSize)
+	}
+	var values map[string]string
+	if err = yaml.Unmarshal(raw, &values); err != nil {
+		return fmt.Errorf("secrets_file: parsing: %w", err)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if !envName.MatchString(name) {
+			return fmt.Errorf("secrets_file: invalid variable name '%s'", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	secretEnv.Lock()
+	defer secretEnv.Unlock()
+	for _, name := range names {
+		if os.Getenv(name) != "" && !secretEnv.names[name] {
+			continue
+		}
+		if err = os.Setenv(name, values[name]); err != nil {
+			return fmt.Errorf("secrets_file: setting %s: %w", name, err)
+		}
+		secretEnv.names[name] = true
+		debug.AddSecret(values[name])
+	}
+	return nil
+}
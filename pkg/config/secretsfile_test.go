@@ -0,0 +1,81 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/debug"
+)
+
+func TestLoadSecretsFile(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity() error: %v", err)
+	}
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, identity.Recipient())
+	if err != nil {
+		t.Fatalf("Encrypt() error: %v", err)
+	}
+	_, _ = w.Write([]byte("SMTP_PASSWORD: s3cr3t-smtp\nAPI_TOKEN: from-file\n"))
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "secrets.age"), buf.Bytes(), 0600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	keyFile := filepath.Join(dir, "key.txt")
+	if err = os.WriteFile(keyFile, []byte("# created: 2025-01-01\n"+identity.String()+"\n"), 0600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	t.Setenv("SMTP_PASSWORD", "")
+	t.Setenv("API_TOKEN", "from-env")
+	t.Setenv(DefaultSecretsKeyEnv, identity.String())
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"key from env", `secrets_file: {path: secrets.age}`},
+		{"key file", `secrets_file: {path: secrets.age, key_file: key.txt, key_env: UNSET_KEY}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "config.yaml")
+			if err := os.WriteFile(path, []byte("default_template: page.html\n"+tt.config+"\n"), 0644); err != nil {
+				t.Fatalf("WriteFile() error: %v", err)
+			}
+			if _, err := ParseConfigFile(path); err != nil {
+				t.Fatalf("ParseConfigFile() error: %v", err)
+			}
+			if got := os.Getenv("SMTP_PASSWORD"); got != "s3cr3t-smtp" {
+				t.Errorf("SMTP_PASSWORD = %q, want the decrypted secret", got)
+			}
+			// The environment overrides the secrets file
+			if got := os.Getenv("API_TOKEN"); got != "from-env" {
+				t.Errorf("API_TOKEN = %q, want from-env", got)
+			}
+		})
+	}
+	if got := debug.Redact("password s3cr3t-smtp"); strings.Contains(got, "s3cr3t") {
+		t.Errorf("Redact() = %q, want the secret redacted", got)
+	}
+
+	// A wrong or missing key is an error
+	other, _ := age.GenerateX25519Identity()
+	t.Setenv(DefaultSecretsKeyEnv, other.String())
+	config := &Config{ConfigFilePath: filepath.Join(dir, "config.yaml"), SecretsFile: SecretsFile{Path: "secrets.age"}}
+	if err = config.loadSecretsFile(); err == nil || !strings.Contains(err.Error(), "decrypting") {
+		t.Errorf("loadSecretsFile() with a wrong key error = %v", err)
+	}
+	t.Setenv(DefaultSecretsKeyEnv, "")
+	if err = config.loadSecretsFile(); err == nil || !strings.Contains(err.Error(), DefaultSecretsKeyEnv+" is not set") {
+		t.Errorf("loadSecretsFile() without a key error = %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ByteSize
+		wantErr bool
+	}{
+		{"1024", 1024, false},
+		{"512KB", 512 << 10, false},
+		{"64MB", 64 << 20, false},
+		{"64 MiB", 64 << 20, false},
+		{"1.5G", 3 << 29, false},
+		{"10b", 10, false},
+		{"lots", 0, true},
+		{"-1MB", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseByteSize(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseByteSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseByteSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestByteSize_YAML(t *testing.T) {
+	var c Cache
+	if err := yaml.Unmarshal([]byte("template_memory: 8MB\nresponse_memory: 4096\n"), &c); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if c.TemplateLimit() != 8<<20 || c.ResponseLimit() != 4096 {
+		t.Errorf("limits = %d, %d", c.TemplateLimit(), c.ResponseLimit())
+	}
+	if (&Cache{}).ResponseLimit() != int64(DefaultResponseMemory) {
+		t.Error("ResponseLimit() should default to DefaultResponseMemory")
+	}
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql" // registers the "mysql" driver
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" driver
+	_ "modernc.org/sqlite"             // registers the "sqlite" driver
+)
+
+// DefaultQueryTimeout is how long a data source's query may run
+const DefaultQueryTimeout = 5 * time.Second
+
+// sqlDrivers maps the database server data source types to their drivers
+var sqlDrivers = map[string]string{
+	DataSourcePostgres: "pgx",
+	DataSourceMySQL:    "mysql",
+}
+
+// dbPool holds the connection pools of the database servers, shared by all
+// requests and reloads of a long-running server
+var dbPool = struct {
+	sync.Mutex
+	dbs map[string]*sql.DB
+}{dbs: map[string]*sql.DB{}}
+
+// sharedDB returns the connection pool for a database server, opening it on
+// first use. Idle connections are closed after a while, so that pools no
+// longer in use after a reload hold none.
+func sharedDB(driver, dsn string) (*sql.DB, error) {
+	dbPool.Lock()
+	defer dbPool.Unlock()
+	key := driver + "\x00" + dsn
+	if db, ok := dbPool.dbs[key]; ok {
+		return db, nil
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetConnMaxIdleTime(5 * time.Minute)
+	dbPool.dbs[key] = db
+	return db, nil
+}
+
+// sqliteDSN returns the data source name opening a SQLite file read-only
+func sqliteDSN(path string) string {
+	return "file:" + (&url.URL{Path: path}).EscapedPath() + "?mode=ro"
+}
+
+// loadSQL runs the query of a database data source. SQLite files are opened
+// for each load, so that a replaced file is picked up at once; database
+// servers are reached through a shared pool, with the DSN taken from the
+// environment so that credentials stay out of the config.
+func (c *Config) loadSQL(s *DataSource) (any, error) {
+	if s.Type == DataSourceSQLite {
+		db, err := sql.Open("sqlite", sqliteDSN(c.resolvePath(s.Path)))
+		if err != nil {
+			return nil, fmt.Errorf("data source %s: %w", s.Name, err)
+		}
+		defer func() {
+			_ = db.Close()
+		}()
+		return s.queryRows(db)
+	}
+	dsn := os.Getenv(s.DSNEnv)
+	if dsn == "" {
+		return nil, fmt.Errorf("data source %s: %s is not set", s.Name, s.DSNEnv)
+	}
+	db, err := sharedDB(sqlDrivers[s.Type], dsn)
+	if err != nil {
+		return nil, fmt.Errorf("data source %s: %w", s.Name, err)
+	}
+	return s.queryRows(db)
+}
+
+// queryRows runs the data source's query within its timeout and returns the
+// rows as a list of maps from the column names to the values
+func (s *DataSource) queryRows(db *sql.DB) (any, error) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultQueryTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, s.Query)
+	if err != nil {
+		return nil, fmt.Errorf("data source %s: %w", s.Name, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("data source %s: %w", s.Name, err)
+	}
+	out := []any{}
+	for rows.Next() {
+		values := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err = rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("data source %s: %w", s.Name, err)
+		}
+		row := make(map[string]any, len(columns))
+		for i, col := range columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[col] = values[i]
+		}
+		out = append(out, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("data source %s: %w", s.Name, err)
+	}
+	return normalizeData(out)
+}
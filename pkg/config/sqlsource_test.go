@@ -0,0 +1,129 @@
+package config
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDataFor_SQLiteDataSource(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite", filepath.Join(dir, "site db.sqlite"))
+	if err != nil {
+		t.Fatalf("sql.Open() error: %v", err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE posts (id INTEGER, title TEXT, body BLOB, score REAL)",
+		"INSERT INTO posts VALUES (1, 'Hello', x'6869', 1.5), (2, 'World', NULL, 2)",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatalf("Exec(%q) error: %v", stmt, err)
+		}
+	}
+	_ = db.Close()
+
+	config := &Config{
+		ConfigFilePath: filepath.Join(dir, "config.yaml"),
+		DataSources: []DataSource{
+			{Name: "posts", Type: DataSourceSQLite, Path: "site db.sqlite", Query: "SELECT id, title, body, score FROM posts ORDER BY id"},
+			{Name: "none", Type: DataSourceSQLite, Path: "site db.sqlite", Query: "SELECT id FROM posts WHERE id > 10"},
+			{Name: "bad", Type: DataSourceSQLite, Path: "site db.sqlite", Query: "SELECT nope FROM posts"},
+			{Name: "missing", Type: DataSourceSQLite, Path: "missing.sqlite", Query: "SELECT 1"},
+		},
+	}
+	if err = config.validateDataSources(); err != nil {
+		t.Fatalf("validateDataSources() error: %v", err)
+	}
+	data, err := config.DataFor(nil, "")
+	if err != nil {
+		t.Fatalf("DataFor() error: %v", err)
+	}
+	expected := map[string]any{
+		"posts": []any{
+			map[string]any{"id": 1, "title": "Hello", "body": "hi", "score": 1.5},
+			map[string]any{"id": 2, "title": "World", "body": nil, "score": 2},
+		},
+		"none":    []any{},
+		"bad":     nil,
+		"missing": nil,
+	}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("DataFor() = %#v, want %#v", data, expected)
+	}
+}
+
+func TestValidateDataSources_Databases(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  DataSource
+		wantErr string
+	}{
+		{name: "missing query", source: DataSource{Name: "a", Type: DataSourceSQLite, Path: "site.db"}, wantErr: "path and query are required"},
+		{name: "missing path", source: DataSource{Name: "a", Type: DataSourceSQLite, Query: "SELECT 1"}, wantErr: "path and query are required"},
+		{name: "missing dsn_env", source: DataSource{Name: "a", Type: DataSourcePostgres, Query: "SELECT 1"}, wantErr: "dsn_env and query are required"},
+		{name: "missing mysql query", source: DataSource{Name: "a", Type: DataSourceMySQL, DSNEnv: "DB_DSN"}, wantErr: "dsn_env and query are required"},
+		{name: "unknown type", source: DataSource{Name: "a", Type: "oracle"}, wantErr: "unknown type 'oracle'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{DataSources: []DataSource{tt.source}}
+			if err := config.validateDataSources(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateDataSources() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadSQL_Timeout(t *testing.T) {
+	config := &Config{ConfigFilePath: filepath.Join(t.TempDir(), "config.yaml")}
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "empty.sqlite"))
+	if err != nil {
+		t.Fatalf("sql.Open() error: %v", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	s := &DataSource{
+		Name:    "slow",
+		Query:   "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT count(*) FROM n",
+		Timeout: 50 * time.Millisecond,
+	}
+	start := time.Now()
+	if _, err = s.queryRows(db); err == nil {
+		t.Error("queryRows() succeeded, want timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("queryRows() took %v, want it cut off by the timeout", elapsed)
+	}
+
+	t.Setenv("TEST_DB_DSN", "")
+	s = &DataSource{Name: "db", Type: DataSourcePostgres, DSNEnv: "TEST_DB_DSN", Query: "SELECT 1"}
+	if _, err = config.loadSQL(s); err == nil || !strings.Contains(err.Error(), "TEST_DB_DSN is not set") {
+		t.Errorf("loadSQL() error = %v, want TEST_DB_DSN is not set", err)
+	}
+}
+
+func TestSharedDB(t *testing.T) {
+	dsn := sqliteDSN(filepath.Join(t.TempDir(), "shared.sqlite"))
+	a, err := sharedDB("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("sharedDB() error: %v", err)
+	}
+	b, err := sharedDB("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("sharedDB() error: %v", err)
+	}
+	if a != b {
+		t.Error("sharedDB() opened a second pool for the same DSN")
+	}
+	c, err := sharedDB("sqlite", sqliteDSN(filepath.Join(t.TempDir(), "other.sqlite")))
+	if err != nil {
+		t.Fatalf("sharedDB() error: %v", err)
+	}
+	if a == c {
+		t.Error("sharedDB() reused a pool for a different DSN")
+	}
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/ssi"
+)
+
+// ssiEchoFunc is the template function #echo directives are translated to
+const ssiEchoFunc = "ssiEcho"
+
+// loadSSITemplate parses a template file after translating its server-side
+// include directives, with text/template if text is set
+func (c *Config) loadSSITemplate(filename string, text bool) (Executable, error) {
+	root := c.resolvePath(c.SSI.Root)
+	if c.SSI.Root == "" {
+		root = c.templatePath(".")
+	}
+	expander := &ssi.Expander{Root: root, EchoFunc: ssiEchoFunc}
+	src, err := expander.ExpandFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse: %w", err)
+	}
+	tmpl, err := parseTemplate(path.Base(filename), text, c.funcMap(), src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse: %w", err)
+	}
+	for _, tree := range tmpl.Trees() {
+		expander.Restore(tree)
+	}
+	return tmpl, nil
+}
+
+// ssiEcho returns an SSI variable for the request being rendered
+func (c *Config) ssiEcho(data any, name string) string {
+	var r *http.Request
+	switch d := data.(type) {
+	case TemplateData:
+		r, _ = d.Request.(*http.Request)
+	case *TemplateData:
+		r, _ = d.Request.(*http.Request)
+	}
+	return ssi.Var(r, name, c.Now())
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSSITemplate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "inc"), 0755); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+	files := map[string]string{
+		"page.shtml": `<!--#include virtual="/inc/nav.html" --><p>{{.RequestURI}} from <!--#echo var="HTTP_USER_AGENT" --></p>` +
+			`<script><!--#include virtual="/inc/track.js" --></script>`,
+		"inc/nav.html": `<nav>{{.Secret}}</nav>`,
+		"inc/track.js": `var tpl = "{{user}}"; if (a < b) { track('view'); }`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+
+	cfg := &Config{ConfigFilePath: filepath.Join(dir, "config.yaml"), SSI: SSI{Enabled: true}}
+	tmpl, err := cfg.LoadTemplate(filepath.Join(dir, "page.shtml"))
+	if err != nil {
+		t.Fatalf("LoadTemplate() error: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/about", nil)
+	req.Header.Set("User-Agent", "Lynx")
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, TemplateData{RequestURI: "/about", Request: req}); err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+	// Included files are copied literally, in scripts too
+	want := `<nav>{{.Secret}}</nav><p>/about from Lynx</p>` +
+		`<script>var tpl = "{{user}}"; if (a < b) { track('view'); }</script>`
+	if buf.String() != want {
+		t.Errorf("Execute() = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxPostMemory bounds the multipart form data POST keeps in memory
+const maxPostMemory = 10 << 20
+
+// cgiServerVars are the CGI meta-variables SERVER copies from the
+// environment when tmpl.cgi runs as a CGI script. Other environment
+// variables are never exposed.
+var cgiServerVars = []string{
+	"AUTH_TYPE", "DOCUMENT_ROOT", "GATEWAY_INTERFACE", "PATH_INFO",
+	"PATH_TRANSLATED", "REMOTE_HOST", "REMOTE_USER", "SCRIPT_FILENAME",
+	"SCRIPT_NAME", "SERVER_ADDR", "SERVER_ADMIN", "SERVER_PORT",
+	"SERVER_PROTOCOL", "SERVER_SOFTWARE",
+}
+
+// httpRequest returns the request being rendered, if any
+func (d TemplateData) httpRequest() *http.Request {
+	r, _ := d.Request.(*http.Request)
+	return r
+}
+
+// GET returns the query parameters of the request, like PHP's $_GET. A
+// repeated parameter has its first value.
+func (d TemplateData) GET() map[string]string {
+	m := map[string]string{}
+	if r := d.httpRequest(); r != nil && r.URL != nil {
+		for k, v := range r.URL.Query() {
+			m[k] = v[0]
+		}
+	}
+	return m
+}
+
+// POST returns the form fields of a urlencoded or multipart request body,
+// like PHP's $_POST
+func (d TemplateData) POST() map[string]string {
+	m := map[string]string{}
+	r := d.httpRequest()
+	if r == nil {
+		return m
+	}
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "multipart/form-data" {
+		_ = r.ParseMultipartForm(maxPostMemory)
+	} else {
+		_ = r.ParseForm()
+	}
+	for k, v := range r.PostForm {
+		m[k] = v[0]
+	}
+	return m
+}
+
+// COOKIE returns the cookies of the request, like PHP's $_COOKIE
+func (d TemplateData) COOKIE() map[string]string {
+	m := map[string]string{}
+	if r := d.httpRequest(); r != nil {
+		for _, c := range r.Cookies() {
+			if _, ok := m[c.Name]; !ok {
+				m[c.Name] = c.Value
+			}
+		}
+	}
+	return m
+}
+
+// SERVER returns request and server information like PHP's $_SERVER: the
+// request line, client and server addresses, HTTP_* headers, and the CGI
+// meta-variables set by the web server
+func (d TemplateData) SERVER() map[string]string {
+	m := map[string]string{}
+	for _, name := range cgiServerVars {
+		if v := os.Getenv(name); v != "" {
+			m[name] = v
+		}
+	}
+	r := d.httpRequest()
+	if r == nil {
+		return m
+	}
+	for k, v := range r.Header {
+		m["HTTP_"+strings.ToUpper(strings.ReplaceAll(k, "-", "_"))] = strings.Join(v, ", ")
+	}
+	if r.Host != "" {
+		m["HTTP_HOST"] = r.Host
+	}
+	m["REQUEST_METHOD"] = r.Method
+	m["REQUEST_URI"] = d.RequestURI
+	if r.URL != nil {
+		m["QUERY_STRING"] = r.URL.RawQuery
+		m["PHP_SELF"] = r.URL.Path
+	}
+	if r.Proto != "" && m["SERVER_PROTOCOL"] == "" {
+		m["SERVER_PROTOCOL"] = r.Proto
+	}
+	if host, port, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		m["REMOTE_ADDR"] = host
+		m["REMOTE_PORT"] = port
+	} else if r.RemoteAddr != "" {
+		m["REMOTE_ADDR"] = r.RemoteAddr
+	}
+	m["SERVER_NAME"] = RequestHost(r)
+	if r.TLS != nil {
+		m["HTTPS"] = "on"
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		m["CONTENT_TYPE"] = ct
+	}
+	if r.ContentLength > 0 {
+		m["CONTENT_LENGTH"] = strconv.FormatInt(r.ContentLength, 10)
+	}
+	// Like CGI, credentials are not passed on
+	delete(m, "HTTP_AUTHORIZATION")
+	delete(m, "HTTP_CONTENT_TYPE")
+	delete(m, "HTTP_CONTENT_LENGTH")
+	return m
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSuperglobals(t *testing.T) {
+	req := httptest.NewRequest("POST", "http://example.com/form.php?id=7&id=8", strings.NewReader("name=Ann&note=hi+there"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "Lynx")
+	req.Header.Set("Authorization", "Basic c2VjcmV0")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	req.RemoteAddr = "192.0.2.7:5555"
+	data := TemplateData{RequestURI: "/form.php?id=7&id=8", Request: req}
+
+	tmpl := template.Must(template.New("t").Parse(
+		`{{.GET.id}} {{.POST.name}} {{.POST.note}} {{.COOKIE.session}} ` +
+			`{{.SERVER.REQUEST_METHOD}} {{.SERVER.PHP_SELF}} {{.SERVER.REMOTE_ADDR}} ` +
+			`{{.SERVER.SERVER_NAME}} {{.SERVER.HTTP_USER_AGENT}} {{.SERVER.CONTENT_TYPE}}`))
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+	want := "7 Ann hi there abc POST /form.php 192.0.2.7 example.com Lynx application/x-www-form-urlencoded"
+	if buf.String() != want {
+		t.Errorf("Execute() = %q, want %q", buf.String(), want)
+	}
+
+	if _, ok := data.SERVER()["HTTP_AUTHORIZATION"]; ok {
+		t.Errorf("SERVER() exposes the Authorization header")
+	}
+
+	empty := TemplateData{}
+	if len(empty.GET())+len(empty.POST())+len(empty.COOKIE()) != 0 {
+		t.Errorf("superglobals of data without a request are not empty")
+	}
+
+	t.Setenv("REMOTE_USER", "ann")
+	t.Setenv("TMPL_CGI_SECRET", "hidden")
+	server := data.SERVER()
+	if server["REMOTE_USER"] != "ann" {
+		t.Errorf("SERVER()[REMOTE_USER] = %q, want ann", server["REMOTE_USER"])
+	}
+	if _, ok := server["TMPL_CGI_SECRET"]; ok {
+		t.Errorf("SERVER() exposes an environment variable outside the CGI set")
+	}
+}
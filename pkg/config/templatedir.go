@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// pinTemplateDir resolves a template_dir symlink once, so that every
+// template a loaded config reads comes from the same release even while
+// the symlink is switched to another
+func (c *Config) pinTemplateDir() {
+	link := c.TemplateDirPath()
+	if link == "" {
+		return
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return
+	}
+	if resolved, err := filepath.EvalSymlinks(link); err == nil {
+		c.templateRoot = resolved
+	}
+}
+
+// TemplateDirPath returns the path of a local template_dir, or "" if there
+// is none
+func (c *Config) TemplateDirPath() string {
+	if c.TemplateDir == "" || isRemote(c.resolvePath(c.TemplateDir)) {
+		return ""
+	}
+	return c.resolvePath(c.TemplateDir)
+}
+
+// SwitchTemplateDir validates the config against the templates in dir,
+// then atomically points the template_dir symlink at it. A relative dir
+// resolves like other paths in the config. The config keeps reading the
+// directory it was loaded with; reload it to switch.
+func (c *Config) SwitchTemplateDir(dir string) error {
+	link := c.TemplateDirPath()
+	if fi, err := os.Lstat(link); link == "" || err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("template_dir must be a symlink to switch")
+	}
+	target, err := filepath.Abs(c.resolvePath(dir))
+	if err != nil {
+		return err
+	}
+	next := *c
+	next.templateRoot = target
+	if err = next.Validate(); err != nil {
+		return fmt.Errorf("validating %s: %w", dir, err)
+	}
+	return replaceSymlink(link, target)
+}
+
+// replaceSymlink points the symlink link at target by renaming a new
+// symlink over it, so that the link is never missing
+func replaceSymlink(link, target string) error {
+	tmp := filepath.Join(filepath.Dir(link), fmt.Sprintf(".%s.tmp-%d", filepath.Base(link), os.Getpid()))
+	_ = os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
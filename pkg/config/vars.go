@@ -0,0 +1,38 @@
+package config
+
+import "fmt"
+
+// templateVars returns the request-scoped variables of the template data
+func templateVars(data any) (map[string]any, error) {
+	var vars map[string]any
+	switch d := data.(type) {
+	case TemplateData:
+		vars = d.Vars
+	case *TemplateData:
+		vars = d.Vars
+	}
+	if vars == nil {
+		return nil, fmt.Errorf("variables need the page data ($) as first argument")
+	}
+	return vars, nil
+}
+
+// setVar stores a value for the rest of the request, for use by later
+// templates and partials
+func setVar(data any, name string, value any) (string, error) {
+	vars, err := templateVars(data)
+	if err != nil {
+		return "", fmt.Errorf("setVar: %w", err)
+	}
+	vars[name] = value
+	return "", nil
+}
+
+// getVar returns a value stored with setVar, or nil
+func getVar(data any, name string) (any, error) {
+	vars, err := templateVars(data)
+	if err != nil {
+		return nil, fmt.Errorf("getVar: %w", err)
+	}
+	return vars[name], nil
+}
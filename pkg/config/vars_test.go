@@ -0,0 +1,36 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFuncMap_Vars(t *testing.T) {
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "page.html"), []byte(
+		`{{define "nav"}}{{setVar $ "section" "Docs"}}{{range .Data.items}}{{setVar $ "count" (add1 (getVar $ "count" | default 0))}}{{end}}{{end}}`+
+			`{{template "nav" .}}<h1>{{getVar . "section"}}</h1>{{.Vars.count}} {{getVar . "missing"}}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	config := &Config{ConfigFilePath: filepath.Join(tempDir, "config.yaml")}
+	tmpl, err := config.LoadTemplate("page.html")
+	if err != nil {
+		t.Fatalf("LoadTemplate() error: %v", err)
+	}
+	var buf strings.Builder
+	data := TemplateData{Data: map[string]any{"items": []any{1, 2, 3}}, Vars: map[string]any{}}
+	if err = tmpl.Execute(&buf, data); err != nil {
+		t.Fatalf("Execute() error: %v", err)
+	}
+	if want := "<h1>Docs</h1>3 "; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+
+	if err = tmpl.Execute(&buf, TemplateData{}); err == nil || !strings.Contains(err.Error(), "setVar") {
+		t.Errorf("Execute() without Vars error = %v, want setVar error", err)
+	}
+}
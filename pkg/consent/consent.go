@@ -0,0 +1,234 @@
+// Package consent keeps track of the cookie consent choices of visitors, so
+// pages can show a consent banner until a choice is made and only include
+// analytics and other optional scripts in the categories a visitor accepted.
+package consent
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/redirect"
+)
+
+// Defaults for consent settings
+const (
+	DefaultCookie = "consent"
+	DefaultPath   = "/_consent"
+	DefaultMaxAge = 180 * 24 * time.Hour
+)
+
+// DefaultCategories are offered when no categories are configured
+var DefaultCategories = []string{"analytics", "marketing"}
+
+// Choices of the consent endpoint besides a list of categories
+const (
+	All   = "all"
+	None  = "none"
+	Reset = "reset"
+)
+
+// validName matches category names and policy versions, which are stored
+// in the cookie
+var validName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// Settings configures consent tracking
+type Settings struct {
+	Enabled    bool          `yaml:"enabled"`
+	Categories []string      `yaml:"categories,omitempty"`
+	Version    string        `yaml:"version,omitempty"`
+	Cookie     string        `yaml:"cookie,omitempty"`
+	Path       string        `yaml:"path,omitempty"`
+	MaxAge     time.Duration `yaml:"max_age,omitempty"`
+}
+
+// Consent is the consent state of a request
+type Consent struct {
+	// Enabled is false if consent tracking is disabled, in which case
+	// every category is allowed
+	Enabled bool
+	// Decided is true once the visitor accepted or rejected the categories
+	// of the current policy version
+	Decided bool
+	// Granted are the accepted categories
+	Granted []string
+	// Categories are the configured categories
+	Categories []string
+
+	path string
+}
+
+// categories returns the configured categories
+func (s *Settings) categories() []string {
+	if len(s.Categories) == 0 {
+		return DefaultCategories
+	}
+	return s.Categories
+}
+
+// cookie returns the name of the consent cookie
+func (s *Settings) cookie() string {
+	if s.Cookie == "" {
+		return DefaultCookie
+	}
+	return s.Cookie
+}
+
+// maxAge returns how long a choice is remembered
+func (s *Settings) maxAge() time.Duration {
+	if s.MaxAge <= 0 {
+		return DefaultMaxAge
+	}
+	return s.MaxAge
+}
+
+// EndpointPath returns the path of the endpoint that records choices
+func (s *Settings) EndpointPath() string {
+	if s.Path == "" {
+		return DefaultPath
+	}
+	return s.Path
+}
+
+// Validate checks the consent settings
+func (s *Settings) Validate() error {
+	if !s.Enabled {
+		return nil
+	}
+	for _, c := range s.categories() {
+		if !validName.MatchString(c) || c == All || c == None || c == Reset {
+			return fmt.Errorf("consent: invalid category '%s'", c)
+		}
+	}
+	if s.Version != "" && !validName.MatchString(s.Version) {
+		return fmt.Errorf("consent: invalid version '%s'", s.Version)
+	}
+	if !strings.HasPrefix(s.EndpointPath(), "/") {
+		return fmt.Errorf("consent: path must start with /")
+	}
+	return nil
+}
+
+// Resolve reads the consent state of a request from its cookie. A cookie
+// from another policy version counts as no choice, so that visitors are
+// asked again when the policy changes.
+func (s *Settings) Resolve(r *http.Request) Consent {
+	if !s.Enabled {
+		return Consent{}
+	}
+	categories := s.categories()
+	c := Consent{Enabled: true, Categories: categories, path: s.EndpointPath()}
+	cookie, err := r.Cookie(s.cookie())
+	if err != nil {
+		return c
+	}
+	version, value, ok := strings.Cut(cookie.Value, ":")
+	if !ok || version != s.Version {
+		return c
+	}
+	c.Decided = true
+	for _, name := range strings.Split(value, ".") {
+		if slices.Contains(categories, name) && !slices.Contains(c.Granted, name) {
+			c.Granted = append(c.Granted, name)
+		}
+	}
+	sort.Strings(c.Granted)
+	return c
+}
+
+// Allows reports whether the visitor accepted a category
+func (c Consent) Allows(category string) bool {
+	return !c.Enabled || slices.Contains(c.Granted, category)
+}
+
+// Key identifies the consent state, for caching pages that depend on it
+func (c Consent) Key() string {
+	if !c.Decided {
+		return ""
+	}
+	return "granted:" + strings.Join(c.Granted, ".")
+}
+
+// SetURL returns the URL that records a choice and then returns to the
+// given page. The choice is "all", "none", a comma-separated list of
+// categories, or "reset" to forget the choice.
+func (c Consent) SetURL(choice, returnTo string) string {
+	return c.path + "?" + url.Values{"set": {choice}, "return": {returnTo}}.Encode()
+}
+
+// SetHeaders marks the response as varying with the consent cookie
+func (s *Settings) SetHeaders(h http.Header) {
+	if !s.Enabled {
+		return
+	}
+	h.Add("Vary", "Cookie")
+}
+
+// Handle serves the consent endpoint: it stores the accepted categories in
+// a cookie, or clears it for "reset", and redirects back to the page. A
+// form posting "set" values for each accepted category works as well.
+// Requests from other sites are refused, so that links elsewhere cannot
+// record consent the visitor did not give.
+func (s *Settings) Handle(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		http.Error(w, "cross-site consent request", http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	cookie := &http.Cookie{
+		Name:     s.cookie(),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	var choices []string
+	for _, v := range r.Form["set"] {
+		choices = append(choices, strings.Split(v, ",")...)
+	}
+	switch {
+	case slices.Equal(choices, []string{Reset}):
+		cookie.MaxAge = -1
+	default:
+		granted, err := s.granted(choices)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cookie.Value = s.Version + ":" + strings.Join(granted, ".")
+		cookie.MaxAge = int(s.maxAge() / time.Second)
+	}
+	http.SetCookie(w, cookie)
+	http.Redirect(w, r, redirect.Local(r.Form.Get("return")), http.StatusSeeOther)
+}
+
+// granted returns the categories a choice accepts
+func (s *Settings) granted(choices []string) ([]string, error) {
+	categories := s.categories()
+	var granted []string
+	for _, choice := range choices {
+		switch choice = strings.TrimSpace(choice); {
+		case choice == All:
+			return slices.Sorted(slices.Values(categories)), nil
+		case choice == None || choice == "":
+		case slices.Contains(categories, choice):
+			if !slices.Contains(granted, choice) {
+				granted = append(granted, choice)
+			}
+		default:
+			return nil, fmt.Errorf("unknown consent category")
+		}
+	}
+	if len(choices) == 0 {
+		return nil, fmt.Errorf("no consent choice")
+	}
+	sort.Strings(granted)
+	return granted, nil
+}
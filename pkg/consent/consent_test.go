@@ -0,0 +1,135 @@
+package consent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	settings := &Settings{Enabled: true, Categories: []string{"analytics", "marketing", "video"}, Version: "2"}
+
+	tests := []struct {
+		name            string
+		cookie          string
+		expectedDecided bool
+		expectedGranted []string
+	}{
+		{"No cookie", "", false, nil},
+		{"Accepted some", "2:video.analytics", true, []string{"analytics", "video"}},
+		{"Rejected all", "2:", true, nil},
+		{"Unknown category", "2:analytics.tracking", true, []string{"analytics"}},
+		{"Old version", "1:analytics.marketing", false, nil},
+		{"Malformed", "analytics", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "consent", Value: tt.cookie})
+			}
+			got := settings.Resolve(req)
+			if got.Decided != tt.expectedDecided || !reflect.DeepEqual(got.Granted, tt.expectedGranted) {
+				t.Errorf("Resolve() = %v %v, want %v %v", got.Decided, got.Granted, tt.expectedDecided, tt.expectedGranted)
+			}
+			for _, c := range settings.Categories {
+				want := false
+				for _, g := range tt.expectedGranted {
+					want = want || g == c
+				}
+				if got.Allows(c) != want {
+					t.Errorf("Allows(%s) = %v, want %v", c, got.Allows(c), want)
+				}
+			}
+		})
+	}
+
+	disabled := (&Settings{}).Resolve(httptest.NewRequest("GET", "/", nil))
+	if !disabled.Allows("analytics") || disabled.Decided {
+		t.Errorf("Resolve() with consent disabled = %+v, want every category allowed", disabled)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	settings := &Settings{Enabled: true, Version: "2"}
+
+	tests := []struct {
+		name             string
+		query            string
+		expectedStatus   int
+		expectedLocation string
+		expectedCookie   string
+	}{
+		{"Accept all", "set=all&return=/blog/post?x=1", http.StatusSeeOther, "/blog/post?x=1", "consent=2:analytics.marketing;"},
+		{"Reject all", "set=none&return=/", http.StatusSeeOther, "/", "consent=2:;"},
+		{"Some categories", "set=marketing,analytics&return=/", http.StatusSeeOther, "/", "consent=2:analytics.marketing;"},
+		{"Form fields", "set=analytics&set=none&return=/", http.StatusSeeOther, "/", "consent=2:analytics;"},
+		{"Reset", "set=reset&return=/", http.StatusSeeOther, "/", "consent=; Path=/; Max-Age=0"},
+		{"Unknown category", "set=tracking&return=/", http.StatusBadRequest, "", ""},
+		{"No choice", "return=/", http.StatusBadRequest, "", ""},
+		{"Off-site return", "set=none&return=//evil.example.com/", http.StatusSeeOther, "/", "consent=2:;"},
+		{"Tab in return", "set=none&return=/%09/evil.example.com", http.StatusSeeOther, "/", "consent=2:;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			settings.Handle(w, httptest.NewRequest("GET", "/_consent?"+tt.query, nil))
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.expectedStatus)
+			}
+			if got := w.Header().Get("Location"); got != tt.expectedLocation {
+				t.Errorf("Location = %q, want %q", got, tt.expectedLocation)
+			}
+			if got := w.Header().Get("Set-Cookie"); !strings.HasPrefix(got, tt.expectedCookie) {
+				t.Errorf("Set-Cookie = %q, want prefix %q", got, tt.expectedCookie)
+			}
+		})
+	}
+
+	// Posted forms work too, but not from other sites
+	form := url.Values{"set": {"analytics"}, "return": {"/"}}
+	req := httptest.NewRequest("POST", "/_consent", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	settings.Handle(w, req)
+	if got := w.Header().Get("Set-Cookie"); !strings.HasPrefix(got, "consent=2:analytics;") {
+		t.Errorf("Set-Cookie = %q after posting a form", got)
+	}
+	req = httptest.NewRequest("GET", "/_consent?set=all", nil)
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	w = httptest.NewRecorder()
+	settings.Handle(w, req)
+	if w.Code != http.StatusForbidden || w.Header().Get("Set-Cookie") != "" {
+		t.Errorf("cross-site request status = %d, Set-Cookie = %q", w.Code, w.Header().Get("Set-Cookie"))
+	}
+}
+
+func TestSetURL(t *testing.T) {
+	c := (&Settings{Enabled: true}).Resolve(httptest.NewRequest("GET", "/", nil))
+	if got, want := c.SetURL("all", "/a?b=1"), "/_consent?return=%2Fa%3Fb%3D1&set=all"; got != want {
+		t.Errorf("SetURL() = %q, want %q", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		settings Settings
+		wantErr  string
+	}{
+		{Settings{Enabled: true, Categories: []string{"Analytics"}}, "invalid category"},
+		{Settings{Enabled: true, Categories: []string{"all"}}, "invalid category"},
+		{Settings{Enabled: true, Version: "v:1"}, "invalid version"},
+		{Settings{Enabled: true, Path: "consent"}, "must start with /"},
+	}
+	for _, tt := range tests {
+		if err := tt.settings.Validate(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Validate(%+v) error = %v, want %q", tt.settings, err, tt.wantErr)
+		}
+	}
+	if err := (&Settings{Enabled: true, Version: "2025-06"}).Validate(); err != nil {
+		t.Errorf("Validate() error: %v", err)
+	}
+}
@@ -2,17 +2,63 @@ package debug
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 
 	"gopkg.mhn.org/tmpl.cgi/pkg/cgicapture"
 )
 
 var debugGloballyEnabled bool
 
+// Redacted replaces secret values in debug output
+const Redacted = "[REDACTED]"
+
+// minSecretLen is the length below which values are not redacted, since
+// they would match too much unrelated text
+const minSecretLen = 4
+
+// secrets are the values kept out of debug output
+var secrets = struct {
+	sync.RWMutex
+	values map[string]bool
+}{values: map[string]bool{}}
+
+// AddSecret keeps a value, such as an API key read from a secret file, out
+// of debug error pages and other debug output, as is and as it appears in
+// JSON
+func AddSecret(value string) {
+	if len(value) < minSecretLen {
+		return
+	}
+	quoted, _ := json.Marshal(value)
+	secrets.Lock()
+	defer secrets.Unlock()
+	secrets.values[value] = true
+	secrets.values[string(quoted[1:len(quoted)-1])] = true
+}
+
+// Redact returns s with every registered secret replaced by [REDACTED],
+// longest first so that a secret containing another is hidden whole
+func Redact(s string) string {
+	secrets.RLock()
+	values := make([]string, 0, len(secrets.values))
+	for value := range secrets.values {
+		values = append(values, value)
+	}
+	secrets.RUnlock()
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, value := range values {
+		s = strings.ReplaceAll(s, value, Redacted)
+	}
+	return s
+}
+
 // IsDebugEnabled checks if debug mode is enabled via TMPL_CGI_DEBUG environment variable
 func IsDebugEnabled() bool {
 	if debugGloballyEnabled {
@@ -33,8 +79,13 @@ func RenderDebugErrorAsCGIString(messages [][2]string) string {
 	})
 }
 
-// RenderDebugError renders a detailed error page
+// RenderDebugError renders a detailed error page, with secrets redacted
 func RenderDebugError(w http.ResponseWriter, messages [][2]string) {
+	redacted := make([][2]string, len(messages))
+	for i, m := range messages {
+		redacted[i] = [2]string{m[0], Redact(m[1])}
+	}
+	messages = redacted
 	debugTemplate := `<!DOCTYPE html>
 <html>
 <head>
@@ -410,3 +410,24 @@ func TestDebugGlobalState(t *testing.T) {
 		t.Error("SetDebugMode should set global debug to true")
 	}
 }
+
+func TestRenderDebugError_RedactsSecrets(t *testing.T) {
+	AddSecret("sk-live-abcdef")
+	AddSecret("sk-live-abcdef-extended")
+	AddSecret(`tok"en<1>`)
+	AddSecret("ab")
+
+	w := httptest.NewRecorder()
+	RenderDebugError(w, [][2]string{{"Error executing template", "bad key sk-live-abcdef-extended and sk-live-abcdef, ab"}})
+	body := w.Body.String()
+	if strings.Contains(body, "sk-live") {
+		t.Errorf("debug page shows a secret: %s", body)
+	}
+	if !strings.Contains(body, "bad key [REDACTED] and [REDACTED], ab") {
+		t.Errorf("debug page does not show redacted message: %s", body)
+	}
+
+	if got := Redact(`{"key": "tok\"en\u003c1\u003e"}`); got != `{"key": "[REDACTED]"}` {
+		t.Errorf("Redact() = %q, want the JSON form redacted", got)
+	}
+}
@@ -0,0 +1,170 @@
+// Package feed parses RSS 2.0 and Atom feeds into a common entry list.
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Feed is a parsed RSS or Atom feed
+type Feed struct {
+	Title   string
+	Link    string
+	Entries []Entry
+}
+
+// Entry is a single feed item
+type Entry struct {
+	Title     string
+	Link      string
+	Summary   string
+	Author    string
+	Published time.Time
+}
+
+// rss is the subset of RSS 2.0 that is read
+type rss struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Link  string `xml:"link"`
+		Items []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			Description string `xml:"description"`
+			Author      string `xml:"author"`
+			Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+			PubDate     string `xml:"pubDate"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// atomLink is an Atom link element
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// atom is the subset of Atom that is read
+type atom struct {
+	Title   string     `xml:"title"`
+	Links   []atomLink `xml:"link"`
+	Entries []struct {
+		Title     string     `xml:"title"`
+		Links     []atomLink `xml:"link"`
+		Summary   string     `xml:"summary"`
+		Content   string     `xml:"content"`
+		Author    string     `xml:"author>name"`
+		Published string     `xml:"published"`
+		Updated   string     `xml:"updated"`
+	} `xml:"entry"`
+}
+
+// dateLayouts are the timestamp formats found in the wild
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02",
+}
+
+// parseDate parses a feed timestamp, returning the zero time if unrecognized
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Parse decodes an RSS 2.0 or Atom document, keeping at most limit entries
+// (all entries if limit <= 0)
+func Parse(data []byte, limit int) (*Feed, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	var root string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("reading feed: %w", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			root = se.Name.Local
+			break
+		}
+	}
+
+	var f Feed
+	switch root {
+	case "rss":
+		var doc rss
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parsing RSS feed: %w", err)
+		}
+		f.Title, f.Link = strings.TrimSpace(doc.Channel.Title), strings.TrimSpace(doc.Channel.Link)
+		for _, it := range doc.Channel.Items {
+			author := it.Author
+			if author == "" {
+				author = it.Creator
+			}
+			f.Entries = append(f.Entries, Entry{
+				Title:     strings.TrimSpace(it.Title),
+				Link:      strings.TrimSpace(it.Link),
+				Summary:   strings.TrimSpace(it.Description),
+				Author:    strings.TrimSpace(author),
+				Published: parseDate(it.PubDate),
+			})
+		}
+	case "feed":
+		var doc atom
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parsing Atom feed: %w", err)
+		}
+		f.Title, f.Link = strings.TrimSpace(doc.Title), alternate(doc.Links)
+		for _, e := range doc.Entries {
+			summary := e.Summary
+			if summary == "" {
+				summary = e.Content
+			}
+			published := e.Published
+			if published == "" {
+				published = e.Updated
+			}
+			f.Entries = append(f.Entries, Entry{
+				Title:     strings.TrimSpace(e.Title),
+				Link:      alternate(e.Links),
+				Summary:   strings.TrimSpace(summary),
+				Author:    strings.TrimSpace(e.Author),
+				Published: parseDate(published),
+			})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported feed format <%s>", root)
+	}
+
+	if limit > 0 && len(f.Entries) > limit {
+		f.Entries = f.Entries[:limit]
+	}
+	return &f, nil
+}
+
+// alternate returns the rel="alternate" (or first) link href
+func alternate(links []atomLink) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
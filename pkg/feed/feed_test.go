@@ -0,0 +1,83 @@
+package feed
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_RSS(t *testing.T) {
+	data := []byte(`<?xml version="1.0"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+  <title>Example News</title>
+  <link>https://news.example.com/</link>
+  <item>
+    <title>First &amp; foremost</title>
+    <link>https://news.example.com/1</link>
+    <description>Summary one</description>
+    <dc:creator>Jo Writer</dc:creator>
+    <pubDate>Mon, 02 Jun 2025 10:00:00 +0000</pubDate>
+  </item>
+  <item><title>Second</title><link>https://news.example.com/2</link></item>
+  <item><title>Third</title><link>https://news.example.com/3</link></item>
+</channel>
+</rss>`)
+
+	f, err := Parse(data, 2)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if f.Title != "Example News" || f.Link != "https://news.example.com/" {
+		t.Errorf("feed = %q %q", f.Title, f.Link)
+	}
+	if len(f.Entries) != 2 {
+		t.Fatalf("entries = %d, want 2 (limit)", len(f.Entries))
+	}
+	e := f.Entries[0]
+	if e.Title != "First & foremost" || e.Author != "Jo Writer" || e.Summary != "Summary one" {
+		t.Errorf("entry = %+v", e)
+	}
+	if !e.Published.Equal(time.Date(2025, 6, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Published = %v", e.Published)
+	}
+}
+
+func TestParse_Atom(t *testing.T) {
+	data := []byte(`<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Example Blog</title>
+  <link rel="self" href="https://blog.example.com/atom.xml"/>
+  <link href="https://blog.example.com/"/>
+  <entry>
+    <title>Hello</title>
+    <link rel="alternate" href="https://blog.example.com/hello"/>
+    <author><name>Sam</name></author>
+    <updated>2025-06-01T08:00:00Z</updated>
+    <content type="html">&lt;p&gt;Body&lt;/p&gt;</content>
+  </entry>
+</feed>`)
+
+	f, err := Parse(data, 0)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if f.Title != "Example Blog" || f.Link != "https://blog.example.com/" {
+		t.Errorf("feed = %q %q", f.Title, f.Link)
+	}
+	e := f.Entries[0]
+	if e.Link != "https://blog.example.com/hello" || e.Author != "Sam" || e.Summary != "<p>Body</p>" {
+		t.Errorf("entry = %+v", e)
+	}
+	if !e.Published.Equal(time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Published = %v", e.Published)
+	}
+}
+
+func TestParse_Unsupported(t *testing.T) {
+	if _, err := Parse([]byte(`<html><body>nope</body></html>`), 0); err == nil {
+		t.Error("Parse() of HTML should return error")
+	}
+	if _, err := Parse([]byte(`not xml`), 0); err == nil {
+		t.Error("Parse() of non-XML should return error")
+	}
+}
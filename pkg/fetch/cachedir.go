@@ -0,0 +1,44 @@
+package fetch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultCacheDir returns the directory cached responses are kept in when
+// none is configured: tmpl.cgi in the user's cache directory, or, for
+// processes without a home directory such as CGI scripts, a directory named
+// after the user in the system temp dir
+func DefaultCacheDir() string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "tmpl.cgi")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("tmpl.cgi-cache-%d", os.Getuid()))
+}
+
+// CacheDir returns dir, or the default cache directory if it is empty,
+// creating it if needed. Other users could plant or alter entries in a
+// directory they can write to, so it must belong to the user the process
+// runs as and be accessible only to them (mode 0700).
+func CacheDir(dir string) (string, error) {
+	if dir == "" {
+		dir = DefaultCacheDir()
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	st, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case !st.IsDir():
+		return "", fmt.Errorf("cache directory %s is not a directory", dir)
+	case !ownedByProcess(st):
+		return "", fmt.Errorf("cache directory %s is not owned by the current user", dir)
+	case st.Mode().Perm()&0077 != 0:
+		return "", fmt.Errorf("cache directory %s is accessible to other users; it must have mode 0700", dir)
+	}
+	return dir, nil
+}
@@ -0,0 +1,53 @@
+package fetch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheDir(t *testing.T) {
+	base := t.TempDir()
+	open := filepath.Join(base, "open")
+	if err := os.Mkdir(open, 0700); err != nil {
+		t.Fatalf("Mkdir() error: %v", err)
+	}
+	if err := os.Chmod(open, 0777); err != nil {
+		t.Fatalf("Chmod() error: %v", err)
+	}
+	link := filepath.Join(base, "link")
+	if err := os.Symlink(open, link); err != nil {
+		t.Fatalf("Symlink() error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		dir  string
+		ok   bool
+	}{
+		{name: "created", dir: filepath.Join(base, "new", "cache"), ok: true},
+		{name: "writable by others", dir: open},
+		{name: "symlink", dir: link},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CacheDir(tt.dir)
+			if (err == nil) != tt.ok {
+				t.Fatalf("CacheDir() error = %v, want ok %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			st, err := os.Stat(got)
+			if err != nil || st.Mode().Perm() != 0700 {
+				t.Errorf("CacheDir() = %s with mode %v, want mode 0700", got, st.Mode().Perm())
+			}
+		})
+	}
+
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(base, "xdg"))
+	t.Setenv("HOME", filepath.Join(base, "home"))
+	if got, err := CacheDir(""); err != nil || filepath.Dir(got) != filepath.Join(base, "xdg") {
+		t.Errorf("CacheDir(\"\") = %s, %v; want a directory in the user cache dir", got, err)
+	}
+}
@@ -0,0 +1,244 @@
+// Package fetch retrieves remote resources through a small on-disk cache, so
+// that short-lived CGI processes can share responses between requests.
+package fetch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// DefaultMaxSize bounds the size of a fetched resource
+const DefaultMaxSize = 1 << 20
+
+// Options controls a cached fetch
+type Options struct {
+	// CacheDir holds cached responses; defaults to DefaultCacheDir. It
+	// must be owned by the current user with mode 0700.
+	CacheDir string
+	// TTL is how long a cached response is reused
+	TTL time.Duration
+	// MaxSize bounds the response size; defaults to DefaultMaxSize
+	MaxSize int64
+	// Client performs the request; defaults to a client with a 10s timeout
+	Client *http.Client
+	// Sign, if set, prepares each request, for example to add credentials
+	Sign func(*http.Request)
+}
+
+// Cached returns the body at url, reusing a cached copy younger than the TTL.
+// If the fetch fails and a stale copy exists, the stale copy is returned, so
+// pages degrade gracefully while a remote service is down. Each copy is
+// stored with a checksum and is only used if it still matches.
+func Cached(url string, opts Options) ([]byte, error) {
+	if mockDir != "" {
+		return mock(url)
+	}
+	dir, err := CacheDir(opts.CacheDir)
+	if err != nil {
+		// Caching is best-effort: without a usable cache directory every
+		// call fetches
+		return Get(url, opts)
+	}
+	cacheFile := cacheFile(url, dir)
+	st, statErr := os.Stat(cacheFile)
+	if statErr == nil && time.Since(st.ModTime()) < opts.TTL {
+		if body, err := readEntry(cacheFile); err == nil {
+			return body, nil
+		}
+	}
+	body, err := Get(url, opts)
+	if err != nil {
+		if statErr == nil {
+			if stale, rerr := readEntry(cacheFile); rerr == nil {
+				return stale, nil
+			}
+		}
+		return nil, err
+	}
+	// A read-only filesystem just means no cache
+	sum := sha256.Sum256(body)
+	_ = writeAtomic(cacheFile, append(sum[:], body...))
+	return body, nil
+}
+
+// readEntry reads a cached response, which is stored after its SHA-256
+// checksum
+func readEntry(cacheFile string) ([]byte, error) {
+	data, err := os.ReadFile(cacheFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < sha256.Size || sha256.Sum256(data[sha256.Size:]) != [sha256.Size]byte(data[:sha256.Size]) {
+		return nil, fmt.Errorf("cached copy %s does not match its checksum", cacheFile)
+	}
+	return data[sha256.Size:], nil
+}
+
+// Get fetches url without caching, enforcing the size limit
+func Get(url string, opts Options) ([]byte, error) {
+	if mockDir != "" {
+		return mock(url)
+	}
+	body, _, err := get(url, "", opts)
+	return body, err
+}
+
+// get fetches url, enforcing the size limit. With an ETag, the request is
+// conditional and a nil body means the resource has not changed.
+func get(url, etag string, opts Options) (body []byte, newETag string, err error) {
+	maxSize := opts.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if opts.Sign != nil {
+		opts.Sign(req)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotModified && etag != "" {
+		return nil, etag, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err = io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(body)) > maxSize {
+		return nil, "", fmt.Errorf("response exceeds %d bytes", maxSize)
+	}
+	return body, resp.Header.Get("ETag"), nil
+}
+
+// File returns the path of a local copy of url, for files such as
+// templates that are read from disk. The copy keeps the base name of the
+// URL path. Once it is older than the TTL it is revalidated with its ETag,
+// and if that fails the stale copy is used. A copy that no longer matches
+// the checksum recorded with it is fetched again.
+func File(url string, opts Options) (string, error) {
+	if mockDir != "" {
+		return mockFile(url)
+	}
+	cacheDir, err := CacheDir(opts.CacheDir)
+	if err != nil {
+		return "", err
+	}
+	dir := cacheFile("file\x00"+url, cacheDir)
+	local := filepath.Join(dir, fileName(url))
+	etagFile := filepath.Join(dir, ".etag")
+	sumFile := filepath.Join(dir, ".sha256")
+	st, statErr := os.Stat(local)
+	if statErr == nil && !matchesSum(local, sumFile) {
+		statErr = fmt.Errorf("cached copy %s does not match its checksum", local)
+	}
+	if statErr == nil && time.Since(st.ModTime()) < opts.TTL {
+		return local, nil
+	}
+	var etag string
+	if statErr == nil {
+		if b, err := os.ReadFile(etagFile); err == nil {
+			etag = string(b)
+		}
+	}
+	body, newETag, err := get(url, etag, opts)
+	switch {
+	case err != nil && statErr == nil:
+		return local, nil
+	case err != nil:
+		return "", err
+	case body == nil:
+		now := time.Now()
+		_ = os.Chtimes(local, now, now)
+		return local, nil
+	}
+	sum := sha256.Sum256(body)
+	if err = writeAtomic(sumFile, []byte(hex.EncodeToString(sum[:]))); err != nil {
+		return "", err
+	}
+	if err = writeAtomic(local, body); err != nil {
+		return "", err
+	}
+	if newETag != "" {
+		_ = writeAtomic(etagFile, []byte(newETag))
+	} else {
+		_ = os.Remove(etagFile)
+	}
+	return local, nil
+}
+
+// matchesSum reports whether a file matches the hex SHA-256 checksum
+// stored in sumFile
+func matchesSum(filename, sumFile string) bool {
+	want, err := os.ReadFile(sumFile)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return false
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]) == string(want)
+}
+
+// fileName returns the base name of a URL's path
+func fileName(rawURL string) string {
+	name := "index"
+	if u, err := neturl.Parse(rawURL); err == nil {
+		if base := path.Base(u.Path); base != "/" && base != "." {
+			name = base
+		}
+	}
+	return name
+}
+
+// cacheFile returns the path for url in cacheDir
+func cacheFile(url, cacheDir string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(cacheDir, hex.EncodeToString(sum[:]))
+}
+
+// writeAtomic writes data to filename via a temporary file and rename
+func writeAtomic(filename string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
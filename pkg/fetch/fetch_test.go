@@ -0,0 +1,197 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCached(t *testing.T) {
+	var hits atomic.Int32
+	var fail atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if fail.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	opts := Options{CacheDir: filepath.Join(t.TempDir(), "cache"), TTL: time.Hour}
+	for i := 0; i < 2; i++ {
+		body, err := Cached(ts.URL, opts)
+		if err != nil || string(body) != "hello" {
+			t.Fatalf("Cached() = %q, %v", body, err)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("server hit %d times, want 1", hits.Load())
+	}
+
+	// Expire the cache entry and make the server fail: the stale copy is served
+	old := time.Now().Add(-2 * time.Hour)
+	_ = os.Chtimes(cacheFile(ts.URL, opts.CacheDir), old, old)
+	fail.Store(true)
+	body, err := Cached(ts.URL, opts)
+	if err != nil || string(body) != "hello" {
+		t.Errorf("Cached() with failing server = %q, %v; want stale copy", body, err)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("server hit %d times, want 2", hits.Load())
+	}
+}
+
+func TestCached_Checksum(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	opts := Options{CacheDir: filepath.Join(t.TempDir(), "cache"), TTL: time.Hour}
+	if _, err := Cached(ts.URL, opts); err != nil {
+		t.Fatalf("Cached() error: %v", err)
+	}
+	// An altered copy is not used, but fetched again
+	cached, err := os.ReadFile(cacheFile(ts.URL, opts.CacheDir))
+	if err != nil {
+		t.Fatalf("ReadFile() error: %v", err)
+	}
+	if err = os.WriteFile(cacheFile(ts.URL, opts.CacheDir), append(cached[:len(cached)-5], "evil!"...), 0600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	body, err := Cached(ts.URL, opts)
+	if err != nil || string(body) != "hello" || hits.Load() != 2 {
+		t.Errorf("Cached() = %q, %v after %d fetches; want hello fetched again", body, err, hits.Load())
+	}
+}
+
+func TestCached_InsecureCacheDir(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatalf("Chmod() error: %v", err)
+	}
+	opts := Options{CacheDir: dir, TTL: time.Hour}
+	if body, err := Cached(ts.URL, opts); err != nil || string(body) != "hello" {
+		t.Errorf("Cached() = %q, %v; want the fetched body", body, err)
+	}
+	if _, err := os.Stat(cacheFile(ts.URL, dir)); !os.IsNotExist(err) {
+		t.Errorf("Cached() stored a copy in a directory writable by others")
+	}
+	if _, err := File(ts.URL, opts); err == nil {
+		t.Error("File() with a directory writable by others should return error")
+	}
+}
+
+func TestGet_SizeLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer ts.Close()
+
+	if _, err := Get(ts.URL, Options{MaxSize: 10}); err == nil {
+		t.Error("Get() over size limit should return error")
+	}
+	if _, err := Get(ts.URL, Options{MaxSize: 100}); err != nil {
+		t.Errorf("Get() at size limit: %v", err)
+	}
+}
+
+func TestCached_NoServer(t *testing.T) {
+	opts := Options{CacheDir: filepath.Join(t.TempDir(), "cache"), TTL: time.Hour}
+	if _, err := Cached("http://127.0.0.1:1/", opts); err == nil {
+		t.Error("Cached() with unreachable server and no cache should return error")
+	}
+}
+
+func TestFile(t *testing.T) {
+	var full, notModified atomic.Int32
+	var content atomic.Value
+	content.Store("v1")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := content.Load().(string)
+		etag := `"` + body + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	opts := Options{CacheDir: filepath.Join(t.TempDir(), "cache"), TTL: time.Hour}
+	url := ts.URL + "/templates/page.html?rev=1"
+	read := func() string {
+		local, err := File(url, opts)
+		if err != nil {
+			t.Fatalf("File() error: %v", err)
+		}
+		if filepath.Base(local) != "page.html" {
+			t.Errorf("File() = %s, want a copy named page.html", local)
+		}
+		b, _ := os.ReadFile(local)
+		return string(b)
+	}
+	expire := func() {
+		local, _ := File(url, opts)
+		old := time.Now().Add(-2 * time.Hour)
+		_ = os.Chtimes(local, old, old)
+	}
+
+	if got := read(); got != "v1" {
+		t.Errorf("File() content = %q, want v1", got)
+	}
+	read()
+	if full.Load() != 1 || notModified.Load() != 0 {
+		t.Errorf("server answered %d times, want once within the TTL", full.Load()+notModified.Load())
+	}
+
+	// An expired copy is revalidated, and only fetched again if it changed
+	expire()
+	if got := read(); got != "v1" || notModified.Load() != 1 || full.Load() != 1 {
+		t.Errorf("File() after expiry = %q with %d full and %d not modified responses", got, full.Load(), notModified.Load())
+	}
+	expire()
+	content.Store("v2")
+	if got := read(); got != "v2" || full.Load() != 2 {
+		t.Errorf("File() after a change = %q with %d full responses", got, full.Load())
+	}
+
+	// A copy altered on disk is fetched again within the TTL
+	local, _ := File(url, opts)
+	if err := os.WriteFile(local, []byte("evil"), 0600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	if got := read(); got != "v2" || full.Load() != 3 {
+		t.Errorf("File() after tampering = %q with %d full responses", got, full.Load())
+	}
+}
+
+func TestGet_Sign(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer ts.Close()
+
+	sign := func(r *http.Request) { r.Header.Set("Authorization", "signed") }
+	body, err := Get(ts.URL, Options{Sign: sign})
+	if err != nil || string(body) != "signed" {
+		t.Errorf("Get() = %q, %v; want the signed request", body, err)
+	}
+}
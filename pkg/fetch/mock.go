@@ -0,0 +1,67 @@
+package fetch
+
+import (
+	"fmt"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// MockDataEnv names the environment variable that sets the mock data
+// directory, for CGI deployments that cannot pass flags
+const MockDataEnv = "TMPL_CGI_MOCK_DATA"
+
+// mockDir holds recorded responses served instead of the network
+var mockDir string
+
+// SetMockDir serves every fetch from the recorded responses in dir instead
+// of the network, for offline development and deterministic tests. An
+// empty dir fetches from the network again.
+func SetMockDir(dir string) {
+	mockDir = dir
+}
+
+// MockFile returns the path in dir of the recorded response for url: the
+// host followed by the URL path, with index for a path ending in a slash,
+// and the query string, if any, appended after an underscore
+func MockFile(dir, url string) (string, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return "", err
+	}
+	name := path.Clean("/" + u.Path)
+	if name == "/" || u.Path[len(u.Path)-1] == '/' {
+		name = path.Join(name, "index")
+	}
+	if u.RawQuery != "" {
+		name += "_" + neturl.PathEscape(u.RawQuery)
+	}
+	name = filepath.FromSlash(u.Host + name)
+	if u.Host == "" || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("no mock data path for %s", url)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// mockFile returns the path of the recorded response for url, which must
+// exist
+func mockFile(url string) (string, error) {
+	filename, err := MockFile(mockDir, url)
+	if err != nil {
+		return "", err
+	}
+	if _, err = os.Stat(filename); err != nil {
+		return "", fmt.Errorf("no mock data for %s: %w", url, err)
+	}
+	return filename, nil
+}
+
+// mock reads the recorded response for url
+func mock(url string) ([]byte, error) {
+	filename, err := mockFile(url)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filename)
+}
@@ -0,0 +1,77 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMockFile(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://api.example.com/products.json", "api.example.com/products.json"},
+		{"https://api.example.com/search?q=go", "api.example.com/search_q=go"},
+		{"https://api.example.com/a/b?path=x/y", "api.example.com/a/b_path=x%2Fy"},
+		{"https://blog.example.com", "blog.example.com/index"},
+		{"https://blog.example.com/posts/", "blog.example.com/posts/index"},
+		{"http://127.0.0.1:8080/../../etc/passwd", "127.0.0.1:8080/etc/passwd"},
+		{"https://../x", ""},
+		{"file:///etc/passwd", ""},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		got, err := MockFile(dir, tt.url)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("MockFile(%q) = %q, want an error", tt.url, got)
+			}
+			continue
+		}
+		if want := filepath.Join(dir, filepath.FromSlash(tt.want)); err != nil || got != want {
+			t.Errorf("MockFile(%q) = %q, %v, want %q", tt.url, got, err, want)
+		}
+	}
+}
+
+func TestMock(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write([]byte("live"))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	recorded, _ := MockFile(dir, ts.URL+"/data.json")
+	if err := os.MkdirAll(filepath.Dir(recorded), 0755); err != nil {
+		t.Fatalf("MkdirAll() error: %v", err)
+	}
+	if err := os.WriteFile(recorded, []byte("recorded"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	SetMockDir(dir)
+	defer SetMockDir("")
+
+	opts := Options{CacheDir: filepath.Join(t.TempDir(), "cache")}
+	if body, err := Cached(ts.URL+"/data.json", opts); err != nil || string(body) != "recorded" {
+		t.Errorf("Cached() = %q, %v, want the recorded response", body, err)
+	}
+	if body, err := Get(ts.URL+"/data.json", opts); err != nil || string(body) != "recorded" {
+		t.Errorf("Get() = %q, %v, want the recorded response", body, err)
+	}
+	if local, err := File(ts.URL+"/data.json", opts); err != nil || local != recorded {
+		t.Errorf("File() = %q, %v, want %q", local, err, recorded)
+	}
+	if body, err := Get(ts.URL+"/other.json", opts); err == nil {
+		t.Errorf("Get() without a recorded response = %q, want an error", body)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("server hit %d times, want no network access", hits.Load())
+	}
+}
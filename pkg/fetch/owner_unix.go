@@ -0,0 +1,15 @@
+//go:build unix
+
+package fetch
+
+import (
+	"os"
+	"syscall"
+)
+
+// ownedByProcess reports whether a file belongs to the user the process
+// runs as
+func ownedByProcess(st os.FileInfo) bool {
+	sys, ok := st.Sys().(*syscall.Stat_t)
+	return ok && int(sys.Uid) == os.Getuid()
+}
@@ -0,0 +1,138 @@
+package gallery
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// EXIF tags read by the gallery
+const (
+	tagImageDescription = 0x010e
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagDateTimeOriginal = 0x9003
+)
+
+// exifDateLayout is the timestamp format used by EXIF
+const exifDateLayout = "2006:01:02 15:04:05"
+
+var errNoExif = errors.New("no EXIF data")
+
+// exifInfo holds the EXIF fields the gallery exposes
+type exifInfo struct {
+	Date    time.Time
+	Caption string
+}
+
+// readExif extracts the capture date and caption from a JPEG stream
+func readExif(r io.Reader) (exifInfo, error) {
+	var info exifInfo
+	tiff, err := findExifSegment(r)
+	if err != nil {
+		return info, err
+	}
+	if len(tiff) < 8 {
+		return info, errNoExif
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return info, errNoExif
+	}
+
+	ifd0 := readIFD(tiff, order, order.Uint32(tiff[4:8]))
+	info.Caption = strings.TrimSpace(ifd0.ascii(tiff, order, tagImageDescription))
+	date := ifd0.ascii(tiff, order, tagDateTime)
+	if off, ok := ifd0[tagExifIFD]; ok {
+		exifIFD := readIFD(tiff, order, order.Uint32(off[8:12]))
+		if d := exifIFD.ascii(tiff, order, tagDateTimeOriginal); d != "" {
+			date = d
+		}
+	}
+	if date != "" {
+		if t, err := time.ParseInLocation(exifDateLayout, strings.TrimSpace(date), time.Local); err == nil {
+			info.Date = t
+		}
+	}
+	return info, nil
+}
+
+// findExifSegment scans JPEG markers for the APP1 Exif segment and returns
+// its TIFF payload
+func findExifSegment(r io.Reader) ([]byte, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xff, 0xd8} {
+		return nil, errNoExif
+	}
+	for {
+		var marker [4]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return nil, errNoExif
+		}
+		if marker[0] != 0xff || marker[1] == 0xda {
+			// Start of scan: no more metadata segments
+			return nil, errNoExif
+		}
+		size := int(binary.BigEndian.Uint16(marker[2:])) - 2
+		if size < 0 {
+			return nil, errNoExif
+		}
+		segment := make([]byte, size)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return nil, errNoExif
+		}
+		if marker[1] == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:], nil
+		}
+	}
+}
+
+// ifd maps tag IDs to their raw 12-byte directory entries
+type ifd map[uint16][]byte
+
+// readIFD reads the image file directory at the given offset
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) ifd {
+	entries := ifd{}
+	if int(offset)+2 > len(tiff) {
+		return entries
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		start := int(offset) + 2 + i*12
+		if start+12 > len(tiff) {
+			break
+		}
+		entry := tiff[start : start+12]
+		entries[order.Uint16(entry)] = entry
+	}
+	return entries
+}
+
+// ascii returns the value of an ASCII-typed tag, or "" if absent
+func (d ifd) ascii(tiff []byte, order binary.ByteOrder, tag uint16) string {
+	entry, ok := d[tag]
+	if !ok || order.Uint16(entry[2:]) != 2 {
+		return ""
+	}
+	n := int(order.Uint32(entry[4:]))
+	var value []byte
+	if n <= 4 {
+		value = entry[8 : 8+n]
+	} else {
+		off := int(order.Uint32(entry[8:]))
+		if off+n > len(tiff) {
+			return ""
+		}
+		value = tiff[off : off+n]
+	}
+	return string(bytes.TrimRight(value, "\x00"))
+}
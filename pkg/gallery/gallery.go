@@ -0,0 +1,216 @@
+// Package gallery scans image directories and prepares template-ready
+// listings, including EXIF captions and generated thumbnails.
+package gallery
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ThumbDir is the subdirectory of each gallery that holds generated thumbnails
+const ThumbDir = ".thumbs"
+
+// DefaultThumbSize is the longest edge of a thumbnail, in pixels
+const DefaultThumbSize = 320
+
+// Options controls how a gallery directory is scanned
+type Options struct {
+	// Root is the filesystem directory that gallery paths are relative to
+	Root string
+	// URLPrefix is the public URL at which Root is served
+	URLPrefix string
+	// ThumbSize is the longest edge of generated thumbnails
+	ThumbSize int
+	// StripMetadata serves metadata-free copies of the originals
+	StripMetadata bool
+}
+
+// Image describes a single gallery entry
+type Image struct {
+	Name     string
+	URL      string
+	ThumbURL string
+	Width    int
+	Height   int
+	Date     time.Time
+	Caption  string
+}
+
+// Scan lists the images in dir (relative to opts.Root), generating any
+// missing or stale thumbnails. Images are ordered by date, then by name.
+func Scan(dir string, opts Options) ([]Image, error) {
+	if opts.ThumbSize <= 0 {
+		opts.ThumbSize = DefaultThumbSize
+	}
+	clean := filepath.Clean("/" + dir)
+	fsDir := filepath.Join(opts.Root, clean)
+	entries, err := os.ReadDir(fsDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading gallery directory: %w", err)
+	}
+
+	images := []Image{}
+	for _, e := range entries {
+		if e.IsDir() || !isImage(e.Name()) {
+			continue
+		}
+		img, err := scanImage(fsDir, e.Name(), opts)
+		if err != nil {
+			return nil, fmt.Errorf("gallery image %s: %w", e.Name(), err)
+		}
+		img.URL = path.Join(opts.URLPrefix, filepath.ToSlash(clean), e.Name())
+		if opts.StripMetadata {
+			img.URL = path.Join(opts.URLPrefix, filepath.ToSlash(clean), StrippedDir, e.Name())
+		}
+		img.ThumbURL = path.Join(opts.URLPrefix, filepath.ToSlash(clean), ThumbDir, e.Name())
+		images = append(images, img)
+	}
+
+	sort.SliceStable(images, func(i, j int) bool {
+		if !images[i].Date.Equal(images[j].Date) {
+			return images[i].Date.Before(images[j].Date)
+		}
+		return images[i].Name < images[j].Name
+	})
+	return images, nil
+}
+
+// isImage reports whether the file name has a supported image extension
+func isImage(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// scanImage reads the metadata of one image and ensures its thumbnail exists
+func scanImage(dir, name string, opts Options) (Image, error) {
+	filename := filepath.Join(dir, name)
+	f, err := os.Open(filename)
+	if err != nil {
+		return Image{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return Image{}, err
+	}
+	img := Image{Name: name, Date: stat.ModTime()}
+	if info, err := readExif(f); err == nil {
+		img.Caption = info.Caption
+		if !info.Date.IsZero() {
+			img.Date = info.Date
+		}
+	}
+
+	if _, err = f.Seek(0, 0); err != nil {
+		return Image{}, err
+	}
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return Image{}, fmt.Errorf("decoding image: %w", err)
+	}
+	img.Width, img.Height = cfg.Width, cfg.Height
+
+	thumb := filepath.Join(dir, ThumbDir, name)
+	if err = refresh(f, stat, thumb, func(dst string) error {
+		return writeThumbnail(f, dst, opts.ThumbSize)
+	}); err != nil {
+		return Image{}, fmt.Errorf("generating thumbnail: %w", err)
+	}
+	if opts.StripMetadata {
+		stripped := filepath.Join(dir, StrippedDir, name)
+		if err = refresh(f, stat, stripped, func(dst string) error {
+			return writeStripped(f, dst)
+		}); err != nil {
+			return Image{}, fmt.Errorf("stripping metadata: %w", err)
+		}
+	}
+	return img, nil
+}
+
+// refresh regenerates a derived file with generate if it is missing or older
+// than the source
+func refresh(src *os.File, stat os.FileInfo, dst string, generate func(string) error) error {
+	if ds, err := os.Stat(dst); err == nil && !ds.ModTime().Before(stat.ModTime()) {
+		return nil
+	}
+	if _, err := src.Seek(0, 0); err != nil {
+		return err
+	}
+	return generate(dst)
+}
+
+// writeThumbnail decodes src and writes a scaled copy to dst in the same
+// format, so that it is served with the original's content type. The
+// thumbnail is re-encoded from pixel data, so it never carries the original
+// metadata.
+func writeThumbnail(src *os.File, dst string, size int) error {
+	img, format, err := image.Decode(src)
+	if err != nil {
+		return err
+	}
+	thumb := scale(img, size)
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(tmp, thumb, &jpeg.Options{Quality: 85})
+	case "gif":
+		err = gif.Encode(tmp, thumb, nil)
+	default:
+		err = png.Encode(tmp, thumb)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// scale resizes img so its longest edge is at most size pixels, using
+// nearest-neighbour sampling
+func scale(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+		return dst
+	}
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	tw, th = max(tw, 1), max(th, 1)
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		sy := b.Min.Y + y*h/th
+		for x := 0; x < tw; x++ {
+			dst.Set(x, y, img.At(b.Min.X+x*w/tw, sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,270 @@
+package gallery
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// buildExif returns an APP1 segment with an IFD0 holding the given
+// description and date
+func buildExif(description, date string) []byte {
+	var tiff bytes.Buffer
+	le := binary.LittleEndian
+	tiff.WriteString("II")
+	_ = binary.Write(&tiff, le, uint16(42))
+	_ = binary.Write(&tiff, le, uint32(8))
+
+	desc := append([]byte(description), 0)
+	dt := append([]byte(date), 0)
+	dataOff := uint32(8 + 2 + 2*12 + 4)
+
+	_ = binary.Write(&tiff, le, uint16(2))
+	for _, e := range []struct {
+		tag   uint16
+		value []byte
+	}{{tagImageDescription, desc}, {tagDateTime, dt}} {
+		_ = binary.Write(&tiff, le, e.tag)
+		_ = binary.Write(&tiff, le, uint16(2))
+		_ = binary.Write(&tiff, le, uint32(len(e.value)))
+		_ = binary.Write(&tiff, le, dataOff)
+		dataOff += uint32(len(e.value))
+	}
+	_ = binary.Write(&tiff, le, uint32(0))
+	tiff.Write(desc)
+	tiff.Write(dt)
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	seg := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+func writeJPEG(t *testing.T, filename string, w, h int, exif []byte) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("encoding JPEG: %v", err)
+	}
+	data := buf.Bytes()
+	if exif != nil {
+		data = append(append([]byte{0xff, 0xd8}, exif...), data[2:]...)
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		t.Fatalf("writing JPEG: %v", err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "photos", "trip1")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	writeJPEG(t, filepath.Join(dir, "b.jpg"), 640, 480, buildExif("Sunset at the pier", "2023:07:04 20:15:00"))
+	writeJPEG(t, filepath.Join(dir, "a.jpg"), 100, 200, buildExif("Breakfast", "2023:07:04 08:00:00"))
+	pngFile, _ := os.Create(filepath.Join(dir, "c.png"))
+	_ = png.Encode(pngFile, image.NewRGBA(image.Rect(0, 0, 10, 10)))
+	_ = pngFile.Close()
+	_ = os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an image"), 0644)
+
+	images, err := Scan("photos/trip1", Options{Root: root, URLPrefix: "/media", ThumbSize: 64})
+	if err != nil {
+		t.Fatalf("Scan() error: %v", err)
+	}
+
+	if len(images) != 3 {
+		t.Fatalf("Scan() returned %d images, want 3", len(images))
+	}
+
+	// EXIF dates order the photos; the PNG has no EXIF so uses its mtime (now)
+	if images[0].Name != "a.jpg" || images[1].Name != "b.jpg" || images[2].Name != "c.png" {
+		t.Errorf("Scan() order = %s, %s, %s", images[0].Name, images[1].Name, images[2].Name)
+	}
+
+	b := images[1]
+	if b.Caption != "Sunset at the pier" {
+		t.Errorf("Caption = %q", b.Caption)
+	}
+	if !b.Date.Equal(time.Date(2023, 7, 4, 20, 15, 0, 0, time.Local)) {
+		t.Errorf("Date = %v", b.Date)
+	}
+	if b.Width != 640 || b.Height != 480 {
+		t.Errorf("Size = %dx%d, want 640x480", b.Width, b.Height)
+	}
+	if b.URL != "/media/photos/trip1/b.jpg" || b.ThumbURL != "/media/photos/trip1/.thumbs/b.jpg" {
+		t.Errorf("URL = %s, ThumbURL = %s", b.URL, b.ThumbURL)
+	}
+
+	f, err := os.Open(filepath.Join(dir, ThumbDir, "b.jpg"))
+	if err != nil {
+		t.Fatalf("thumbnail not generated: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		t.Fatalf("decoding thumbnail: %v", err)
+	}
+	if cfg.Width != 64 || cfg.Height != 48 {
+		t.Errorf("thumbnail size = %dx%d, want 64x48", cfg.Width, cfg.Height)
+	}
+}
+
+func TestScan_GIFThumbnail(t *testing.T) {
+	root := t.TempDir()
+	img := image.NewPaletted(image.Rect(0, 0, 200, 100), []color.Color{color.Black, color.White})
+	f, err := os.Create(filepath.Join(root, "anim.gif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = gif.Encode(f, img, nil)
+	_ = f.Close()
+
+	if _, err = Scan("/", Options{Root: root, ThumbSize: 50}); err != nil {
+		t.Fatalf("Scan() error: %v", err)
+	}
+	thumb, err := os.Open(filepath.Join(root, ThumbDir, "anim.gif"))
+	if err != nil {
+		t.Fatalf("thumbnail not generated: %v", err)
+	}
+	defer func() { _ = thumb.Close() }()
+	cfg, format, err := image.DecodeConfig(thumb)
+	if err != nil {
+		t.Fatalf("decoding thumbnail: %v", err)
+	}
+	if format != "gif" || cfg.Width != 50 || cfg.Height != 25 {
+		t.Errorf("thumbnail = %s %dx%d, want gif 50x25", format, cfg.Width, cfg.Height)
+	}
+}
+
+func TestScan_PathEscape(t *testing.T) {
+	root := t.TempDir()
+	if _, err := Scan("../../etc", Options{Root: root}); err == nil {
+		t.Error("Scan() outside root should fail to find the directory")
+	}
+}
+
+func TestReadExif_NoExif(t *testing.T) {
+	if _, err := readExif(bytes.NewReader([]byte("not a jpeg"))); err == nil {
+		t.Error("readExif() on non-JPEG should fail")
+	}
+}
+
+func TestStripMetadata_JPEG(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "gps.jpg")
+	writeJPEG(t, filename, 32, 32, buildExif("Home address", "2023:07:04 20:15:00"))
+	original, _ := os.ReadFile(filename)
+
+	var out bytes.Buffer
+	if err := StripMetadata(filename, bytes.NewReader(original), &out); err != nil {
+		t.Fatalf("StripMetadata() error: %v", err)
+	}
+
+	if bytes.Contains(out.Bytes(), []byte("Exif\x00\x00")) || bytes.Contains(out.Bytes(), []byte("Home address")) {
+		t.Error("stripped JPEG still contains EXIF data")
+	}
+	if _, err := readExif(bytes.NewReader(out.Bytes())); err == nil {
+		t.Error("readExif() found EXIF in stripped JPEG")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(out.Bytes())); err != nil {
+		t.Errorf("stripped JPEG does not decode: %v", err)
+	}
+}
+
+func TestStripMetadata_JPEGAppSegments(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "a.jpg")
+	writeJPEG(t, filename, 8, 8, nil)
+	original, _ := os.ReadFile(filename)
+	segment := func(marker byte, payload string) []byte {
+		seg := []byte{0xff, marker, 0, 0}
+		binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+		return append(seg, payload...)
+	}
+	var data []byte
+	data = append(data, original[:2]...)
+	data = append(data, segment(0xe2, "ICC_PROFILE\x00\x01\x01profile")...)
+	data = append(data, segment(0xe2, "FPXR\x00serial 12345")...)
+	data = append(data, segment(0xec, "Ducky owner")...)
+	data = append(data, original[2:]...)
+
+	var out bytes.Buffer
+	if err := StripMetadata(filename, bytes.NewReader(data), &out); err != nil {
+		t.Fatalf("StripMetadata() error: %v", err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("ICC_PROFILE")) {
+		t.Error("stripped JPEG lost its colour profile")
+	}
+	if bytes.Contains(out.Bytes(), []byte("serial 12345")) || bytes.Contains(out.Bytes(), []byte("owner")) {
+		t.Error("stripped JPEG still contains vendor APPn segments")
+	}
+}
+
+func TestStripMetadata_PNG(t *testing.T) {
+	var plain bytes.Buffer
+	_ = png.Encode(&plain, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+
+	// Insert a tEXt chunk after IHDR (signature + 25-byte IHDR chunk)
+	text := []byte("Comment\x00taken at 51.5N 0.1W")
+	chunk := make([]byte, 8, 12+len(text))
+	binary.BigEndian.PutUint32(chunk, uint32(len(text)))
+	copy(chunk[4:], "tEXt")
+	chunk = append(chunk, text...)
+	crc := crc32.ChecksumIEEE(chunk[4:])
+	chunk = binary.BigEndian.AppendUint32(chunk, crc)
+	data := append(append(append([]byte{}, plain.Bytes()[:33]...), chunk...), plain.Bytes()[33:]...)
+
+	var out bytes.Buffer
+	if err := StripMetadata("a.png", bytes.NewReader(data), &out); err != nil {
+		t.Fatalf("StripMetadata() error: %v", err)
+	}
+	if bytes.Contains(out.Bytes(), []byte("51.5N")) {
+		t.Error("stripped PNG still contains tEXt chunk")
+	}
+	if !bytes.Equal(out.Bytes(), plain.Bytes()) {
+		t.Error("stripped PNG differs from the original without metadata")
+	}
+
+	// A chunk length beyond the PNG limit is rejected before reading it
+	huge := append(append([]byte{}, plain.Bytes()[:33]...), 0xff, 0xff, 0xff, 0xff, 'I', 'D', 'A', 'T')
+	if err := StripMetadata("a.png", bytes.NewReader(huge), io.Discard); !errors.Is(err, errBadImage) {
+		t.Errorf("StripMetadata() with a huge chunk error = %v, want errBadImage", err)
+	}
+}
+
+func TestScan_StripMetadata(t *testing.T) {
+	root := t.TempDir()
+	writeJPEG(t, filepath.Join(root, "a.jpg"), 16, 16, buildExif("Caption", "2023:07:04 08:00:00"))
+
+	images, err := Scan("/", Options{Root: root, URLPrefix: "/media", StripMetadata: true})
+	if err != nil {
+		t.Fatalf("Scan() error: %v", err)
+	}
+	if images[0].URL != "/media/.stripped/a.jpg" {
+		t.Errorf("URL = %s, want /media/.stripped/a.jpg", images[0].URL)
+	}
+	stripped, err := os.ReadFile(filepath.Join(root, StrippedDir, "a.jpg"))
+	if err != nil {
+		t.Fatalf("stripped copy not written: %v", err)
+	}
+	if bytes.Contains(stripped, []byte("Exif\x00\x00")) {
+		t.Error("stripped copy still contains EXIF data")
+	}
+}
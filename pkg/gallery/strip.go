@@ -0,0 +1,193 @@
+package gallery
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StrippedDir is the subdirectory of each gallery that holds copies of the
+// originals with their metadata removed
+const StrippedDir = ".stripped"
+
+var errBadImage = errors.New("malformed image")
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// maxPNGChunk is the largest chunk length the PNG specification allows
+const maxPNGChunk = 1<<31 - 1
+
+// keptJPEGApps are the APPn segments that affect how a JPEG is decoded:
+// APP0 (JFIF), APP2 when it holds an ICC colour profile, and APP14 (Adobe
+// colour transform)
+var keptJPEGApps = map[byte]string{
+	0xe0: "JFIF\x00",
+	0xe2: "ICC_PROFILE\x00",
+	0xee: "Adobe",
+}
+
+// strippedPNGChunks are ancillary PNG chunks that can carry personal metadata
+var strippedPNGChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// StripMetadata copies an image from r to w without EXIF (including GPS),
+// XMP, IPTC, vendor and comment metadata. Colour profiles are preserved,
+// since removing them changes how the image looks; they describe the
+// device's colours, which can hint at its model. Formats other than JPEG
+// and PNG are copied unchanged.
+func StripMetadata(name string, r io.Reader, w io.Writer) error {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg":
+		return stripJPEG(r, w)
+	case ".png":
+		return stripPNG(r, w)
+	}
+	_, err := io.Copy(w, r)
+	return err
+}
+
+// stripJPEG removes COM segments and the APPn segments other than
+// keptJPEGApps, among them APP1 (EXIF/XMP), APP13 (IPTC) and maker data
+func stripJPEG(r io.Reader, w io.Writer) error {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xff, 0xd8} {
+		return errBadImage
+	}
+	if _, err := w.Write(soi[:]); err != nil {
+		return err
+	}
+	for {
+		var marker [4]byte
+		if _, err := io.ReadFull(r, marker[:2]); err != nil {
+			return errBadImage
+		}
+		if marker[0] != 0xff {
+			return errBadImage
+		}
+		if marker[1] == 0xda {
+			// Start of scan: the rest is image data
+			if _, err := w.Write(marker[:2]); err != nil {
+				return err
+			}
+			_, err := io.Copy(w, r)
+			return err
+		}
+		if _, err := io.ReadFull(r, marker[2:]); err != nil {
+			return errBadImage
+		}
+		size := int(binary.BigEndian.Uint16(marker[2:])) - 2
+		if size < 0 {
+			return errBadImage
+		}
+		segment := make([]byte, size)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return errBadImage
+		}
+		if !keepJPEGSegment(marker[1], segment) {
+			continue
+		}
+		if _, err := w.Write(marker[:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(segment); err != nil {
+			return err
+		}
+	}
+}
+
+// keepJPEGSegment reports whether a segment is copied to the stripped JPEG
+func keepJPEGSegment(marker byte, segment []byte) bool {
+	switch {
+	case marker == 0xfe:
+		return false
+	case marker >= 0xe0 && marker <= 0xef:
+		id, ok := keptJPEGApps[marker]
+		return ok && bytes.HasPrefix(segment, []byte(id))
+	}
+	return true
+}
+
+// stripPNG removes textual, timestamp and EXIF chunks
+func stripPNG(r io.Reader, w io.Writer) error {
+	sig := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, sig); err != nil || !bytes.Equal(sig, pngSignature) {
+		return errBadImage
+	}
+	if _, err := w.Write(sig); err != nil {
+		return err
+	}
+	for {
+		var head [8]byte
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return errBadImage
+		}
+		length := binary.BigEndian.Uint32(head[:4])
+		kind := string(head[4:])
+		if length > maxPNGChunk {
+			return fmt.Errorf("%w: %s chunk is too long", errBadImage, kind)
+		}
+
+		// Chunks are streamed, since image data chunks can be large; a
+		// chunk with a bad CRC fails the copy after it has been written
+		out := w
+		if strippedPNGChunks[kind] {
+			out = io.Discard
+		} else if _, err := w.Write(head[:]); err != nil {
+			return err
+		}
+		crc := crc32.NewIEEE()
+		_, _ = crc.Write(head[4:])
+		if _, err := io.CopyN(io.MultiWriter(out, crc), r, int64(length)); err != nil {
+			if errors.Is(err, io.EOF) {
+				return errBadImage
+			}
+			return err
+		}
+		var sum [4]byte
+		if _, err := io.ReadFull(r, sum[:]); err != nil {
+			return errBadImage
+		}
+		if binary.BigEndian.Uint32(sum[:]) != crc.Sum32() {
+			return fmt.Errorf("%w: bad CRC in %s chunk", errBadImage, kind)
+		}
+		if _, err := out.Write(sum[:]); err != nil {
+			return err
+		}
+		if kind == "IEND" {
+			return nil
+		}
+	}
+}
+
+// writeStripped writes a metadata-free copy of src to dst
+func writeStripped(src *os.File, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	err = StripMetadata(dst, src, tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
@@ -0,0 +1,210 @@
+// Package har provides a minimal HTTP Archive (HAR 1.2) writer for recording
+// simulated requests and the responses rendered for them.
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Log is the top-level HAR document
+type Log struct {
+	mu      sync.Mutex
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Entries []Entry `json:"entries"`
+}
+
+// Creator identifies the application that produced the HAR
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Entry is a single request/response pair
+type Entry struct {
+	StartedDateTime string   `json:"startedDateTime"`
+	Time            float64  `json:"time"`
+	Request         Request  `json:"request"`
+	Response        Response `json:"response"`
+	Cache           struct{} `json:"cache"`
+	Timings         Timings  `json:"timings"`
+	Comment         string   `json:"comment,omitempty"`
+}
+
+// Request describes the simulated request
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+// Response describes the rendered response
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []NameValue `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+// Content holds the response body
+type Content struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// NameValue is a generic name/value pair used for headers, cookies and query parameters
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Timings holds the phase durations of an entry, in milliseconds
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// New creates an empty HAR log
+func New(creatorName, creatorVersion string) *Log {
+	return &Log{
+		Version: "1.2",
+		Creator: Creator{Name: creatorName, Version: creatorVersion},
+		Entries: []Entry{},
+	}
+}
+
+// Add records a request together with the rendered response. A nil Log
+// discards the entry, so callers need not check whether recording is on
+func (l *Log) Add(req *http.Request, started time.Time, elapsed time.Duration,
+	status int, header http.Header, body []byte, comment string) {
+	if l == nil {
+		return
+	}
+	ms := float64(elapsed) / float64(time.Millisecond)
+	entry := Entry{
+		StartedDateTime: started.Format(time.RFC3339Nano),
+		Time:            ms,
+		Request: Request{
+			Method:      req.Method,
+			URL:         requestURL(req),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     cookies(req.Cookies()),
+			Headers:     headers(req.Header),
+			QueryString: queryString(req),
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response: Response{
+			Status:      status,
+			StatusText:  http.StatusText(status),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []NameValue{},
+			Headers:     headers(header),
+			Content: Content{
+				Size:     len(body),
+				MimeType: header.Get("Content-Type"),
+				Text:     string(body),
+			},
+			HeadersSize: -1,
+			BodySize:    len(body),
+		},
+		Timings: Timings{Send: 0, Wait: ms, Receive: 0},
+		Comment: comment,
+	}
+	l.mu.Lock()
+	l.Entries = append(l.Entries, entry)
+	l.mu.Unlock()
+}
+
+// Write encodes the log as a HAR document
+func (l *Log) Write(w io.Writer) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Log *Log `json:"log"`
+	}{l})
+}
+
+// WriteFile writes the log as a HAR document to the named file
+func (l *Log) WriteFile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("creating HAR file: %w", err)
+	}
+	if err = l.Write(f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing HAR file: %w", err)
+	}
+	return f.Close()
+}
+
+// requestURL returns an absolute URL for the request
+func requestURL(req *http.Request) string {
+	u := *req.URL
+	if u.Scheme == "" {
+		u.Scheme = "http"
+	}
+	if u.Host == "" {
+		u.Host = req.Host
+	}
+	return u.String()
+}
+
+// headers converts an http.Header into sorted name/value pairs
+func headers(h http.Header) []NameValue {
+	nv := []NameValue{}
+	for name, values := range h {
+		for _, v := range values {
+			nv = append(nv, NameValue{Name: name, Value: v})
+		}
+	}
+	sort.SliceStable(nv, func(i, j int) bool { return nv[i].Name < nv[j].Name })
+	return nv
+}
+
+// cookies converts request cookies into name/value pairs
+func cookies(cs []*http.Cookie) []NameValue {
+	nv := []NameValue{}
+	for _, c := range cs {
+		nv = append(nv, NameValue{Name: c.Name, Value: c.Value})
+	}
+	return nv
+}
+
+// queryString converts the request query into sorted name/value pairs
+func queryString(req *http.Request) []NameValue {
+	nv := []NameValue{}
+	q := req.URL.Query()
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range q[k] {
+			nv = append(nv, NameValue{Name: k, Value: v})
+		}
+	}
+	return nv
+}
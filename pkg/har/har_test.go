@@ -0,0 +1,81 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLog_AddAndWrite(t *testing.T) {
+	l := New("tmpl.cgi", "test")
+
+	req, _ := http.NewRequest("GET", "/blog/1?b=2&a=1", nil)
+	req.Host = "example.com"
+	req.Header.Set("User-Agent", "Template-Validator/1.0")
+	header := http.Header{}
+	header.Set("Content-Type", "text/html; charset=utf-8")
+
+	l.Add(req, time.Now(), 5*time.Millisecond, http.StatusOK, header, []byte("<p>hi</p>"), "template: blog.html")
+
+	var buf bytes.Buffer
+	if err := l.Write(&buf); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	var doc struct {
+		Log struct {
+			Version string  `json:"version"`
+			Entries []Entry `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("HAR output is not valid JSON: %v", err)
+	}
+
+	if doc.Log.Version != "1.2" {
+		t.Errorf("Version = %s, want 1.2", doc.Log.Version)
+	}
+	if len(doc.Log.Entries) != 1 {
+		t.Fatalf("Entries length = %d, want 1", len(doc.Log.Entries))
+	}
+
+	e := doc.Log.Entries[0]
+	if e.Request.URL != "http://example.com/blog/1?b=2&a=1" {
+		t.Errorf("Request.URL = %s", e.Request.URL)
+	}
+	if len(e.Request.QueryString) != 2 || e.Request.QueryString[0].Name != "a" {
+		t.Errorf("QueryString = %v, want sorted a, b", e.Request.QueryString)
+	}
+	if e.Response.Status != http.StatusOK || e.Response.Content.Text != "<p>hi</p>" {
+		t.Errorf("Response = %+v", e.Response)
+	}
+	if e.Response.Content.MimeType != "text/html; charset=utf-8" {
+		t.Errorf("MimeType = %s", e.Response.Content.MimeType)
+	}
+}
+
+func TestLog_NilAdd(t *testing.T) {
+	var l *Log
+	req, _ := http.NewRequest("GET", "/", nil)
+	// Must not panic
+	l.Add(req, time.Now(), 0, http.StatusOK, http.Header{}, nil, "")
+}
+
+func TestLog_WriteFile(t *testing.T) {
+	l := New("tmpl.cgi", "test")
+	filename := filepath.Join(t.TempDir(), "out.har")
+	if err := l.WriteFile(filename); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("reading HAR file: %v", err)
+	}
+	if !json.Valid(data) {
+		t.Errorf("HAR file is not valid JSON: %s", data)
+	}
+}
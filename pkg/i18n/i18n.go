@@ -0,0 +1,170 @@
+// Package i18n formats values for a locale: dates with localized month and
+// day names in the conventional order of a language, and numbers and prices
+// with the locale's separators.
+package i18n
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is the locale used when none is configured
+const DefaultLocale = "en"
+
+// Date styles, from the most compact to the most verbose
+const (
+	Short  = "short"
+	Medium = "medium"
+	Long   = "long"
+	Full   = "full"
+)
+
+// dateNames are the month and weekday names of a language
+type dateNames struct {
+	months      [12]string
+	shortMonths [12]string
+	weekdays    [7]string // from Sunday
+}
+
+// dateFormats are the date patterns of a locale by style. Patterns use
+// d/dd for the day, M/MM/MMM/MMMM for the month, yy/yyyy for the year and
+// EEEE for the weekday; other characters are copied.
+type dateFormats map[string]string
+
+var names = map[string]dateNames{
+	"en": {
+		months:      [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		shortMonths: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		weekdays:    [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	},
+	"de": {
+		months:      [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths: [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		weekdays:    [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+	},
+	"fr": {
+		months:      [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		shortMonths: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		weekdays:    [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+	},
+	"es": {
+		months:      [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		shortMonths: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		weekdays:    [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+	},
+	"it": {
+		months:      [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		shortMonths: [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		weekdays:    [7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+	},
+	"nl": {
+		months:      [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		shortMonths: [12]string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		weekdays:    [7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+	},
+	"pt": {
+		months:      [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		shortMonths: [12]string{"jan.", "fev.", "mar.", "abr.", "mai.", "jun.", "jul.", "ago.", "set.", "out.", "nov.", "dez."},
+		weekdays:    [7]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+	},
+}
+
+// formats holds the date patterns by language, and by language and region
+// where a region differs from its language's default
+var formats = map[string]dateFormats{
+	"en":    {Short: "M/d/yy", Medium: "MMM d, yyyy", Long: "MMMM d, yyyy", Full: "EEEE, MMMM d, yyyy"},
+	"en-GB": {Short: "dd/MM/yyyy", Medium: "d MMM yyyy", Long: "d MMMM yyyy", Full: "EEEE d MMMM yyyy"},
+	"en-AU": {Short: "d/M/yy", Medium: "d MMM yyyy", Long: "d MMMM yyyy", Full: "EEEE d MMMM yyyy"},
+	"de":    {Short: "dd.MM.yy", Medium: "dd.MM.yyyy", Long: "d. MMMM yyyy", Full: "EEEE, d. MMMM yyyy"},
+	"fr":    {Short: "dd/MM/yyyy", Medium: "d MMM yyyy", Long: "d MMMM yyyy", Full: "EEEE d MMMM yyyy"},
+	"es":    {Short: "d/M/yy", Medium: "d MMM yyyy", Long: "d 'de' MMMM 'de' yyyy", Full: "EEEE, d 'de' MMMM 'de' yyyy"},
+	"it":    {Short: "dd/MM/yy", Medium: "d MMM yyyy", Long: "d MMMM yyyy", Full: "EEEE d MMMM yyyy"},
+	"nl":    {Short: "dd-MM-yyyy", Medium: "d MMM yyyy", Long: "d MMMM yyyy", Full: "EEEE d MMMM yyyy"},
+	"pt":    {Short: "dd/MM/yyyy", Medium: "d 'de' MMM 'de' yyyy", Long: "d 'de' MMMM 'de' yyyy", Full: "EEEE, d 'de' MMMM 'de' yyyy"},
+}
+
+// supported are the locales with their own date patterns, for matching
+var supported = func() []language.Tag {
+	tags := []language.Tag{language.MustParse(DefaultLocale)}
+	for name := range formats {
+		if name != DefaultLocale {
+			tags = append(tags, language.MustParse(name))
+		}
+	}
+	return tags
+}()
+
+var matcher = language.NewMatcher(supported)
+
+// ParseLocale parses a BCP 47 language tag such as "de-CH"
+func ParseLocale(locale string) (language.Tag, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return language.Und, fmt.Errorf("invalid locale '%s': %w", locale, err)
+	}
+	return tag, nil
+}
+
+// lookup returns the names and patterns closest to a locale, falling back
+// to English
+func lookup(tag language.Tag) (dateNames, dateFormats) {
+	_, i, _ := matcher.Match(tag)
+	match := supported[i]
+	base, _ := match.Base()
+	return names[base.String()], formats[match.String()]
+}
+
+// FormatDate formats t in a date style of a locale, such as "2. Januar
+// 2006" for the long style of German
+func FormatDate(tag language.Tag, t time.Time, style string) (string, error) {
+	n, f := lookup(tag)
+	pattern, ok := f[style]
+	if !ok {
+		return "", fmt.Errorf("unknown date style '%s'", style)
+	}
+	var b strings.Builder
+	for i := 0; i < len(pattern); {
+		c := pattern[i]
+		if c == '\'' {
+			end := strings.IndexByte(pattern[i+1:], '\'')
+			if end < 0 {
+				end = len(pattern) - i - 1
+			}
+			b.WriteString(pattern[i+1 : i+1+end])
+			i += end + 2
+			continue
+		}
+		j := i
+		for j < len(pattern) && pattern[j] == c {
+			j++
+		}
+		switch token := pattern[i:j]; token {
+		case "d":
+			b.WriteString(strconv.Itoa(t.Day()))
+		case "dd":
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case "M":
+			b.WriteString(strconv.Itoa(int(t.Month())))
+		case "MM":
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case "MMM":
+			b.WriteString(n.shortMonths[t.Month()-1])
+		case "MMMM":
+			b.WriteString(n.months[t.Month()-1])
+		case "yy":
+			fmt.Fprintf(&b, "%02d", t.Year()%100)
+		case "yyyy":
+			b.WriteString(strconv.Itoa(t.Year()))
+		case "EEEE":
+			b.WriteString(n.weekdays[t.Weekday()])
+		default:
+			b.WriteString(token)
+		}
+		i = j
+	}
+	return b.String(), nil
+}
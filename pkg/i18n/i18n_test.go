@@ -0,0 +1,107 @@
+package i18n
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatDate(t *testing.T) {
+	date := time.Date(2025, time.March, 4, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		locale   string
+		style    string
+		expected string
+	}{
+		{"en", Short, "3/4/25"},
+		{"en-US", Long, "March 4, 2025"},
+		{"en", Full, "Tuesday, March 4, 2025"},
+		{"en-GB", Short, "04/03/2025"},
+		{"en-GB", Long, "4 March 2025"},
+		{"de", Medium, "04.03.2025"},
+		{"de-CH", Long, "4. März 2025"},
+		{"de", Full, "Dienstag, 4. März 2025"},
+		{"fr", Medium, "4 mars 2025"},
+		{"fr-CA", Full, "mardi 4 mars 2025"},
+		{"es", Long, "4 de marzo de 2025"},
+		{"it", Full, "martedì 4 marzo 2025"},
+		{"nl", Short, "04-03-2025"},
+		{"pt-BR", Medium, "4 de mar. de 2025"},
+		{"ja", Long, "March 4, 2025"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale+"/"+tt.style, func(t *testing.T) {
+			tag, err := ParseLocale(tt.locale)
+			if err != nil {
+				t.Fatalf("ParseLocale() error: %v", err)
+			}
+			got, err := FormatDate(tag, date, tt.style)
+			if err != nil {
+				t.Fatalf("FormatDate() error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("FormatDate() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatDate_Errors(t *testing.T) {
+	if _, err := ParseLocale("not a locale!"); err == nil {
+		t.Error("ParseLocale() succeeded for an invalid tag")
+	}
+	tag, _ := ParseLocale(DefaultLocale)
+	if _, err := FormatDate(tag, time.Now(), "tiny"); err == nil {
+		t.Error("FormatDate() succeeded for an unknown style")
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		locale   string
+		value    float64
+		expected string
+	}{
+		{"en", 1234567.5, "1,234,567.5"},
+		{"de", 1234567.5, "1.234.567,5"},
+		{"fr", 1234.25, "1\u00a0234,25"},
+		{"en", -42, "-42"},
+	}
+	for _, tt := range tests {
+		tag, _ := ParseLocale(tt.locale)
+		if got := FormatNumber(tag, tt.value); got != tt.expected {
+			t.Errorf("FormatNumber(%s, %v) = %q, want %q", tt.locale, tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestFormatCurrency(t *testing.T) {
+	tests := []struct {
+		locale   string
+		code     string
+		amount   float64
+		expected string
+	}{
+		{"en", "EUR", 1234.5, "€1,234.50"},
+		{"en-US", "USD", 19.999, "$20.00"},
+		{"de", "EUR", 1234.5, "1.234,50\u00a0€"},
+		{"de-CH", "CHF", 1234.5, "CHF\u00a01’234.50"},
+		{"nl", "EUR", 9.95, "€\u00a09,95"},
+		{"fr", "EUR", -5, "-5,00\u00a0€"},
+		{"en", "JPY", 1500.4, "¥1,500"},
+		{"en", "EUR", -0.001, "€0.00"},
+	}
+	for _, tt := range tests {
+		tag, _ := ParseLocale(tt.locale)
+		got, err := FormatCurrency(tag, tt.code, tt.amount)
+		if err != nil {
+			t.Fatalf("FormatCurrency() error: %v", err)
+		}
+		if got != tt.expected {
+			t.Errorf("FormatCurrency(%s, %s, %v) = %q, want %q", tt.locale, tt.code, tt.amount, got, tt.expected)
+		}
+	}
+	tag, _ := ParseLocale(DefaultLocale)
+	if _, err := FormatCurrency(tag, "XYZW", 1); err == nil {
+		t.Error("FormatCurrency() succeeded for an unknown currency")
+	}
+}
@@ -0,0 +1,393 @@
+package i18n
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+)
+
+// Catalog holds translated messages by locale and key. Messages use a
+// subset of ICU MessageFormat: {name} arguments, {name, number},
+// {name, date, STYLE}, {name, plural, ...}, {name, selectordinal, ...} and
+// {name, select, ...}, with # standing for the number inside plurals and
+// apostrophes quoting braces.
+type Catalog struct {
+	locales  []language.Tag
+	messages []map[string]msg
+	matcher  language.Matcher
+	fallback int
+}
+
+// msg is a parsed message: a list of text, argument and # parts
+type msg []part
+
+// part is one piece of a message. A part with no name is text, or the
+// plural number if hash is set.
+type part struct {
+	text    string
+	hash    bool
+	name    string
+	kind    string
+	style   string
+	options map[string]msg
+}
+
+// NewCatalog parses the messages of each locale. Keys missing from the
+// best matching locale are looked up in the default locale, if present.
+func NewCatalog(messages map[string]map[string]string) (*Catalog, error) {
+	names := make([]string, 0, len(messages))
+	for name := range messages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	c := &Catalog{fallback: -1}
+	for _, name := range names {
+		tag, err := ParseLocale(name)
+		if err != nil {
+			return nil, err
+		}
+		parsed := make(map[string]msg, len(messages[name]))
+		for key, text := range messages[name] {
+			m, err := parseMessage(text)
+			if err != nil {
+				return nil, fmt.Errorf("message %s.%s: %w", name, key, err)
+			}
+			parsed[key] = m
+		}
+		if name == DefaultLocale {
+			c.fallback = len(c.locales)
+		}
+		c.locales = append(c.locales, tag)
+		c.messages = append(c.messages, parsed)
+	}
+	if len(c.locales) > 0 {
+		c.matcher = language.NewMatcher(c.locales)
+	}
+	return c, nil
+}
+
+// Format formats the message key of the locale closest to tag with the
+// named arguments. A key no locale has is returned as is, so that missing
+// translations show up on the page instead of breaking it.
+func (c *Catalog) Format(tag language.Tag, key string, args map[string]any) (string, error) {
+	m, ok := c.lookup(tag, key)
+	if !ok {
+		return key, nil
+	}
+	var b strings.Builder
+	if err := m.format(&b, tag, args, nil); err != nil {
+		return "", fmt.Errorf("message %s: %w", key, err)
+	}
+	return b.String(), nil
+}
+
+// lookup finds a message in the best matching locale, or else in the
+// default locale
+func (c *Catalog) lookup(tag language.Tag, key string) (msg, bool) {
+	if c.matcher == nil {
+		return nil, false
+	}
+	_, i, conf := c.matcher.Match(tag)
+	if conf != language.No {
+		if m, ok := c.messages[i][key]; ok {
+			return m, true
+		}
+	}
+	if c.fallback >= 0 {
+		m, ok := c.messages[c.fallback][key]
+		return m, ok
+	}
+	return nil, false
+}
+
+// format writes the message. num is the value # stands for, inside a
+// plural.
+func (m msg) format(b *strings.Builder, tag language.Tag, args map[string]any, num *float64) error {
+	for _, p := range m {
+		switch {
+		case p.hash:
+			if num != nil {
+				b.WriteString(FormatNumber(tag, *num))
+			} else {
+				b.WriteByte('#')
+			}
+		case p.name == "":
+			b.WriteString(p.text)
+		default:
+			if err := p.formatArg(b, tag, args, num); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// formatArg writes an argument part
+func (p *part) formatArg(b *strings.Builder, tag language.Tag, args map[string]any, num *float64) error {
+	v, ok := args[p.name]
+	switch p.kind {
+	case "":
+		if ok && v != nil {
+			fmt.Fprint(b, v)
+		}
+		return nil
+	case "number":
+		n, ok := toFloat(v)
+		if !ok {
+			return fmt.Errorf("argument %s is not a number", p.name)
+		}
+		b.WriteString(FormatNumber(tag, n))
+		return nil
+	case "date":
+		t, ok := v.(time.Time)
+		if !ok {
+			return fmt.Errorf("argument %s is not a time", p.name)
+		}
+		style := p.style
+		if style == "" {
+			style = Medium
+		}
+		s, err := FormatDate(tag, t, style)
+		if err != nil {
+			return err
+		}
+		b.WriteString(s)
+		return nil
+	case "select":
+		option, ok := p.options[fmt.Sprint(v)]
+		if !ok {
+			option = p.options["other"]
+		}
+		return option.format(b, tag, args, num)
+	}
+	// plural and selectordinal
+	n, ok := toFloat(v)
+	if !ok {
+		return fmt.Errorf("argument %s is not a number", p.name)
+	}
+	option, ok := p.options["="+strconv.FormatFloat(n, 'f', -1, 64)]
+	if !ok {
+		rules := plural.Cardinal
+		if p.kind == "selectordinal" {
+			rules = plural.Ordinal
+		}
+		if option, ok = p.options[pluralForm(rules, tag, n)]; !ok {
+			option = p.options["other"]
+		}
+	}
+	return option.format(b, tag, args, &n)
+}
+
+// pluralForms names the plural forms the way ICU messages do
+var pluralForms = map[plural.Form]string{
+	plural.Zero:  "zero",
+	plural.One:   "one",
+	plural.Two:   "two",
+	plural.Few:   "few",
+	plural.Many:  "many",
+	plural.Other: "other",
+}
+
+// pluralForm returns the plural form of n in a language
+func pluralForm(rules *plural.Rules, tag language.Tag, n float64) string {
+	digits := strconv.FormatFloat(math.Abs(n), 'f', -1, 64)
+	whole, frac, _ := strings.Cut(digits, ".")
+	i, _ := strconv.Atoi(whole)
+	f, _ := strconv.Atoi("0" + frac)
+	return pluralForms[rules.MatchPlural(tag, i, len(frac), len(frac), f, f)]
+}
+
+// toFloat converts a numeric argument
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// parseMessage parses a message in the ICU subset
+func parseMessage(s string) (msg, error) {
+	p := &parser{s: s}
+	m, err := p.message(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected '}' at offset %d", p.pos)
+	}
+	return m, nil
+}
+
+// parser reads a message
+type parser struct {
+	s   string
+	pos int
+}
+
+// message reads parts up to the end of the text or, nested in an option,
+// up to the closing brace. # is special inside plural options.
+func (p *parser) message(inPlural bool) (msg, error) {
+	var m msg
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			m = append(m, part{text: text.String()})
+			text.Reset()
+		}
+	}
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		switch {
+		case c == '}':
+			flush()
+			return m, nil
+		case c == '{':
+			flush()
+			arg, err := p.argument()
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, arg)
+		case c == '#' && inPlural:
+			flush()
+			m = append(m, part{hash: true})
+			p.pos++
+		case c == '\'':
+			text.WriteString(p.quoted(inPlural))
+		default:
+			text.WriteByte(c)
+			p.pos++
+		}
+	}
+	flush()
+	return m, nil
+}
+
+// quoted reads an apostrophe: a doubled apostrophe is literal, and one
+// before a brace (or # in a plural) quotes text up to the next apostrophe.
+// Any other apostrophe is literal.
+func (p *parser) quoted(inPlural bool) string {
+	rest := p.s[p.pos+1:]
+	if strings.HasPrefix(rest, "'") {
+		p.pos += 2
+		return "'"
+	}
+	if rest == "" || !(rest[0] == '{' || rest[0] == '}' || (inPlural && rest[0] == '#')) {
+		p.pos++
+		return "'"
+	}
+	end := strings.IndexByte(rest, '\'')
+	if end < 0 {
+		p.pos = len(p.s)
+		return rest
+	}
+	p.pos += end + 2
+	return rest[:end]
+}
+
+// argument reads an argument from its opening brace through its closing
+// brace
+func (p *parser) argument() (part, error) {
+	start := p.pos
+	p.pos++
+	fields := []string{}
+	for len(fields) < 3 {
+		end := strings.IndexAny(p.s[p.pos:], ",{}")
+		if end < 0 {
+			return part{}, fmt.Errorf("unclosed argument at offset %d", start)
+		}
+		fields = append(fields, strings.TrimSpace(p.s[p.pos:p.pos+end]))
+		p.pos += end
+		if p.s[p.pos] != ',' {
+			break
+		}
+		p.pos++
+		if len(fields) == 2 && (fields[1] == "plural" || fields[1] == "selectordinal" || fields[1] == "select") {
+			break
+		}
+	}
+	arg := part{name: fields[0]}
+	if arg.name == "" {
+		return part{}, fmt.Errorf("argument without a name at offset %d", start)
+	}
+	if len(fields) > 1 {
+		arg.kind = fields[1]
+	}
+	if len(fields) > 2 {
+		arg.style = fields[2]
+	}
+	switch arg.kind {
+	case "", "number", "date":
+		if p.pos >= len(p.s) || p.s[p.pos] != '}' {
+			return part{}, fmt.Errorf("unclosed argument at offset %d", start)
+		}
+		p.pos++
+		return arg, nil
+	case "plural", "selectordinal", "select":
+		options, err := p.options(arg.kind != "select")
+		if err != nil {
+			return part{}, err
+		}
+		if _, ok := options["other"]; !ok {
+			return part{}, fmt.Errorf("%s argument %s has no 'other' option", arg.kind, arg.name)
+		}
+		arg.options = options
+		return arg, nil
+	}
+	return part{}, fmt.Errorf("unknown argument type '%s'", arg.kind)
+}
+
+// options reads the "selector {message}" options of a plural or select
+// argument through its closing brace
+func (p *parser) options(inPlural bool) (map[string]msg, error) {
+	options := map[string]msg{}
+	for {
+		for p.pos < len(p.s) && isSpace(p.s[p.pos]) {
+			p.pos++
+		}
+		if p.pos >= len(p.s) {
+			return nil, fmt.Errorf("unclosed options")
+		}
+		if p.s[p.pos] == '}' {
+			p.pos++
+			return options, nil
+		}
+		end := strings.IndexByte(p.s[p.pos:], '{')
+		if end < 0 {
+			return nil, fmt.Errorf("option without a message at offset %d", p.pos)
+		}
+		selector := strings.TrimSpace(p.s[p.pos : p.pos+end])
+		if selector == "" || strings.ContainsAny(selector, " \t\n}") {
+			return nil, fmt.Errorf("invalid option '%s'", selector)
+		}
+		p.pos += end + 1
+		m, err := p.message(inPlural)
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.s) {
+			return nil, fmt.Errorf("unclosed option '%s'", selector)
+		}
+		p.pos++
+		options[selector] = m
+	}
+}
+
+// isSpace reports whether c is white space between options
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
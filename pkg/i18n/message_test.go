@@ -0,0 +1,100 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCatalog_Format(t *testing.T) {
+	catalog, err := NewCatalog(map[string]map[string]string{
+		"en": {
+			"items":    "{count, plural, =0 {No items} one {# item} other {# items}}",
+			"welcome":  "Hello, {name}!",
+			"invite":   "{host} invited {guests, plural, one {one guest} other {# guests}} to {gender, select, female {her} male {his} other {their}} party",
+			"place":    "You finished {n, selectordinal, one {#st} two {#nd} few {#rd} other {#th}}",
+			"quoted":   "Use '{name}' literally, it''s fine",
+			"total":    "Total: {sum, number}",
+			"deadline": "Due {when, date, long}",
+			"only_en":  "English only",
+		},
+		"de": {
+			"items":   "{count, plural, =0 {Keine Artikel} one {# Artikel} other {# Artikel}}",
+			"welcome": "Hallo, {name}!",
+			"total":   "Summe: {sum, number}",
+		},
+		"pl": {
+			"items": "{count, plural, one {# plik} few {# pliki} many {# plików} other {# pliku}}",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCatalog() error: %v", err)
+	}
+	tests := []struct {
+		locale   string
+		key      string
+		args     map[string]any
+		expected string
+	}{
+		{"en", "items", map[string]any{"count": 0}, "No items"},
+		{"en", "items", map[string]any{"count": 1}, "1 item"},
+		{"en", "items", map[string]any{"count": 1500}, "1,500 items"},
+		{"en", "items", map[string]any{"count": 1.5}, "1.5 items"},
+		{"en-GB", "welcome", map[string]any{"name": "Ada"}, "Hello, Ada!"},
+		{"en", "invite", map[string]any{"host": "Kim", "guests": 3, "gender": "female"}, "Kim invited 3 guests to her party"},
+		{"en", "invite", map[string]any{"host": "Sam", "guests": 1, "gender": "x"}, "Sam invited one guest to their party"},
+		{"en", "place", map[string]any{"n": 2}, "You finished 2nd"},
+		{"en", "place", map[string]any{"n": 13}, "You finished 13th"},
+		{"en", "place", map[string]any{"n": 23}, "You finished 23rd"},
+		{"en", "quoted", nil, "Use {name} literally, it's fine"},
+		{"en", "deadline", map[string]any{"when": time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)}, "Due June 2, 2025"},
+		{"de-AT", "items", map[string]any{"count": 2}, "2 Artikel"},
+		{"de", "total", map[string]any{"sum": 1234.5}, "Summe: 1.234,5"},
+		{"de", "only_en", nil, "English only"},
+		{"pl", "items", map[string]any{"count": 1}, "1 plik"},
+		{"pl", "items", map[string]any{"count": 3}, "3 pliki"},
+		{"pl", "items", map[string]any{"count": 5}, "5 plików"},
+		{"pl", "items", map[string]any{"count": 22}, "22 pliki"},
+		{"fr", "welcome", map[string]any{"name": "Zoé"}, "Hello, Zoé!"},
+		{"en", "no.such.key", nil, "no.such.key"},
+	}
+	for _, tt := range tests {
+		tag, _ := ParseLocale(tt.locale)
+		got, err := catalog.Format(tag, tt.key, tt.args)
+		if err != nil {
+			t.Errorf("Format(%s, %s) error: %v", tt.locale, tt.key, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("Format(%s, %s) = %q, want %q", tt.locale, tt.key, got, tt.expected)
+		}
+	}
+
+	tag, _ := ParseLocale("en")
+	if _, err = catalog.Format(tag, "items", map[string]any{"count": "many"}); err == nil {
+		t.Error("Format() succeeded with a non-numeric plural argument")
+	}
+}
+
+func TestNewCatalog_Errors(t *testing.T) {
+	tests := []struct {
+		message string
+		wantErr string
+	}{
+		{"{count, plural, one {# item}}", "no 'other' option"},
+		{"{count, plural, one {# item} other {# items}", "unclosed"},
+		{"Hello {name", "unclosed argument"},
+		{"{n, currency}", "unknown argument type 'currency'"},
+		{"{}", "without a name"},
+		{"stray }", "unexpected '}'"},
+	}
+	for _, tt := range tests {
+		_, err := NewCatalog(map[string]map[string]string{"en": {"key": tt.message}})
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("NewCatalog(%q) error = %v, want %q", tt.message, err, tt.wantErr)
+		}
+	}
+	if _, err := NewCatalog(map[string]map[string]string{"not a locale!": {}}); err == nil {
+		t.Error("NewCatalog() succeeded for an invalid locale")
+	}
+}
@@ -0,0 +1,68 @@
+package i18n
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// currencyPatterns place the currency symbol (¤) relative to the amount (#),
+// by language and by language and region where a region differs. Spaces are
+// non-breaking, so that prices are not split across lines.
+var currencyPatterns = map[string]string{
+	"en":    "¤#",
+	"de":    "#\u00a0¤",
+	"de-CH": "¤\u00a0#",
+	"fr":    "#\u00a0¤",
+	"es":    "#\u00a0¤",
+	"it":    "#\u00a0¤",
+	"nl":    "¤\u00a0#",
+	"pt":    "¤\u00a0#",
+	"pt-PT": "#\u00a0¤",
+}
+
+// currencyPattern returns the symbol placement of a locale, symbol first by
+// default
+func currencyPattern(tag language.Tag) string {
+	base, _ := tag.Base()
+	if region, conf := tag.Region(); conf == language.Exact {
+		if p, ok := currencyPatterns[base.String()+"-"+region.String()]; ok {
+			return p
+		}
+	}
+	if p, ok := currencyPatterns[base.String()]; ok {
+		return p
+	}
+	return "¤#"
+}
+
+// FormatNumber formats a number with the digit grouping and decimal
+// separator of a locale, such as "1.234,5" in German
+func FormatNumber(tag language.Tag, v float64) string {
+	return message.NewPrinter(tag).Sprint(number.Decimal(v))
+}
+
+// FormatCurrency formats an amount of a currency, given by its ISO 4217
+// code, the way a locale writes prices: "€1,234.50" in English and
+// "1.234,50 €" in German. The amount is rounded to the currency's usual
+// number of decimals.
+func FormatCurrency(tag language.Tag, code string, amount float64) (string, error) {
+	unit, err := currency.ParseISO(code)
+	if err != nil {
+		return "", fmt.Errorf("unknown currency '%s'", code)
+	}
+	scale, _ := currency.Standard.Rounding(unit)
+	p := message.NewPrinter(tag)
+	digits := p.Sprint(number.Decimal(math.Abs(amount), number.Scale(scale)))
+	out := strings.Replace(currencyPattern(tag), "#", digits, 1)
+	out = strings.Replace(out, "¤", p.Sprint(currency.Symbol(unit)), 1)
+	if amount < 0 && math.Round(-amount*math.Pow10(scale)) > 0 {
+		out = "-" + out
+	}
+	return out, nil
+}
@@ -0,0 +1,217 @@
+// Package ldapgroup looks up the groups of a user in an LDAP directory, so
+// that routes restricted with require_group can use intranet directories
+// when the web server authenticates users but provides no group data.
+package ldapgroup
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Defaults for the directory settings
+const (
+	DefaultGroupFilter    = "(|(memberUid={user})(member={dn}))"
+	DefaultUserFilter     = "(uid={user})"
+	DefaultGroupAttribute = "cn"
+	DefaultCacheTTL       = 5 * time.Minute
+	DefaultTimeout        = 10 * time.Second
+)
+
+// Directory configures group lookups in an LDAP directory. In the filters,
+// {user} is replaced by the user name and {dn} by the user's DN, found with
+// the user filter; both are escaped.
+type Directory struct {
+	URL             string        `yaml:"url"`
+	StartTLS        bool          `yaml:"start_tls,omitempty"`
+	BindDN          string        `yaml:"bind_dn,omitempty"`
+	BindPassword    string        `yaml:"bind_password,omitempty"`
+	BindPasswordEnv string        `yaml:"bind_password_env,omitempty"`
+	BaseDN          string        `yaml:"base_dn"`
+	UserFilter      string        `yaml:"user_filter,omitempty"`
+	GroupFilter     string        `yaml:"group_filter,omitempty"`
+	GroupAttribute  string        `yaml:"group_attribute,omitempty"`
+	CacheTTL        time.Duration `yaml:"cache_ttl,omitempty"`
+	Timeout         time.Duration `yaml:"timeout,omitempty"`
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+
+	// dial connects to the directory; tests replace it
+	dial func() (ldap.Client, error)
+}
+
+// cacheEntry holds the groups of a user until it expires
+type cacheEntry struct {
+	groups  []string
+	expires time.Time
+}
+
+// Validate checks the directory settings
+func (d *Directory) Validate() error {
+	u, err := url.Parse(d.URL)
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		return fmt.Errorf("ldap: url must be an ldap:// or ldaps:// URL")
+	}
+	if d.StartTLS && u.Scheme == "ldaps" {
+		return fmt.Errorf("ldap: start_tls cannot be used with ldaps")
+	}
+	if d.BaseDN == "" {
+		return fmt.Errorf("ldap: base_dn is required")
+	}
+	for _, filter := range []string{d.UserFilter, d.GroupFilter} {
+		if filter == "" {
+			continue
+		}
+		if _, err = ldap.CompileFilter(expand(filter, "user", "dn")); err != nil {
+			return fmt.Errorf("ldap: invalid filter %s: %w", filter, err)
+		}
+	}
+	return nil
+}
+
+// Groups returns the names of the groups user belongs to, from the cache
+// if it was looked up within the cache TTL
+func (d *Directory) Groups(user string) ([]string, error) {
+	now := time.Now()
+	d.mu.Lock()
+	if e, ok := d.cache[user]; ok && now.Before(e.expires) {
+		d.mu.Unlock()
+		return e.groups, nil
+	}
+	d.mu.Unlock()
+
+	groups, err := d.lookup(user)
+	if err != nil {
+		return nil, err
+	}
+	ttl := d.CacheTTL
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	d.mu.Lock()
+	if d.cache == nil {
+		d.cache = map[string]cacheEntry{}
+	}
+	d.cache[user] = cacheEntry{groups: groups, expires: now.Add(ttl)}
+	d.mu.Unlock()
+	return groups, nil
+}
+
+// lookup searches the directory for the groups of user
+func (d *Directory) lookup(user string) ([]string, error) {
+	conn, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	groupFilter := d.GroupFilter
+	if groupFilter == "" {
+		groupFilter = DefaultGroupFilter
+	}
+	dn := ""
+	if strings.Contains(groupFilter, "{dn}") {
+		if dn, err = d.userDN(conn, user); err != nil {
+			return nil, err
+		}
+	}
+	attr := d.GroupAttribute
+	if attr == "" {
+		attr = DefaultGroupAttribute
+	}
+	res, err := conn.Search(ldap.NewSearchRequest(d.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		0, d.timeoutSeconds(), false, expand(groupFilter, ldap.EscapeFilter(user), ldap.EscapeFilter(dn)),
+		[]string{attr}, nil))
+	if err != nil {
+		return nil, fmt.Errorf("ldap: searching groups of %s: %w", user, err)
+	}
+	groups := []string{}
+	for _, e := range res.Entries {
+		groups = append(groups, e.GetAttributeValues(attr)...)
+	}
+	return groups, nil
+}
+
+// userDN returns the DN of the user's entry, or "" if there is none
+func (d *Directory) userDN(conn ldap.Client, user string) (string, error) {
+	filter := d.UserFilter
+	if filter == "" {
+		filter = DefaultUserFilter
+	}
+	res, err := conn.Search(ldap.NewSearchRequest(d.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, d.timeoutSeconds(), false, expand(filter, ldap.EscapeFilter(user), ""), []string{"dn"}, nil))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return "", fmt.Errorf("ldap: searching user %s: %w", user, err)
+	}
+	if res == nil || len(res.Entries) != 1 {
+		// Unknown or ambiguous users have no groups by DN
+		return "", nil
+	}
+	return res.Entries[0].DN, nil
+}
+
+// connect dials the directory and binds with the configured credentials
+func (d *Directory) connect() (ldap.Client, error) {
+	dial := d.dial
+	if dial == nil {
+		dial = d.dialURL
+	}
+	conn, err := dial()
+	if err != nil {
+		return nil, fmt.Errorf("ldap: connecting: %w", err)
+	}
+	if d.StartTLS {
+		u, _ := url.Parse(d.URL)
+		if err = conn.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("ldap: starting TLS: %w", err)
+		}
+	}
+	if d.BindDN != "" {
+		password := d.BindPassword
+		if d.BindPasswordEnv != "" {
+			password = os.Getenv(d.BindPasswordEnv)
+		}
+		if err = conn.Bind(d.BindDN, password); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("ldap: binding as %s: %w", d.BindDN, err)
+		}
+	}
+	return conn, nil
+}
+
+// dialURL connects to the configured URL
+func (d *Directory) dialURL() (ldap.Client, error) {
+	conn, err := ldap.DialURL(d.URL, ldap.DialWithDialer(&net.Dialer{Timeout: d.timeout()}))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(d.timeout())
+	return conn, nil
+}
+
+// timeout returns the configured timeout or the default
+func (d *Directory) timeout() time.Duration {
+	if d.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return d.Timeout
+}
+
+// timeoutSeconds returns the timeout as a server-side search time limit
+func (d *Directory) timeoutSeconds() int {
+	return int((d.timeout() + time.Second - 1) / time.Second)
+}
+
+// expand replaces the {user} and {dn} placeholders of a filter
+func expand(filter, user, dn string) string {
+	return strings.NewReplacer("{user}", user, "{dn}", dn).Replace(filter)
+}
@@ -0,0 +1,112 @@
+package ldapgroup
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// fakeConn answers searches from a fixed set of entries; methods not
+// overridden panic through the nil embedded interface
+type fakeConn struct {
+	ldap.Client
+	bound    string
+	filters  []string
+	failBind bool
+}
+
+func (c *fakeConn) Bind(dn, password string) error {
+	if c.failBind || password != "secret" {
+		return fmt.Errorf("invalid credentials")
+	}
+	c.bound = dn
+	return nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	c.filters = append(c.filters, req.Filter)
+	switch req.Filter {
+	case "(uid=ann)":
+		return &ldap.SearchResult{Entries: []*ldap.Entry{ldap.NewEntry("uid=ann,ou=people,dc=example,dc=com", nil)}}, nil
+	case "(|(memberUid=ann)(member=uid=ann,ou=people,dc=example,dc=com))":
+		return &ldap.SearchResult{Entries: []*ldap.Entry{
+			ldap.NewEntry("cn=staff,ou=groups,dc=example,dc=com", map[string][]string{"cn": {"staff"}}),
+			ldap.NewEntry("cn=admins,ou=groups,dc=example,dc=com", map[string][]string{"cn": {"admins"}}),
+		}}, nil
+	}
+	return &ldap.SearchResult{}, nil
+}
+
+func TestGroups(t *testing.T) {
+	dials := 0
+	conn := &fakeConn{}
+	d := &Directory{
+		URL:             "ldap://ldap.example.com",
+		BindDN:          "cn=reader,dc=example,dc=com",
+		BindPasswordEnv: "TEST_LDAP_PASSWORD",
+		BaseDN:          "dc=example,dc=com",
+		CacheTTL:        time.Hour,
+	}
+	d.dial = func() (ldap.Client, error) {
+		dials++
+		return conn, nil
+	}
+	t.Setenv("TEST_LDAP_PASSWORD", "secret")
+
+	groups, err := d.Groups("ann")
+	if err != nil {
+		t.Fatalf("Groups() error: %v", err)
+	}
+	if want := []string{"staff", "admins"}; !reflect.DeepEqual(groups, want) {
+		t.Errorf("Groups() = %v, want %v", groups, want)
+	}
+	if conn.bound != d.BindDN {
+		t.Errorf("bound as %q, want %q", conn.bound, d.BindDN)
+	}
+
+	// Cached lookups do not reconnect
+	if _, err = d.Groups("ann"); err != nil || dials != 1 {
+		t.Errorf("Groups() again: err %v, %d dials, want 1", err, dials)
+	}
+
+	// Filter values are escaped
+	groups, err = d.Groups("x)(uid=*")
+	if err != nil || len(groups) != 0 {
+		t.Errorf("Groups() of unknown user = %v, %v, want none", groups, err)
+	}
+	if got := conn.filters[len(conn.filters)-1]; got != `(|(memberUid=x\29\28uid=\2a)(member=))` {
+		t.Errorf("group filter = %s", got)
+	}
+
+	conn.failBind = true
+	if _, err = d.Groups("bob"); err == nil {
+		t.Errorf("Groups() with a failed bind succeeded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		dir  *Directory
+		ok   bool
+	}{
+		{"Valid", &Directory{URL: "ldaps://ldap.example.com", BaseDN: "dc=example"}, true},
+		{"Custom filter", &Directory{URL: "ldap://ldap", BaseDN: "dc=example", GroupFilter: "(&(objectClass=group)(member={dn}))"}, true},
+		{"HTTP URL", &Directory{URL: "http://ldap.example.com", BaseDN: "dc=example"}, false},
+		{"No base DN", &Directory{URL: "ldap://ldap.example.com"}, false},
+		{"StartTLS with ldaps", &Directory{URL: "ldaps://ldap", BaseDN: "dc=example", StartTLS: true}, false},
+		{"Bad filter", &Directory{URL: "ldap://ldap", BaseDN: "dc=example", GroupFilter: "(member={dn}"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.dir.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Package oembed looks up embed HTML for media URLs from an allowlist of
+// oEmbed providers, caching responses on disk so CGI invocations share them.
+package oembed
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/fetch"
+)
+
+// DefaultCacheTTL is how long a fetched response is reused
+const DefaultCacheTTL = 24 * time.Hour
+
+// Provider is an oEmbed endpoint and the URLs it handles
+type Provider struct {
+	Name     string   `yaml:"name"`
+	Patterns []string `yaml:"patterns"`
+	Endpoint string   `yaml:"endpoint"`
+}
+
+// BuiltinProviders are providers that can be enabled by name
+var BuiltinProviders = []Provider{
+	{
+		Name:     "youtube",
+		Patterns: []string{`^https?://(www\.|m\.)?youtube\.com/(watch|shorts/)`, `^https?://youtu\.be/`},
+		Endpoint: "https://www.youtube.com/oembed",
+	},
+	{
+		Name:     "vimeo",
+		Patterns: []string{`^https?://(www\.|player\.)?vimeo\.com/`},
+		Endpoint: "https://vimeo.com/api/oembed.json",
+	},
+	{
+		Name:     "soundcloud",
+		Patterns: []string{`^https?://(www\.|m\.)?soundcloud\.com/`},
+		Endpoint: "https://soundcloud.com/oembed",
+	},
+}
+
+// Response is the subset of an oEmbed response exposed to templates. HTML
+// is the provider's iframe, rebuilt with known attributes only, or empty if
+// the provider returned other markup or an iframe from another domain.
+type Response struct {
+	Type            string        `json:"type"`
+	Title           string        `json:"title"`
+	AuthorName      string        `json:"author_name"`
+	AuthorURL       string        `json:"author_url"`
+	ProviderName    string        `json:"provider_name"`
+	HTML            template.HTML `json:"html"`
+	URL             string        `json:"url"`
+	ThumbnailURL    string        `json:"thumbnail_url"`
+	ThumbnailWidth  int           `json:"thumbnail_width"`
+	ThumbnailHeight int           `json:"thumbnail_height"`
+	Width           any           `json:"width"`
+	Height          any           `json:"height"`
+}
+
+// Client resolves URLs against its providers
+type Client struct {
+	Providers  []Provider
+	CacheDir   string
+	CacheTTL   time.Duration
+	HTTPClient *http.Client
+}
+
+// Lookup returns the embed data for mediaURL, or an error if no allowed
+// provider handles it
+func (c *Client) Lookup(mediaURL string) (*Response, error) {
+	provider, err := c.match(mediaURL)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := url.Parse(provider.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing endpoint for provider %s: %w", provider.Name, err)
+	}
+	q := endpoint.Query()
+	q.Set("url", mediaURL)
+	q.Set("format", "json")
+	endpoint.RawQuery = q.Encode()
+
+	ttl := c.CacheTTL
+	if ttl == 0 {
+		ttl = DefaultCacheTTL
+	}
+	body, err := fetch.Cached(endpoint.String(), fetch.Options{
+		CacheDir: c.CacheDir,
+		TTL:      ttl,
+		Client:   c.HTTPClient,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching oEmbed data from %s: %w", provider.Name, err)
+	}
+	var resp Response
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decoding oEmbed response from %s: %w", provider.Name, err)
+	}
+	// Embeds may come from the provider's domain or the media URL's
+	resp.HTML = sanitizeHTML(string(resp.HTML), domainOf(provider.Endpoint), domainOf(mediaURL))
+	return &resp, nil
+}
+
+// Validate checks that a provider has a name, an http(s) endpoint and
+// patterns that compile
+func (p *Provider) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("provider without a name")
+	}
+	if u, err := url.Parse(p.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("provider %s: endpoint must be an http(s) URL", p.Name)
+	}
+	if len(p.Patterns) == 0 {
+		return fmt.Errorf("provider %s: no patterns", p.Name)
+	}
+	for _, pattern := range p.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("compiling pattern for provider %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// match returns the first provider with a pattern matching mediaURL
+func (c *Client) match(mediaURL string) (*Provider, error) {
+	for i := range c.Providers {
+		p := &c.Providers[i]
+		for _, pattern := range p.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("compiling pattern for provider %s: %w", p.Name, err)
+			}
+			if re.MatchString(mediaURL) {
+				return p, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no allowed oEmbed provider for %s", mediaURL)
+}
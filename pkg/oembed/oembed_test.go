@@ -0,0 +1,106 @@
+package oembed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_Lookup(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Query().Get("url") != "https://media.example/v/42" || r.URL.Query().Get("format") != "json" {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"type":"video","title":"Demo","html":"<iframe src=\"https://media.example/e/42\"></iframe>","width":640}`))
+	}))
+	defer ts.Close()
+
+	client := &Client{
+		Providers: []Provider{{Name: "test", Patterns: []string{`^https://media\.example/v/`}, Endpoint: ts.URL + "/oembed"}},
+		CacheDir:  filepath.Join(t.TempDir(), "cache"),
+		CacheTTL:  time.Hour,
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Lookup("https://media.example/v/42")
+		if err != nil {
+			t.Fatalf("Lookup() error: %v", err)
+		}
+		if resp.Title != "Demo" || resp.Type != "video" {
+			t.Errorf("Lookup() = %+v", resp)
+		}
+		if !strings.Contains(string(resp.HTML), "<iframe") {
+			t.Errorf("HTML = %s", resp.HTML)
+		}
+	}
+
+	if hits.Load() != 1 {
+		t.Errorf("provider hit %d times, want 1 (second lookup cached)", hits.Load())
+	}
+}
+
+func TestClient_Lookup_UnsafeHTML(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"type":"rich","html":"<script src=\"https://media.example/e.js\"></script>"}`))
+	}))
+	defer ts.Close()
+
+	client := &Client{
+		Providers: []Provider{{Name: "test", Patterns: []string{`^https://media\.example/v/`}, Endpoint: ts.URL + "/oembed"}},
+		CacheDir:  filepath.Join(t.TempDir(), "cache"),
+	}
+	resp, err := client.Lookup("https://media.example/v/42")
+	if err != nil {
+		t.Fatalf("Lookup() error: %v", err)
+	}
+	if resp.HTML != "" {
+		t.Errorf("HTML = %s, want the script dropped", resp.HTML)
+	}
+}
+
+func TestClient_Lookup_NotAllowed(t *testing.T) {
+	client := &Client{Providers: BuiltinProviders[:1], CacheDir: filepath.Join(t.TempDir(), "cache")}
+	_, err := client.Lookup("https://evil.example/video")
+	if err == nil || !strings.Contains(err.Error(), "no allowed oEmbed provider") {
+		t.Errorf("Lookup() error = %v, want not allowed", err)
+	}
+}
+
+func TestClient_Lookup_ProviderError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	client := &Client{
+		Providers: []Provider{{Name: "test", Patterns: []string{`.*`}, Endpoint: ts.URL}},
+		CacheDir:  filepath.Join(t.TempDir(), "cache"),
+	}
+	if _, err := client.Lookup("https://media.example/v/1"); err == nil {
+		t.Error("Lookup() with failing provider should return error")
+	}
+}
+
+func TestBuiltinProviders_Match(t *testing.T) {
+	client := &Client{Providers: BuiltinProviders}
+	tests := map[string]string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ": "youtube",
+		"https://youtu.be/dQw4w9WgXcQ":                "youtube",
+		"https://vimeo.com/76979871":                  "vimeo",
+		"https://soundcloud.com/artist/track":         "soundcloud",
+	}
+	for u, want := range tests {
+		p, err := client.match(u)
+		if err != nil || p.Name != want {
+			t.Errorf("match(%s) = %v, %v; want %s", u, p, err, want)
+		}
+	}
+}
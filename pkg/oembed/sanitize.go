@@ -0,0 +1,97 @@
+package oembed
+
+import (
+	"html/template"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// iframeAttrs are the attributes kept on a provider's iframe
+var iframeAttrs = map[string]bool{
+	"src":             true,
+	"width":           true,
+	"height":          true,
+	"title":           true,
+	"frameborder":     true,
+	"allow":           true,
+	"allowfullscreen": true,
+	"referrerpolicy":  true,
+	"scrolling":       true,
+	"loading":         true,
+}
+
+// sanitizeHTML rebuilds the embed markup of an oEmbed response. Only a
+// single iframe loading an https URL on one of domains is kept, with its
+// layout and permission attributes; any other markup, such as scripts or
+// event handlers, gives "".
+func sanitizeHTML(markup string, domains ...string) template.HTML {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(markup), body)
+	if err != nil {
+		return ""
+	}
+	var frame *html.Node
+	for _, n := range nodes {
+		switch {
+		case n.Type == html.TextNode && strings.TrimSpace(n.Data) == "":
+		case n.Type == html.ElementNode && n.DataAtom == atom.Iframe && frame == nil:
+			frame = n
+		default:
+			return ""
+		}
+	}
+	if frame == nil {
+		return ""
+	}
+	out := &html.Node{Type: html.ElementNode, Data: "iframe", DataAtom: atom.Iframe}
+	hasSrc := false
+	for _, a := range frame.Attr {
+		if a.Namespace != "" || !iframeAttrs[a.Key] {
+			continue
+		}
+		if a.Key == "src" {
+			if !onDomain(a.Val, domains) {
+				return ""
+			}
+			hasSrc = true
+		}
+		out.Attr = append(out.Attr, html.Attribute{Key: a.Key, Val: a.Val})
+	}
+	if !hasSrc {
+		return ""
+	}
+	var b strings.Builder
+	if err = html.Render(&b, out); err != nil {
+		return ""
+	}
+	return template.HTML(b.String())
+}
+
+// onDomain reports whether rawURL is an https URL on one of domains or
+// their subdomains
+func onDomain(rawURL string, domains []string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.User != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range domains {
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// domainOf returns the host of a URL without a leading www., or "" if it
+// cannot be parsed
+func domainOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
@@ -0,0 +1,63 @@
+package oembed
+
+import "testing"
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name   string
+		markup string
+		want   string
+	}{
+		{
+			name:   "iframe",
+			markup: `<iframe width="640" height="360" src="https://www.youtube.com/embed/x?a=1&amp;b=2" frameborder="0" allowfullscreen></iframe>`,
+			want:   `<iframe width="640" height="360" src="https://www.youtube.com/embed/x?a=1&amp;b=2" frameborder="0" allowfullscreen=""></iframe>`,
+		},
+		{
+			name:   "event handlers dropped",
+			markup: `<iframe src="https://player.vimeo.com/video/1" onload="steal()" style="x"></iframe>`,
+			want:   `<iframe src="https://player.vimeo.com/video/1"></iframe>`,
+		},
+		{name: "script", markup: `<iframe src="https://youtube.com/embed/x"></iframe><script>steal()</script>`},
+		{name: "other element", markup: `<div onclick="steal()">video</div>`},
+		{name: "other domain", markup: `<iframe src="https://evil.example/embed/x"></iframe>`},
+		{name: "lookalike domain", markup: `<iframe src="https://notyoutube.com/embed/x"></iframe>`},
+		{name: "javascript URL", markup: `<iframe src="javascript:steal()"></iframe>`},
+		{name: "http URL", markup: `<iframe src="http://youtube.com/embed/x"></iframe>`},
+		{name: "no src", markup: `<iframe srcdoc="<script>steal()</script>"></iframe>`},
+		{name: "two iframes", markup: `<iframe src="https://youtube.com/a"></iframe><iframe src="https://youtube.com/b"></iframe>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeHTML(tt.markup, "youtube.com", "vimeo.com"); string(got) != tt.want {
+				t.Errorf("sanitizeHTML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvider_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		ok       bool
+	}{
+		{"Valid", Provider{Name: "p", Patterns: []string{`^https://v\.example/`}, Endpoint: "https://v.example/oembed"}, true},
+		{"No name", Provider{Patterns: []string{`.*`}, Endpoint: "https://v.example/oembed"}, false},
+		{"Bad pattern", Provider{Name: "p", Patterns: []string{`(`}, Endpoint: "https://v.example/oembed"}, false},
+		{"No patterns", Provider{Name: "p", Endpoint: "https://v.example/oembed"}, false},
+		{"Bad endpoint", Provider{Name: "p", Patterns: []string{`.*`}, Endpoint: "v.example/oembed"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.provider.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+	for _, p := range BuiltinProviders {
+		if err := p.Validate(); err != nil {
+			t.Errorf("built-in provider %s: %v", p.Name, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Package pdf converts rendered HTML pages to PDF documents by running an
+// external renderer such as wkhtmltopdf, WeasyPrint or headless Chromium.
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Defaults for the renderer settings
+const DefaultTimeout = 30 * time.Second
+
+// DefaultCommand reads HTML on stdin and writes PDF to stdout. Local file
+// access is disabled so that HTML in a page cannot embed server files
+// through file:// URLs.
+var DefaultCommand = []string{"wkhtmltopdf", "--quiet", "--disable-local-file-access", "-", "-"}
+
+// Placeholders that make the renderer read and write files instead of
+// stdin and stdout
+const (
+	InputPlaceholder  = "{input}"
+	OutputPlaceholder = "{output}"
+)
+
+// Renderer configures the external HTML-to-PDF command
+type Renderer struct {
+	Command []string      `yaml:"command,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// Validate checks the renderer settings
+func (r *Renderer) Validate() error {
+	if len(r.Command) > 0 && r.Command[0] == "" {
+		return fmt.Errorf("pdf command must name a program")
+	}
+	return nil
+}
+
+// Render converts an HTML document to PDF. If an argument of the command
+// contains {input} or {output}, the HTML is passed in, or the PDF read back
+// from, a temporary file whose path replaces the placeholder; otherwise
+// stdin and stdout are used.
+func (r *Renderer) Render(html []byte) ([]byte, error) {
+	command := r.Command
+	if len(command) == 0 {
+		command = DefaultCommand
+	}
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	dir, err := os.MkdirTemp("", "tmpl.cgi-pdf-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	input := filepath.Join(dir, "input.html")
+	output := filepath.Join(dir, "output.pdf")
+
+	var useInput, useOutput bool
+	args := make([]string, len(command)-1)
+	for i, arg := range command[1:] {
+		useInput = useInput || strings.Contains(arg, InputPlaceholder)
+		useOutput = useOutput || strings.Contains(arg, OutputPlaceholder)
+		arg = strings.ReplaceAll(arg, InputPlaceholder, input)
+		args[i] = strings.ReplaceAll(arg, OutputPlaceholder, output)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command[0], args...)
+	if useInput {
+		if err = os.WriteFile(input, html, 0600); err != nil {
+			return nil, err
+		}
+	} else {
+		cmd.Stdin = bytes.NewReader(html)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("pdf renderer timed out after %v", timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("running pdf renderer: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("running pdf renderer: %w", err)
+	}
+
+	pdf := stdout.Bytes()
+	if useOutput {
+		if pdf, err = os.ReadFile(output); err != nil {
+			return nil, fmt.Errorf("reading pdf renderer output: %w", err)
+		}
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+		return nil, fmt.Errorf("pdf renderer did not produce a PDF document")
+	}
+	return pdf, nil
+}
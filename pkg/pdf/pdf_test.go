@@ -0,0 +1,65 @@
+package pdf
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		renderer Renderer
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "stdin and stdout",
+			renderer: Renderer{Command: []string{"sh", "-c", "printf '%%PDF-1.7 '; cat"}},
+			want:     "%PDF-1.7 <p>hi</p>",
+		},
+		{
+			name:     "input and output files",
+			renderer: Renderer{Command: []string{"sh", "-c", `{ printf '%%PDF-1.7 '; cat "$0"; } > "$1"`, "{input}", "{output}"}},
+			want:     "%PDF-1.7 <p>hi</p>",
+		},
+		{
+			name:     "placeholder inside an argument",
+			renderer: Renderer{Command: []string{"sh", "-c", `printf '%%PDF-1.7 %s' "${0#--print-to-pdf=}" > "${0#--print-to-pdf=}"`, "--print-to-pdf={output}"}},
+			want:     "%PDF-1.7 ",
+		},
+		{
+			name:     "not a PDF",
+			renderer: Renderer{Command: []string{"cat"}},
+			wantErr:  "did not produce a PDF",
+		},
+		{
+			name:     "renderer fails",
+			renderer: Renderer{Command: []string{"sh", "-c", "echo broken >&2; exit 3"}},
+			wantErr:  "broken",
+		},
+		{
+			name:     "timeout",
+			renderer: Renderer{Command: []string{"sleep", "5"}, Timeout: 50 * time.Millisecond},
+			wantErr:  "timed out",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.renderer.Render([]byte("<p>hi</p>"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Render() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Render() error: %v", err)
+			}
+			if !strings.HasPrefix(string(got), tt.want) {
+				t.Errorf("Render() = %q, want prefix %q", got, tt.want)
+			}
+		})
+	}
+}
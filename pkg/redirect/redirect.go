@@ -0,0 +1,25 @@
+// Package redirect checks the return targets that endpoints such as the
+// theme switcher redirect visitors to, so that they cannot be used to send
+// visitors to other sites.
+package redirect
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// Local returns p if it is a path on this site, and / otherwise. Paths
+// with control characters are refused, since browsers drop tabs and
+// newlines from URLs and would read "/\t/evil.com" as "//evil.com".
+func Local(p string) string {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.HasPrefix(p, `/\`) ||
+		strings.ContainsFunc(p, unicode.IsControl) {
+		return "/"
+	}
+	u, err := url.Parse(p)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil {
+		return "/"
+	}
+	return p
+}
@@ -0,0 +1,26 @@
+package redirect
+
+import "testing"
+
+func TestLocal(t *testing.T) {
+	tests := map[string]string{
+		"/blog/post?x=1#top":       "/blog/post?x=1#top",
+		"/":                        "/",
+		"":                         "/",
+		"blog":                     "/",
+		"//evil.example.com/":      "/",
+		`/\evil.example.com/`:      "/",
+		"https://evil.example.com": "/",
+		"/\t/evil.example.com":     "/",
+		"/\n/evil.example.com":     "/",
+		"/\r\n/evil.example.com":   "/",
+		"/%09/evil.example.com":    "/%09/evil.example.com",
+		"/a\x00b":                  "/",
+		"/\u0085/evil.example.com": "/",
+	}
+	for p, want := range tests {
+		if got := Local(p); got != want {
+			t.Errorf("Local(%q) = %q, want %q", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package s3 reads objects from Amazon S3 and compatible object stores,
+// such as MinIO and Cloudflare R2, by turning s3:// URLs into HTTPS URLs and
+// signing requests with AWS Signature Version 4.
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultRegion is used when no region is configured
+const DefaultRegion = "us-east-1"
+
+// emptyHash is the SHA-256 of an empty request body
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Client holds the location and credentials of an object store. Requests
+// are not signed without an access key, for public buckets.
+type Client struct {
+	Region string
+	// Endpoint is the base URL of an S3-compatible store, addressed with
+	// path-style URLs; empty for AWS
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// FromEnv returns a client with credentials from the standard AWS
+// environment variables. The region and endpoint default to those of the
+// environment as well.
+func FromEnv(region, endpoint string) *Client {
+	if region == "" {
+		region = firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = DefaultRegion
+	}
+	if endpoint == "" {
+		endpoint = firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL")
+	}
+	return &Client{
+		Region:          region,
+		Endpoint:        endpoint,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// firstEnv returns the first of the environment variables that is set
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// ObjectURL returns the HTTPS URL of an s3://bucket/key URL
+func (c *Client) ObjectURL(s3URL string) (string, error) {
+	rest, ok := strings.CutPrefix(s3URL, "s3://")
+	if !ok {
+		return "", fmt.Errorf("%s is not an s3:// URL", s3URL)
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" || key == "" {
+		return "", fmt.Errorf("%s does not name a bucket and key", s3URL)
+	}
+	path := "/" + escapePath(key)
+	switch {
+	case c.Endpoint != "":
+		return strings.TrimSuffix(c.Endpoint, "/") + "/" + bucket + path, nil
+	case strings.Contains(bucket, "."):
+		// Dotted bucket names do not match the wildcard certificate
+		return "https://s3." + c.Region + ".amazonaws.com/" + bucket + path, nil
+	}
+	return "https://" + bucket + ".s3." + c.Region + ".amazonaws.com" + path, nil
+}
+
+// Sign adds a Signature Version 4 authorization to a GET or HEAD request
+// made at time t, covering the host and every header already set
+func (c *Client) Sign(req *http.Request, t time.Time) {
+	if c.AccessKeyID == "" {
+		return
+	}
+	amzDate := t.UTC().Format("20060102T150405Z")
+	scope := amzDate[:8] + "/" + c.Region + "/s3/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyHash)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	canonical.WriteString(req.Method + "\n" + req.URL.EscapedPath() + "\n" + canonicalQuery(req.URL.Query()) + "\n")
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	canonical.WriteString("\n" + signed + "\n" + emptyHash)
+
+	sum := sha256.Sum256([]byte(canonical.String()))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+	key := []byte("AWS4" + c.SecretAccessKey)
+	for _, part := range []string{amzDate[:8], c.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKeyID, scope, signed, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters sorted by name and value
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, escape(name, true)+"="+escape(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// escapePath encodes an object key, keeping its slashes
+func escapePath(key string) string {
+	return escape(key, false)
+}
+
+// escape percent-encodes everything but unreserved characters, and
+// slashes unless escapeSlash is set, the way Signature Version 4 requires
+func escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
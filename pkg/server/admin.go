@@ -0,0 +1,91 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// adminHandler serves the JSON admin API used by orchestration tooling:
+//
+//	GET  /health  200 while serving, 503 once draining; reports the config version
+//	GET  /stats   request counters
+//	POST /reload  re-read and validate the config file, then switch to it
+//	POST /drain   report unhealthy so load balancers stop routing here
+//	POST /purge   empty the template and response caches
+//	POST /switch  point the template_dir symlink at ?dir= once it validates, then reload
+//	GET  /cache   explain how the response cache treats the page at ?url=
+func (s *CGIServer) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		status, code := "ok", http.StatusOK
+		if s.draining.Load() {
+			status, code = "draining", http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, map[string]any{"status": status, "config_version": s.snapshot().Version})
+	})
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.statsSnapshot())
+	})
+	mux.HandleFunc("POST /reload", func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Reload(); err != nil {
+			log.Printf("reloading config: %v", err)
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"status": "error", "error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "config_version": s.snapshot().Version})
+	})
+	mux.HandleFunc("POST /switch", func(w http.ResponseWriter, r *http.Request) {
+		dir := r.URL.Query().Get("dir")
+		if dir == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"status": "error", "error": "dir is required"})
+			return
+		}
+		if err := s.SwitchTemplates(dir); err != nil {
+			log.Printf("switching templates: %v", err)
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"status": "error", "error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "config_version": s.snapshot().Version})
+	})
+	mux.HandleFunc("POST /drain", func(w http.ResponseWriter, r *http.Request) {
+		s.draining.Store(true)
+		writeJSON(w, http.StatusOK, map[string]any{"status": "draining", "in_flight": s.stats.inFlight.Load()})
+	})
+
+	mux.HandleFunc("POST /purge", func(w http.ResponseWriter, r *http.Request) {
+		n := s.cache.purge() + s.pages.entries.purge()
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "purged": n})
+	})
+	mux.HandleFunc("GET /cache", func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.snapshot()
+		s.serveCacheDebug(w, r, &cfg)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Read the token per request, so that a reload can change or revoke it
+		if token := s.snapshot().Admin.Token; token != "" {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				writeJSON(w, http.StatusUnauthorized, map[string]any{"status": "error", "error": "unauthorized"})
+				return
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("encoding response: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(body, '\n'))
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/config"
+)
+
+// adminRequest sends a request to the admin API and decodes the response
+func adminRequest(t *testing.T, h http.Handler, method, path, token string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s %s: decoding response %q: %v", method, path, w.Body.String(), err)
+	}
+	return w.Code, body
+}
+
+func TestAdmin_HealthAndDrain(t *testing.T) {
+	server, _ := New(&config.Config{Admin: config.Admin{Listen: "127.0.0.1:0", Token: "t0ken"}})
+	h := server.adminHandler()
+
+	if code, _ := adminRequest(t, h, "GET", "/health", ""); code != http.StatusUnauthorized {
+		t.Errorf("health without token status = %d, want 401", code)
+	}
+	if code, body := adminRequest(t, h, "GET", "/health", "t0ken"); code != http.StatusOK || body["status"] != "ok" {
+		t.Errorf("health = %d %v, want 200 ok", code, body)
+	}
+	if code, _ := adminRequest(t, h, "POST", "/drain", "t0ken"); code != http.StatusOK {
+		t.Errorf("drain status = %d, want 200", code)
+	}
+	if code, body := adminRequest(t, h, "GET", "/health", "t0ken"); code != http.StatusServiceUnavailable || body["status"] != "draining" {
+		t.Errorf("health after drain = %d %v, want 503 draining", code, body)
+	}
+
+	// The token must be sent as a bearer token
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("Authorization", "t0ken")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("health with a bare token status = %d, want 401", w.Code)
+	}
+}
+
+func TestAdmin_Stats(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "page.html"), []byte(`Page`), 0644); err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+	server, _ := New(&config.Config{
+		ConfigFilePath:  filepath.Join(tempDir, "config.yaml"),
+		DefaultTemplate: "page.html",
+		Templates:       []config.Template{{Pattern: "^/draft", Template: "page.html", Draft: true}},
+	})
+	for _, path := range []string{"/", "/a", "/draft"} {
+		req := httptest.NewRequest("GET", path, nil)
+		server.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	_, body := adminRequest(t, server.adminHandler(), "GET", "/stats", "")
+	if body["requests"] != float64(3) || body["not_found"] != float64(1) || body["in_flight"] != float64(0) {
+		t.Errorf("stats = %v", body)
+	}
+}
+
+func TestAdmin_Reload(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+	for name, content := range map[string]string{
+		"old.html":    `Old`,
+		"new.html":    `New`,
+		"config.yaml": `default_template: "old.html"`,
+	} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	cfg, err := config.ParseConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	server, _ := New(cfg)
+	h := server.adminHandler()
+
+	render := func() string {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return w.Body.String()
+	}
+
+	if err = os.WriteFile(configPath, []byte(`default_template: "missing.html"`), 0644); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+	if code, _ := adminRequest(t, h, "POST", "/reload", ""); code != http.StatusUnprocessableEntity {
+		t.Errorf("reload of invalid config status = %d, want 422", code)
+	}
+	if got := render(); got != "Old" {
+		t.Errorf("after failed reload rendered %q, want Old", got)
+	}
+
+	if err = os.WriteFile(configPath, []byte(`default_template: "new.html"`), 0644); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+	if code, body := adminRequest(t, h, "POST", "/reload", ""); code != http.StatusOK {
+		t.Errorf("reload status = %d %v, want 200", code, body)
+	}
+	if got := render(); got != "New" {
+		t.Errorf("after reload rendered %q, want New", got)
+	}
+
+	// A reload that sets the token takes effect at once
+	if err = os.WriteFile(configPath, []byte("default_template: \"new.html\"\nadmin: {token: t0ken}"), 0644); err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+	if code, body := adminRequest(t, h, "POST", "/reload", ""); code != http.StatusOK {
+		t.Errorf("reload status = %d %v, want 200", code, body)
+	}
+	if code, _ := adminRequest(t, h, "GET", "/health", ""); code != http.StatusUnauthorized {
+		t.Errorf("health without the new token status = %d, want 401", code)
+	}
+	if code, _ := adminRequest(t, h, "GET", "/health", "t0ken"); code != http.StatusOK {
+		t.Errorf("health with the new token status = %d, want 200", code)
+	}
+}
+
+func TestAdmin_Switch(t *testing.T) {
+	tempDir := t.TempDir()
+	for name, content := range map[string]string{
+		"releases/v1/page.html":   `One`,
+		"releases/v2/page.html":   `Two`,
+		"releases/bad/other.html": `Bad`,
+		"config.yaml":             "template_dir: current\ndefault_template: page.html",
+	} {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("MkdirAll() error: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(tempDir, "releases", "v1"), filepath.Join(tempDir, "current")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	cfg, err := config.ParseConfigFile(filepath.Join(tempDir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	server, _ := New(cfg)
+	h := server.adminHandler()
+
+	render := func() string {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return w.Body.String()
+	}
+	if got := render(); got != "One" {
+		t.Fatalf("rendered %q, want One", got)
+	}
+	before := server.snapshot()
+
+	if code, _ := adminRequest(t, h, "POST", "/switch?dir=releases/bad", ""); code != http.StatusUnprocessableEntity {
+		t.Errorf("switch to an invalid release status = %d, want 422", code)
+	}
+	if target, _ := filepath.EvalSymlinks(filepath.Join(tempDir, "current")); filepath.Base(target) != "v1" {
+		t.Errorf("after failed switch current points at %s, want v1", target)
+	}
+
+	if code, body := adminRequest(t, h, "POST", "/switch?dir=releases/v2", ""); code != http.StatusOK {
+		t.Errorf("switch status = %d %v, want 200", code, body)
+	}
+	if got := render(); got != "Two" {
+		t.Errorf("after switch rendered %q, want Two", got)
+	}
+
+	// A config loaded before the switch keeps rendering its own release
+	tmpl, err := before.LoadTemplate("page.html")
+	if err != nil {
+		t.Fatalf("LoadTemplate() error: %v", err)
+	}
+	var buf strings.Builder
+	if err = tmpl.Execute(&buf, nil); err != nil || buf.String() != "One" {
+		t.Errorf("config loaded before the switch rendered %q, %v, want One", buf.String(), err)
+	}
+}
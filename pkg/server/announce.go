@@ -0,0 +1,51 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// announceClient posts startup announcements
+var announceClient = &http.Client{Timeout: 10 * time.Second}
+
+// announce posts the standalone server's URL and a summary of its
+// configuration to the configured webhook. The payload carries the message
+// as "text" (Slack, Mattermost, Matrix hookshot), "content" (Discord) and
+// "body", alongside the structured fields.
+func (s *CGIServer) announce(listenURL string) error {
+	cfg := s.snapshot()
+	a := cfg.Announce
+	if a.URL != "" {
+		listenURL = a.URL
+	}
+	host, _ := os.Hostname()
+	text := fmt.Sprintf("tmpl.cgi now serving %s on %s (config %s, %d routes, default template %s)",
+		listenURL, host, cfg.ConfigFilePath, len(cfg.Templates), cfg.DefaultTemplate)
+	payload := map[string]any{
+		"text":             text,
+		"content":          text,
+		"body":             text,
+		"url":              listenURL,
+		"host":             host,
+		"config":           cfg.ConfigFilePath,
+		"routes":           len(cfg.Templates),
+		"default_template": cfg.DefaultTemplate,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := announceClient.Post(a.Webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("posting announcement: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("posting announcement: unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/config"
+)
+
+func TestAnnounce(t *testing.T) {
+	var got map[string]any
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer hook.Close()
+
+	server, err := New(&config.Config{
+		ConfigFilePath:  "/srv/site/config.yaml",
+		DefaultTemplate: "default.html",
+		Templates:       []config.Template{{Pattern: "^/a", Template: "a.html"}},
+		Announce:        config.Announce{Webhook: hook.URL, URL: "http://staging.example.com:8080/"},
+	})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err = server.announce("http://localhost:8080/"); err != nil {
+		t.Fatalf("announce() error: %v", err)
+	}
+
+	if got["url"] != "http://staging.example.com:8080/" {
+		t.Errorf("url = %v", got["url"])
+	}
+	text, _ := got["text"].(string)
+	for _, want := range []string{"http://staging.example.com:8080/", "/srv/site/config.yaml", "1 routes", "default.html"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text %q does not contain %q", text, want)
+		}
+	}
+}
+
+func TestAnnounce_Failure(t *testing.T) {
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer hook.Close()
+
+	server, _ := New(&config.Config{Announce: config.Announce{Webhook: hook.URL}})
+	if err := server.announce("http://localhost:8080/"); err == nil {
+		t.Error("announce() should fail on a non-2xx response")
+	}
+}
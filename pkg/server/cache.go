@@ -0,0 +1,167 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/action"
+	"gopkg.mhn.org/tmpl.cgi/pkg/config"
+	"gopkg.mhn.org/tmpl.cgi/pkg/debug"
+)
+
+// templateCache keeps parsed templates between requests in long-lived
+// modes. Concurrent misses for the same key share a single parse.
+type templateCache struct {
+	entries lru[config.Executable]
+	group   singleflight.Group
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// get returns the cached template for key, calling load on a miss. Errors
+// are returned to every waiting caller but not cached.
+func (c *templateCache) get(key string, load func() (config.Executable, error)) (config.Executable, error) {
+	if tmpl, ok := c.entries.get(key); ok {
+		c.hits.Add(1)
+		return tmpl, nil
+	}
+
+	c.misses.Add(1)
+	v, err, _ := c.group.Do(key, func() (any, error) {
+		tmpl, err := load()
+		if err != nil {
+			return nil, err
+		}
+		c.entries.add(key, tmpl, templateSize(tmpl))
+		return tmpl, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(config.Executable), nil
+}
+
+// purge empties the cache and returns the number of entries removed
+func (c *templateCache) purge() int {
+	return c.entries.purge()
+}
+
+// templateSize estimates the memory held by a parsed template from the
+// length of its source; parse trees take a few times the size of the text
+func templateSize(tmpl config.Executable) int64 {
+	size := int64(1 << 10)
+	for _, tree := range tmpl.Trees() {
+		if tree != nil && tree.Root != nil {
+			size += 4 * int64(len(tree.Root.String()))
+		}
+	}
+	return size
+}
+
+// cachedResponse is a rendered page kept by the response cache, with a
+// snapshot of the data it was rendered from
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	data    []byte
+	created time.Time
+	expires time.Time
+}
+
+// responseKey holds the components of a response cache key, which the
+// cache debug report shows
+type responseKey struct {
+	ConfigVersion string `json:"config_version"`
+	Host          string `json:"host"`
+	RequestURI    string `json:"request_uri"`
+	Template      string `json:"template"`
+	Theme         string `json:"theme,omitempty"`
+	ClientHints   string `json:"client_hints,omitempty"`
+	Consent       string `json:"consent,omitempty"`
+	Fragment      string `json:"fragment,omitempty"`
+}
+
+// String returns the key the response is cached under
+func (k *responseKey) String() string {
+	return strings.Join([]string{k.ConfigVersion, k.Host, k.RequestURI, k.Template,
+		k.Theme, k.ClientHints, k.Consent, k.Fragment}, "\x00")
+}
+
+// responseCacheKey returns the response cache key for a request, or nil and
+// the reason the response is not cacheable
+func responseCacheKey(cfg *config.Config, r *http.Request, requestURI string, route *config.Template,
+	templateName string, result *action.Result) (*responseKey, string) {
+	switch {
+	case cfg.Cache.Responses <= 0:
+		return nil, "response cache disabled"
+	case r.Method != http.MethodGet:
+		return nil, "not a GET request"
+	case result != nil:
+		return nil, "form action"
+	case route != nil && route.NoCache:
+		return nil, "route has no_cache"
+	case route != nil && route.RequiresAuth():
+		return nil, "route requires authorization"
+	case cfg.DataHasRoles(route):
+		return nil, "data depends on user roles"
+	case cfg.PreviewAllowed(r):
+		return nil, "preview request"
+	case cfg.RequestHook.Enabled():
+		return nil, "request hook"
+	}
+	// REMOTE_USER is fixed for the life of the standalone server, the only
+	// place responses are cached, so pages that depend on the user are
+	// kept out of the cache above rather than keyed by it
+	return &responseKey{
+		ConfigVersion: cfg.Version,
+		Host:          r.Host,
+		RequestURI:    requestURI,
+		Template:      templateName,
+		Theme:         cfg.Theme.Resolve(r).Name,
+		ClientHints:   cfg.ClientHints.Key(r),
+		Consent:       cfg.Consent.Resolve(r).Key(),
+		Fragment:      fragmentFor(route, r),
+	}, ""
+}
+
+// dataSnapshot records template data for comparison with later data, with
+// secrets redacted since the cache report shows it
+func dataSnapshot(data any) []byte {
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		b = []byte(fmt.Sprintf("%#v", data))
+	}
+	return []byte(debug.Redact(string(b)))
+}
+
+// responseCache keeps rendered pages for a configured time
+type responseCache struct {
+	entries lru[*cachedResponse]
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// get returns an unexpired response for key
+func (c *responseCache) get(key string, now time.Time) (*cachedResponse, bool) {
+	resp, ok := c.entries.get(key)
+	if !ok || !now.Before(resp.expires) {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return resp, true
+}
+
+// add stores a response; its size is the body plus a fixed overhead
+func (c *responseCache) add(key string, resp *cachedResponse) {
+	c.entries.add(key, resp, int64(len(resp.body)+len(resp.data))+512)
+}
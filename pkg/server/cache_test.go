@@ -0,0 +1,233 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/config"
+)
+
+func TestTemplateCache_Singleflight(t *testing.T) {
+	var c templateCache
+	var loads atomic.Int64
+	cfg := &config.Config{Templates: []config.Template{{Content: "ok"}}}
+	load := func() (config.Executable, error) {
+		loads.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return cfg.LoadTemplate(cfg.Templates[0].TemplateName())
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.get("page.html", load); err != nil {
+				t.Errorf("get() error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("template parsed %d times, want 1", n)
+	}
+	if _, err := c.get("page.html", load); err != nil || loads.Load() != 1 {
+		t.Errorf("cached get() error = %v, loads = %d", err, loads.Load())
+	}
+	if c.hits.Load() < 1 {
+		t.Errorf("hits = %d, want at least 1", c.hits.Load())
+	}
+
+	if n := c.purge(); n != 1 {
+		t.Errorf("purge() = %d, want 1", n)
+	}
+	if _, err := c.get("page.html", load); err != nil || loads.Load() != 2 {
+		t.Errorf("get() after purge error = %v, loads = %d, want 2", err, loads.Load())
+	}
+}
+
+func TestTemplateCache_ErrorsNotCached(t *testing.T) {
+	var c templateCache
+	calls := 0
+	load := func() (config.Executable, error) {
+		calls++
+		return nil, fmt.Errorf("parse error")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.get("bad.html", load); err == nil {
+			t.Error("get() should return the load error")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("load called %d times, want 2", calls)
+	}
+}
+
+func TestServeHTTP_TemplateCache(t *testing.T) {
+	tempDir := t.TempDir()
+	page := filepath.Join(tempDir, "page.html")
+	if err := os.WriteFile(page, []byte(`v1`), 0644); err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+	server, _ := New(&config.Config{
+		ConfigFilePath:  filepath.Join(tempDir, "config.yaml"),
+		DefaultTemplate: "page.html",
+		Cache:           config.Cache{Templates: true},
+	})
+	render := func() string {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return w.Body.String()
+	}
+
+	if got := render(); got != "v1" {
+		t.Fatalf("rendered %q, want v1", got)
+	}
+	if err := os.WriteFile(page, []byte(`v2`), 0644); err != nil {
+		t.Fatalf("Failed to update test template: %v", err)
+	}
+	if got := render(); got != "v1" {
+		t.Errorf("rendered %q from cache, want v1", got)
+	}
+
+	code, body := adminRequest(t, server.adminHandler(), "POST", "/purge", "")
+	if code != http.StatusOK || body["purged"] != float64(1) {
+		t.Errorf("purge = %d %v", code, body)
+	}
+	if got := render(); got != "v2" {
+		t.Errorf("rendered %q after purge, want v2", got)
+	}
+}
+
+func TestServeHTTP_ResponseCache(t *testing.T) {
+	tempDir := t.TempDir()
+	page := filepath.Join(tempDir, "page.html")
+	if err := os.WriteFile(page, []byte(`v1`), 0644); err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+	server, _ := New(&config.Config{
+		ConfigFilePath:  filepath.Join(tempDir, "config.yaml"),
+		DefaultTemplate: "page.html",
+		PreviewToken:    "s3cret",
+		Templates:       []config.Template{{Pattern: "^/live", Template: "page.html", NoCache: true}},
+		Cache:           config.Cache{Responses: time.Minute},
+	})
+	render := func(path string) string {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+		req.RequestURI = path
+		server.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	for _, path := range []string{"/", "/live"} {
+		if got := render(path); got != "v1" {
+			t.Fatalf("%s rendered %q, want v1", path, got)
+		}
+	}
+	if err := os.WriteFile(page, []byte(`v2`), 0644); err != nil {
+		t.Fatalf("Failed to update test template: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", "v1"},
+		{"/live", "v2"},
+		{"/?preview_token=s3cret", "v2"},
+		{"/other", "v2"},
+	}
+	for _, tt := range tests {
+		if got := render(tt.path); got != tt.want {
+			t.Errorf("%s rendered %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	st := server.statsSnapshot().ResponseCache
+	if st.Hits != 1 || st.Entries != 2 || st.Limit != int64(config.DefaultResponseMemory) {
+		t.Errorf("response cache stats = %+v", st)
+	}
+}
+
+func TestServeHTTP_ResponseCacheAuth(t *testing.T) {
+	tempDir := t.TempDir()
+	page := filepath.Join(tempDir, "page.html")
+	if err := os.WriteFile(page, []byte(`v1`), 0644); err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+	t.Setenv("REMOTE_USER", "ann")
+	server, _ := New(&config.Config{
+		ConfigFilePath:  filepath.Join(tempDir, "config.yaml"),
+		DefaultTemplate: "page.html",
+		Templates:       []config.Template{{Pattern: "^/members", Template: "page.html", RequireUser: []string{config.AnyUser}}},
+		Cache:           config.Cache{Responses: time.Minute},
+	})
+	render := func(path string) string {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+		req.RequestURI = path
+		server.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	// Pages that depend on the user are never cached
+	for _, path := range []string{"/", "/members"} {
+		render(path)
+	}
+	if err := os.WriteFile(page, []byte(`v2`), 0644); err != nil {
+		t.Fatalf("Failed to update test template: %v", err)
+	}
+	if got := render("/members"); got != "v2" {
+		t.Errorf("/members rendered %q, want v2", got)
+	}
+	if got := render("/"); got != "v1" {
+		t.Errorf("/ rendered %q, want v1 from the cache", got)
+	}
+	if st := server.statsSnapshot().ResponseCache; st.Hits != 1 || st.Entries != 1 {
+		t.Errorf("response cache stats = %+v, want 1 hit and 1 entry", st)
+	}
+}
+
+func TestServeHTTP_RequestHook(t *testing.T) {
+	var calls atomic.Int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		_, _ = fmt.Fprintf(w, `{"visitor": {"visits": %d}}`, n)
+	}))
+	defer hook.Close()
+
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "page.html"), []byte(`{{.Data.site}} {{.Data.visitor.visits}}`), 0644); err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+	server, _ := New(&config.Config{
+		ConfigFilePath:  filepath.Join(tempDir, "config.yaml"),
+		DefaultTemplate: "page.html",
+		Data:            map[string]any{"site": "Example"},
+		RequestHook:     config.RequestHook{URL: hook.URL},
+		Cache:           config.Cache{Responses: time.Minute},
+	})
+
+	// Hook data is per request, so responses are never cached
+	for _, want := range []string{"Example 1", "Example 2"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.RequestURI = "/"
+		server.ServeHTTP(w, req)
+		if got := w.Body.String(); got != want {
+			t.Errorf("rendered %q, want %q", got, want)
+		}
+	}
+	if st := server.statsSnapshot().ResponseCache; st.Hits != 0 || st.Entries != 0 {
+		t.Errorf("response cache stats = %+v, want nothing cached", st)
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/config"
+)
+
+// cacheReport explains how the response cache treats a URL
+type cacheReport struct {
+	URL       string       `json:"url"`
+	Template  string       `json:"template"`
+	Cacheable bool         `json:"cacheable"`
+	Reason    string       `json:"reason,omitempty"`
+	Key       *responseKey `json:"key,omitempty"`
+	Hit       bool         `json:"hit"`
+	Age       string       `json:"age,omitempty"`
+	ExpiresIn string       `json:"expires_in,omitempty"`
+	DataDiff  []string     `json:"data_diff,omitempty"`
+}
+
+// serveCacheDebug reports, for the page given by the url query parameter,
+// the components of its response cache key, whether a request would be
+// served from the cache, and how the data the page was rendered with
+// differs from the data it would get now. It is served by the admin API,
+// since the data can include values that visitors do not see.
+func (s *CGIServer) serveCacheDebug(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
+	target := r.URL.Query().Get("url")
+	if !strings.HasPrefix(target, "/") {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "url must be a path such as /blog/post"})
+		return
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, target, nil)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	// The theme, consent, client hints and fragment parts of the key come
+	// from cookies and headers, so the report uses those of this request.
+	// The admin API listens on its own address, so the host of the page is
+	// given separately.
+	req.Header = r.Header.Clone()
+	req.Host = r.URL.Query().Get("host")
+	if req.Host == "" {
+		req.Host = r.Host
+	}
+	req.RequestURI = target
+
+	route, err := cfg.MatchRequest(req, cfg.StripBasePath(target))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	now := cfg.Now()
+	templateName := cfg.DefaultTemplate
+	if route != nil {
+		templateName = route.TemplateName()
+		if !route.Published(now) && route.TeaserTemplate != "" {
+			templateName = route.TeaserTemplate
+		}
+	}
+
+	report := cacheReport{URL: target, Template: templateName}
+	key, reason := responseCacheKey(cfg, req, target, route, templateName, nil)
+	if key == nil {
+		report.Reason = reason
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+	report.Cacheable = true
+	report.Key = key
+	cached, ok := s.pages.entries.peek(key.String())
+	if !ok {
+		report.Reason = "not in cache"
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+	report.Age = now.Sub(cached.created).Round(time.Second).String()
+	if now.Before(cached.expires) {
+		report.Hit = true
+		report.ExpiresIn = cached.expires.Sub(now).Round(time.Second).String()
+	} else {
+		report.Reason = "cached copy has expired"
+	}
+	current, err := cfg.DataFor(route, config.RemoteUser())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return
+	}
+	report.DataDiff = lineDiff(string(cached.data), string(dataSnapshot(current)))
+	writeJSON(w, http.StatusOK, report)
+}
+
+// lineDiff compares two texts line by line and returns the lines that
+// differ, prefixed with "-" (only in a) or "+" (only in b), or nil if the
+// texts are equal
+func lineDiff(a, b string) []string {
+	if a == b {
+		return nil
+	}
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of x[i:], y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var diff []string
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, "-"+x[i])
+			i++
+		default:
+			diff = append(diff, "+"+y[j])
+			j++
+		}
+	}
+	return diff
+}
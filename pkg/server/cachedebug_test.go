@@ -0,0 +1,142 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/clienthints"
+	"gopkg.mhn.org/tmpl.cgi/pkg/config"
+)
+
+func TestServeCacheDebug(t *testing.T) {
+	t.Setenv("TMPL_CGI_DEBUG", "1")
+	t.Setenv(config.EnvironmentVar, "prod")
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "page.html"), []byte(`{{.Data.title}}`), 0644); err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+	server, _ := New(&config.Config{
+		ConfigFilePath:  filepath.Join(tempDir, "config.yaml"),
+		DefaultTemplate: "page.html",
+		Templates:       []config.Template{{Pattern: "^/live", Template: "page.html", NoCache: true}},
+		Data: map[string]any{"title": "Old", "author": "Ann",
+			"staging": map[string]any{"_environments": []any{"staging"}, "note": "internal"}},
+		Cache: config.Cache{Responses: time.Minute},
+	})
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+		req.RequestURI = path
+		server.ServeHTTP(w, req)
+		return w
+	}
+	admin := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.adminHandler().ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:9090"+path, nil))
+		return w
+	}
+	report := func(path string) cacheReport {
+		var rep cacheReport
+		w := admin("/cache?host=example.com&url=" + path)
+		if err := json.Unmarshal(w.Body.Bytes(), &rep); err != nil {
+			t.Fatalf("decoding report %q: %v", w.Body.String(), err)
+		}
+		return rep
+	}
+
+	if rep := report("/post"); !rep.Cacheable || rep.Hit || rep.Reason != "not in cache" {
+		t.Errorf("report before caching = %+v", rep)
+	}
+
+	get("/post")
+	// Change the data behind the cache's back
+	server.mu.Lock()
+	server.config.Data = map[string]any{"title": "New", "author": "Ann",
+		"staging": map[string]any{"_environments": []any{"staging"}, "note": "changed"}}
+	server.mu.Unlock()
+
+	rep := report("/post")
+	if !rep.Hit || rep.Key.RequestURI != "/post" || rep.Key.Template != "page.html" {
+		t.Errorf("report = %+v", rep)
+	}
+	// Data limited to other environments is neither stored nor compared
+	wantDiff := []string{`-  "title": "Old"`, `+  "title": "New"`}
+	if !reflect.DeepEqual(rep.DataDiff, wantDiff) {
+		t.Errorf("DataDiff = %q, want %q", rep.DataDiff, wantDiff)
+	}
+	if got := get("/post").Body.String(); got != "Old" {
+		t.Errorf("cached page = %q, want Old", got)
+	}
+
+	if rep := report("/live"); rep.Cacheable || rep.Reason != "route has no_cache" {
+		t.Errorf("report for no_cache route = %+v", rep)
+	}
+	if w := admin("/cache?url=post"); w.Code != 400 {
+		t.Errorf("report for relative url status = %d, want 400", w.Code)
+	}
+	// Visitors get a page, not the report, even in debug mode
+	if got := get("/_debug/cache?url=/post").Body.String(); got != "New" {
+		t.Errorf("public /_debug/cache = %q, want the default page", got)
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []string
+	}{
+		{"a\nb\nc", "a\nb\nc", nil},
+		{"a\nb\nc", "a\nc", []string{"-b"}},
+		{"a\nc", "a\nb\nc", []string{"+b"}},
+		{"a\nb", "a\nx", []string{"-b", "+x"}},
+	}
+	for _, tt := range tests {
+		if got := lineDiff(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lineDiff(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestServeCacheDebug_Headers(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "page.html"), []byte(`{{block "main" .}}page{{end}}`), 0644); err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+	server, _ := New(&config.Config{
+		ConfigFilePath:  filepath.Join(tempDir, "config.yaml"),
+		DefaultTemplate: "page.html",
+		Templates:       []config.Template{{Pattern: "^/list", Template: "page.html", Fragment: "main"}},
+		ClientHints:     clienthints.Settings{Enabled: true},
+		Cache:           config.Cache{Responses: time.Minute},
+	})
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+		req.RequestURI = path
+		req.Header.Set("HX-Request", "true")
+		req.Header.Set("Save-Data", "on")
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	// The report keys the page by the headers of the report request, so it
+	// finds the fragment cached for the same headers
+	get("/list")
+	var rep cacheReport
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://localhost:9090/cache?host=example.com&url=/list", nil)
+	req.Header.Set("HX-Request", "true")
+	req.Header.Set("Save-Data", "on")
+	server.adminHandler().ServeHTTP(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), &rep); err != nil {
+		t.Fatalf("decoding report %q: %v", w.Body.String(), err)
+	}
+	if !rep.Hit || rep.Key.Fragment != "main" || rep.Key.ClientHints != "on" {
+		t.Errorf("report = %+v, key = %+v", rep, rep.Key)
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/config"
+)
+
+// variantStats counts the requests served by one side of a canary rollout
+type variantStats struct {
+	name     string
+	requests atomic.Int64
+	errors   atomic.Int64
+}
+
+// VariantStats is a point-in-time copy of a variant's counters
+type VariantStats struct {
+	Requests  int64   `json:"requests"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// snapshot returns the variant's counters and error rate
+func (v *variantStats) snapshot() VariantStats {
+	st := VariantStats{Requests: v.requests.Load(), Errors: v.errors.Load()}
+	if st.Requests > 0 {
+		st.ErrorRate = float64(st.Errors) / float64(st.Requests)
+	}
+	return st
+}
+
+// selectConfig picks the configuration for a request. Without a canary the
+// variant is nil. With one, the canary header decides if present ("1",
+// "true" or "always" selects the canary, anything else the stable config);
+// otherwise the canary serves its configured percentage of requests.
+func (s *CGIServer) selectConfig(r *http.Request) (config.Config, *variantStats) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.canary == nil {
+		return s.config, nil
+	}
+	useCanary := rand.Float64()*100 < s.config.Canary.Percent
+	if name := s.config.Canary.Header; name != "" {
+		if v := r.Header.Get(name); v != "" {
+			switch strings.ToLower(v) {
+			case "1", "true", "always":
+				useCanary = true
+			default:
+				useCanary = false
+			}
+		}
+	}
+	if useCanary {
+		return *s.canary, &s.stats.canary
+	}
+	return s.config, &s.stats.stable
+}
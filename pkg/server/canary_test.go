@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/config"
+)
+
+func TestCanary(t *testing.T) {
+	tempDir := t.TempDir()
+	for name, content := range map[string]string{
+		"stable.html": `Stable`,
+		"canary.html": `Canary {{.Data.missing.field}}`,
+		"next.yaml":   `default_template: "canary.html"`,
+	} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	newServer := func(percent float64) *CGIServer {
+		server, err := New(&config.Config{
+			ConfigFilePath:  filepath.Join(tempDir, "config.yaml"),
+			DefaultTemplate: "stable.html",
+			Canary:          config.Canary{Config: "next.yaml", Percent: percent, Header: "X-Canary"},
+		})
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		return server
+	}
+	get := func(server *CGIServer, header string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		if header != "" {
+			req.Header.Set("X-Canary", header)
+		}
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	server := newServer(0)
+	tests := []struct {
+		header  string
+		variant string
+		status  int
+	}{
+		{"", "stable", http.StatusOK},
+		{"always", "canary", http.StatusInternalServerError},
+		{"1", "canary", http.StatusInternalServerError},
+		{"never", "stable", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := get(server, tt.header)
+		if got := w.Header().Get("X-Config-Variant"); got != tt.variant || w.Code != tt.status {
+			t.Errorf("header %q: variant = %s, status = %d, want %s, %d", tt.header, got, w.Code, tt.variant, tt.status)
+		}
+	}
+	st := server.statsSnapshot()
+	if st.Stable == nil || st.Canary == nil {
+		t.Fatalf("stats = %+v, want variant stats", st)
+	}
+	if st.Stable.Requests != 2 || st.Stable.Errors != 0 || st.Canary.Requests != 2 || st.Canary.ErrorRate != 1 {
+		t.Errorf("stable = %+v, canary = %+v", *st.Stable, *st.Canary)
+	}
+
+	server = newServer(100)
+	if got := get(server, "").Header().Get("X-Config-Variant"); got != "canary" {
+		t.Errorf("with percent 100 variant = %s, want canary", got)
+	}
+}
+
+func TestCanary_Missing(t *testing.T) {
+	_, err := New(&config.Config{
+		ConfigFilePath: filepath.Join(t.TempDir(), "config.yaml"),
+		Canary:         config.Canary{Config: "missing.yaml"},
+	})
+	if err == nil {
+		t.Error("New() with a missing canary config should fail")
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// lru is a size-bounded cache that evicts the least recently used entries
+// once the total size of its entries exceeds its limit
+type lru[V any] struct {
+	mu    sync.Mutex
+	limit int64
+	used  int64
+	order *list.List // front is most recently used
+	items map[string]*list.Element
+
+	evictions atomic.Int64
+}
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+	size  int64
+}
+
+// get returns the value for key and marks it as recently used
+func (c *lru[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*lruEntry[V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// peek returns the value for key without marking it as used
+func (c *lru[V]) peek(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		return e.Value.(*lruEntry[V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// add stores a value of the given size, evicting older entries to stay
+// within the limit. Values larger than the limit are not stored.
+func (c *lru[V]) add(key string, value V, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.items == nil {
+		c.items = map[string]*list.Element{}
+		c.order = list.New()
+	}
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+	if c.limit > 0 && size > c.limit {
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value, size: size})
+	c.used += size
+	c.evict()
+}
+
+// setLimit changes the size limit, evicting entries if necessary. A limit
+// of zero or less means unbounded.
+func (c *lru[V]) setLimit(limit int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limit = limit
+	c.evict()
+}
+
+// purge removes all entries and returns how many there were
+func (c *lru[V]) purge() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.items)
+	c.items, c.order, c.used = nil, nil, 0
+	return n
+}
+
+// usage returns the number of entries, their total size and the limit
+func (c *lru[V]) usage() (int, int64, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items), c.used, c.limit
+}
+
+// evict drops least recently used entries until the cache fits its limit
+func (c *lru[V]) evict() {
+	for c.limit > 0 && c.used > c.limit && c.order != nil && c.order.Len() > 0 {
+		c.remove(c.order.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// remove deletes an element; the caller holds the lock
+func (c *lru[V]) remove(e *list.Element) {
+	entry := e.Value.(*lruEntry[V])
+	c.order.Remove(e)
+	delete(c.items, entry.key)
+	c.used -= entry.size
+}
@@ -0,0 +1,68 @@
+package server
+
+import "testing"
+
+func TestLRU_Eviction(t *testing.T) {
+	var c lru[string]
+	c.setLimit(30)
+	c.add("a", "A", 10)
+	c.add("b", "B", 10)
+	c.add("c", "C", 10)
+
+	// Touch a so that b is the least recently used
+	if v, ok := c.get("a"); !ok || v != "A" {
+		t.Fatalf("get(a) = %q, %v", v, ok)
+	}
+	c.add("d", "D", 10)
+
+	if _, ok := c.get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	for _, k := range []string{"a", "c", "d"} {
+		if _, ok := c.get(k); !ok {
+			t.Errorf("%s should still be cached", k)
+		}
+	}
+	if n, used, limit := c.usage(); n != 3 || used != 30 || limit != 30 {
+		t.Errorf("usage() = %d, %d, %d, want 3, 30, 30", n, used, limit)
+	}
+	if e := c.evictions.Load(); e != 1 {
+		t.Errorf("evictions = %d, want 1", e)
+	}
+
+	c.setLimit(15)
+	if n, used, _ := c.usage(); n != 1 || used != 10 {
+		t.Errorf("usage() after shrinking = %d, %d, want 1, 10", n, used)
+	}
+	if _, ok := c.get("d"); !ok {
+		t.Error("most recently added entry should survive shrinking")
+	}
+}
+
+func TestLRU_Oversized(t *testing.T) {
+	var c lru[string]
+	c.setLimit(10)
+	c.add("small", "s", 5)
+	c.add("big", "b", 11)
+	if _, ok := c.get("big"); ok {
+		t.Error("entry larger than the limit should not be stored")
+	}
+	if _, ok := c.get("small"); !ok {
+		t.Error("oversized entry should not evict others")
+	}
+}
+
+func TestLRU_Replace(t *testing.T) {
+	var c lru[string]
+	c.add("k", "v1", 10)
+	c.add("k", "v2", 20)
+	if v, _ := c.get("k"); v != "v2" {
+		t.Errorf("get(k) = %q, want v2", v)
+	}
+	if n, used, _ := c.usage(); n != 1 || used != 20 {
+		t.Errorf("usage() = %d, %d, want 1, 20", n, used)
+	}
+	if n := c.purge(); n != 1 {
+		t.Errorf("purge() = %d, want 1", n)
+	}
+}
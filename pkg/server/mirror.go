@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/config"
+)
+
+// mirrorClient sends mirrored requests; responses are discarded
+var mirrorClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// maxMirrorBody bounds the request bodies copied to the shadow backend;
+// requests with larger bodies are not mirrored
+const maxMirrorBody = 1 << 20
+
+// hopHeaders are connection-specific headers that are not forwarded
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// credentialHeaders carry the visitor's credentials, which are only
+// forwarded when the mirror config allows it
+var credentialHeaders = []string{"Authorization", "Cookie"}
+
+// mirror sends a copy of a sampled request to the shadow backend in the
+// background. The client's response never waits for or depends on it.
+func (s *CGIServer) mirror(cfg *config.Mirror, r *http.Request, requestURI string) {
+	if cfg.URL == "" || rand.Float64()*100 >= cfg.Percent {
+		return
+	}
+	methods := cfg.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead}
+	}
+	if !slices.Contains(methods, r.Method) {
+		return
+	}
+
+	header := r.Header.Clone()
+	for _, h := range hopHeaders {
+		header.Del(h)
+	}
+	if !cfg.ForwardCredentials {
+		for _, h := range credentialHeaders {
+			header.Del(h)
+		}
+	}
+	header.Set("X-Mirrored-By", "tmpl.cgi")
+	header.Set("X-Forwarded-Host", r.Host)
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	target := strings.TrimRight(cfg.URL, "/") + requestURI
+	method := r.Method
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		// Read the body for the copy and put it back for the real handler
+		var err error
+		if body, err = io.ReadAll(io.LimitReader(r.Body, maxMirrorBody+1)); err != nil || len(body) > maxMirrorBody {
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	s.mirrors.Add(1)
+	go func() {
+		defer s.mirrors.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+		if err == nil {
+			req.Header = header
+			var resp *http.Response
+			if resp, err = mirrorClient.Do(req); err == nil {
+				_ = resp.Body.Close()
+			}
+		}
+		s.stats.mirrored.Add(1)
+		if err != nil {
+			s.stats.mirrorErrors.Add(1)
+			log.Printf("mirroring %s %s: %v", method, requestURI, err)
+		}
+	}()
+}
+
+// waitMirrors waits up to timeout for mirrored requests still in flight
+func waitMirrors(wg *sync.WaitGroup, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
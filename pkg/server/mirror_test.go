@@ -0,0 +1,107 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/config"
+)
+
+func TestMirror(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		seen = append(seen, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("X-Mirrored-By")+" "+string(body))
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer shadow.Close()
+
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "page.html"), []byte(`Page`), 0644); err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+	server, _ := New(&config.Config{
+		ConfigFilePath:  filepath.Join(tempDir, "config.yaml"),
+		DefaultTemplate: "page.html",
+		Mirror:          config.Mirror{URL: shadow.URL + "/", Percent: 100},
+	})
+
+	for _, method := range []string{"GET", "POST"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "http://example.com/page?x=1", strings.NewReader(""))
+		req.RequestURI = "/page?x=1"
+		server.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Body.String() != "Page" {
+			t.Errorf("%s response = %d %q, want the page unaffected by the shadow", method, w.Code, w.Body.String())
+		}
+	}
+	server.mirrors.Wait()
+
+	want := []string{"GET /page?x=1 tmpl.cgi "}
+	if strings.Join(seen, "|") != strings.Join(want, "|") {
+		t.Errorf("shadow saw %q, want %q", seen, want)
+	}
+	if st := server.statsSnapshot(); st.Mirrored != 1 || st.MirrorErrors != 0 {
+		t.Errorf("mirrored = %d, errors = %d, want 1, 0", st.Mirrored, st.MirrorErrors)
+	}
+}
+
+func TestMirror_Methods(t *testing.T) {
+	var got []string
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, r.Method+" "+string(body))
+	}))
+	defer shadow.Close()
+
+	server, _ := New(&config.Config{
+		Mirror: config.Mirror{URL: shadow.URL, Percent: 100, Methods: []string{"POST"}},
+	})
+	req := httptest.NewRequest("POST", "/form", strings.NewReader("email=a%40example.com"))
+	server.mirror(&server.config.Mirror, req, "/form")
+	server.mirrors.Wait()
+
+	if len(got) != 1 || got[0] != "POST email=a%40example.com" {
+		t.Errorf("shadow saw %q", got)
+	}
+	if rest, _ := io.ReadAll(req.Body); string(rest) != "email=a%40example.com" {
+		t.Errorf("request body after mirroring = %q, want it intact", rest)
+	}
+}
+
+func TestMirror_Credentials(t *testing.T) {
+	var got http.Header
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer shadow.Close()
+
+	for _, forward := range []bool{false, true} {
+		server, _ := New(&config.Config{
+			Mirror: config.Mirror{URL: shadow.URL, Percent: 100, ForwardCredentials: forward},
+		})
+		req := httptest.NewRequest("GET", "/account", nil)
+		req.Header.Set("Authorization", "Basic YWxpY2U6c2VjcmV0")
+		req.Header.Set("Cookie", "session=abc")
+		req.Header.Set("Proxy-Authorization", "Basic cHJveHk6c2VjcmV0")
+		req.Header.Set("Accept-Language", "de")
+		server.mirror(&server.config.Mirror, req, "/account")
+		server.mirrors.Wait()
+
+		if got.Get("Accept-Language") != "de" || got.Get("Proxy-Authorization") != "" {
+			t.Errorf("forward_credentials %v: shadow saw %v", forward, got)
+		}
+		if (got.Get("Authorization") != "") != forward || (got.Get("Cookie") != "") != forward {
+			t.Errorf("forward_credentials %v: Authorization = %q, Cookie = %q", forward, got.Get("Authorization"), got.Get("Cookie"))
+		}
+	}
+}
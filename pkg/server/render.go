@@ -0,0 +1,175 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// renderBuffer collects a rendered page so that an execution error can
+// still be answered with an error page. Once the page grows past the
+// threshold, it stops holding it in memory: a long-lived server commits the
+// status and headers and streams the rest to the client, while a CGI
+// process spills to a temporary file, since its output is buffered by the
+// web server anyway.
+type renderBuffer struct {
+	w         http.ResponseWriter
+	status    int
+	threshold int64
+	spill     bool
+
+	buf       bytes.Buffer
+	file      *os.File
+	streaming bool
+}
+
+// Write implements io.Writer
+func (b *renderBuffer) Write(p []byte) (int, error) {
+	switch {
+	case b.streaming:
+		n, err := b.w.Write(p)
+		if f, ok := b.w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return n, err
+	case b.file != nil:
+		return b.file.Write(p)
+	case b.threshold > 0 && int64(b.buf.Len()+len(p)) > b.threshold:
+		if err := b.overflow(); err != nil {
+			return 0, err
+		}
+		return b.Write(p)
+	}
+	return b.buf.Write(p)
+}
+
+// overflow moves the buffered output out of memory
+func (b *renderBuffer) overflow() error {
+	if b.spill {
+		f, err := os.CreateTemp("", "tmpl.cgi-render-*")
+		if err != nil {
+			return err
+		}
+		_ = os.Remove(f.Name())
+		b.file = f
+	} else {
+		b.w.WriteHeader(b.status)
+		b.streaming = true
+	}
+	_, err := b.Write(b.buf.Bytes())
+	b.buf = bytes.Buffer{}
+	return err
+}
+
+// committed reports whether the status and headers have been sent, after
+// which an error page can no longer replace the response
+func (b *renderBuffer) committed() bool {
+	return b.streaming
+}
+
+// body returns the complete page if it is still held in memory
+func (b *renderBuffer) body() ([]byte, bool) {
+	return b.buf.Bytes(), !b.streaming && b.file == nil
+}
+
+// replace swaps the page held in memory for a converted version of it
+func (b *renderBuffer) replace(p []byte) {
+	b.buf = *bytes.NewBuffer(p)
+}
+
+// finish sends whatever has not been sent yet
+func (b *renderBuffer) finish() error {
+	if b.streaming {
+		return nil
+	}
+	b.w.WriteHeader(b.status)
+	if b.file == nil {
+		_, err := b.w.Write(b.buf.Bytes())
+		return err
+	}
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.Copy(b.w, b.file)
+	return err
+}
+
+// close releases the temporary file, if any
+func (b *renderBuffer) close() {
+	if b.file != nil {
+		_ = b.file.Close()
+		b.file = nil
+	}
+}
+
+// Errors returned when a render exceeds its budget
+var (
+	errRenderTimeout  = errors.New("render exceeded its time budget")
+	errRenderTooLarge = errors.New("render exceeded its output budget")
+)
+
+// budgetWriter enforces a request's output budget, and stops passing output
+// on once the render has run out of time
+type budgetWriter struct {
+	w       io.Writer
+	max     int64
+	written int64
+
+	mu      sync.Mutex
+	expired bool
+}
+
+// Write implements io.Writer
+func (b *budgetWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.expired {
+		return 0, errRenderTimeout
+	}
+	if b.max > 0 && b.written+int64(len(p)) > b.max {
+		return 0, errRenderTooLarge
+	}
+	n, err := b.w.Write(p)
+	b.written += int64(n)
+	return n, err
+}
+
+// expire makes every later write fail, waiting for a write in progress
+func (b *budgetWriter) expire() {
+	b.mu.Lock()
+	b.expired = true
+	b.mu.Unlock()
+}
+
+// executeWithin runs execute, giving up once timeout has passed even if the
+// template is busy without writing. A running template cannot be
+// interrupted, so it is left to finish in the background, but it can no
+// longer write to the response.
+func executeWithin(out *budgetWriter, timeout time.Duration, execute func() error) error {
+	if timeout <= 0 {
+		return execute()
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("panic executing template: %v", p)
+			}
+		}()
+		done <- execute()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		out.expire()
+		return errRenderTimeout
+	}
+}
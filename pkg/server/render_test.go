@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/config"
+)
+
+func TestServeHTTP_LargePages(t *testing.T) {
+	tempDir := t.TempDir()
+	for name, content := range map[string]string{
+		"big.html":    `{{range until 100}}0123456789{{end}}`,
+		"broken.html": `{{range until 100}}0123456789{{end}}{{.Missing.Field}}`,
+	} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+	}
+	cfg := &config.Config{
+		ConfigFilePath:  filepath.Join(tempDir, "config.yaml"),
+		DefaultTemplate: "big.html",
+		Templates:       []config.Template{{Pattern: "^/broken", Template: "broken.html"}},
+		Render:          config.Render{StreamThreshold: 64},
+	}
+	want := strings.Repeat("0123456789", 100)
+
+	serve := func(server *CGIServer, path string) (w *httptest.ResponseRecorder, aborted bool) {
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+		req.RequestURI = path
+		defer func() {
+			if v := recover(); v != nil {
+				if v != http.ErrAbortHandler {
+					panic(v)
+				}
+				aborted = true
+			}
+		}()
+		server.ServeHTTP(w, req)
+		return w, false
+	}
+
+	t.Run("Streamed", func(t *testing.T) {
+		server, _ := New(cfg)
+		w, _ := serve(server, "/")
+		if w.Code != http.StatusOK || w.Body.String() != want || !w.Flushed {
+			t.Errorf("status = %d, flushed = %v, body length = %d", w.Code, w.Flushed, w.Body.Len())
+		}
+	})
+
+	t.Run("Streamed error aborts", func(t *testing.T) {
+		server, _ := New(cfg)
+		w, aborted := serve(server, "/broken")
+		if !aborted {
+			t.Error("error after streaming started should abort the response")
+		}
+		if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "Server Error") {
+			t.Errorf("status = %d, body = %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("Spilled", func(t *testing.T) {
+		server, _ := New(cfg)
+		server.cgi = true
+		w, _ := serve(server, "/")
+		if w.Code != http.StatusOK || w.Body.String() != want || w.Flushed {
+			t.Errorf("status = %d, flushed = %v, body length = %d", w.Code, w.Flushed, w.Body.Len())
+		}
+	})
+
+	t.Run("Spilled error", func(t *testing.T) {
+		server, _ := New(cfg)
+		server.cgi = true
+		w, aborted := serve(server, "/broken")
+		if aborted || w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "0123456789") {
+			t.Errorf("aborted = %v, status = %d, body = %q", aborted, w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestServeHTTP_RenderBudgets(t *testing.T) {
+	tempDir := t.TempDir()
+	for name, content := range map[string]string{
+		"slow.html": `{{range until 10000}}{{range until 1000}}x{{end}}{{end}}`,
+		"big.html":  `{{range until 100}}0123456789{{end}}`,
+	} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		render config.Render
+		page   string
+		status int
+	}{
+		{"Within budget", config.Render{Timeout: time.Minute, MaxOutput: 2000}, "big.html", http.StatusOK},
+		{"Too large", config.Render{MaxOutput: 100}, "big.html", http.StatusInternalServerError},
+		{"Too slow", config.Render{Timeout: time.Millisecond}, "slow.html", http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := New(&config.Config{
+				ConfigFilePath:  filepath.Join(tempDir, "config.yaml"),
+				DefaultTemplate: tt.page,
+				Render:          tt.render,
+			})
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			st := server.statsSnapshot()
+			if tt.status == http.StatusOK && st.RenderTimeouts+st.RenderOversized != 0 {
+				t.Errorf("stats = %+v, want no aborted renders", st)
+			}
+			if tt.render.MaxOutput == 100 && st.RenderOversized != 1 {
+				t.Errorf("RenderOversized = %d, want 1", st.RenderOversized)
+			}
+			if tt.render.Timeout == time.Millisecond && st.RenderTimeouts != 1 {
+				t.Errorf("RenderTimeouts = %d, want 1", st.RenderTimeouts)
+			}
+		})
+	}
+}
+
+func TestExecuteWithin(t *testing.T) {
+	var buf bytes.Buffer
+	out := &budgetWriter{w: &buf}
+	release := make(chan struct{})
+	written := make(chan error, 1)
+
+	// A render that is busy without writing is answered at the deadline
+	err := executeWithin(out, 10*time.Millisecond, func() error {
+		<-release
+		_, err := out.Write([]byte("late"))
+		written <- err
+		return err
+	})
+	if !errors.Is(err, errRenderTimeout) {
+		t.Fatalf("executeWithin() error = %v, want %v", err, errRenderTimeout)
+	}
+
+	// and can no longer write once it resumes
+	close(release)
+	if err := <-written; !errors.Is(err, errRenderTimeout) {
+		t.Errorf("Write() after the deadline error = %v, want %v", err, errRenderTimeout)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("output = %q, want nothing after the deadline", buf.String())
+	}
+
+	if err := executeWithin(&budgetWriter{w: &buf}, time.Minute, func() error { return nil }); err != nil {
+		t.Errorf("executeWithin() error = %v, want nil", err)
+	}
+}
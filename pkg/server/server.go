@@ -2,35 +2,141 @@ package server
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"net/http/cgi"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"gopkg.mhn.org/tmpl.cgi/pkg/action"
+	"gopkg.mhn.org/tmpl.cgi/pkg/clienthints"
 	"gopkg.mhn.org/tmpl.cgi/pkg/config"
 	"gopkg.mhn.org/tmpl.cgi/pkg/debug"
 )
 
 // CGIServer handles CGI requests
 type CGIServer struct {
-	config config.Config
+	mu       sync.RWMutex
+	config   config.Config
+	canary   *config.Config
+	loadedFP string // fingerprint of the config and templates when loaded
+	stats    stats
+	cache    templateCache
+	pages    responseCache
+	cgi      bool
+	draining atomic.Bool
+	mirrors  sync.WaitGroup
+
+	// refreshing is set when data sources are refreshed in the background,
+	// and stopRefresh stops refreshing those of the active configuration
+	refreshing  bool
+	stopRefresh func()
 }
 
 // New creates a new CGI server instance
 func New(cfg *config.Config) (*CGIServer, error) {
-	return &CGIServer{config: *cfg}, nil
+	canary, err := cfg.LoadCanary()
+	if err != nil {
+		return nil, err
+	}
+	return &CGIServer{
+		config:   *cfg,
+		canary:   canary,
+		loadedFP: fingerprint(cfg.ConfigFilePath) + "|" + inputsFingerprint(cfg),
+		stats: stats{
+			started: time.Now(),
+			stable:  variantStats{name: "stable"},
+			canary:  variantStats{name: "canary"},
+		},
+	}, nil
+}
+
+// snapshot returns the active configuration
+func (s *CGIServer) snapshot() config.Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+// Reload re-reads and validates the config file and its templates and
+// switches to them. The active configuration is kept if the new one is
+// invalid.
+func (s *CGIServer) Reload() error {
+	current := s.snapshot()
+	if !current.Reloadable() {
+		return fmt.Errorf("config from stdin or environment cannot be reloaded")
+	}
+	fp := fingerprint(current.ConfigFilePath)
+	cfg, err := config.ParseConfigFileAs(current.ConfigFilePath, current.Format)
+	if err != nil {
+		return err
+	}
+	fp += "|" + inputsFingerprint(cfg)
+	if err = cfg.Validate(); err != nil {
+		return fmt.Errorf("validating config: %w", err)
+	}
+	canary, err := cfg.LoadCanary()
+	if err != nil {
+		return err
+	}
+	// The new refresher reads cfg, which is never written again; the old
+	// one is stopped before the swap
+	s.mu.Lock()
+	var stop func()
+	if s.refreshing {
+		stop = s.stopRefresh
+		s.stopRefresh = startDataRefresh(cfg, canary)
+	}
+	s.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+	s.mu.Lock()
+	s.config = *cfg
+	s.canary = canary
+	s.loadedFP = fp
+	s.mu.Unlock()
+	s.cache.purge()
+	s.pages.entries.purge()
+	s.stats.reloads.Add(1)
+	return nil
+}
+
+// SwitchTemplates points the template_dir symlink at dir once the config
+// validates against the templates there, then reloads. Requests already
+// rendering finish with the old templates; later ones only see the new.
+func (s *CGIServer) SwitchTemplates(dir string) error {
+	current := s.snapshot()
+	if !current.Reloadable() {
+		return fmt.Errorf("config from stdin or environment cannot be reloaded")
+	}
+	if err := current.SwitchTemplateDir(dir); err != nil {
+		return err
+	}
+	return s.Reload()
 }
 
 func (s *CGIServer) Run() error {
 	// Check if running as CGI
 	if os.Getenv("GATEWAY_INTERFACE") != "" {
 		// Running as CGI
+		s.cgi = true
 		err := cgi.Serve(s)
 		if err != nil {
 			return fmt.Errorf("serving CGI server: %v", err)
 		}
+		// The process exiting would cancel a mirrored request, so close
+		// stdout to complete the response and give it a moment to finish
+		if m := s.snapshot().Mirror; m.URL != "" {
+			_ = os.Stdout.Close()
+			waitMirrors(&s.mirrors, m.Timeout+time.Second)
+		}
 	} else {
 		// Running as standalone server for testing
 		debug.SetDebugMode()
@@ -45,6 +151,31 @@ func (s *CGIServer) Run() error {
 		}
 
 		log.Printf("Starting test server on port %s", port)
+		s.StartDataRefresh()
+		cfg := s.snapshot()
+		if cfg.Admin.Listen != "" {
+			adminLn, err := net.Listen("tcp", cfg.Admin.Listen)
+			if err != nil {
+				return fmt.Errorf("listening on admin address %s: %v", cfg.Admin.Listen, err)
+			}
+			log.Printf("Serving admin API on %s", cfg.Admin.Listen)
+			go func() {
+				if err := http.Serve(adminLn, s.adminHandler()); err != nil {
+					log.Printf("serving admin API: %v", err)
+				}
+			}()
+		}
+		if interval := cfg.Watch.PollInterval(); interval > 0 {
+			go s.watchConfig(interval, nil)
+		}
+		if cfg.Announce.Webhook != "" {
+			listenURL := fmt.Sprintf("http://localhost:%d/", ln.Addr().(*net.TCPAddr).Port)
+			go func() {
+				if err := s.announce(listenURL); err != nil {
+					log.Printf("announcing server: %v", err)
+				}
+			}()
+		}
 
 		err = http.Serve(ln, s)
 		if err != nil {
@@ -55,30 +186,317 @@ func (s *CGIServer) Run() error {
 	return nil
 }
 
+// StartDataRefresh refreshes the data sources that have a refresh interval
+// in the background, for the active configuration and those it is reloaded
+// to
+func (s *CGIServer) StartDataRefresh() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refreshing {
+		return
+	}
+	s.refreshing = true
+	// Refresh a copy, since Reload overwrites s.config
+	cfg := s.config
+	s.stopRefresh = startDataRefresh(&cfg, s.canary)
+	s.config = cfg
+}
+
+// startDataRefresh starts refreshing the data sources of a configuration
+// and its canary, returning a function that stops both
+func startDataRefresh(cfg, canary *config.Config) func() {
+	stop := cfg.StartDataRefresh()
+	if canary == nil {
+		return stop
+	}
+	stopCanary := canary.StartDataRefresh()
+	return func() {
+		stop()
+		stopCanary()
+	}
+}
+
 // ServeHTTP handles HTTP requests
 func (s *CGIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.stats.requests.Add(1)
+	s.stats.inFlight.Add(1)
+	defer s.stats.inFlight.Add(-1)
+	sw := &statusWriter{ResponseWriter: w}
+	cfg, variant := s.selectConfig(r)
+	if variant != nil {
+		w.Header().Set("X-Config-Variant", variant.name)
+		variant.requests.Add(1)
+	}
+	s.mirror(&cfg.Mirror, r, getRequestURI(r))
+	s.serve(sw, r, cfg)
+	s.stats.record(sw.status)
+	if variant != nil && sw.status >= 500 {
+		variant.errors.Add(1)
+	}
+}
+
+// serve renders the response to a request using the given configuration
+func (s *CGIServer) serve(w http.ResponseWriter, r *http.Request, cfg config.Config) {
 	requestURI := getRequestURI(r)
-	tmpl, err := s.config.FindTemplate(requestURI)
+	// Routes and built-in endpoints are relative to the base path
+	routeURI := cfg.StripBasePath(requestURI)
+	urlPath, _, _ := strings.Cut(routeURI, "?")
+	cfg.Defaults.SetHeaders(w.Header())
+	w = &defaultsWriter{ResponseWriter: w, defaults: &cfg.Defaults}
+	if cfg.Theme.Enabled && urlPath == cfg.Theme.EndpointPath() {
+		cfg.Theme.Handle(w, r)
+		return
+	}
+	if cfg.Consent.Enabled && urlPath == cfg.Consent.EndpointPath() {
+		cfg.Consent.Handle(w, r)
+		return
+	}
+	if f, ok := cfg.WellKnownFile(urlPath); ok {
+		w.Header().Set("Content-Type", f.ContentType)
+		_, _ = w.Write(f.Body)
+		return
+	}
+	pageURI, printing := config.PrintRequest(routeURI)
+	route, err := cfg.MatchRequest(r, pageURI)
+	if err == nil && printing && (route == nil || route.PrintTemplate == "") {
+		// Only routes with a print template have a print variant
+		printing = false
+		route, err = cfg.MatchRequest(r, routeURI)
+	}
+	if err != nil {
+		log.Printf("matching template: %v", err)
+		debug.WriteDebugError(w, [][2]string{{"Request URI", requestURI}, {"Error matching template", err.Error()}})
+		return
+	}
+	templateName := cfg.DefaultTemplate
+	status := http.StatusOK
+	var result *action.Result
+	if route != nil {
+		templateName = route.TemplateName()
+		if route.Status != 0 {
+			status = route.Status
+		}
+		preview := cfg.PreviewAll || cfg.PreviewAllowed(r)
+		if route.Draft && !preview {
+			writeNotFound(w)
+			return
+		}
+		allowed, err := cfg.Authorize(route, config.RemoteUser())
+		if err != nil {
+			log.Printf("authorizing request: %v", err)
+			debug.WriteDebugError(w, [][2]string{{"Request URI", requestURI}, {"Error authorizing request", err.Error()}})
+			return
+		}
+		if !allowed && cfg.Authz.ForbiddenTemplate == "" {
+			writeForbidden(w)
+			return
+		}
+		if !allowed {
+			templateName = cfg.Authz.ForbiddenTemplate
+			status = http.StatusForbidden
+		} else if !route.Published(cfg.Now()) && !preview {
+			if route.TeaserTemplate == "" {
+				writeNotFound(w)
+				return
+			}
+			templateName = route.TeaserTemplate
+		} else if printing {
+			templateName = route.PrintTemplate
+			canonical, _ := config.PrintRequest(requestURI)
+			w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"canonical\"", canonical))
+			w.Header().Set("X-Robots-Tag", "noindex")
+		} else if route.Action != nil && r.Method == http.MethodPost {
+			result = route.Action.Run(r)
+			if result.Redirect != "" {
+				http.Redirect(w, r, result.Redirect, http.StatusSeeOther)
+				return
+			}
+			status = result.Status
+			if name := route.Action.Template(result); name != "" {
+				templateName = name
+			}
+		}
+	}
+	cfg.Theme.SetHeaders(w.Header())
+	cfg.ClientHints.SetHeaders(w.Header())
+	cfg.Consent.SetHeaders(w.Header())
+	var cacheKey string
+	if key, _ := responseCacheKey(&cfg, r, requestURI, route, templateName, result); key != nil {
+		cacheKey = key.String()
+	}
+	if cacheKey != "" {
+		if cached, ok := s.pages.get(cacheKey, cfg.Now()); ok {
+			for k, v := range cached.header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(cached.status)
+			_, _ = w.Write(cached.body)
+			return
+		}
+	}
+	tmpl, err := s.loadTemplate(&cfg, templateName)
 	if err != nil {
 		log.Printf("loading template: %v", err)
 		debug.WriteDebugError(w, [][2]string{{"Request URI", requestURI}, {"Error loading template", err.Error()}})
 		return
 	}
+	visible, err := cfg.DataFor(route, config.RemoteUser())
+	if err != nil {
+		log.Printf("filtering data: %v", err)
+		debug.WriteDebugError(w, [][2]string{{"Request URI", requestURI}, {"Error filtering data", err.Error()}})
+		return
+	}
+	// Cached pages keep a snapshot of the route's data for the cache
+	// report; pages using the request hook are not cached
+	rendered := visible
+	visible = cfg.WithRequestHook(r, requestURI, visible)
 	data := config.TemplateData{
 		RequestURI: requestURI,
+		BasePath:   cfg.NormalizedBasePath(),
 		Request:    r,
-		Data:       s.config.Data,
+		Data:       visible,
+		Action:     result,
+		Theme:      cfg.Theme.Resolve(r),
+		Hints:      clienthints.Parse(r.Header),
+		Consent:    cfg.Consent.Resolve(r),
+		Env:        cfg.TemplateEnv(os.Environ()),
+		Vars:       map[string]any{},
+		Tmpl:       cfg.TmplInfo(),
+	}
+	if route != nil && route.PrintTemplate != "" && !printing {
+		data.PrintURL = config.PrintURL(requestURI)
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"alternate\"; media=\"print\"", data.PrintURL))
+	}
+	w.Header().Set("Content-Type", cfg.ContentTypeFor(route, templateName))
+	if route != nil {
+		route.SetHeaders(w.Header())
+	}
+	buf := &renderBuffer{
+		w:         w,
+		status:    status,
+		threshold: int64(cfg.Render.StreamThreshold),
+		spill:     s.cgi,
+	}
+	defer buf.close()
+	fragment := fragmentFor(route, r)
+	if fragment != "" && templateName != route.TemplateName() {
+		fragment = ""
 	}
-	var buf bytes.Buffer
-	err = tmpl.Execute(&buf, data)
+	steps := cfg.PipelineSteps(route)
+	if fragment != "" {
+		steps = config.WithoutLayouts(steps)
+	}
+	if status == http.StatusForbidden {
+		steps = nil
+	}
+	if len(steps) > 0 {
+		// The pipeline needs the whole page
+		buf.threshold = 0
+	}
+	out := &budgetWriter{w: buf, max: int64(cfg.Render.MaxOutput)}
+	if route != nil && route.Fragment != "" {
+		w.Header().Add("Vary", "HX-Request, HX-Boosted, Turbo-Frame")
+	}
+	err = executeWithin(out, cfg.Render.Timeout, func() error {
+		if fragment != "" {
+			return tmpl.ExecuteTemplate(out, fragment, data)
+		}
+		return tmpl.Execute(out, data)
+	})
 	if err != nil {
 		log.Printf("executing template: %v", err)
+		switch {
+		case errors.Is(err, errRenderTimeout):
+			s.stats.timeouts.Add(1)
+		case errors.Is(err, errRenderTooLarge):
+			s.stats.oversized.Add(1)
+		}
+		if buf.committed() {
+			// Part of the page has been sent; drop the connection so the
+			// client sees a truncated response rather than a complete one
+			panic(http.ErrAbortHandler)
+		}
 		debug.WriteDebugError(w, [][2]string{{"Request URI", requestURI}, {"Error executing template", err.Error()}})
 		return
 	}
+	if len(steps) > 0 {
+		page, _ := buf.body()
+		out, contentType, err := cfg.RunPipeline(steps, page, templateName, data, func(name string) (config.Executable, error) {
+			return s.loadTemplate(&cfg, name)
+		})
+		if err != nil {
+			log.Printf("running pipeline: %v", err)
+			debug.WriteDebugError(w, [][2]string{{"Request URI", requestURI}, {"Error running pipeline", err.Error()}})
+			return
+		}
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Disposition", route.Disposition(urlPath, cfg.OutputFormat(route)))
+		}
+		buf.replace(out)
+	}
+
+	if body, ok := buf.body(); ok && cacheKey != "" && status == http.StatusOK {
+		s.pages.entries.setLimit(cfg.Cache.ResponseLimit())
+		s.pages.add(cacheKey, &cachedResponse{
+			status:  status,
+			header:  w.Header().Clone(),
+			body:    bytes.Clone(body),
+			data:    dataSnapshot(rendered),
+			created: cfg.Now(),
+			expires: cfg.Now().Add(cfg.Cache.Responses),
+		})
+	}
+	if err = buf.finish(); err != nil {
+		log.Printf("writing response: %v", err)
+	}
+}
 
+// loadTemplate parses the named template, reusing a previously parsed copy
+// when the template cache is enabled
+func (s *CGIServer) loadTemplate(cfg *config.Config, name string) (config.Executable, error) {
+	if !cfg.Cache.Templates {
+		return cfg.LoadTemplate(name)
+	}
+	s.cache.entries.setLimit(cfg.Cache.TemplateLimit())
+	return s.cache.get(cfg.Version+"\x00"+name, func() (config.Executable, error) {
+		return cfg.LoadTemplate(name)
+	})
+}
+
+// fragmentFor returns the block of the route's template to render for a
+// partial page request, or "" for the full page
+func fragmentFor(route *config.Template, r *http.Request) string {
+	if route == nil {
+		return ""
+	}
+	return route.FragmentFor(r)
+}
+
+// writeForbidden writes a plain 403 response
+func writeForbidden(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+	_, _ = w.Write([]byte(`<!DOCTYPE HTML PUBLIC "-//IETF//DTD HTML 2.0//EN">
+<html><head>
+<title>403 Forbidden</title>
+</head><body>
+<h1>Forbidden</h1>
+<p>You don't have permission to access this resource.</p>
+</body></html>`))
+}
+
+// writeNotFound writes a plain 404 response
+func writeNotFound(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	_, _ = w.Write(buf.Bytes())
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write([]byte(`<!DOCTYPE HTML PUBLIC "-//IETF//DTD HTML 2.0//EN">
+<html><head>
+<title>404 Not Found</title>
+</head><body>
+<h1>Not Found</h1>
+<p>The requested URL was not found on this server.</p>
+</body></html>`))
 }
 
 // getRequestURI extracts the request URI from the HTTP request
@@ -89,3 +507,38 @@ func getRequestURI(r *http.Request) string {
 	}
 	return requestURI
 }
+
+// defaultsWriter drops the default caching headers when the response is
+// not successful, so that error pages are not cached like pages
+type defaultsWriter struct {
+	http.ResponseWriter
+	defaults    *config.Defaults
+	wroteHeader bool
+}
+
+func (w *defaultsWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status >= 300 && status != http.StatusNotModified {
+		w.defaults.ClearCaching(w.Header())
+	}
+	if status >= 200 {
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *defaultsWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher so streamed pages reach the client
+func (w *defaultsWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *defaultsWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -4,10 +4,18 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"gopkg.mhn.org/tmpl.cgi/pkg/action"
 	"gopkg.mhn.org/tmpl.cgi/pkg/config"
+	"gopkg.mhn.org/tmpl.cgi/pkg/consent"
+	"gopkg.mhn.org/tmpl.cgi/pkg/pdf"
+	"gopkg.mhn.org/tmpl.cgi/pkg/theme"
+	"gopkg.mhn.org/tmpl.cgi/pkg/wellknown"
 )
 
 func TestNew(t *testing.T) {
@@ -213,6 +221,1123 @@ func TestServeHTTP_TemplateExecutionError(t *testing.T) {
 	}
 }
 
+func TestServeHTTP_Draft(t *testing.T) {
+	tempDir := t.TempDir()
+
+	err := os.WriteFile(tempDir+"/draft.html", []byte(`Draft: {{.RequestURI}}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "draft.html",
+		PreviewToken:    "s3cret",
+		Templates: []config.Template{
+			{Pattern: "^/drafts/", Template: "draft.html", Draft: true},
+		},
+	}
+
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		cookie         string
+		expectedStatus int
+	}{
+		{"Published page", "/other", "", http.StatusOK},
+		{"Draft without token", "/drafts/post", "", http.StatusNotFound},
+		{"Draft with wrong token", "/drafts/post?preview_token=nope", "", http.StatusNotFound},
+		{"Draft with query token", "/drafts/post?preview_token=s3cret", "", http.StatusOK},
+		{"Draft with cookie token", "/drafts/post", "s3cret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+			req.RequestURI = tt.path
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "preview_token", Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+
+			server.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", w.Code, tt.expectedStatus)
+			}
+		})
+	}
+
+	// Debug mode does not unlock drafts, preview_all does
+	t.Setenv("TMPL_CGI_DEBUG", "1")
+	for _, previewAll := range []bool{false, true} {
+		cfg.PreviewAll = previewAll
+		server, err := New(cfg)
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		req := httptest.NewRequest("GET", "http://example.com/drafts/post", nil)
+		req.RequestURI = "/drafts/post"
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		if want := map[bool]int{false: http.StatusNotFound, true: http.StatusOK}[previewAll]; w.Code != want {
+			t.Errorf("ServeHTTP() with preview_all %v status = %d, want %d", previewAll, w.Code, want)
+		}
+	}
+}
+
+func TestServeHTTP_PublishWindow(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"page.html":   `Page`,
+		"teaser.html": `Coming soon`,
+	} {
+		if err := os.WriteFile(tempDir+"/"+name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+	}
+
+	future := time.Now().Add(24 * time.Hour)
+	past := time.Now().Add(-24 * time.Hour)
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "page.html",
+		Templates: []config.Template{
+			{Pattern: "^/teaser$", Template: "page.html", PublishDate: future, TeaserTemplate: "teaser.html"},
+			{Pattern: "^/future$", Template: "page.html", PublishDate: future},
+			{Pattern: "^/expired$", Template: "page.html", ExpiryDate: past},
+			{Pattern: "^/live$", Template: "page.html", PublishDate: past, ExpiryDate: future},
+		},
+	}
+
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	tests := []struct {
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"/teaser", http.StatusOK, "Coming soon"},
+		{"/future", http.StatusNotFound, "Not Found"},
+		{"/expired", http.StatusNotFound, "Not Found"},
+		{"/live", http.StatusOK, "Page"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+			req.RequestURI = tt.path
+			w := httptest.NewRecorder()
+
+			server.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", w.Code, tt.expectedStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("ServeHTTP() body should contain %q, got: %s", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+
+	// Travel past the publish and expiry dates
+	t.Setenv(config.NowEnv, time.Now().Add(48*time.Hour).Format(time.RFC3339))
+	for path, expectedStatus := range map[string]int{"/future": http.StatusOK, "/live": http.StatusNotFound} {
+		req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+		req.RequestURI = path
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		if w.Code != expectedStatus {
+			t.Errorf("ServeHTTP(%s) with %s status = %d, want %d", path, config.NowEnv, w.Code, expectedStatus)
+		}
+	}
+}
+
+func TestServeHTTP_Action(t *testing.T) {
+	tempDir := t.TempDir()
+
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer provider.Close()
+
+	for name, content := range map[string]string{
+		"form.html":   `Form{{with .Action}}: {{.Error}}{{end}}`,
+		"thanks.html": `Thanks: {{.Action.Message}} {{.Action.Form.email}}`,
+	} {
+		if err := os.WriteFile(tempDir+"/"+name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "form.html",
+		Templates: []config.Template{
+			{Pattern: "^/subscribe$", Template: "form.html", Action: &action.Action{
+				Type: "newsletter", Provider: "buttondown", URL: provider.URL, APIKey: "k",
+				SuccessTemplate: "thanks.html",
+			}},
+		},
+	}
+
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		email          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"GET shows form", "GET", "", http.StatusOK, "Form"},
+		{"POST subscribes", "POST", "reader@example.com", http.StatusOK, "Thanks: You are now subscribed. reader@example.com"},
+		{"POST invalid email", "POST", "nope", http.StatusBadRequest, "Form: Please enter a valid email address."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://example.com/subscribe", strings.NewReader("email="+tt.email))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.RequestURI = "/subscribe"
+			w := httptest.NewRecorder()
+
+			server.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", w.Code, tt.expectedStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("ServeHTTP() body should contain %q, got: %s", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestServeHTTP_WellKnown(t *testing.T) {
+	tempDir := t.TempDir()
+
+	err := os.WriteFile(tempDir+"/page.html", []byte(`Page`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "page.html",
+		SecurityTxt: &wellknown.SecurityTxt{
+			Contact: []string{"mailto:security@example.com"},
+			Expires: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		WellKnown: map[string]any{
+			"assetlinks.json": []any{map[string]any{"relation": []any{"delegate_permission/common.get_login_creds"}}},
+		},
+	}
+
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		expectedType string
+		expectedBody string
+	}{
+		{"security.txt", "/.well-known/security.txt", "text/plain; charset=utf-8", "Contact: mailto:security@example.com\n"},
+		{"security.txt with query", "/.well-known/security.txt?x=1", "text/plain; charset=utf-8", "Expires: 2030-01-01T00:00:00Z\n"},
+		{"assetlinks.json", "/.well-known/assetlinks.json", "application/json", `"delegate_permission/common.get_login_creds"`},
+		{"Unknown well-known document", "/.well-known/missing.json", "text/html; charset=utf-8", "Page"},
+		{"humans.txt not configured", "/humans.txt", "text/html; charset=utf-8", "Page"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+			req.RequestURI = tt.path
+			w := httptest.NewRecorder()
+
+			server.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Content-Type"); got != tt.expectedType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.expectedType)
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("body = %q, want it to contain %q", w.Body.String(), tt.expectedBody)
+			}
+		})
+	}
+}
+
+func TestServeHTTP_OutputPDF(t *testing.T) {
+	tempDir := t.TempDir()
+
+	err := os.WriteFile(tempDir+"/invoice.html", []byte(`<h1>Invoice {{.RequestURI}}</h1>`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "invoice.html",
+		Templates: []config.Template{
+			{Pattern: "^/invoices/", Template: "invoice.html", Output: config.OutputPDF},
+			{Pattern: "^/report", Template: "invoice.html", Output: config.OutputPDF, Filename: "Q3 report.pdf"},
+			{Pattern: "^/broken", Template: "invoice.html", Output: config.OutputPDF},
+		},
+		// A stand-in renderer that wraps the HTML in a PDF header
+		PDF: pdf.Renderer{Command: []string{"sh", "-c", "printf '%%PDF-1.4\\n'; cat"}},
+	}
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	tests := []struct {
+		name                string
+		path                string
+		expectedDisposition string
+	}{
+		{"Name from URL", "/invoices/2024-17", `attachment; filename=2024-17.pdf`},
+		{"Name from URL with extension", "/invoices/2024-17.html", `attachment; filename=2024-17.pdf`},
+		{"Configured name", "/report", `attachment; filename="Q3 report.pdf"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.RequestURI = tt.path
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %q", w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/pdf" {
+				t.Errorf("Content-Type = %q, want application/pdf", got)
+			}
+			if got := w.Header().Get("Content-Disposition"); got != tt.expectedDisposition {
+				t.Errorf("Content-Disposition = %q, want %q", got, tt.expectedDisposition)
+			}
+			if want := "%PDF-1.4\n<h1>Invoice " + tt.path + "</h1>"; w.Body.String() != want {
+				t.Errorf("body = %q, want %q", w.Body.String(), want)
+			}
+		})
+	}
+
+	t.Run("Renderer failure", func(t *testing.T) {
+		server.config.PDF.Command = []string{"sh", "-c", "echo oops >&2; exit 1"}
+		req := httptest.NewRequest("GET", "/broken", nil)
+		req.RequestURI = "/broken"
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("status = %d, want 500", w.Code)
+		}
+		if w.Header().Get("Content-Disposition") != "" {
+			t.Error("Content-Disposition set on error page")
+		}
+	})
+}
+
+func TestServeHTTP_OutputSpreadsheet(t *testing.T) {
+	tempDir := t.TempDir()
+
+	err := os.WriteFile(tempDir+"/report.html", []byte(`<table>{{range .Data.rows}}<tr><td>{{.name}}</td><td>{{.units}}</td></tr>{{end}}</table>`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "report.html",
+		Templates: []config.Template{
+			{Pattern: `^/report\.xlsx$`, Template: "report.html", Output: config.OutputXLSX},
+			{Pattern: `^/report\.ods$`, Template: "report.html", Output: config.OutputODS},
+		},
+		Data: map[string]any{"rows": []any{
+			map[string]any{"name": "Widgets", "units": 12},
+		}},
+	}
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	tests := []struct {
+		path         string
+		expectedType string
+		expectedName string
+	}{
+		{"/report.xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "report.xlsx"},
+		{"/report.ods", "application/vnd.oasis.opendocument.spreadsheet", "report.ods"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.RequestURI = tt.path
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Content-Type"); got != tt.expectedType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.expectedType)
+			}
+			if got := w.Header().Get("Content-Disposition"); got != "attachment; filename="+tt.expectedName {
+				t.Errorf("Content-Disposition = %q", got)
+			}
+			if !strings.HasPrefix(w.Body.String(), "PK") {
+				t.Errorf("body is not a zip archive: %q", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestServeHTTP_OutputVCF(t *testing.T) {
+	tempDir := t.TempDir()
+
+	card := `{{range .Data.team}}
+BEGIN:VCARD
+VERSION:4.0
+FN:{{vcardEscape .name}}
+ORG:{{vcardEscape .org}}
+END:VCARD
+{{end}}`
+	err := os.WriteFile(tempDir+"/team.vcf", []byte(card), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "team.vcf",
+		Templates: []config.Template{
+			{Pattern: "^/team/", Template: "team.vcf", Output: config.OutputVCF},
+		},
+		Data: map[string]any{"team": []any{
+			map[string]any{"name": "Ada \"The Countess\" Lovelace", "org": "Babbage & Co; R&D"},
+		}},
+	}
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/team/ada", nil)
+	req.RequestURI = "/team/ada"
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Content-Type"); got != "text/vcard; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != "attachment; filename=ada.vcf" {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	want := "BEGIN:VCARD\r\nVERSION:4.0\r\nFN:Ada \"The Countess\" Lovelace\r\nORG:Babbage & Co\\; R&D\r\nEND:VCARD\r\n"
+	if w.Body.String() != want {
+		t.Errorf("body = %q, want %q", w.Body.String(), want)
+	}
+}
+
+func TestServeHTTP_PrintTemplate(t *testing.T) {
+	tempDir := t.TempDir()
+
+	templates := map[string]string{
+		"article.html": `Article {{.RequestURI}} <link rel="alternate" media="print" href="{{.PrintURL}}">`,
+		"print.html":   `Print {{.RequestURI}}`,
+		"default.html": `Default`,
+	}
+	for name, content := range templates {
+		if err := os.WriteFile(tempDir+"/"+name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create template %s: %v", name, err)
+		}
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "default.html",
+		Templates: []config.Template{
+			{Pattern: `^/article/\d+$`, Template: "article.html", PrintTemplate: "print.html"},
+			{Pattern: "^/other", Template: "default.html"},
+		},
+	}
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		expectedBody string
+		expectedLink string
+		noindex      bool
+	}{
+		{"Page", "/article/7", `Article /article/7 <link rel="alternate" media="print" href="/article/7?print=1">`, `</article/7?print=1>; rel="alternate"; media="print"`, false},
+		{"Print query", "/article/7?print=1", "Print /article/7?print=1", `</article/7>; rel="canonical"`, true},
+		{"Print suffix", "/article/7/print", "Print /article/7/print", `</article/7>; rel="canonical"`, true},
+		{"No print template", "/other?print=1", "Default", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.RequestURI = tt.path
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+
+			if w.Body.String() != tt.expectedBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.expectedBody)
+			}
+			if got := w.Header().Get("Link"); got != tt.expectedLink {
+				t.Errorf("Link = %q, want %q", got, tt.expectedLink)
+			}
+			if got := w.Header().Get("X-Robots-Tag") == "noindex"; got != tt.noindex {
+				t.Errorf("noindex = %v, want %v", got, tt.noindex)
+			}
+		})
+	}
+}
+
+func TestServeHTTP_Theme(t *testing.T) {
+	tempDir := t.TempDir()
+
+	err := os.WriteFile(tempDir+"/page.html", []byte(`<html data-theme="{{.Theme.Name}}"><a href="{{.Theme.SetURL "dark" .RequestURI}}">`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "page.html",
+		Theme:           theme.Settings{Enabled: true},
+		Cache:           config.Cache{Responses: time.Minute},
+	}
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	get := func(path string, cookie string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RequestURI = path
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/page", "")
+	if !strings.Contains(w.Body.String(), `data-theme="light"`) {
+		t.Errorf("body = %q, want the light theme", w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `href="/_theme?return=%2Fpage&amp;set=dark"`) {
+		t.Errorf("body = %q, want a link to the theme endpoint", w.Body.String())
+	}
+	if got := w.Header().Get("Accept-CH"); got != theme.HintHeader {
+		t.Errorf("Accept-CH = %q", got)
+	}
+
+	w = get("/_theme?set=dark&return=/page", "")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/page" {
+		t.Fatalf("theme endpoint answered %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+
+	// The cached light page must not be served to a dark theme request
+	w = get("/page", w.Header().Get("Set-Cookie"))
+	if !strings.Contains(w.Body.String(), `data-theme="dark"`) {
+		t.Errorf("body = %q, want the dark theme", w.Body.String())
+	}
+}
+
+func TestServeHTTP_Consent(t *testing.T) {
+	tempDir := t.TempDir()
+
+	page := `{{if not .Consent.Decided}}<div id="banner"><a href="{{.Consent.SetURL "all" .RequestURI}}">Accept</a></div>{{end}}` +
+		`{{if .Consent.Allows "analytics"}}<script src="/stats.js"></script>{{end}}`
+	err := os.WriteFile(tempDir+"/page.html", []byte(page), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "page.html",
+		Consent:         consent.Settings{Enabled: true},
+		Cache:           config.Cache{Responses: time.Minute},
+	}
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	get := func(path string, cookie string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RequestURI = path
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/page", "")
+	want := `<div id="banner"><a href="/_consent?return=%2Fpage&amp;set=all">Accept</a></div>`
+	if w.Body.String() != want {
+		t.Errorf("body = %q, want %q", w.Body.String(), want)
+	}
+
+	w = get("/_consent?set=all&return=/page", "")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/page" {
+		t.Fatalf("consent endpoint answered %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+
+	// The cached page with the banner must not be served after consenting
+	w = get("/page", w.Header().Get("Set-Cookie"))
+	if got := w.Body.String(); got != `<script src="/stats.js"></script>` {
+		t.Errorf("body = %q after consenting, want the analytics script only", got)
+	}
+}
+
+func TestServeHTTP_Fragment(t *testing.T) {
+	tempDir := t.TempDir()
+
+	page := `<html><body>{{block "results" .}}<ul><li>{{.RequestURI}}</li></ul>{{end}}</body></html>`
+	err := os.WriteFile(tempDir+"/search.html", []byte(page), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "search.html",
+		Templates: []config.Template{
+			{Pattern: "^/search", Template: "search.html", Fragment: "results"},
+		},
+	}
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	full := "<html><body><ul><li>/search</li></ul></body></html>"
+	partial := "<ul><li>/search</li></ul>"
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		expectedBody string
+	}{
+		{"Full page", nil, full},
+		{"htmx request", map[string]string{"HX-Request": "true"}, partial},
+		{"Boosted htmx request", map[string]string{"HX-Request": "true", "HX-Boosted": "true"}, full},
+		{"Turbo Frame request", map[string]string{"Turbo-Frame": "results"}, partial},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/search", nil)
+			req.RequestURI = "/search"
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+
+			if w.Body.String() != tt.expectedBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.expectedBody)
+			}
+			if got := w.Header().Get("Vary"); !strings.Contains(got, "HX-Request") {
+				t.Errorf("Vary = %q, want HX-Request", got)
+			}
+		})
+	}
+}
+
+func TestServeHTTP_Authorization(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"page.html":      `Page`,
+		"forbidden.html": `No access for {{.SERVER.REMOTE_USER}}`,
+		"groups":         "# intranet groups\nstaff: ann bob\nadmins: ann\n",
+	} {
+		if err := os.WriteFile(tempDir+"/"+name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "page.html",
+		Authz:           config.Authz{GroupFile: "groups"},
+		Templates: []config.Template{
+			{Pattern: "^/admin/", Template: "page.html", RequireGroup: []string{"admins"}},
+			{Pattern: "^/staff/", Template: "page.html", RequireUser: []string{"carol"}, RequireGroup: []string{"staff"}},
+			{Pattern: "^/members/", Template: "page.html", RequireUser: []string{config.AnyUser}},
+		},
+	}
+
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	tests := []struct {
+		user           string
+		path           string
+		expectedStatus int
+	}{
+		{"", "/public", http.StatusOK},
+		{"", "/members/", http.StatusForbidden},
+		{"dave", "/members/", http.StatusOK},
+		{"ann", "/admin/", http.StatusOK},
+		{"bob", "/admin/", http.StatusForbidden},
+		{"bob", "/staff/", http.StatusOK},
+		{"carol", "/staff/", http.StatusOK},
+		{"dave", "/staff/", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.user+" "+tt.path, func(t *testing.T) {
+			t.Setenv("REMOTE_USER", tt.user)
+			req := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+			req.RequestURI = tt.path
+			w := httptest.NewRecorder()
+
+			server.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("ServeHTTP() status = %d, want %d", w.Code, tt.expectedStatus)
+			}
+		})
+	}
+
+	cfg.Authz.ForbiddenTemplate = "forbidden.html"
+	server, err = New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	t.Setenv("REMOTE_USER", "bob")
+	req := httptest.NewRequest("GET", "http://example.com/admin/", nil)
+	req.RequestURI = "/admin/"
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden || w.Body.String() != "No access for bob" {
+		t.Errorf("ServeHTTP() = %d %q, want 403 from the forbidden template", w.Code, w.Body.String())
+	}
+}
+
+func TestServeHTTP_Defaults(t *testing.T) {
+	tempDir := t.TempDir()
+
+	if err := os.WriteFile(tempDir+"/page.txt", []byte(`Hello {{.RequestURI}}`), 0644); err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "page.txt",
+		Defaults: config.Defaults{
+			ContentType:  "text/plain",
+			Charset:      "iso-8859-1",
+			CacheControl: "public, max-age=300",
+			Headers:      map[string]string{"X-Frame-Options": "DENY"},
+		},
+	}
+
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "http://example.com/page", nil)
+	req.RequestURI = "/page"
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	expected := map[string]string{
+		"Content-Type":    "text/plain; charset=iso-8859-1",
+		"Cache-Control":   "public, max-age=300",
+		"X-Frame-Options": "DENY",
+	}
+	for name, want := range expected {
+		if got := w.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	// Error pages keep the other headers but are not cached like pages
+	cfg.Templates = []config.Template{
+		{Pattern: "^/gone$", Content: "Gone", Status: http.StatusNotFound},
+		{Pattern: "^/removed$", Content: "Removed", Status: http.StatusGone, Headers: map[string]string{"Cache-Control": "public, max-age=60"}},
+	}
+	server, err = New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	for path, want := range map[string]string{"/gone": "", "/removed": "public, max-age=60"} {
+		req = httptest.NewRequest("GET", "http://example.com"+path, nil)
+		req.RequestURI = path
+		w = httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		if w.Code < 400 {
+			t.Errorf("%s: status = %d, want an error", path, w.Code)
+		}
+		if got := w.Header().Get("Cache-Control"); got != want {
+			t.Errorf("%s: Cache-Control = %q, want %q", path, got, want)
+		}
+		if got := w.Header().Get("X-Frame-Options"); got != "DENY" {
+			t.Errorf("%s: X-Frame-Options = %q, want DENY", path, got)
+		}
+	}
+}
+
+func TestServeHTTP_RouteHeaders(t *testing.T) {
+	tempDir := t.TempDir()
+
+	if err := os.WriteFile(tempDir+"/page.html", []byte(`Page`), 0644); err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "page.html",
+		Defaults:        config.Defaults{CacheControl: "public, max-age=300"},
+		Templates: []config.Template{
+			{Pattern: "^/robots\\.txt$", Content: "User-agent: *", Headers: map[string]string{
+				"Content-Type":  "text/plain; charset=utf-8",
+				"Cache-Control": "public, max-age=86400",
+			}},
+			{Pattern: "^/private/", Template: "page.html", Headers: map[string]string{"X-Robots-Tag": "noindex"}},
+		},
+	}
+
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected map[string]string
+	}{
+		{"/robots.txt", map[string]string{"Content-Type": "text/plain; charset=utf-8", "Cache-Control": "public, max-age=86400"}},
+		{"/private/a", map[string]string{"Content-Type": "text/html; charset=utf-8", "Cache-Control": "public, max-age=300", "X-Robots-Tag": "noindex"}},
+		{"/other", map[string]string{"Cache-Control": "public, max-age=300", "X-Robots-Tag": ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+			req.RequestURI = tt.path
+			w := httptest.NewRecorder()
+
+			server.ServeHTTP(w, req)
+
+			for name, want := range tt.expected {
+				if got := w.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestServeHTTP_RouteStatus(t *testing.T) {
+	tempDir := t.TempDir()
+
+	if err := os.WriteFile(tempDir+"/page.html", []byte(`Page {{.RequestURI}}`), 0644); err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "page.html",
+		Cache:           config.Cache{Responses: time.Minute},
+		Templates: []config.Template{
+			{Pattern: "^/gone/", Template: "page.html", Status: http.StatusGone},
+			{Pattern: "^/old$", Content: "Moved", Status: http.StatusMovedPermanently, Headers: map[string]string{"Location": "/new"}},
+			{Pattern: "^/new$", Template: "page.html"},
+			{Pattern: "^/", Template: "page.html", Status: http.StatusNotFound},
+		},
+	}
+
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	tests := []struct {
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"/gone/page", http.StatusGone, "Page /gone/page"},
+		{"/old", http.StatusMovedPermanently, "Moved"},
+		{"/new", http.StatusOK, "Page /new"},
+		{"/missing", http.StatusNotFound, "Page /missing"},
+		{"/missing", http.StatusNotFound, "Page /missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+			req.RequestURI = tt.path
+			w := httptest.NewRecorder()
+
+			server.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus || w.Body.String() != tt.expectedBody {
+				t.Errorf("ServeHTTP() = %d %q, want %d %q", w.Code, w.Body.String(), tt.expectedStatus, tt.expectedBody)
+			}
+		})
+	}
+}
+
+func TestServeHTTP_BasePath(t *testing.T) {
+	tempDir := t.TempDir()
+
+	templates := map[string]string{
+		"page.html":    `Page {{.RequestURI}} <a href="{{.BasePath}}/">home</a>`,
+		"article.html": `Article {{.RequestURI}}`,
+		"print.html":   `Print`,
+	}
+	for name, content := range templates {
+		if err := os.WriteFile(tempDir+"/"+name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "page.html",
+		BasePath:        "/cgi-bin/tmpl.cgi/",
+		Templates: []config.Template{
+			{Pattern: "/article/", PatternType: config.PatternPrefix, Template: "article.html", PrintTemplate: "print.html"},
+		},
+	}
+
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	tests := []struct {
+		path         string
+		expectedBody string
+		expectedLink string
+	}{
+		{"/cgi-bin/tmpl.cgi/article/1", "Article /cgi-bin/tmpl.cgi/article/1", `</cgi-bin/tmpl.cgi/article/1?print=1>; rel="alternate"; media="print"`},
+		{"/cgi-bin/tmpl.cgi/article/1?print=1", "Print", `</cgi-bin/tmpl.cgi/article/1>; rel="canonical"`},
+		{"/cgi-bin/tmpl.cgi", `Page /cgi-bin/tmpl.cgi <a href="/cgi-bin/tmpl.cgi/">home</a>`, ""},
+		{"/cgi-bin/tmpl.cgix/article/1", `Page /cgi-bin/tmpl.cgix/article/1 <a href="/cgi-bin/tmpl.cgi/">home</a>`, ""},
+		{"/article/1", "Article /article/1", `</article/1?print=1>; rel="alternate"; media="print"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+			req.RequestURI = tt.path
+			w := httptest.NewRecorder()
+
+			server.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK || w.Body.String() != tt.expectedBody {
+				t.Errorf("ServeHTTP() = %d %q, want 200 %q", w.Code, w.Body.String(), tt.expectedBody)
+			}
+			if link := w.Header().Get("Link"); link != tt.expectedLink {
+				t.Errorf("Link = %q, want %q", link, tt.expectedLink)
+			}
+		})
+	}
+}
+
+func TestServeHTTP_Pipeline(t *testing.T) {
+	tempDir := t.TempDir()
+
+	templates := map[string]string{
+		"search.html": `{{block "results" .}}<ul><li>{{.RequestURI}}</li></ul>{{end}}`,
+		"layout.html": `<html><body>{{.Content}}</body></html>`,
+	}
+	for name, content := range templates {
+		if err := os.WriteFile(tempDir+"/"+name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+	}
+
+	cfg := &config.Config{
+		ConfigFilePath:  tempDir + "/config.yaml",
+		DefaultTemplate: "search.html",
+		Pipelines: map[string][]config.PipelineStep{
+			"page": {{Layout: "layout.html"}},
+		},
+		Templates: []config.Template{
+			{Pattern: "^/search", Template: "search.html", Fragment: "results", Pipeline: "page"},
+		},
+	}
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		headers      map[string]string
+		expectedBody string
+	}{
+		{"Full page", "/search", nil, "<html><body><ul><li>/search</li></ul></body></html>"},
+		{"Fragment skips layouts", "/search", map[string]string{"HX-Request": "true"}, "<ul><li>/search</li></ul>"},
+		{"No pipeline", "/other", nil, "<ul><li>/other</li></ul>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.RequestURI = tt.path
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK || w.Body.String() != tt.expectedBody {
+				t.Errorf("ServeHTTP() = %d %q, want 200 %q", w.Code, w.Body.String(), tt.expectedBody)
+			}
+		})
+	}
+}
+
+func TestStartDataRefresh(t *testing.T) {
+	var oldHits, newHits atomic.Int32
+	oldSrc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		oldHits.Add(1)
+		_, _ = w.Write([]byte(`{"name": "old"}`))
+	}))
+	defer oldSrc.Close()
+	newSrc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		newHits.Add(1)
+		_, _ = w.Write([]byte(`{"name": "new"}`))
+	}))
+	defer newSrc.Close()
+
+	dir := t.TempDir()
+	writeConfig := func(url string) {
+		content := `default_template: "page.html"
+data: {site: Example}
+data_sources:
+  - name: live
+    url: ` + url + `
+    cache_dir: ` + filepath.Join(t.TempDir(), "cache") + `
+    refresh: 1h
+`
+		if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+	writeConfig(oldSrc.URL)
+	if err := os.WriteFile(filepath.Join(dir, "page.html"), []byte(`{{with .Data.live}}{{.name}}{{end}}`), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	cfg, err := config.ParseConfigFile(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	server, _ := New(cfg)
+	server.StartDataRefresh()
+	defer func() { server.stopRefresh() }()
+
+	waitFor := func(hits *atomic.Int32) {
+		deadline := time.Now().Add(2 * time.Second)
+		for hits.Load() == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("data source was not loaded in the background")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	body := func() string {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RequestURI = "/"
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	waitFor(&oldHits)
+	for i := 0; i < 3; i++ {
+		if got := body(); got != "old" {
+			t.Errorf("body = %q, want old", got)
+		}
+	}
+	if n := oldHits.Load(); n != 1 {
+		t.Errorf("data source fetched %d times, want once in the background", n)
+	}
+
+	// A reload refreshes the new configuration's sources
+	writeConfig(newSrc.URL)
+	if err = server.Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	waitFor(&newHits)
+	if got := body(); got != "new" {
+		t.Errorf("body = %q after reload, want new", got)
+	}
+}
+
+func TestStartDataRefresh_ReloadRace(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.yaml": `default_template: "page.html"
+data: {site: Example}
+data_sources:
+  - name: keys
+    type: secrets
+    path: secrets
+    refresh: 1s
+`,
+		"page.html":   `{{with .Data.keys}}{{.api}}{{end}}`,
+		"secrets/api": "s3cr3t-api-key",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("MkdirAll() error: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+	cfg, err := config.ParseConfigFile(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	server, _ := New(cfg)
+
+	// Reload while the first refresh is loading the source; run with -race
+	server.StartDataRefresh()
+	for i := 0; i < 3; i++ {
+		if err = server.Reload(); err != nil {
+			t.Fatalf("Reload() error: %v", err)
+		}
+	}
+	server.mu.RLock()
+	stop := server.stopRefresh
+	server.mu.RUnlock()
+	stop()
+}
+
 // TestRun is tricky to test directly since it involves network operations
 // We'll test the logic paths but not the actual network binding
 func TestRun_CGIDetection(t *testing.T) {
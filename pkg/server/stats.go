@@ -0,0 +1,143 @@
+package server
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// stats counts the requests handled by a long-lived server
+type stats struct {
+	started  time.Time
+	requests atomic.Int64
+	inFlight atomic.Int64
+	errors   atomic.Int64
+	notFound atomic.Int64
+	reloads  atomic.Int64
+
+	timeouts  atomic.Int64
+	oversized atomic.Int64
+
+	stable variantStats
+	canary variantStats
+
+	mirrored     atomic.Int64
+	mirrorErrors atomic.Int64
+}
+
+// Stats is a point-in-time copy of the server counters
+type Stats struct {
+	Uptime   string `json:"uptime"`
+	Requests int64  `json:"requests"`
+	InFlight int64  `json:"in_flight"`
+	Errors   int64  `json:"errors"`
+	NotFound int64  `json:"not_found"`
+	Reloads  int64  `json:"reloads"`
+
+	RenderTimeouts  int64 `json:"render_timeouts"`
+	RenderOversized int64 `json:"render_oversized"`
+
+	Mirrored     int64 `json:"mirrored"`
+	MirrorErrors int64 `json:"mirror_errors"`
+
+	TemplateCache CacheStats `json:"template_cache"`
+	ResponseCache CacheStats `json:"response_cache"`
+
+	Stable *VariantStats `json:"stable,omitempty"`
+	Canary *VariantStats `json:"canary,omitempty"`
+}
+
+// CacheStats describes the usage of a cache
+type CacheStats struct {
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	Limit     int64 `json:"limit"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+}
+
+// statsSnapshot returns the current counter values
+func (s *CGIServer) statsSnapshot() Stats {
+	st := &s.stats
+	out := Stats{
+		Uptime:   time.Since(st.started).Round(time.Second).String(),
+		Requests: st.requests.Load(),
+		InFlight: st.inFlight.Load(),
+		Errors:   st.errors.Load(),
+		NotFound: st.notFound.Load(),
+		Reloads:  st.reloads.Load(),
+
+		RenderTimeouts:  st.timeouts.Load(),
+		RenderOversized: st.oversized.Load(),
+
+		Mirrored:     st.mirrored.Load(),
+		MirrorErrors: st.mirrorErrors.Load(),
+
+		TemplateCache: cacheStats(&s.cache.entries, &s.cache.hits, &s.cache.misses),
+		ResponseCache: cacheStats(&s.pages.entries, &s.pages.hits, &s.pages.misses),
+	}
+	s.mu.RLock()
+	hasCanary := s.canary != nil
+	s.mu.RUnlock()
+	if hasCanary {
+		stable, canary := st.stable.snapshot(), st.canary.snapshot()
+		out.Stable, out.Canary = &stable, &canary
+	}
+	return out
+}
+
+// cacheStats reports the usage of an LRU cache
+func cacheStats[V any](c *lru[V], hits, misses *atomic.Int64) CacheStats {
+	entries, used, limit := c.usage()
+	return CacheStats{
+		Entries:   entries,
+		Bytes:     used,
+		Limit:     limit,
+		Hits:      hits.Load(),
+		Misses:    misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
+
+// record counts a finished request by its status
+func (st *stats) record(status int) {
+	switch {
+	case status == http.StatusNotFound:
+		st.notFound.Add(1)
+	case status >= 500:
+		st.errors.Add(1)
+	}
+}
+
+// statusWriter remembers the status code written through it
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher so streamed pages reach the client
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
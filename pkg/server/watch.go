@@ -0,0 +1,108 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/config"
+)
+
+// fingerprint identifies the current state of a file, or of the config
+// files in a directory. Resolving symlinks catches the atomic ..data symlink
+// swap used by Kubernetes ConfigMap and Secret volumes, where the file's own
+// path never changes.
+func fingerprint(path string) string {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return ""
+	}
+	fi, err := os.Stat(resolved)
+	if err != nil {
+		return ""
+	}
+	if !fi.IsDir() {
+		return fmt.Sprintf("%s:%d:%d", resolved, fi.Size(), fi.ModTime().UnixNano())
+	}
+	var b strings.Builder
+	entries, _ := os.ReadDir(path)
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".yaml", ".yml", ".json", ".toml":
+			b.WriteString(fingerprint(filepath.Join(path, e.Name())) + ";")
+		}
+	}
+	return b.String()
+}
+
+// templatesFingerprint identifies the current state of every file in the
+// given template directories, so that edited and newly added templates are
+// both noticed
+func templatesFingerprint(dirs []string) string {
+	var b strings.Builder
+	for _, dir := range dirs {
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if !e.IsDir() {
+				b.WriteString(fingerprint(filepath.Join(dir, e.Name())) + ";")
+			}
+		}
+	}
+	return b.String()
+}
+
+// inputsFingerprint identifies the current state of the templates and data
+// files a config reads
+func inputsFingerprint(cfg *config.Config) string {
+	var b strings.Builder
+	// A loaded config keeps the directory a template_dir symlink pointed at,
+	// so the symlink is followed again to notice it being switched
+	if dir := cfg.TemplateDirPath(); dir != "" {
+		resolved, _ := filepath.EvalSymlinks(dir)
+		b.WriteString(resolved + "|")
+	}
+	b.WriteString(templatesFingerprint(cfg.TemplateDirs()))
+	for _, path := range cfg.DataFilePaths() {
+		b.WriteString(fingerprint(path) + ";")
+	}
+	return b.String()
+}
+
+// watchConfig polls the config file and template directories and reloads
+// when they change, until stop is closed. A config that fails to load or
+// validate is logged and not retried until the files change again.
+func (s *CGIServer) watchConfig(interval time.Duration, stop <-chan struct{}) {
+	s.mu.RLock()
+	last := s.loadedFP
+	s.mu.RUnlock()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		cfg := s.snapshot()
+		configFP := fingerprint(cfg.ConfigFilePath)
+		if configFP == "" {
+			continue
+		}
+		fp := configFP + "|" + inputsFingerprint(&cfg)
+		if fp == last {
+			continue
+		}
+		last = fp
+		if err := s.Reload(); err != nil {
+			log.Printf("reloading changed config: %v", err)
+			continue
+		}
+		s.mu.RLock()
+		last = s.loadedFP
+		s.mu.RUnlock()
+		log.Printf("Reloaded config %s (version %s)", cfg.ConfigFilePath, s.snapshot().Version)
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/config"
+)
+
+func TestWatchConfig_SymlinkSwap(t *testing.T) {
+	// Lay out a directory the way Kubernetes projects a ConfigMap:
+	// config.yaml -> ..data/config.yaml, ..data -> ..v1
+	dir := t.TempDir()
+	mtime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	writeVersion := func(name, defaultTemplate string) {
+		vdir := filepath.Join(dir, name)
+		if err := os.Mkdir(vdir, 0755); err != nil {
+			t.Fatalf("Mkdir() error: %v", err)
+		}
+		files := map[string]string{
+			"config.yaml": `default_template: "` + defaultTemplate + `"`,
+			"a.html":      `A`,
+			"b.html":      `B`,
+		}
+		for fname, content := range files {
+			p := filepath.Join(vdir, fname)
+			if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+				t.Fatalf("WriteFile() error: %v", err)
+			}
+			// Same size and mtime in both versions: only the symlink differs
+			_ = os.Chtimes(p, mtime, mtime)
+		}
+	}
+	writeVersion("..v1", "a.html")
+	if err := os.Symlink("..v1", filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("Symlink() error: %v", err)
+	}
+	for _, fname := range []string{"config.yaml", "a.html", "b.html"} {
+		if err := os.Symlink(filepath.Join("..data", fname), filepath.Join(dir, fname)); err != nil {
+			t.Fatalf("Symlink() error: %v", err)
+		}
+	}
+
+	cfg, err := config.ParseConfigFile(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	server, _ := New(cfg)
+	oldVersion := server.snapshot().Version
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go server.watchConfig(10*time.Millisecond, stop)
+
+	writeVersion("..v2", "b.html")
+	if err = os.Symlink("..v2", filepath.Join(dir, "..data_tmp")); err != nil {
+		t.Fatalf("Symlink() error: %v", err)
+	}
+	if err = os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+		t.Fatalf("Rename() error: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.snapshot().DefaultTemplate != "b.html" {
+		if time.Now().After(deadline) {
+			t.Fatal("config was not reloaded after the symlink swap")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v := server.snapshot().Version; v == oldVersion || v == "" {
+		t.Errorf("Version = %q after reload, was %q", v, oldVersion)
+	}
+}
+
+func TestWatchConfig_Templates(t *testing.T) {
+	dir := t.TempDir()
+	tmplDir := filepath.Join(dir, "templates")
+	if err := os.Mkdir(tmplDir, 0755); err != nil {
+		t.Fatalf("Mkdir() error: %v", err)
+	}
+	page := filepath.Join(tmplDir, "page.html")
+	// Write files atomically so the watcher never sees a half-written one
+	write := func(path, content string, mtime time.Time) {
+		tmp := filepath.Join(dir, ".tmp")
+		if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+		_ = os.Chtimes(tmp, mtime, mtime)
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatalf("Rename() error: %v", err)
+		}
+	}
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	write(filepath.Join(dir, "config.yaml"), `default_template: "templates/page.html"
+cache: {templates: true}`, base)
+	write(page, `v1`, base)
+
+	cfg, err := config.ParseConfigFile(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	server, _ := New(cfg)
+	stop := make(chan struct{})
+	defer close(stop)
+	go server.watchConfig(10*time.Millisecond, stop)
+
+	waitReloads := func(n int64) {
+		deadline := time.Now().Add(2 * time.Second)
+		for server.stats.reloads.Load() < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("reloads = %d, want %d", server.stats.reloads.Load(), n)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	body := func() string {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RequestURI = "/"
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	if got := body(); got != "v1" {
+		t.Fatalf("body = %q, want v1", got)
+	}
+
+	write(page, `v2`, base.Add(time.Second))
+	waitReloads(1)
+	if got := body(); got != "v2" {
+		t.Errorf("body = %q after template edit, want v2", got)
+	}
+
+	// A template that no longer parses is rejected and the cached copy stays
+	write(page, `{{ if }}`, base.Add(2*time.Second))
+	time.Sleep(100 * time.Millisecond)
+	if n := server.stats.reloads.Load(); n != 1 {
+		t.Errorf("reloads = %d after broken template, want 1", n)
+	}
+	if got := body(); got != "v2" {
+		t.Errorf("body = %q after broken template, want v2", got)
+	}
+
+	write(page, `v3`, base.Add(3*time.Second))
+	waitReloads(2)
+	if got := body(); got != "v3" {
+		t.Errorf("body = %q after fix, want v3", got)
+	}
+
+	// New files in a template directory are noticed as well
+	write(filepath.Join(tmplDir, "partial.html"), `p`, base)
+	waitReloads(3)
+}
+
+func TestWatchConfig_DataFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(path, content string, mtime time.Time) {
+		tmp := filepath.Join(dir, ".tmp")
+		if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+		_ = os.Chtimes(tmp, mtime, mtime)
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatalf("Rename() error: %v", err)
+		}
+	}
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	write(filepath.Join(dir, "config.yaml"), `default_template: "page.html"
+data_files: [site.yaml]`, base)
+	write(filepath.Join(dir, "page.html"), `{{.Data.site.name}}`, base)
+	write(filepath.Join(dir, "site.yaml"), `name: v1`, base)
+
+	cfg, err := config.ParseConfigFile(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("ParseConfigFile() error: %v", err)
+	}
+	server, _ := New(cfg)
+	stop := make(chan struct{})
+	defer close(stop)
+	go server.watchConfig(10*time.Millisecond, stop)
+
+	write(filepath.Join(dir, "site.yaml"), `name: v2`, base.Add(time.Second))
+	deadline := time.Now().Add(2 * time.Second)
+	for server.stats.reloads.Load() < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("data file edit was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RequestURI = "/"
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if got := w.Body.String(); got != "v2" {
+		t.Errorf("body = %q after data file edit, want v2", got)
+	}
+}
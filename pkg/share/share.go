@@ -0,0 +1,132 @@
+// Package share builds social media share links with correctly encoded
+// page URLs and titles.
+package share
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Link is a share link for one platform
+type Link struct {
+	Platform string
+	Name     string
+	URL      string
+}
+
+// platform describes how to build a share URL for one service
+type platform struct {
+	name  string
+	build func(pageURL, title string) string
+}
+
+// query encodes the given key/value pairs, preserving their order
+func query(base string, kv ...string) string {
+	var b strings.Builder
+	b.WriteString(base)
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i == 0 {
+			b.WriteByte('?')
+		} else {
+			b.WriteByte('&')
+		}
+		b.WriteString(kv[i])
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(kv[i+1]))
+	}
+	return b.String()
+}
+
+// mailtoEscape percent-encodes s for a mailto URI, where RFC 6068 requires
+// spaces to be %20 rather than +
+func mailtoEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// platforms maps platform IDs to their URL builders
+var platforms = map[string]platform{
+	"bluesky": {"Bluesky", func(u, t string) string {
+		return query("https://bsky.app/intent/compose", "text", strings.TrimSpace(t+" "+u))
+	}},
+	"email": {"Email", func(u, t string) string {
+		return "mailto:?subject=" + mailtoEscape(t) + "&body=" + mailtoEscape(u)
+	}},
+	"facebook": {"Facebook", func(u, t string) string {
+		return query("https://www.facebook.com/sharer/sharer.php", "u", u)
+	}},
+	"hackernews": {"Hacker News", func(u, t string) string {
+		return query("https://news.ycombinator.com/submitlink", "u", u, "t", t)
+	}},
+	"linkedin": {"LinkedIn", func(u, t string) string {
+		return query("https://www.linkedin.com/sharing/share-offsite/", "url", u)
+	}},
+	"mastodon": {"Mastodon", func(u, t string) string {
+		return query("https://mastodonshare.com/", "text", t, "url", u)
+	}},
+	"reddit": {"Reddit", func(u, t string) string {
+		return query("https://www.reddit.com/submit", "url", u, "title", t)
+	}},
+	"telegram": {"Telegram", func(u, t string) string {
+		return query("https://t.me/share/url", "url", u, "text", t)
+	}},
+	"twitter": {"X (Twitter)", func(u, t string) string {
+		return query("https://twitter.com/intent/tweet", "url", u, "text", t)
+	}},
+	"whatsapp": {"WhatsApp", func(u, t string) string {
+		return query("https://wa.me/", "text", strings.TrimSpace(t+" "+u))
+	}},
+}
+
+// DefaultPlatforms are enabled when no explicit list is configured
+var DefaultPlatforms = []string{"twitter", "facebook", "linkedin", "reddit", "email"}
+
+// Builder creates share links for a set of enabled platforms
+type Builder struct {
+	Enabled []string
+}
+
+// enabled returns the configured platforms, or the defaults
+func (b *Builder) enabled() []string {
+	if len(b.Enabled) == 0 {
+		return DefaultPlatforms
+	}
+	return b.Enabled
+}
+
+// Validate checks that every enabled platform is known
+func (b *Builder) Validate() error {
+	for _, id := range b.enabled() {
+		if _, ok := platforms[id]; !ok {
+			return fmt.Errorf("unknown share platform '%s'", id)
+		}
+	}
+	return nil
+}
+
+// URL returns the share URL for a single platform
+func (b *Builder) URL(id, pageURL, title string) (string, error) {
+	for _, e := range b.enabled() {
+		if e == id {
+			p, ok := platforms[id]
+			if !ok {
+				return "", fmt.Errorf("unknown share platform '%s'", id)
+			}
+			return p.build(pageURL, title), nil
+		}
+	}
+	return "", fmt.Errorf("share platform '%s' is not enabled", id)
+}
+
+// Links returns share links for all enabled platforms, in configured order
+func (b *Builder) Links(pageURL, title string) ([]Link, error) {
+	links := []Link{}
+	for _, id := range b.enabled() {
+		p, ok := platforms[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown share platform '%s'", id)
+		}
+		links = append(links, Link{Platform: id, Name: p.name, URL: p.build(pageURL, title)})
+	}
+	return links, nil
+}
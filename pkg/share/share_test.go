@@ -0,0 +1,84 @@
+package share
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBuilder_URL(t *testing.T) {
+	b := &Builder{}
+	pageURL := "https://example.com/blog/1?a=b&c=d"
+	title := "Tips & Tricks: 100% #golang"
+
+	tests := []struct {
+		platform string
+		prefix   string
+		params   map[string]string
+	}{
+		{"twitter", "https://twitter.com/intent/tweet?", map[string]string{"url": pageURL, "text": title}},
+		{"facebook", "https://www.facebook.com/sharer/sharer.php?", map[string]string{"u": pageURL}},
+		{"linkedin", "https://www.linkedin.com/sharing/share-offsite/?", map[string]string{"url": pageURL}},
+		{"reddit", "https://www.reddit.com/submit?", map[string]string{"url": pageURL, "title": title}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			got, err := b.URL(tt.platform, pageURL, title)
+			if err != nil {
+				t.Fatalf("URL() error: %v", err)
+			}
+			if !strings.HasPrefix(got, tt.prefix) {
+				t.Errorf("URL() = %s, want prefix %s", got, tt.prefix)
+			}
+			u, err := url.Parse(got)
+			if err != nil {
+				t.Fatalf("URL() is not parseable: %v", err)
+			}
+			for k, v := range tt.params {
+				if u.Query().Get(k) != v {
+					t.Errorf("param %s = %q, want %q", k, u.Query().Get(k), v)
+				}
+			}
+		})
+	}
+}
+
+func TestBuilder_URL_Email(t *testing.T) {
+	b := &Builder{Enabled: []string{"email"}}
+	got, err := b.URL("email", "https://example.com/?a=1&b=2", "Fish & Chips")
+	if err != nil {
+		t.Fatalf("URL() error: %v", err)
+	}
+	want := "mailto:?subject=Fish%20%26%20Chips&body=https%3A%2F%2Fexample.com%2F%3Fa%3D1%26b%3D2"
+	if got != want {
+		t.Errorf("URL() = %s, want %s", got, want)
+	}
+}
+
+func TestBuilder_Disabled(t *testing.T) {
+	b := &Builder{Enabled: []string{"reddit"}}
+	if _, err := b.URL("twitter", "https://example.com/", ""); err == nil {
+		t.Error("URL() for disabled platform should return error")
+	}
+}
+
+func TestBuilder_Links(t *testing.T) {
+	b := &Builder{Enabled: []string{"mastodon", "hackernews"}}
+	links, err := b.Links("https://example.com/", "Hello")
+	if err != nil {
+		t.Fatalf("Links() error: %v", err)
+	}
+	if len(links) != 2 || links[0].Platform != "mastodon" || links[1].Name != "Hacker News" {
+		t.Errorf("Links() = %+v", links)
+	}
+}
+
+func TestBuilder_Validate(t *testing.T) {
+	if err := (&Builder{}).Validate(); err != nil {
+		t.Errorf("Validate() with defaults: %v", err)
+	}
+	if err := (&Builder{Enabled: []string{"myspace"}}).Validate(); err == nil {
+		t.Error("Validate() with unknown platform should return error")
+	}
+}
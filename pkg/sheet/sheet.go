@@ -0,0 +1,181 @@
+// Package sheet turns the HTML tables of a rendered page into spreadsheet
+// files (Office Open XML .xlsx and OpenDocument .ods), so that a template
+// shapes a report once and it can be downloaded for Excel or LibreOffice.
+package sheet
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Cell is one spreadsheet cell
+type Cell struct {
+	Value  string
+	Number bool
+	Header bool
+}
+
+// Sheet is one worksheet, built from one HTML table
+type Sheet struct {
+	Name string
+	Rows [][]Cell
+}
+
+// maxColspan bounds the empty cells added for a colspan attribute
+const maxColspan = 1000
+
+// numberPattern matches cell text that is stored as a number. Values with
+// leading zeros, such as postal codes, stay text.
+var numberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?$`)
+
+// ParseHTML reads the tables of an HTML document. Each table becomes a sheet
+// named by its data-sheet attribute or caption. Cells holding plain decimal
+// numbers are stored as numbers unless marked data-type="string"; th cells
+// are marked as headers.
+func ParseHTML(r io.Reader) ([]Sheet, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("parsing page: %w", err)
+	}
+	var sheets []Sheet
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Table {
+			sheets = append(sheets, parseTable(n, len(sheets)+1))
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("page contains no tables")
+	}
+	uniqueNames(sheets)
+	return sheets, nil
+}
+
+// parseTable reads the rows of a table, skipping rows of nested tables
+func parseTable(table *html.Node, index int) Sheet {
+	s := Sheet{Name: attr(table, "data-sheet")}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Table:
+				continue
+			case atom.Caption:
+				if s.Name == "" {
+					s.Name = text(c)
+				}
+			case atom.Tr:
+				s.Rows = append(s.Rows, parseRow(c))
+			default:
+				walk(c)
+			}
+		}
+	}
+	walk(table)
+	if s.Name == "" {
+		s.Name = fmt.Sprintf("Sheet%d", index)
+	}
+	return s
+}
+
+// parseRow reads the cells of a table row
+func parseRow(tr *html.Node) []Cell {
+	var row []Cell
+	for c := tr.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || (c.DataAtom != atom.Td && c.DataAtom != atom.Th) {
+			continue
+		}
+		value := text(c)
+		cell := Cell{Value: value, Header: c.DataAtom == atom.Th}
+		switch attr(c, "data-type") {
+		case "number":
+			if f, err := strconv.ParseFloat(value, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+				cell.Value = strconv.FormatFloat(f, 'f', -1, 64)
+				cell.Number = true
+			}
+		case "string":
+		default:
+			cell.Number = !cell.Header && numberPattern.MatchString(value)
+		}
+		row = append(row, cell)
+		span, _ := strconv.Atoi(attr(c, "colspan"))
+		for i := 1; i < span && i < maxColspan; i++ {
+			row = append(row, Cell{Header: cell.Header})
+		}
+	}
+	return row
+}
+
+// attr returns the value of an element's attribute
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// text returns the text content of a node with whitespace collapsed
+func text(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.Type == html.ElementNode && n.DataAtom == atom.Br:
+			b.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// uniqueNames makes sheet names valid and distinct. Spreadsheet
+// applications limit names to 31 characters and reject some punctuation.
+func uniqueNames(sheets []Sheet) {
+	seen := map[string]bool{}
+	for i := range sheets {
+		name := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`[]:*?/\`, r) {
+				return '_'
+			}
+			return r
+		}, sheets[i].Name)
+		base := name
+		name = truncate(base, 31)
+		for n := 2; seen[strings.ToLower(name)]; n++ {
+			suffix := fmt.Sprintf(" (%d)", n)
+			name = truncate(base, 31-len(suffix)) + suffix
+		}
+		seen[strings.ToLower(name)] = true
+		sheets[i].Name = name
+	}
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
@@ -0,0 +1,153 @@
+package sheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHTML(t *testing.T) {
+	page := `<html><body>
+<h1>Report</h1>
+<table data-sheet="Q3: Sales">
+  <tr><th>Region</th><th>Units</th><th>Zip</th></tr>
+  <tr><td>North &amp; East</td><td>1200</td><td>01234</td></tr>
+  <tr><td colspan="2">Total</td><td data-type="number"> 1e3 </td></tr>
+</table>
+<table>
+  <caption>Notes</caption>
+  <tbody><tr><td>A <b>bold</b><br>note <table><tr><td>nested</td></tr></table></td></tr></tbody>
+</table>
+<table><tr><td data-type="string">42</td></tr></table>
+</body></html>`
+
+	sheets, err := ParseHTML(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("ParseHTML() error: %v", err)
+	}
+	want := []Sheet{
+		{Name: "Q3_ Sales", Rows: [][]Cell{
+			{{Value: "Region", Header: true}, {Value: "Units", Header: true}, {Value: "Zip", Header: true}},
+			{{Value: "North & East"}, {Value: "1200", Number: true}, {Value: "01234"}},
+			{{Value: "Total"}, {}, {Value: "1000", Number: true}},
+		}},
+		{Name: "Notes", Rows: [][]Cell{
+			{{Value: "A bold note nested"}},
+		}},
+		{Name: "Sheet3", Rows: [][]Cell{{{Value: "nested"}}}},
+		{Name: "Sheet4", Rows: [][]Cell{{{Value: "42"}}}},
+	}
+	if !reflect.DeepEqual(sheets, want) {
+		t.Errorf("ParseHTML() =\n%+v\nwant\n%+v", sheets, want)
+	}
+
+	if _, err = ParseHTML(strings.NewReader(`<p>no tables</p>`)); err == nil {
+		t.Error("expected error for a page without tables")
+	}
+}
+
+func TestUniqueNames(t *testing.T) {
+	long := strings.Repeat("x", 40)
+	sheets := []Sheet{{Name: "Data"}, {Name: "data"}, {Name: long}, {Name: long}}
+	uniqueNames(sheets)
+	var names []string
+	for _, s := range sheets {
+		names = append(names, s.Name)
+	}
+	want := []string{"Data", "data (2)", strings.Repeat("x", 31), strings.Repeat("x", 27) + " (2)"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %q, want %q", names, want)
+	}
+}
+
+func TestColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := column(i); got != want {
+			t.Errorf("column(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
+
+// readZip returns the files of a zip archive in order
+func readZip(t *testing.T, data []byte) ([]string, map[string]string) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error: %v", err)
+	}
+	var names []string
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Open(%s) error: %v", f.Name, err)
+		}
+		b, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		names = append(names, f.Name)
+		files[f.Name] = string(b)
+	}
+	return names, files
+}
+
+var testSheets = []Sheet{
+	{Name: "R&D", Rows: [][]Cell{
+		{{Value: "Name", Header: true}, {Value: "Score", Header: true}},
+		{{Value: "<Ada>"}, {Value: "9.5", Number: true}},
+		{{}, {Value: "3", Number: true}},
+	}},
+	{Name: "Empty"},
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteXLSX(&buf, testSheets); err != nil {
+		t.Fatalf("WriteXLSX() error: %v", err)
+	}
+	_, files := readZip(t, buf.Bytes())
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet2.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("missing %s", name)
+		}
+	}
+	if wb := files["xl/workbook.xml"]; !strings.Contains(wb, `<sheet name="R&amp;D" sheetId="1" r:id="rId1"/>`) {
+		t.Errorf("workbook.xml = %s", wb)
+	}
+	sheet1 := files["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" s="1" t="inlineStr"><is><t xml:space="preserve">Name</t></is></c>`,
+		`<c r="A2" t="inlineStr"><is><t xml:space="preserve">&lt;Ada&gt;</t></is></c>`,
+		`<c r="B2"><v>9.5</v></c>`,
+		`<c r="A3"/><c r="B3"><v>3</v></c>`,
+	} {
+		if !strings.Contains(sheet1, want) {
+			t.Errorf("sheet1.xml missing %s:\n%s", want, sheet1)
+		}
+	}
+}
+
+func TestWriteODS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteODS(&buf, testSheets); err != nil {
+		t.Fatalf("WriteODS() error: %v", err)
+	}
+	names, files := readZip(t, buf.Bytes())
+	if names[0] != "mimetype" || files["mimetype"] != ODSContentType {
+		t.Errorf("first file = %s %q, want the mimetype", names[0], files["mimetype"])
+	}
+	content := files["content.xml"]
+	for _, want := range []string{
+		`<table:table table:name="R&amp;D">`,
+		`<table:table-cell table:style-name="header" office:value-type="string"><text:p>Name</text:p></table:table-cell>`,
+		`<table:table-cell office:value-type="string"><text:p>&lt;Ada&gt;</text:p></table:table-cell>`,
+		`<table:table-cell office:value-type="float" office:value="9.5"><text:p>9.5</text:p></table:table-cell>`,
+		`<table:table table:name="Empty"><table:table-row><table:table-cell/></table:table-row></table:table>`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("content.xml missing %s", want)
+		}
+	}
+}
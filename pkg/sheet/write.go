@@ -0,0 +1,179 @@
+package sheet
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Content types of the spreadsheet formats
+const (
+	XLSXContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	ODSContentType  = "application/vnd.oasis.opendocument.spreadsheet"
+)
+
+// escape returns s escaped for XML text and attribute values
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// column returns the letters of a zero-based column index (A, B, ..., AA)
+func column(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// zipFile adds a compressed file to a zip archive
+func zipFile(zw *zip.Writer, name, content string) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, content)
+	return err
+}
+
+// WriteXLSX writes the sheets as an Office Open XML workbook
+func WriteXLSX(w io.Writer, sheets []Sheet) error {
+	zw := zip.NewWriter(w)
+	var overrides, workbook, rels strings.Builder
+	for i, s := range sheets {
+		fmt.Fprintf(&overrides, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(s.Name), i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+
+	files := [][2]string{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			overrides.String() + `</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + workbook.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() + `</Relationships>`},
+		// Style 1 is bold, for header cells
+		{"xl/styles.xml", xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+			`</styleSheet>`},
+	}
+	for i, s := range sheets {
+		files = append(files, [2]string{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), worksheetXML(s)})
+	}
+	for _, f := range files {
+		if err := zipFile(zw, f[0], f[1]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// worksheetXML renders one sheet as SpreadsheetML
+func worksheetXML(s Sheet) string {
+	var b strings.Builder
+	b.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for r, row := range s.Rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, cell := range row {
+			ref := fmt.Sprintf("%s%d", column(c), r+1)
+			style := ""
+			if cell.Header {
+				style = ` s="1"`
+			}
+			switch {
+			case cell.Value == "":
+				fmt.Fprintf(&b, `<c r="%s"%s/>`, ref, style)
+			case cell.Number:
+				fmt.Fprintf(&b, `<c r="%s"%s><v>%s</v></c>`, ref, style, cell.Value)
+			default:
+				fmt.Fprintf(&b, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, escape(cell.Value))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// WriteODS writes the sheets as an OpenDocument spreadsheet
+func WriteODS(w io.Writer, sheets []Sheet) error {
+	zw := zip.NewWriter(w)
+	// The mimetype must come first and be stored uncompressed
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(f, ODSContentType); err != nil {
+		return err
+	}
+
+	var content strings.Builder
+	content.WriteString(xml.Header + `<office:document-content` +
+		` xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"` +
+		` xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0"` +
+		` xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"` +
+		` xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"` +
+		` xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0"` +
+		` office:version="1.2">` +
+		`<office:automatic-styles><style:style style:name="header" style:family="table-cell">` +
+		`<style:text-properties fo:font-weight="bold"/></style:style></office:automatic-styles>` +
+		`<office:body><office:spreadsheet>`)
+	for _, s := range sheets {
+		fmt.Fprintf(&content, `<table:table table:name="%s">`, escape(s.Name))
+		if len(s.Rows) == 0 {
+			// A table needs at least one row
+			content.WriteString(`<table:table-row><table:table-cell/></table:table-row>`)
+		}
+		for _, row := range s.Rows {
+			content.WriteString(`<table:table-row>`)
+			for _, cell := range row {
+				style := ""
+				if cell.Header {
+					style = ` table:style-name="header"`
+				}
+				switch {
+				case cell.Value == "":
+					fmt.Fprintf(&content, `<table:table-cell%s/>`, style)
+				case cell.Number:
+					fmt.Fprintf(&content, `<table:table-cell%s office:value-type="float" office:value="%s"><text:p>%s</text:p></table:table-cell>`, style, cell.Value, cell.Value)
+				default:
+					fmt.Fprintf(&content, `<table:table-cell%s office:value-type="string"><text:p>%s</text:p></table:table-cell>`, style, escape(cell.Value))
+				}
+			}
+			content.WriteString(`</table:table-row>`)
+		}
+		content.WriteString(`</table:table>`)
+	}
+	content.WriteString(`</office:spreadsheet></office:body></office:document-content>`)
+
+	files := [][2]string{
+		{"META-INF/manifest.xml", xml.Header + `<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.2">` +
+			`<manifest:file-entry manifest:full-path="/" manifest:media-type="` + ODSContentType + `"/>` +
+			`<manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>` +
+			`</manifest:manifest>`},
+		{"content.xml", content.String()},
+	}
+	for _, f := range files {
+		if err = zipFile(zw, f[0], f[1]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
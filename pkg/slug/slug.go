@@ -0,0 +1,90 @@
+// Package slug turns titles into URL-safe slugs: lower-case ASCII words
+// joined by hyphens, with accented, Greek and Cyrillic letters
+// transliterated.
+package slug
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// transliterations spell letters that do not decompose into an ASCII letter
+// and accents
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "ae", 'œ': "oe", 'Œ': "oe", 'ø': "o", 'Ø': "o",
+	'đ': "d", 'Đ': "d", 'ð': "d", 'Ð': "d", 'þ': "th", 'Þ': "th", 'ł': "l",
+	'Ł': "l", 'ı': "i", 'ħ': "h", 'Ħ': "h", 'ŋ': "ng", 'Ŋ': "ng",
+
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya", 'є': "ye",
+	'і': "i", 'ї': "yi", 'ґ': "g",
+}
+
+// Make returns the slug of s. Replacements are applied first, longest
+// match first, so that "&" can become "and" or "ä" can become "ae"; then
+// letters are transliterated and lower-cased, and every run of other
+// characters becomes a single hyphen.
+func Make(s string, replacements map[string]string) string {
+	if len(replacements) > 0 {
+		keys := make([]string, 0, len(replacements))
+		for k := range replacements {
+			if k != "" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+		pairs := make([]string, 0, 2*len(keys))
+		for _, k := range keys {
+			pairs = append(pairs, k, replacements[k])
+		}
+		s = strings.NewReplacer(pairs...).Replace(s)
+	}
+	var b strings.Builder
+	hyphen := false
+	word := func(w string) {
+		if w == "" {
+			return
+		}
+		if hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		hyphen = false
+		b.WriteString(w)
+	}
+	for _, r := range s {
+		// Look letters up before decomposing them, so that й is not
+		// taken for и with an accent
+		if t, ok := transliterations[unicode.ToLower(r)]; ok {
+			word(t)
+			continue
+		}
+		for _, d := range norm.NFD.String(string(r)) {
+			switch {
+			case unicode.Is(unicode.Mn, d):
+				// accents of decomposed letters
+			case d < unicode.MaxASCII && (unicode.IsLetter(d) || unicode.IsDigit(d)):
+				word(string(unicode.ToLower(d)))
+			case transliterations[unicode.ToLower(d)] != "":
+				word(transliterations[unicode.ToLower(d)])
+			case d == '\'' || d == '’':
+				// apostrophes join the letters around them
+			default:
+				hyphen = true
+			}
+		}
+	}
+	return b.String()
+}
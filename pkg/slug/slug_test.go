@@ -0,0 +1,29 @@
+package slug
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		input        string
+		replacements map[string]string
+		expected     string
+	}{
+		{"Hello, World!", nil, "hello-world"},
+		{"  Crème Brûlée -- Recipe #2  ", nil, "creme-brulee-recipe-2"},
+		{"Straße in Łódź", nil, "strasse-in-lodz"},
+		{"Don't Stop Me Now", nil, "dont-stop-me-now"},
+		{"Ελληνικά Νέα", nil, "ellinika-nea"},
+		{"Новости дня", nil, "novosti-dnya"},
+		{"Съезд и йогурт", nil, "sezd-i-yogurt"},
+		{"Fish & Chips", map[string]string{"&": "and"}, "fish-and-chips"},
+		{"Übergrößen für Äpfel", map[string]string{"ä": "ae", "Ä": "ae", "ö": "oe", "ü": "ue", "Ü": "ue"}, "uebergroessen-fuer-aepfel"},
+		{"C++ & C#", map[string]string{"++": "pp", "#": "sharp", "&": "and"}, "cpp-and-csharp"},
+		{"東京 2025", nil, "2025"},
+		{"!!!", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := Make(tt.input, tt.replacements); got != tt.expected {
+			t.Errorf("Make(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Package ssi translates Apache server-side include directives in template
+// sources, so pages of a legacy SSI site can be served as templates while
+// they are migrated.
+package ssi
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template/parse"
+	"time"
+)
+
+// maxDepth bounds nested includes
+const maxDepth = 16
+
+// timeFormat is Apache's default timefmt, "%A, %d-%b-%Y %H:%M:%S %Z"
+const timeFormat = "Monday, 02-Jan-2006 15:04:05 MST"
+
+var (
+	directivePattern = regexp.MustCompile(`<!--#([a-z]+)((?:\s+[a-z]+\s*=\s*"[^"]*")*)\s*-->`)
+	attrPattern      = regexp.MustCompile(`([a-z]+)\s*=\s*"([^"]*)"`)
+	varPattern       = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Expander translates the directives of template sources
+type Expander struct {
+	// Root is the directory that include virtual paths are relative to
+	Root string
+	// EchoFunc is the template function that looks up echo variables
+	EchoFunc string
+
+	// literals holds the text of included files, which the expanded source
+	// refers to by placeholders
+	literals []string
+}
+
+// placeholderPattern matches the placeholders of included text
+var placeholderPattern = regexp.MustCompile("\x00ssi([0-9]+)\x00")
+
+// ExpandFile reads a template file and translates its directives:
+// #include virtual and #include file insert the named file, and #echo var
+// becomes a call of the echo function. Other directives are left alone.
+// Included files are content, not templates, so their text is replaced by
+// placeholders that Restore puts back after the source is parsed.
+func (e *Expander) ExpandFile(filename string) (string, error) {
+	e.literals = nil
+	return e.expandFile(filename, 0)
+}
+
+// Restore replaces the placeholders in the text of parsed templates with
+// the included text, which the template engine then treats as literal
+// markup in whatever context it appears, such as a script
+func (e *Expander) Restore(trees ...*parse.Tree) {
+	for _, t := range trees {
+		if t != nil {
+			e.restore(t.Root)
+		}
+	}
+}
+
+func (e *Expander) restore(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			e.restore(child)
+		}
+	case *parse.TextNode:
+		n.Text = placeholderPattern.ReplaceAllFunc(n.Text, func(m []byte) []byte {
+			i, _ := strconv.Atoi(string(placeholderPattern.FindSubmatch(m)[1]))
+			return []byte(e.literals[i])
+		})
+	case *parse.IfNode:
+		e.restore(n.List)
+		e.restore(n.ElseList)
+	case *parse.RangeNode:
+		e.restore(n.List)
+		e.restore(n.ElseList)
+	case *parse.WithNode:
+		e.restore(n.List)
+		e.restore(n.ElseList)
+	}
+}
+
+// literal returns text of an included file as a placeholder, and the text
+// of the page itself, which is a template, as it is
+func (e *Expander) literal(text string, depth int) string {
+	if depth == 0 || text == "" {
+		return text
+	}
+	e.literals = append(e.literals, text)
+	return fmt.Sprintf("\x00ssi%d\x00", len(e.literals)-1)
+}
+
+func (e *Expander) expandFile(filename string, depth int) (string, error) {
+	if depth > maxDepth {
+		return "", fmt.Errorf("includes nested more than %d deep at %s", maxDepth, filename)
+	}
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return "", err
+	}
+
+	text := string(src)
+	var out strings.Builder
+	last := 0
+	for _, loc := range directivePattern.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(e.literal(text[last:loc[0]], depth))
+		last = loc[1]
+		directive := text[loc[0]:loc[1]]
+		attrs := map[string]string{}
+		for _, a := range attrPattern.FindAllStringSubmatch(text[loc[4]:loc[5]], -1) {
+			attrs[a[1]] = a[2]
+		}
+		switch text[loc[2]:loc[3]] {
+		case "include":
+			included, err := e.include(filename, attrs, depth)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(included)
+		case "echo":
+			name := attrs["var"]
+			switch {
+			case !varPattern.MatchString(name):
+				return "", fmt.Errorf("%s: invalid echo variable '%s'", filename, name)
+			case name == "LAST_MODIFIED":
+				out.WriteString(e.literal(info.ModTime().Format(timeFormat), depth))
+			case name == "DOCUMENT_NAME":
+				out.WriteString(e.literal(filepath.Base(filename), depth))
+			default:
+				fmt.Fprintf(&out, `{{%s $ %q}}`, e.EchoFunc, name)
+			}
+		default:
+			out.WriteString(e.literal(directive, depth))
+		}
+	}
+	out.WriteString(e.literal(text[last:], depth))
+	return out.String(), nil
+}
+
+// include returns the translated contents of an included file
+func (e *Expander) include(from string, attrs map[string]string, depth int) (string, error) {
+	var filename string
+	switch {
+	case attrs["virtual"] != "":
+		filename = filepath.Join(e.Root, filepath.FromSlash(path.Clean("/"+attrs["virtual"])))
+	case attrs["file"] != "":
+		f := attrs["file"]
+		if path.IsAbs(f) || strings.Contains("/"+f+"/", "/../") {
+			return "", fmt.Errorf("%s: include file '%s' must be relative and must not contain ..", from, f)
+		}
+		filename = filepath.Join(filepath.Dir(from), filepath.FromSlash(f))
+	default:
+		return "", fmt.Errorf("%s: include needs a virtual or file attribute", from)
+	}
+	included, err := e.expandFile(filename, depth+1)
+	if err != nil {
+		return "", fmt.Errorf("including %s: %w", filename, err)
+	}
+	return included, nil
+}
+
+// Var returns the value of an echo variable for a request: the standard
+// SSI variables, CGI request variables such as REMOTE_ADDR, and HTTP_*
+// request headers. Unknown variables are "(none)", as in Apache.
+func Var(r *http.Request, name string, now time.Time) string {
+	if r == nil {
+		return "(none)"
+	}
+	var value string
+	switch name {
+	case "DOCUMENT_URI":
+		value = r.URL.Path
+	case "QUERY_STRING":
+		value = r.URL.RawQuery
+	case "QUERY_STRING_UNESCAPED":
+		value = r.URL.RawQuery
+		if q, err := url.QueryUnescape(value); err == nil {
+			value = q
+		}
+	case "DATE_LOCAL":
+		value = now.Local().Format(timeFormat)
+	case "DATE_GMT":
+		value = now.UTC().Format(timeFormat)
+	case "REQUEST_METHOD":
+		value = r.Method
+	case "REQUEST_URI":
+		value = r.RequestURI
+	case "REMOTE_ADDR":
+		value = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(value); err == nil {
+			value = host
+		}
+	case "SERVER_NAME":
+		value = r.Host
+		if host, _, err := net.SplitHostPort(value); err == nil {
+			value = host
+		}
+	case "HTTPS":
+		if r.TLS != nil {
+			value = "on"
+		}
+	default:
+		if header, ok := strings.CutPrefix(name, "HTTP_"); ok {
+			value = r.Header.Get(strings.ReplaceAll(header, "_", "-"))
+		}
+	}
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
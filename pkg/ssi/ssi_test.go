@@ -0,0 +1,93 @@
+package ssi
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestExpandFile(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"page.shtml":           `<!--#include virtual="/inc/header.html" --><p>Hi <!--#echo var="HTTP_USER_AGENT" --></p><!--#include file="inc/footer.html"--><!--#config timefmt="%Y" -->`,
+		"inc/header.html":      `<h1>{{not a template}}</h1><!--#include file="nav.html" -->`,
+		"inc/nav.html":         `<nav><!--#echo var="DOCUMENT_URI" --></nav>`,
+		"inc/footer.html":      `<footer>footer</footer>`,
+		"loop.shtml":           `<!--#include file="loop.shtml" -->`,
+		"escape.shtml":         `<!--#include file="../secret" -->`,
+		"missing.shtml":        `<!--#include virtual="/nope.html" -->`,
+		"badvar.shtml":         `<!--#echo var="}} {{evil" -->`,
+		"outside/virtual.html": `<!--#include virtual="/../../etc/passwd" -->`,
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("MkdirAll() error: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+	e := &Expander{Root: root, EchoFunc: "ssiEcho"}
+
+	got, err := e.ExpandFile(filepath.Join(root, "page.shtml"))
+	if err != nil {
+		t.Fatalf("ExpandFile() error: %v", err)
+	}
+	// Included text is kept out of the template source until it is parsed
+	if strings.Contains(got, "not a template") {
+		t.Errorf("ExpandFile() = %q, want included text replaced by placeholders", got)
+	}
+	tmpl, err := template.New("page").Funcs(template.FuncMap{"ssiEcho": func(any, string) string { return "" }}).Parse(got)
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	e.Restore(tmpl.Tree)
+	want := `<h1>{{not a template}}</h1><nav>{{ssiEcho $ "DOCUMENT_URI"}}</nav>` +
+		`<p>Hi {{ssiEcho $ "HTTP_USER_AGENT"}}</p><footer>footer</footer><!--#config timefmt="%Y" -->`
+	if got = tmpl.Tree.Root.String(); got != want {
+		t.Errorf("restored template =\n%s\nwant\n%s", got, want)
+	}
+
+	for _, name := range []string{"loop.shtml", "escape.shtml", "missing.shtml", "badvar.shtml"} {
+		if _, err := e.ExpandFile(filepath.Join(root, name)); err == nil {
+			t.Errorf("ExpandFile(%s) succeeded, want error", name)
+		}
+	}
+
+	// Virtual paths cannot leave the root
+	if _, err := e.ExpandFile(filepath.Join(root, "outside/virtual.html")); err == nil || !strings.Contains(err.Error(), filepath.Join(root, "etc", "passwd")) {
+		t.Errorf("ExpandFile() error = %v, want a path inside the root", err)
+	}
+}
+
+func TestVar(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://example.com:8080/docs/a%20b.html?q=a%20b", nil)
+	req.RequestURI = "/docs/a%20b.html?q=a%20b"
+	req.RemoteAddr = "192.0.2.7:5555"
+	req.Header.Set("User-Agent", "Lynx")
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]string{
+		"DOCUMENT_URI":           "/docs/a b.html",
+		"QUERY_STRING":           "q=a%20b",
+		"QUERY_STRING_UNESCAPED": "q=a b",
+		"DATE_GMT":               "Friday, 01-Mar-2024 12:00:00 UTC",
+		"REQUEST_METHOD":         "GET",
+		"REQUEST_URI":            "/docs/a%20b.html?q=a%20b",
+		"REMOTE_ADDR":            "192.0.2.7",
+		"SERVER_NAME":            "example.com",
+		"HTTP_USER_AGENT":        "Lynx",
+		"HTTP_REFERER":           "(none)",
+		"PATH":                   "(none)",
+	}
+	for name, want := range tests {
+		if got := Var(req, name, now); got != want {
+			t.Errorf("Var(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
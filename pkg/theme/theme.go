@@ -0,0 +1,155 @@
+// Package theme picks a color theme for each request from a cookie or the
+// browser's prefers-color-scheme client hint, so pages can be rendered in
+// the right theme on the server instead of flashing the wrong one first.
+package theme
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/redirect"
+)
+
+// Defaults for theme settings
+const (
+	DefaultCookie = "theme"
+	DefaultPath   = "/_theme"
+)
+
+// DefaultThemes are offered when no themes are configured
+var DefaultThemes = []string{"light", "dark"}
+
+// HintHeader is the client hint carrying the user's color scheme preference
+const HintHeader = "Sec-CH-Prefers-Color-Scheme"
+
+// cookieMaxAge is how long a chosen theme is remembered
+const cookieMaxAge = 365 * 24 * time.Hour
+
+// Settings configures theme negotiation
+type Settings struct {
+	Enabled bool     `yaml:"enabled"`
+	Themes  []string `yaml:"themes,omitempty"`
+	Default string   `yaml:"default,omitempty"`
+	Cookie  string   `yaml:"cookie,omitempty"`
+	Path    string   `yaml:"path,omitempty"`
+}
+
+// Theme is the theme chosen for a request
+type Theme struct {
+	// Name is the active theme, or "" if negotiation is disabled
+	Name string
+	// Source is where the theme came from: "cookie", "hint" or "default"
+	Source string
+	// Choices are the configured themes
+	Choices []string
+
+	path string
+}
+
+// themes returns the configured themes
+func (s *Settings) themes() []string {
+	if len(s.Themes) == 0 {
+		return DefaultThemes
+	}
+	return s.Themes
+}
+
+// cookie returns the name of the theme cookie
+func (s *Settings) cookie() string {
+	if s.Cookie == "" {
+		return DefaultCookie
+	}
+	return s.Cookie
+}
+
+// EndpointPath returns the path of the endpoint that switches themes
+func (s *Settings) EndpointPath() string {
+	if s.Path == "" {
+		return DefaultPath
+	}
+	return s.Path
+}
+
+// Validate checks the theme settings
+func (s *Settings) Validate() error {
+	if !s.Enabled {
+		return nil
+	}
+	if s.Default != "" && !slices.Contains(s.themes(), s.Default) {
+		return fmt.Errorf("theme: default '%s' is not one of the themes", s.Default)
+	}
+	if !strings.HasPrefix(s.EndpointPath(), "/") {
+		return fmt.Errorf("theme: path must start with /")
+	}
+	return nil
+}
+
+// Resolve picks the theme for a request: a valid cookie wins, then the
+// color scheme client hint, then the default theme
+func (s *Settings) Resolve(r *http.Request) Theme {
+	if !s.Enabled {
+		return Theme{}
+	}
+	themes := s.themes()
+	t := Theme{Choices: themes, path: s.EndpointPath()}
+	if c, err := r.Cookie(s.cookie()); err == nil && slices.Contains(themes, c.Value) {
+		t.Name, t.Source = c.Value, "cookie"
+		return t
+	}
+	if hint := strings.Trim(r.Header.Get(HintHeader), `" `); hint != "" && slices.Contains(themes, hint) {
+		t.Name, t.Source = hint, "hint"
+		return t
+	}
+	t.Name, t.Source = s.Default, "default"
+	if t.Name == "" {
+		t.Name = themes[0]
+	}
+	return t
+}
+
+// SetURL returns the URL that switches to the named theme and then returns
+// to the given page. The name "auto" forgets the chosen theme.
+func (t Theme) SetURL(name, returnTo string) string {
+	return t.path + "?" + url.Values{"set": {name}, "return": {returnTo}}.Encode()
+}
+
+// SetHeaders asks the browser for the color scheme hint and marks the
+// response as varying with the theme
+func (s *Settings) SetHeaders(h http.Header) {
+	if !s.Enabled {
+		return
+	}
+	h.Set("Accept-CH", HintHeader)
+	// Critical-CH makes the browser retry the first request with the hint
+	h.Set("Critical-CH", HintHeader)
+	h.Add("Vary", HintHeader+", Cookie")
+}
+
+// Handle serves the theme endpoint: it stores the requested theme in a
+// cookie, or clears it for "auto", and redirects back to the page
+func (s *Settings) Handle(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	name := query.Get("set")
+	cookie := &http.Cookie{
+		Name:     s.cookie(),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	switch {
+	case name == "auto":
+		cookie.MaxAge = -1
+	case slices.Contains(s.themes(), name):
+		cookie.Value = name
+		cookie.MaxAge = int(cookieMaxAge / time.Second)
+	default:
+		http.Error(w, "unknown theme", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, cookie)
+	http.Redirect(w, r, redirect.Local(query.Get("return")), http.StatusSeeOther)
+}
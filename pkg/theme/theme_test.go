@@ -0,0 +1,102 @@
+package theme
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	settings := &Settings{Enabled: true, Themes: []string{"light", "dark", "sepia"}, Default: "sepia"}
+
+	tests := []struct {
+		name           string
+		cookie         string
+		hint           string
+		expectedName   string
+		expectedSource string
+	}{
+		{"Default", "", "", "sepia", "default"},
+		{"Client hint", "", "dark", "dark", "hint"},
+		{"Quoted client hint", "", `"light"`, "light", "hint"},
+		{"Unknown client hint", "", "no-preference", "sepia", "default"},
+		{"Cookie beats hint", "light", "dark", "light", "cookie"},
+		{"Unknown cookie", "neon", "dark", "dark", "hint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "theme", Value: tt.cookie})
+			}
+			if tt.hint != "" {
+				req.Header.Set(HintHeader, tt.hint)
+			}
+			got := settings.Resolve(req)
+			if got.Name != tt.expectedName || got.Source != tt.expectedSource {
+				t.Errorf("Resolve() = %s (%s), want %s (%s)", got.Name, got.Source, tt.expectedName, tt.expectedSource)
+			}
+		})
+	}
+
+	if got := (&Settings{}).Resolve(httptest.NewRequest("GET", "/", nil)); got.Name != "" {
+		t.Errorf("Resolve() with negotiation disabled = %q", got.Name)
+	}
+	if got := (&Settings{Enabled: true}).Resolve(httptest.NewRequest("GET", "/", nil)); got.Name != "light" {
+		t.Errorf("Resolve() without configured themes = %q, want light", got.Name)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	settings := &Settings{Enabled: true}
+
+	tests := []struct {
+		name             string
+		query            string
+		expectedStatus   int
+		expectedLocation string
+		expectedCookie   string
+	}{
+		{"Set theme", "set=dark&return=/blog/post?x=1", http.StatusSeeOther, "/blog/post?x=1", "theme=dark"},
+		{"Forget theme", "set=auto&return=/", http.StatusSeeOther, "/", "theme=; Path=/; Max-Age=0"},
+		{"Unknown theme", "set=neon&return=/", http.StatusBadRequest, "", ""},
+		{"Off-site return", "set=dark&return=//evil.example.com/", http.StatusSeeOther, "/", "theme=dark"},
+		{"Tab in return", "set=dark&return=/%09/evil.example.com", http.StatusSeeOther, "/", "theme=dark"},
+		{"Absolute return", "set=dark&return=https://evil.example.com/", http.StatusSeeOther, "/", "theme=dark"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			settings.Handle(w, httptest.NewRequest("GET", "/_theme?"+tt.query, nil))
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.expectedStatus)
+			}
+			if got := w.Header().Get("Location"); got != tt.expectedLocation {
+				t.Errorf("Location = %q, want %q", got, tt.expectedLocation)
+			}
+			if got := w.Header().Get("Set-Cookie"); !strings.HasPrefix(got, tt.expectedCookie) {
+				t.Errorf("Set-Cookie = %q, want prefix %q", got, tt.expectedCookie)
+			}
+		})
+	}
+}
+
+func TestSetURL(t *testing.T) {
+	theme := (&Settings{Enabled: true}).Resolve(httptest.NewRequest("GET", "/", nil))
+	if got := theme.SetURL("dark", "/a?b=c"); got != "/_theme?return=%2Fa%3Fb%3Dc&set=dark" {
+		t.Errorf("SetURL() = %q", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (&Settings{Enabled: true, Default: "sepia"}).Validate(); err == nil {
+		t.Error("expected error for a default that is not a theme")
+	}
+	if err := (&Settings{Enabled: true, Path: "theme"}).Validate(); err == nil {
+		t.Error("expected error for a relative path")
+	}
+	if err := (&Settings{Enabled: true, Themes: []string{"a", "b"}, Default: "b"}).Validate(); err != nil {
+		t.Errorf("Validate() error: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Package token generates identifiers and random strings from crypto/rand,
+// for form nonces, download tokens and other values that must not be
+// guessable.
+package token
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// MaxLength bounds the length of generated strings, so that a template
+// cannot exhaust memory by mistake
+const MaxLength = 4096
+
+// Alphabets of the random string functions
+const (
+	Alpha        = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	Numeric      = "0123456789"
+	AlphaNumeric = Alpha + Numeric
+	ASCII        = " !\"#$%&'()*+,-./" + Numeric + ":;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`abcdefghijklmnopqrstuvwxyz{|}~"
+)
+
+// crockford is the base32 alphabet of ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// checkLength rejects lengths out of range
+func checkLength(n int) error {
+	if n < 0 || n > MaxLength {
+		return fmt.Errorf("length %d out of range 0 to %d", n, MaxLength)
+	}
+	return nil
+}
+
+// New returns n random bytes encoded as unpadded URL-safe base64, so that
+// 16 bytes give a 22 character token with 128 bits of entropy
+func New(n int) (string, error) {
+	if err := checkLength(n); err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// String returns n characters drawn uniformly from alphabet
+func String(n int, alphabet string) (string, error) {
+	if err := checkLength(n); err != nil {
+		return "", err
+	}
+	if alphabet == "" {
+		return "", fmt.Errorf("empty alphabet")
+	}
+	size := big.NewInt(int64(len(alphabet)))
+	out := make([]byte, n)
+	for i := range out {
+		j, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		out[i] = alphabet[j.Int64()]
+	}
+	return string(out), nil
+}
+
+// Int returns a random integer in [min, max)
+func Int(min, max int) (int, error) {
+	if max <= min {
+		return 0, fmt.Errorf("max %d must be greater than min %d", max, min)
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)-int64(min)))
+	if err != nil {
+		return 0, err
+	}
+	return min + int(n.Int64()), nil
+}
+
+// ULID returns a ULID for time t: a 48-bit millisecond timestamp and 80
+// random bits in Crockford base32, so that IDs sort by creation time
+func ULID(t time.Time) (string, error) {
+	var b [16]byte
+	ms := uint64(t.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+	// 128 bits in 26 characters of 5 bits, the first holding 3
+	out := make([]byte, 26)
+	value := new(big.Int).SetBytes(b[:])
+	mask := big.NewInt(31)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[new(big.Int).And(value, mask).Int64()]
+		value.Rsh(value, 5)
+	}
+	return string(out), nil
+}
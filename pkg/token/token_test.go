@@ -0,0 +1,78 @@
+package token
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	a, err := New(16)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	b, _ := New(16)
+	if len(a) != 22 || a == b {
+		t.Errorf("New(16) = %q, %q, want distinct 22 character tokens", a, b)
+	}
+	if strings.ContainsAny(a, "+/=") {
+		t.Errorf("New() = %q, want URL-safe characters", a)
+	}
+	if _, err = New(MaxLength + 1); err == nil {
+		t.Error("New() succeeded beyond MaxLength")
+	}
+}
+
+func TestString(t *testing.T) {
+	s, err := String(64, Numeric)
+	if err != nil {
+		t.Fatalf("String() error: %v", err)
+	}
+	if len(s) != 64 || strings.Trim(s, Numeric) != "" {
+		t.Errorf("String(64, Numeric) = %q", s)
+	}
+	if _, err = String(4, ""); err == nil {
+		t.Error("String() succeeded with an empty alphabet")
+	}
+	if _, err = String(-1, Alpha); err == nil {
+		t.Error("String() succeeded with a negative length")
+	}
+}
+
+func TestInt(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		n, err := Int(-3, 3)
+		if err != nil {
+			t.Fatalf("Int() error: %v", err)
+		}
+		if n < -3 || n >= 3 {
+			t.Fatalf("Int(-3, 3) = %d", n)
+		}
+	}
+	if _, err := Int(5, 5); err == nil {
+		t.Error("Int() succeeded with an empty range")
+	}
+}
+
+func TestULID(t *testing.T) {
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	a, err := ULID(at)
+	if err != nil {
+		t.Fatalf("ULID() error: %v", err)
+	}
+	if len(a) != 26 || strings.Trim(a, crockford) != "" {
+		t.Errorf("ULID() = %q, want 26 Crockford base32 characters", a)
+	}
+	// The first 10 characters encode the timestamp
+	b, _ := ULID(at)
+	later, _ := ULID(at.Add(time.Millisecond))
+	if a[:10] != b[:10] || a == b {
+		t.Errorf("ULID() = %q, %q, want the same time prefix and distinct IDs", a, b)
+	}
+	if !(a < later) {
+		t.Errorf("ULID() = %q at a later time sorts before %q", later, a)
+	}
+	if a[:10] != "01JGJFGR48" {
+		t.Errorf("ULID() time prefix = %q, want 01JGJFGR48", a[:10])
+	}
+}
@@ -0,0 +1,60 @@
+// Package vcard helps templates produce vCard (.vcf) contact cards: it
+// escapes property values, embeds photos and formats the rendered text the
+// way RFC 6350 requires.
+package vcard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// ContentType is the media type of vCard files
+const ContentType = "text/vcard; charset=utf-8"
+
+// maxLine is the longest a content line may be, in octets, before it is
+// folded
+const maxLine = 75
+
+// escaper escapes text property values
+var escaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// Escape escapes a text value so that commas, semicolons, backslashes and
+// newlines in it are taken literally
+func Escape(value string) string {
+	return escaper.Replace(value)
+}
+
+// PhotoURI returns image data as a data: URI for the PHOTO property
+func PhotoURI(data []byte) string {
+	return "data:" + http.DetectContentType(data) + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// Format turns rendered template text into vCard content lines. Indentation
+// and blank lines, which templates produce freely, are removed; lines end
+// in CRLF and are folded at 75 octets.
+func Format(text []byte) []byte {
+	var out bytes.Buffer
+	for _, line := range strings.Split(string(text), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		// Continuation lines start with a space, which counts towards
+		// their length
+		limit := maxLine
+		for len(line) > limit {
+			cut := limit
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			out.WriteString(line[:cut] + "\r\n ")
+			line = line[cut:]
+			limit = maxLine - 1
+		}
+		out.WriteString(line + "\r\n")
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,55 @@
+package vcard
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Ada Lovelace", "Ada Lovelace"},
+		{"Lovelace, Ada; Countess", `Lovelace\, Ada\; Countess`},
+		{`C:\temp`, `C:\\temp`},
+		{"line one\r\nline two\nthree", `line one\nline two\nthree`},
+	}
+	for _, tt := range tests {
+		if got := Escape(tt.input); got != tt.expected {
+			t.Errorf("Escape(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestPhotoURI(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	if got := PhotoURI(png); !strings.HasPrefix(got, "data:image/png;base64,iVBORw0KGgo") {
+		t.Errorf("PhotoURI() = %q", got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	input := "\n  BEGIN:VCARD\n  VERSION:4.0\n\n  FN:Ada\n  NOTE:" + strings.Repeat("é", 50) + "\n  END:VCARD\n"
+	got := string(Format([]byte(input)))
+
+	lines := strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n")
+	if lines[0] != "BEGIN:VCARD" || lines[1] != "VERSION:4.0" || lines[2] != "FN:Ada" || lines[len(lines)-1] != "END:VCARD" {
+		t.Errorf("Format() = %q", got)
+	}
+	var note strings.Builder
+	for i, line := range lines {
+		if len(line) > maxLine {
+			t.Errorf("line %d is %d octets long", i, len(line))
+		}
+		if i >= 3 && i < len(lines)-1 {
+			if i > 3 && !strings.HasPrefix(line, " ") {
+				t.Errorf("continuation line %q does not start with a space", line)
+			}
+			note.WriteString(strings.TrimPrefix(line, " "))
+		}
+	}
+	if want := "NOTE:" + strings.Repeat("é", 50); note.String() != want {
+		t.Errorf("unfolded NOTE = %q, want %q", note.String(), want)
+	}
+}
@@ -0,0 +1,199 @@
+// Package wellknown generates the site metadata files served from fixed
+// paths, such as /.well-known/security.txt and /humans.txt.
+package wellknown
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Paths at which the generated files are served
+const (
+	SecurityTxtPath = "/.well-known/security.txt"
+	HumansTxtPath   = "/humans.txt"
+)
+
+// File is a generated document and its content type
+type File struct {
+	ContentType string
+	Body        []byte
+}
+
+// Prefix is the URL path below which well-known documents are served
+const Prefix = "/.well-known/"
+
+// JSON renders a document configured in the well_known section, such as
+// assetlinks.json or apple-app-site-association, as JSON
+func JSON(name string, doc any) (*File, error) {
+	if name == "" || strings.ContainsAny(name, "/\\") || name == "." || name == ".." {
+		return nil, fmt.Errorf("invalid well-known document name '%s'", name)
+	}
+	if name == "security.txt" {
+		return nil, fmt.Errorf("security.txt is configured with security_txt, not well_known")
+	}
+	body, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("well-known document %s: %w", name, err)
+	}
+	return &File{ContentType: "application/json", Body: append(body, '\n')}, nil
+}
+
+// SecurityTxt configures /.well-known/security.txt (RFC 9116)
+type SecurityTxt struct {
+	Contact            []string  `yaml:"contact"`
+	Expires            time.Time `yaml:"expires"`
+	Encryption         []string  `yaml:"encryption,omitempty"`
+	Acknowledgments    []string  `yaml:"acknowledgments,omitempty"`
+	PreferredLanguages []string  `yaml:"preferred_languages,omitempty"`
+	Canonical          []string  `yaml:"canonical,omitempty"`
+	Policy             []string  `yaml:"policy,omitempty"`
+	Hiring             []string  `yaml:"hiring,omitempty"`
+}
+
+// Validate checks that the required fields are present and that all fields
+// holding URIs have a scheme
+func (s *SecurityTxt) Validate() error {
+	if len(s.Contact) == 0 {
+		return fmt.Errorf("security.txt requires at least one contact")
+	}
+	if s.Expires.IsZero() {
+		return fmt.Errorf("security.txt requires expires")
+	}
+	for field, uris := range map[string][]string{
+		"contact":         s.Contact,
+		"encryption":      s.Encryption,
+		"acknowledgments": s.Acknowledgments,
+		"canonical":       s.Canonical,
+		"policy":          s.Policy,
+		"hiring":          s.Hiring,
+	} {
+		for _, uri := range uris {
+			scheme, _, ok := strings.Cut(uri, ":")
+			if !ok || scheme == "" || strings.ContainsAny(scheme, "/@ ") {
+				return fmt.Errorf("security.txt %s '%s' must be a URI such as mailto:, tel: or https:", field, uri)
+			}
+			if field != "contact" && scheme == "http" {
+				return fmt.Errorf("security.txt %s '%s' must use https", field, uri)
+			}
+		}
+	}
+	return nil
+}
+
+// Warnings returns problems with the expiry date that do not prevent the
+// file from being served
+func (s *SecurityTxt) Warnings(now time.Time) []string {
+	var warnings []string
+	switch {
+	case s.Expires.IsZero():
+	case !s.Expires.After(now):
+		warnings = append(warnings, fmt.Sprintf("security.txt expired on %s", s.Expires.Format("2006-01-02")))
+	case s.Expires.Sub(now) < 30*24*time.Hour:
+		warnings = append(warnings, fmt.Sprintf("security.txt expires soon, on %s", s.Expires.Format("2006-01-02")))
+	case s.Expires.Sub(now) > 366*24*time.Hour:
+		warnings = append(warnings, "security.txt expires more than a year from now; RFC 9116 recommends less")
+	}
+	return warnings
+}
+
+// Render generates the file
+func (s *SecurityTxt) Render() *File {
+	var b strings.Builder
+	write := func(field string, values ...string) {
+		for _, v := range values {
+			fmt.Fprintf(&b, "%s: %s\n", field, v)
+		}
+	}
+	write("Contact", s.Contact...)
+	write("Expires", s.Expires.UTC().Format(time.RFC3339))
+	write("Encryption", s.Encryption...)
+	write("Acknowledgments", s.Acknowledgments...)
+	if len(s.PreferredLanguages) > 0 {
+		write("Preferred-Languages", strings.Join(s.PreferredLanguages, ", "))
+	}
+	write("Canonical", s.Canonical...)
+	write("Policy", s.Policy...)
+	write("Hiring", s.Hiring...)
+	return &File{ContentType: "text/plain; charset=utf-8", Body: []byte(b.String())}
+}
+
+// Person is a member of the team listed in humans.txt
+type Person struct {
+	Role     string `yaml:"role"`
+	Name     string `yaml:"name"`
+	Contact  string `yaml:"contact,omitempty"`
+	Twitter  string `yaml:"twitter,omitempty"`
+	Location string `yaml:"location,omitempty"`
+}
+
+// HumansTxt configures /humans.txt (humanstxt.org)
+type HumansTxt struct {
+	Team   []Person `yaml:"team"`
+	Thanks []string `yaml:"thanks,omitempty"`
+	Site   struct {
+		LastUpdate string   `yaml:"last_update,omitempty"`
+		Language   string   `yaml:"language,omitempty"`
+		Doctype    string   `yaml:"doctype,omitempty"`
+		Standards  []string `yaml:"standards,omitempty"`
+		Components []string `yaml:"components,omitempty"`
+		Software   []string `yaml:"software,omitempty"`
+	} `yaml:"site,omitempty"`
+}
+
+// Validate checks that every team member has a role and a name
+func (h *HumansTxt) Validate() error {
+	if len(h.Team) == 0 {
+		return fmt.Errorf("humans.txt requires at least one team member")
+	}
+	for i, p := range h.Team {
+		if p.Role == "" || p.Name == "" {
+			return fmt.Errorf("humans.txt team member %d requires role and name", i+1)
+		}
+	}
+	return nil
+}
+
+// Render generates the file
+func (h *HumansTxt) Render() *File {
+	var b strings.Builder
+	b.WriteString("/* TEAM */\n")
+	for i, p := range h.Team {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s: %s\n", p.Role, p.Name)
+		for _, f := range [][2]string{{"Contact", p.Contact}, {"Twitter", p.Twitter}, {"From", p.Location}} {
+			if f[1] != "" {
+				fmt.Fprintf(&b, "%s: %s\n", f[0], f[1])
+			}
+		}
+	}
+	if len(h.Thanks) > 0 {
+		b.WriteString("\n/* THANKS */\n")
+		for _, name := range h.Thanks {
+			b.WriteString(name + "\n")
+		}
+	}
+	site := [][2]string{
+		{"Last update", h.Site.LastUpdate},
+		{"Language", h.Site.Language},
+		{"Doctype", h.Site.Doctype},
+		{"Standards", strings.Join(h.Site.Standards, ", ")},
+		{"Components", strings.Join(h.Site.Components, ", ")},
+		{"Software", strings.Join(h.Site.Software, ", ")},
+	}
+	header := false
+	for _, f := range site {
+		if f[1] == "" {
+			continue
+		}
+		if !header {
+			b.WriteString("\n/* SITE */\n")
+			header = true
+		}
+		fmt.Fprintf(&b, "%s: %s\n", f[0], f[1])
+	}
+	return &File{ContentType: "text/plain; charset=utf-8", Body: []byte(b.String())}
+}
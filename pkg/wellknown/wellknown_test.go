@@ -0,0 +1,123 @@
+package wellknown
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecurityTxt_Validate(t *testing.T) {
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		s       SecurityTxt
+		wantErr bool
+	}{
+		{"Valid", SecurityTxt{Contact: []string{"mailto:security@example.com"}, Expires: expires}, false},
+		{"No contact", SecurityTxt{Expires: expires}, true},
+		{"No expiry", SecurityTxt{Contact: []string{"mailto:security@example.com"}}, true},
+		{"Bare email", SecurityTxt{Contact: []string{"security@example.com"}, Expires: expires}, true},
+		{"Plain http policy", SecurityTxt{
+			Contact: []string{"https://example.com/report"},
+			Expires: expires,
+			Policy:  []string{"http://example.com/policy"},
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.s.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSecurityTxt_Warnings(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		expires time.Time
+		want    string
+	}{
+		{"Fine", now.AddDate(0, 6, 0), ""},
+		{"Expired", now.AddDate(0, 0, -1), "expired"},
+		{"Soon", now.AddDate(0, 0, 10), "expires soon"},
+		{"Too far", now.AddDate(2, 0, 0), "more than a year"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := SecurityTxt{Contact: []string{"mailto:a@example.com"}, Expires: tt.expires}
+			warnings := s.Warnings(now)
+			if tt.want == "" {
+				if len(warnings) != 0 {
+					t.Errorf("Warnings() = %v, want none", warnings)
+				}
+				return
+			}
+			if len(warnings) != 1 || !strings.Contains(warnings[0], tt.want) {
+				t.Errorf("Warnings() = %v, want %q", warnings, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecurityTxt_Render(t *testing.T) {
+	s := SecurityTxt{
+		Contact:            []string{"mailto:security@example.com", "https://example.com/report"},
+		Expires:            time.Date(2030, 1, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600)),
+		PreferredLanguages: []string{"en", "de"},
+	}
+	want := "Contact: mailto:security@example.com\n" +
+		"Contact: https://example.com/report\n" +
+		"Expires: 2030-01-01T11:00:00Z\n" +
+		"Preferred-Languages: en, de\n"
+	if got := string(s.Render().Body); got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestHumansTxt(t *testing.T) {
+	h := HumansTxt{
+		Team:   []Person{{Role: "Developer", Name: "Ada", Location: "London"}},
+		Thanks: []string{"Everyone"},
+	}
+	h.Site.Standards = []string{"HTML5", "CSS3"}
+	if err := h.Validate(); err != nil {
+		t.Fatalf("Validate() error: %v", err)
+	}
+	want := "/* TEAM */\nDeveloper: Ada\nFrom: London\n\n/* THANKS */\nEveryone\n\n/* SITE */\nStandards: HTML5, CSS3\n"
+	if got := string(h.Render().Body); got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+
+	if err := (&HumansTxt{Team: []Person{{Name: "Ada"}}}).Validate(); err == nil {
+		t.Error("Validate() without role should fail")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	doc := map[string]any{"webcredentials": map[string]any{"apps": []any{"ABCDE12345.com.example.app"}}}
+	f, err := JSON("apple-app-site-association", doc)
+	if err != nil {
+		t.Fatalf("JSON() error: %v", err)
+	}
+	if f.ContentType != "application/json" {
+		t.Errorf("ContentType = %q, want application/json", f.ContentType)
+	}
+	want := "{\n  \"webcredentials\": {\n    \"apps\": [\n      \"ABCDE12345.com.example.app\"\n    ]\n  }\n}\n"
+	if string(f.Body) != want {
+		t.Errorf("Body = %q, want %q", f.Body, want)
+	}
+
+	for _, name := range []string{"", "../etc", "a/b", "security.txt"} {
+		if _, err := JSON(name, doc); err == nil {
+			t.Errorf("JSON(%q) should fail", name)
+		}
+	}
+	if _, err := JSON("bad.json", map[string]any{"f": func() {}}); err == nil {
+		t.Error("JSON() with unencodable value should fail")
+	}
+}
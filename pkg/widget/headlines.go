@@ -0,0 +1,39 @@
+package widget
+
+import (
+	"fmt"
+	"strconv"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/feed"
+)
+
+// headlines adapts an RSS or Atom feed into its latest entries
+type headlines struct{}
+
+func init() {
+	Register("rss", headlines{})
+}
+
+func (headlines) Validate(options map[string]string) error {
+	if options["url"] == "" {
+		return fmt.Errorf("rss requires the url option")
+	}
+	if n := options["limit"]; n != "" {
+		if _, err := strconv.Atoi(n); err != nil {
+			return fmt.Errorf("rss: invalid limit '%s'", n)
+		}
+	}
+	return nil
+}
+
+func (headlines) Load(options map[string]string, get Getter) (any, error) {
+	limit := 5
+	if n, err := strconv.Atoi(options["limit"]); err == nil {
+		limit = n
+	}
+	body, err := get(options["url"])
+	if err != nil {
+		return nil, fmt.Errorf("fetching feed: %w", err)
+	}
+	return feed.Parse(body, limit)
+}
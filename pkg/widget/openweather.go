@@ -0,0 +1,102 @@
+package widget
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/debug"
+)
+
+// Weather is the normalized current-conditions report
+type Weather struct {
+	Location    string
+	Description string
+	Icon        string
+	IconURL     string
+	Temperature float64
+	FeelsLike   float64
+	Humidity    int
+	WindSpeed   float64
+	Units       string
+}
+
+// openWeather adapts the OpenWeather current weather API
+type openWeather struct{}
+
+func init() {
+	Register("openweather", openWeather{})
+}
+
+func (openWeather) Validate(options map[string]string) error {
+	if options["location"] == "" {
+		return fmt.Errorf("openweather requires the location option")
+	}
+	if options["api_key"] == "" && options["api_key_env"] == "" {
+		return fmt.Errorf("openweather requires api_key or api_key_env")
+	}
+	switch options["units"] {
+	case "", "metric", "imperial", "standard":
+	default:
+		return fmt.Errorf("openweather: unknown units '%s'", options["units"])
+	}
+	return nil
+}
+
+func (openWeather) Load(options map[string]string, get Getter) (any, error) {
+	apiKey := options["api_key"]
+	if env := options["api_key_env"]; env != "" {
+		apiKey = os.Getenv(env)
+	}
+	debug.AddSecret(apiKey)
+	units := options["units"]
+	if units == "" {
+		units = "metric"
+	}
+	base := options["url"]
+	if base == "" {
+		base = "https://api.openweathermap.org/data/2.5/weather"
+	}
+	q := url.Values{}
+	q.Set("q", options["location"])
+	q.Set("units", units)
+	q.Set("appid", apiKey)
+
+	body, err := get(base + "?" + q.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("fetching weather: %w", err)
+	}
+	var resp struct {
+		Name    string `json:"name"`
+		Weather []struct {
+			Description string `json:"description"`
+			Icon        string `json:"icon"`
+		} `json:"weather"`
+		Main struct {
+			Temp      float64 `json:"temp"`
+			FeelsLike float64 `json:"feels_like"`
+			Humidity  int     `json:"humidity"`
+		} `json:"main"`
+		Wind struct {
+			Speed float64 `json:"speed"`
+		} `json:"wind"`
+	}
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decoding weather: %w", err)
+	}
+	w := &Weather{
+		Location:    resp.Name,
+		Temperature: resp.Main.Temp,
+		FeelsLike:   resp.Main.FeelsLike,
+		Humidity:    resp.Main.Humidity,
+		WindSpeed:   resp.Wind.Speed,
+		Units:       units,
+	}
+	if len(resp.Weather) > 0 {
+		w.Description = resp.Weather[0].Description
+		w.Icon = resp.Weather[0].Icon
+		w.IconURL = "https://openweathermap.org/img/wn/" + w.Icon + "@2x.png"
+	}
+	return w, nil
+}
@@ -0,0 +1,115 @@
+// Package widget adapts third-party APIs into tidy, template-ready
+// structures. Fetches are cached and failures degrade to an error result
+// rather than breaking the page.
+package widget
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"time"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/fetch"
+)
+
+// DefaultCacheTTL is how long a widget's upstream response is reused
+const DefaultCacheTTL = 10 * time.Minute
+
+// Adapter fetches and normalizes one kind of third-party data
+type Adapter interface {
+	// Validate checks the widget's options
+	Validate(options map[string]string) error
+	// Load fetches the data using get and returns the normalized result
+	Load(options map[string]string, get Getter) (any, error)
+}
+
+// Getter fetches a URL through the widget cache
+type Getter func(url string) ([]byte, error)
+
+// adapters holds the registered adapter types
+var adapters = map[string]Adapter{}
+
+// Register makes an adapter available under the given type name
+func Register(typ string, a Adapter) {
+	adapters[typ] = a
+}
+
+// Types returns the registered adapter type names
+func Types() []string {
+	types := make([]string, 0, len(adapters))
+	for t := range adapters {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Widget configures a named widget instance
+type Widget struct {
+	Name     string            `yaml:"name"`
+	Type     string            `yaml:"type"`
+	Options  map[string]string `yaml:"options,omitempty"`
+	CacheTTL time.Duration     `yaml:"cache_ttl,omitempty"`
+	CacheDir string            `yaml:"cache_dir,omitempty"`
+}
+
+// Result is what templates receive. When OK is false, Error describes the
+// problem and Data is nil, so templates can render a fallback.
+type Result struct {
+	OK    bool
+	Error string
+	Data  any
+}
+
+// Validate checks the widget configuration
+func (w *Widget) Validate() error {
+	a, ok := adapters[w.Type]
+	if !ok {
+		return fmt.Errorf("widget %s: unknown type '%s'", w.Name, w.Type)
+	}
+	if err := a.Validate(w.Options); err != nil {
+		return fmt.Errorf("widget %s: %w", w.Name, err)
+	}
+	return nil
+}
+
+// Load fetches the widget's data. Errors are logged and reported in the
+// result rather than returned.
+func (w *Widget) Load() *Result {
+	a, ok := adapters[w.Type]
+	if !ok {
+		return &Result{Error: fmt.Sprintf("unknown widget type '%s'", w.Type)}
+	}
+	ttl := w.CacheTTL
+	if ttl == 0 {
+		ttl = DefaultCacheTTL
+	}
+	get := func(url string) ([]byte, error) {
+		body, err := fetch.Cached(url, fetch.Options{CacheDir: w.CacheDir, TTL: ttl})
+		return body, withoutQuery(err)
+	}
+	data, err := a.Load(w.Options, get)
+	if err != nil {
+		log.Printf("widget %s: %v", w.Name, err)
+		return &Result{Error: err.Error()}
+	}
+	return &Result{OK: true, Data: data}
+}
+
+// withoutQuery removes the query string from the URL in a request error,
+// since adapters such as openweather put API keys there and errors are
+// logged and shown to templates
+func withoutQuery(err error) error {
+	var ue *url.Error
+	if !errors.As(err, &ue) {
+		return err
+	}
+	u, perr := url.Parse(ue.URL)
+	if perr != nil {
+		return fmt.Errorf("%s request: %w", ue.Op, ue.Err)
+	}
+	u.RawQuery, u.User = "", nil
+	return fmt.Errorf("%s %s: %w", ue.Op, u, ue.Err)
+}
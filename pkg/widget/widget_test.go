@@ -0,0 +1,96 @@
+package widget
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.mhn.org/tmpl.cgi/pkg/debug"
+	"gopkg.mhn.org/tmpl.cgi/pkg/feed"
+)
+
+func TestOpenWeather(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") != "Berlin,DE" || r.URL.Query().Get("appid") != "key" || r.URL.Query().Get("units") != "imperial" {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"name":"Berlin","weather":[{"description":"light rain","icon":"10d"}],
+			"main":{"temp":61.5,"feels_like":60.1,"humidity":82},"wind":{"speed":9.2}}`))
+	}))
+	defer ts.Close()
+
+	w := &Widget{Name: "weather", Type: "openweather", CacheDir: filepath.Join(t.TempDir(), "cache"), Options: map[string]string{
+		"location": "Berlin,DE", "api_key": "key", "units": "imperial", "url": ts.URL,
+	}}
+	if err := w.Validate(); err != nil {
+		t.Fatalf("Validate() error: %v", err)
+	}
+	res := w.Load()
+	if !res.OK {
+		t.Fatalf("Load() = %+v", res)
+	}
+	weather := res.Data.(*Weather)
+	if weather.Location != "Berlin" || weather.Temperature != 61.5 || weather.Description != "light rain" {
+		t.Errorf("Weather = %+v", weather)
+	}
+	if weather.IconURL != "https://openweathermap.org/img/wn/10d@2x.png" {
+		t.Errorf("IconURL = %s", weather.IconURL)
+	}
+}
+
+func TestHeadlines(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<rss><channel><title>News</title>
+			<item><title>One</title></item><item><title>Two</title></item><item><title>Three</title></item>
+			</channel></rss>`))
+	}))
+	defer ts.Close()
+
+	w := &Widget{Name: "news", Type: "rss", CacheDir: filepath.Join(t.TempDir(), "cache"), Options: map[string]string{"url": ts.URL, "limit": "2"}}
+	res := w.Load()
+	if !res.OK {
+		t.Fatalf("Load() = %+v", res)
+	}
+	if n := len(res.Data.(*feed.Feed).Entries); n != 2 {
+		t.Errorf("entries = %d, want 2", n)
+	}
+}
+
+func TestWidget_Degrades(t *testing.T) {
+	w := &Widget{Name: "news", Type: "rss", CacheDir: filepath.Join(t.TempDir(), "cache"), Options: map[string]string{"url": "http://127.0.0.1:1/feed"}}
+	res := w.Load()
+	if res.OK || res.Error == "" || res.Data != nil {
+		t.Errorf("Load() with unreachable upstream = %+v, want error result", res)
+	}
+}
+
+func TestWidget_ErrorHidesAPIKey(t *testing.T) {
+	w := &Widget{Name: "weather", Type: "openweather", CacheDir: filepath.Join(t.TempDir(), "cache"), Options: map[string]string{
+		"location": "Berlin,DE", "api_key": "0p3nw3ath3r-k3y", "url": "http://127.0.0.1:1/weather",
+	}}
+	res := w.Load()
+	if res.OK || !strings.Contains(res.Error, "127.0.0.1:1/weather") || strings.Contains(res.Error, "0p3nw3ath3r") {
+		t.Errorf("Load() error = %q, want the URL without its API key", res.Error)
+	}
+	if got := debug.Redact("key 0p3nw3ath3r-k3y"); strings.Contains(got, "0p3nw3ath3r") {
+		t.Errorf("Redact() = %q, want the API key redacted", got)
+	}
+}
+
+func TestWidget_Validate(t *testing.T) {
+	tests := []Widget{
+		{Name: "x", Type: "stock-ticker"},
+		{Name: "x", Type: "openweather", Options: map[string]string{"api_key": "k"}},
+		{Name: "x", Type: "openweather", Options: map[string]string{"location": "Paris"}},
+		{Name: "x", Type: "rss"},
+		{Name: "x", Type: "rss", Options: map[string]string{"url": "http://x", "limit": "ten"}},
+	}
+	for _, w := range tests {
+		if err := w.Validate(); err == nil {
+			t.Errorf("Validate(%+v) expected error", w)
+		}
+	}
+}